@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	apimodels "github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/reconcile"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// runFsck checks the database against the git branches and optionally repairs drift.
+// It returns the exit code: 0 when everything is consistent or repaired, 1 when issues
+// remain and 2 on usage or runtime errors.
+func runFsck(ctx context.Context, repo repository.Repository, gitService git.GitService, args []string) int {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	repair := flags.String("repair", "", "Repair drift, treating this store as the truth (database/git)")
+	asJSON := flags.Bool("json", false, "Print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	checker := reconcile.New(repo, gitService)
+
+	var report *reconcile.Report
+	var err error
+	if *repair != "" {
+		source := reconcile.Source(*repair)
+		if !source.Valid() {
+			fmt.Fprintf(os.Stderr, "invalid -repair value %q: must be 'database' or 'git'\n", *repair)
+			return 2
+		}
+		report, err = checker.Repair(ctx, source)
+	} else {
+		report, err = checker.Check(ctx)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fsck failed: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(apimodels.FromFsckReport(report))
+	} else {
+		printFsckReport(os.Stdout, report, *repair != "")
+	}
+
+	if !report.Clean() {
+		return 1
+	}
+	return 0
+}
+
+// printFsckReport writes a human readable report, one line per issue
+func printFsckReport(w io.Writer, report *reconcile.Report, repaired bool) {
+	fmt.Fprintf(w, "Checked %d prompts, %d snippets and %d branches\n", report.Prompts, report.Snippets, report.Branches)
+	if len(report.Issues) == 0 {
+		fmt.Fprintln(w, "No issues found")
+		return
+	}
+
+	for _, issue := range report.Issues {
+		line := fmt.Sprintf("%-8s %s  %-16s %q", issue.Kind, issue.ID, issue.Problem, issue.Title)
+		if len(issue.Fields) > 0 {
+			line += " (" + strings.Join(issue.Fields, ", ") + ")"
+		}
+		if repaired {
+			if issue.Repaired {
+				line += "  repaired"
+			} else {
+				line += "  not repaired: " + issue.Error
+			}
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -35,18 +35,30 @@
 // @tag.name prompt-tags
 // @tag.description Manage tags for prompts
 //
+// @tag.name prompt-versions
+// @tag.description Browse the git version history of prompts
+//
 // @tag.name snippets
 // @tag.description Operations on code snippets and text blocks
 //
 // @tag.name snippet-tags
 // @tag.description Manage tags for snippets
 //
+// @tag.name snippet-versions
+// @tag.description Browse the git version history of snippets
+//
 // @tag.name notes
 // @tag.description Manage notes associated with prompts
 //
+// @tag.name search
+// @tag.description Full-text search across prompts, snippets and notes
+//
 // @tag.name templates
 // @tag.description Template analysis and preview operations
 //
+// @tag.name admin
+// @tag.description Maintenance operations such as database and git consistency checks
+//
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
@@ -56,6 +68,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -85,6 +98,35 @@ func determineEnvironment(cliEnv string) string {
 	return "dev" // default
 }
 
+// usage prints the command line help
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command [command flags]]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Without a command the API server is started.")
+	fmt.Fprintln(out, "\nCommands:")
+	fmt.Fprintln(out, "  fsck        Check the database against the git branches (-repair database|git, -json)")
+	fmt.Fprintln(out, "  rebuild-db  Fill an empty database from the git repository")
+	fmt.Fprintln(out, "  sync        Pull from and push to a configured git remote (-remote name, -json)")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// runCommand runs a maintenance command and returns the process exit code
+func runCommand(command string, args []string, repo repository.Repository, gitService git.GitService) int {
+	switch command {
+	case "fsck":
+		return runFsck(context.Background(), repo, gitService, args)
+	case "rebuild-db":
+		return runRebuildDB(context.Background(), repo, gitService, args)
+	case "sync":
+		return runSync(context.Background(), repo, gitService, args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", command)
+		flag.Usage()
+		return 2
+	}
+}
+
 func main() {
 	// Set up basic logger for startup (will be reconfigured after config load)
 	logging.SetDefault("proompt")
@@ -94,6 +136,7 @@ func main() {
 	environment := flag.String("env", "", "Environment (dev/prod), overrides PROOMPT_ENV")
 	silent := flag.Bool("s", false, "Silent mode - disable stdout logging")
 	logLevel := flag.String("l", "", "Log level (debug/info/warn/error), overrides config")
+	flag.Usage = usage
 	flag.Parse()
 
 	// Determine environment
@@ -151,12 +194,24 @@ func main() {
 			os.Exit(1)
 		}
 
+		// Run migrations for Turso database
+		if err := database.RunMigrations(cfg.Database.Turso.Migrations); err != nil {
+			slog.Error("Failed to run migrations", "error", err)
+			os.Exit(1)
+		}
+
 	default:
 		slog.Error("Unknown database type", "type", cfg.DatabaseType())
 		os.Exit(1)
 	}
 	defer database.Close()
 
+	// Index variables of rows written before the has_variables column existed
+	if err := repository.IndexVariables(context.Background(), database); err != nil {
+		slog.Error("Failed to index variables", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize git service
 	gitService, err := git.NewGitService(cfg)
 	if err != nil {
@@ -168,8 +223,15 @@ func main() {
 	repo := repository.New(database, gitService)
 	defer repo.Close()
 
+	// Run a maintenance command instead of the server when one is given
+	if command := flag.Arg(0); command != "" {
+		code := runCommand(command, flag.Args()[1:], repo, gitService)
+		repo.Close()
+		os.Exit(code)
+	}
+
 	// Create API server
-	server := api.New(cfg, repo, slog.Default())
+	server := api.New(cfg, repo, gitService, slog.Default())
 
 	// Set up graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
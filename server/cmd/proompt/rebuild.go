@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/reconcile"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// runRebuildDB fills the configured database, which must be empty, from the git
+// repository. Migrations have already run by the time it is called. It returns the exit code.
+func runRebuildDB(ctx context.Context, repo repository.Repository, gitService git.GitService, args []string) int {
+	flags := flag.NewFlagSet("rebuild-db", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	result, err := reconcile.New(repo, gitService).Rebuild(ctx)
+	if errors.Is(err, reconcile.ErrDatabaseNotEmpty) {
+		fmt.Fprintf(os.Stderr, "refusing to rebuild: %v; point the configuration at a new database file\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rebuild failed: %v\n", err)
+		return 2
+	}
+
+	fmt.Printf("Restored %d prompts, %d snippets, %d tags, %d notes and %d links from git\n",
+		result.Prompts, result.Snippets, result.Tags, result.Notes, result.Links)
+	return 0
+}
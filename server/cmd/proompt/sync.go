@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	apimodels "github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/reconcile"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// runSync syncs the git repository with a configured remote. It returns the exit code:
+// 0 when everything was synced, 1 when branches are left in conflict and 2 on errors.
+func runSync(ctx context.Context, repo repository.Repository, gitService git.GitService, args []string) int {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	remote := flags.String("remote", "", "Name of the configured remote (default: the first one)")
+	asJSON := flags.Bool("json", false, "Print the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	result, err := reconcile.New(repo, gitService).Sync(ctx, *remote)
+	if errors.Is(err, git.ErrUnknownRemote) {
+		fmt.Fprintf(os.Stderr, "sync failed: %v; add a <remote> to the storage configuration\n", err)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sync failed: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(apimodels.FromSyncResult(result))
+	} else {
+		printSyncResult(os.Stdout, result)
+	}
+
+	if len(result.Conflicts) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printSyncResult writes a summary line and one line per changed or conflicting branch
+func printSyncResult(w io.Writer, result *reconcile.SyncResult) {
+	fmt.Fprintf(w, "Synced with %s: %d pulled, %d pushed, %d deleted, %d conflicts, %d up to date\n",
+		result.Remote, len(result.Pulled), len(result.Pushed), len(result.Deleted), len(result.Conflicts), result.UpToDate)
+
+	for _, branch := range result.Pulled {
+		fmt.Fprintf(w, "  pulled    %s\n", branch)
+	}
+	for _, branch := range result.Pushed {
+		fmt.Fprintf(w, "  pushed    %s\n", branch)
+	}
+	for _, branch := range result.Deleted {
+		fmt.Fprintf(w, "  deleted   %s\n", branch)
+	}
+	for _, conflict := range result.Conflicts {
+		fmt.Fprintf(w, "  conflict  %s (local %s, remote %s)\n", conflict.Branch, shortHash(conflict.Local), shortHash(conflict.Remote))
+	}
+}
+
+// shortHash abbreviates a commit hash for display
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/logging"
+	"github.com/dikkadev/proompt/server/internal/reconcile"
+)
+
+// AdminHandlers contains handlers for maintenance operations
+type AdminHandlers struct {
+	checker *reconcile.Checker
+	logger  *slog.Logger
+}
+
+// NewAdminHandlers creates a new admin handlers instance
+func NewAdminHandlers(checker *reconcile.Checker) *AdminHandlers {
+	return &AdminHandlers{
+		checker: checker,
+		logger:  logging.NewLogger("handlers.admin"),
+	}
+}
+
+// Fsck godoc
+// @Summary Check database and git consistency
+// @Description Compare every prompt and snippet row with its git branch and report rows without a branch, branches without a row, and rows whose content differs from the branch tip
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.FsckReportResponse "Consistency report"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/fsck [get]
+func (h *AdminHandlers) Fsck(w http.ResponseWriter, r *http.Request) {
+	report, err := h.checker.Check(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to check consistency", "error", err)
+		models.WriteInternalError(w, "Failed to check consistency")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.FromFsckReport(report))
+}
+
+// RepairFsck godoc
+// @Summary Repair database and git drift
+// @Description Check consistency and repair every issue found. With source=database branches are rebuilt from database rows; with source=git rows are rebuilt from branch tips. Rows without a branch always get one created.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param source query string true "Store to treat as the truth" Enums(database, git)
+// @Success 200 {object} models.FsckReportResponse "Report with the outcome of each repair"
+// @Failure 400 {object} models.ErrorResponse "Invalid source"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /admin/fsck/repair [post]
+func (h *AdminHandlers) RepairFsck(w http.ResponseWriter, r *http.Request) {
+	source := reconcile.Source(r.URL.Query().Get("source"))
+	if !source.Valid() {
+		models.WriteBadRequest(w, "source must be 'database' or 'git'")
+		return
+	}
+
+	report, err := h.checker.Repair(r.Context(), source)
+	if err != nil {
+		h.logger.Error("Failed to repair drift", "source", source, "error", err)
+		models.WriteInternalError(w, "Failed to repair drift")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.FromFsckReport(report))
+}
+
+// SyncGit godoc
+// @Summary Sync the versioning repository with a git remote
+// @Description Fetch the prompt and snippet branches of a configured remote, fast-forward and import branches changed there, and push local changes. Branches changed on both sides are reported as conflicts and left alone.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param remote query string false "Name of the configured remote, defaults to the first one"
+// @Success 200 {object} models.SyncResponse "What was pulled, pushed and left in conflict"
+// @Failure 400 {object} models.ErrorResponse "Unknown remote"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /git/sync [post]
+func (h *AdminHandlers) SyncGit(w http.ResponseWriter, r *http.Request) {
+	remote := r.URL.Query().Get("remote")
+
+	result, err := h.checker.Sync(r.Context(), remote)
+	if errors.Is(err, git.ErrUnknownRemote) {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to sync with remote", "remote", remote, "error", err)
+		models.WriteInternalError(w, "Failed to sync with remote")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.FromSyncResult(result))
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dikkadev/proompt/server/internal/reconcile"
+)
+
+func TestRepairFsckInvalidSource(t *testing.T) {
+	handlers := NewAdminHandlers(nil)
+
+	for _, target := range []string{"/api/admin/fsck/repair", "/api/admin/fsck/repair?source=both"} {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		w := httptest.NewRecorder()
+
+		handlers.RepairFsck(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", target, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestSyncGitUnknownRemote(t *testing.T) {
+	handlers := NewAdminHandlers(reconcile.New(nil, &mockGitService{}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/git/sync?remote=upstream", nil)
+	w := httptest.NewRecorder()
+
+	handlers.SyncGit(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
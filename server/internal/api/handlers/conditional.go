@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
+)
+
+// Labels of the two sides in the conflict markers of a content merge
+const (
+	mergeLabelYours   = "yours"
+	mergeLabelCurrent = "current"
+)
+
+// etag returns the entity tag of a prompt or snippet, which is derived from its updated_at
+func etag(updatedAt time.Time) string {
+	return fmt.Sprintf(`"%d"`, updatedAt.UnixNano())
+}
+
+// setETag sets the ETag header that clients send back in If-Match to make an update conditional
+func setETag(w http.ResponseWriter, updatedAt time.Time) {
+	w.Header().Set("ETag", etag(updatedAt))
+}
+
+// entityTagRegex matches one entity tag of an If-Match list and the comma after it
+var entityTagRegex = regexp.MustCompile(`^\s*((?:W/)?"[^"]*")\s*(?:,|$)`)
+
+// parseIfMatch reads the entity tags listed in the If-Match header. It returns nil when the
+// header is absent or "*"; updates only apply to existing resources, which "*" always
+// matches, so the update is unconditional. Only a header that is not a list of entity tags
+// is an error.
+func parseIfMatch(r *http.Request) ([]string, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+
+	var tags []string
+	for rest := value; rest != ""; {
+		match := entityTagRegex.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, fmt.Errorf("invalid If-Match header '%s', must be \"*\" or a list of ETags", value)
+		}
+		tags = append(tags, match[1])
+		rest = rest[len(match[0]):]
+	}
+	return tags, nil
+}
+
+// ifMatchVersion returns the updated_at an update must still find for the If-Match tags to
+// match, or nil when the update is unconditional. If-Match compares strongly, so weak tags
+// never match; neither do tags not made by this server. When no tag matches current, the
+// version of the first tag of this server is returned, or the zero time if there is none,
+// so the update is rejected as a conflict.
+func ifMatchVersion(tags []string, current time.Time) *time.Time {
+	if tags == nil {
+		return nil
+	}
+
+	var expected time.Time
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+		if err != nil {
+			continue
+		}
+		version := time.Unix(0, nanos)
+		if version.Equal(current) {
+			return &version
+		}
+		if expected.IsZero() {
+			expected = version
+		}
+	}
+	return &expected
+}
+
+// mergeContent merges the content of a rejected update with the content stored since,
+// using the version the client edited as base
+func mergeContent(baseHash, base, yours, current string) *models.ContentMergeResponse {
+	return models.FromContentMerge(baseHash, git.MergeContent(base, yours, current, mergeLabelYours, mergeLabelCurrent))
+}
+
+// conflictError returns the error part of a 409 response for a resource changed since it was read
+func conflictError(resource string) models.ErrorResponse {
+	return models.ErrorResponse{
+		Error:   http.StatusText(http.StatusConflict),
+		Message: resource + " was modified since it was read",
+		Code:    http.StatusConflict,
+	}
+}
+
+// writeConflict writes a 409 response along with the ETag of the current version
+func writeConflict(w http.ResponseWriter, updatedAt time.Time, response any) {
+	setETag(w, updatedAt)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(response)
+}
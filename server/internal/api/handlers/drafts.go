@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/logging"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// DraftHandlers contains handlers for drafts, experimental variants of a prompt kept on
+// their own git branches until they are promoted into the prompt
+type DraftHandlers struct {
+	repo       repository.Repository
+	gitService git.GitService
+	logger     *slog.Logger
+}
+
+// NewDraftHandlers creates a new draft handlers instance
+func NewDraftHandlers(repo repository.Repository, gitService git.GitService) *DraftHandlers {
+	return &DraftHandlers{
+		repo:       repo,
+		gitService: gitService,
+		logger:     logging.NewLogger("handlers.drafts"),
+	}
+}
+
+// ListPromptDrafts godoc
+// @Summary List drafts of a prompt
+// @Description List the drafts of a prompt, sorted by name
+// @Tags prompt-drafts
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Success 200 {object} models.DraftListResponse "List of drafts"
+// @Failure 404 {object} models.ErrorResponse "Prompt not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/drafts [get]
+func (h *DraftHandlers) ListPromptDrafts(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.repo.Prompts().GetByID(r.Context(), id); err != nil {
+		models.WriteNotFound(w, "Prompt")
+		return
+	}
+
+	drafts, err := h.gitService.ListPromptDrafts(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to list prompt drafts", "prompt_id", id, "error", err)
+		models.WriteInternalError(w, "Failed to list drafts")
+		return
+	}
+
+	responses := models.FromPromptDrafts(drafts)
+	json.NewEncoder(w).Encode(models.ListResponse[*models.DraftResponse]{
+		Data:       responses,
+		Total:      len(responses),
+		Page:       1,
+		PageSize:   len(responses),
+		TotalPages: 1,
+	})
+}
+
+// CreatePromptDraft godoc
+// @Summary Create a draft of a prompt
+// @Description Fork a draft from the current version of a prompt. The draft is stored on its own git branch and does not change the prompt until it is promoted.
+// @Tags prompt-drafts
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param draft body models.CreateDraftRequest true "Draft to create"
+// @Success 201 {object} models.DraftResponse "Created draft"
+// @Failure 400 {object} models.ErrorResponse "Invalid request data or draft name"
+// @Failure 404 {object} models.ErrorResponse "Prompt not found"
+// @Failure 409 {object} models.ErrorResponse "Draft already exists"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/drafts [post]
+func (h *DraftHandlers) CreatePromptDraft(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var req models.CreateDraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.WriteBadRequest(w, "Invalid JSON body")
+		return
+	}
+	if req.Name == "" {
+		models.WriteValidationError(w, map[string]string{"name": "Name is required"})
+		return
+	}
+
+	if _, err := h.repo.Prompts().GetByID(r.Context(), id); err != nil {
+		models.WriteNotFound(w, "Prompt")
+		return
+	}
+
+	draft, err := h.gitService.CreatePromptDraft(r.Context(), id, req.Name)
+	if err != nil {
+		h.writeDraftError(w, err, id, req.Name, "Failed to create draft")
+		return
+	}
+
+	h.logger.Info("Prompt draft created", "prompt_id", id, "draft", draft.Name, "base", draft.Base)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.FromPromptDraft(draft))
+}
+
+// GetPromptDraft godoc
+// @Summary Get a draft of a prompt
+// @Description Get a draft with the prompt as it is on the draft
+// @Tags prompt-drafts
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param name path string true "Draft name"
+// @Success 200 {object} models.DraftResponse "Draft"
+// @Failure 404 {object} models.ErrorResponse "Draft not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/drafts/{name} [get]
+func (h *DraftHandlers) GetPromptDraft(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	name := r.PathValue("name")
+
+	draft, err := h.gitService.GetPromptDraft(r.Context(), id, name)
+	if err != nil {
+		h.writeDraftError(w, err, id, name, "Failed to get draft")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.FromPromptDraft(draft))
+}
+
+// UpdatePromptDraft godoc
+// @Summary Update a draft of a prompt
+// @Description Record a new version of the prompt on a draft. Only fields versioned in git can be changed; the prompt itself is left untouched.
+// @Tags prompt-drafts
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param name path string true "Draft name"
+// @Param draft body models.UpdateDraftRequest true "Fields to change"
+// @Success 200 {object} models.DraftResponse "Updated draft"
+// @Failure 400 {object} models.ErrorResponse "Invalid request data"
+// @Failure 404 {object} models.ErrorResponse "Draft not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/drafts/{name} [put]
+func (h *DraftHandlers) UpdatePromptDraft(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	name := r.PathValue("name")
+
+	draft, err := h.gitService.GetPromptDraft(r.Context(), id, name)
+	if err != nil {
+		h.writeDraftError(w, err, id, name, "Failed to update draft")
+		return
+	}
+
+	var req models.UpdateDraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.WriteBadRequest(w, "Invalid JSON body")
+		return
+	}
+
+	updatedFields := req.ApplyTo(draft.Prompt)
+	draft.Prompt.UpdatedAt = time.Now()
+	h.logger.Debug("Applying updates to draft", "prompt_id", id, "draft", name, "updated_fields", updatedFields)
+
+	if err := h.gitService.UpdatePromptDraft(r.Context(), draft.Prompt, name, req.Message); err != nil {
+		h.writeDraftError(w, err, id, name, "Failed to update draft")
+		return
+	}
+
+	updated, err := h.gitService.GetPromptDraft(r.Context(), id, name)
+	if err != nil {
+		h.writeDraftError(w, err, id, name, "Failed to get draft")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.FromPromptDraft(updated))
+}
+
+// DeletePromptDraft godoc
+// @Summary Delete a draft of a prompt
+// @Description Delete a draft's branch. Commits already promoted stay in the prompt's history.
+// @Tags prompt-drafts
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param name path string true "Draft name"
+// @Success 204 "Draft deleted"
+// @Failure 404 {object} models.ErrorResponse "Draft not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/drafts/{name} [delete]
+func (h *DraftHandlers) DeletePromptDraft(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	name := r.PathValue("name")
+
+	if err := h.gitService.DeletePromptDraft(r.Context(), id, name); err != nil {
+		h.writeDraftError(w, err, id, name, "Failed to delete draft")
+		return
+	}
+
+	h.logger.Info("Prompt draft deleted", "prompt_id", id, "draft", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PreviewPromptDraft godoc
+// @Summary Preview a draft of a prompt
+// @Description Resolve the content of a draft with snippets and the given variables, like the template preview
+// @Tags prompt-drafts
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param name path string true "Draft name"
+// @Param request body models.DraftPreviewRequest false "Template variables"
+// @Success 200 {object} models.TemplatePreviewResponse "Template preview result"
+// @Failure 400 {object} models.ErrorResponse "Invalid request data, or variable values that do not fit their types"
+// @Failure 404 {object} models.ErrorResponse "Draft not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/drafts/{name}/preview [post]
+func (h *DraftHandlers) PreviewPromptDraft(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	name := r.PathValue("name")
+
+	var req models.DraftPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		models.WriteBadRequest(w, "Invalid JSON body")
+		return
+	}
+
+	draft, err := h.gitService.GetPromptDraft(r.Context(), id, name)
+	if err != nil {
+		h.writeDraftError(w, err, id, name, "Failed to get draft")
+		return
+	}
+
+	snippets, err := h.repo.Snippets().List(r.Context(), repository.SnippetFilters{})
+	if err != nil {
+		models.WriteInternalError(w, "Failed to fetch snippets")
+		return
+	}
+
+	response, errors := previewTemplate(snippets, draft.Prompt.Content, req.Variables)
+	if len(errors) > 0 {
+		models.WriteValidationError(w, errors)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// PromotePromptDraft godoc
+// @Summary Promote a draft into its prompt
+// @Description Merge the changes of a draft into its prompt, record a merge commit with the given message and delete the draft. Fields the draft changed take the draft's value; content changed on both sides is merged line by line.
+// @Tags prompt-drafts
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param name path string true "Draft name"
+// @Param request body models.PromoteDraftRequest false "Commit message"
+// @Success 200 {object} models.PromptResponse "Prompt after the promotion"
+// @Failure 400 {object} models.ErrorResponse "Invalid request data"
+// @Failure 404 {object} models.ErrorResponse "Prompt or draft not found"
+// @Failure 409 {object} models.DraftConflictResponse "Draft content conflicts with the prompt"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/drafts/{name}/promote [post]
+func (h *DraftHandlers) PromotePromptDraft(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	name := r.PathValue("name")
+
+	var req models.PromoteDraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		models.WriteBadRequest(w, "Invalid JSON body")
+		return
+	}
+
+	if _, err := h.repo.Prompts().GetByID(r.Context(), id); err != nil {
+		models.WriteNotFound(w, "Prompt")
+		return
+	}
+
+	prompt, err := h.repo.Prompts().PromoteDraft(r.Context(), id, name, req.Message)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		h.logger.Debug("Draft conflicts with prompt", "prompt_id", id, "draft", name, "error", err)
+		h.writeDraftConflict(w, r, id, name)
+		return
+	}
+	if err != nil {
+		h.writeDraftError(w, err, id, name, "Failed to promote draft")
+		return
+	}
+
+	h.logger.Info("Prompt draft promoted", "prompt_id", id, "draft", name)
+	setETag(w, prompt.UpdatedAt)
+	json.NewEncoder(w).Encode(models.FromPrompt(prompt))
+}
+
+// writeDraftConflict answers a rejected promotion with the current prompt, the draft and
+// the conflicting merge of their contents
+func (h *DraftHandlers) writeDraftConflict(w http.ResponseWriter, r *http.Request, id, name string) {
+	ctx := r.Context()
+	current, err := h.repo.Prompts().GetByID(ctx, id)
+	if err != nil {
+		h.logger.Error("Failed to get prompt after conflict", "prompt_id", id, "error", err)
+		models.WriteInternalError(w, "Failed to promote draft")
+		return
+	}
+	draft, err := h.gitService.GetPromptDraft(ctx, id, name)
+	if err != nil {
+		h.writeDraftError(w, err, id, name, "Failed to promote draft")
+		return
+	}
+	base, err := h.gitService.GetPromptVersion(ctx, id, draft.Base)
+	if err != nil {
+		h.logger.Error("Failed to get draft base after conflict", "prompt_id", id, "commit", draft.Base, "error", err)
+		models.WriteInternalError(w, "Failed to promote draft")
+		return
+	}
+
+	_, merge := git.MergePrompt(base, current, draft.Prompt)
+	writeConflict(w, current.UpdatedAt, &models.DraftConflictResponse{
+		ErrorResponse: models.ErrorResponse{
+			Error:   http.StatusText(http.StatusConflict),
+			Message: "Draft " + name + " conflicts with changes made to the prompt since it was created",
+			Code:    http.StatusConflict,
+		},
+		Current: models.FromPrompt(current),
+		Draft:   models.FromPromptDraft(draft),
+		Merge:   models.FromContentMerge(draft.Base, merge),
+	})
+}
+
+// writeDraftError writes the response for an error returned by a draft operation
+func (h *DraftHandlers) writeDraftError(w http.ResponseWriter, err error, id, name, message string) {
+	switch {
+	case errors.Is(err, git.ErrDraftNotFound):
+		models.WriteNotFound(w, "Draft")
+	case errors.Is(err, git.ErrDraftExists):
+		models.WriteError(w, http.StatusConflict, "Draft "+name+" already exists")
+	case errors.Is(err, git.ErrInvalidDraftName):
+		models.WriteBadRequest(w, err.Error())
+	default:
+		h.logger.Error(message, "prompt_id", id, "draft", name, "error", err)
+		models.WriteInternalError(w, message)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
+	domainModels "github.com/dikkadev/proompt/server/internal/models"
+)
+
+func TestCreatePromptDraft(t *testing.T) {
+	repo := newMockRepository()
+	gitService := newMockGitService()
+	handlers := NewDraftHandlers(repo, gitService)
+
+	repo.prompts.Create(context.Background(), &domainModels.Prompt{ID: "test-id", Title: "Test Prompt", Content: "Hello"})
+
+	create := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/prompts/"+id+"/drafts", bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handlers.CreatePromptDraft(w, req)
+		return w
+	}
+
+	if w := create("test-id", `{"name": "shorter"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if w := create("test-id", `{"name": "shorter"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for an existing draft, got %d", http.StatusConflict, w.Code)
+	}
+	if w := create("test-id", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without a name, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := create("nonexistent", `{"name": "shorter"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a missing prompt, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestUpdateAndPreviewPromptDraft(t *testing.T) {
+	repo := newMockRepository()
+	gitService := newMockGitService()
+	handlers := NewDraftHandlers(repo, gitService)
+
+	repo.snippets.snippets["signature"] = &domainModels.Snippet{ID: "signature", Title: "signature", Content: "Regards, {{author}}"}
+	gitService.drafts[git.DraftBranch("test-id", "shorter")] = &git.PromptDraft{
+		Name:   "shorter",
+		Prompt: &domainModels.Prompt{ID: "test-id", Title: "Test Prompt", Content: "Hello"},
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/prompts/test-id/drafts/shorter",
+		bytes.NewBufferString(`{"content": "Hi {{name}}\n@signature", "message": "Try a shorter greeting"}`))
+	req.SetPathValue("id", "test-id")
+	req.SetPathValue("name", "shorter")
+	w := httptest.NewRecorder()
+	handlers.UpdatePromptDraft(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var draft models.DraftResponse
+	if err := json.NewDecoder(w.Body).Decode(&draft); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if draft.Prompt.Content != "Hi {{name}}\n@signature" || draft.Prompt.Title != "Test Prompt" {
+		t.Errorf("Expected only the content to change, got %+v", draft.Prompt)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/prompts/test-id/drafts/shorter/preview",
+		bytes.NewBufferString(`{"variables": {"name": "Ada", "author": "Bob"}}`))
+	req.SetPathValue("id", "test-id")
+	req.SetPathValue("name", "shorter")
+	w = httptest.NewRecorder()
+	handlers.PreviewPromptDraft(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var preview models.TemplatePreviewResponse
+	if err := json.NewDecoder(w.Body).Decode(&preview); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if preview.ResolvedContent != "Hi Ada\nRegards, Bob" {
+		t.Errorf("Expected the draft content to be resolved, got %q", preview.ResolvedContent)
+	}
+
+	// Previewing a draft that does not exist
+	req = httptest.NewRequest(http.MethodPost, "/api/prompts/test-id/drafts/longer/preview", nil)
+	req.SetPathValue("id", "test-id")
+	req.SetPathValue("name", "longer")
+	w = httptest.NewRecorder()
+	handlers.PreviewPromptDraft(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
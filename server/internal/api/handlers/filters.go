@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// parseTagFilter reads the comma-separated tags and the tag_mode query parameters
+func parseTagFilter(r *http.Request) ([]string, repository.TagMode, error) {
+	var tags []string
+	for _, tag := range strings.Split(r.URL.Query().Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	mode := repository.TagModeAny
+	if modeParam := r.URL.Query().Get("tag_mode"); modeParam != "" {
+		mode = repository.TagMode(modeParam)
+		if !mode.Valid() {
+			return nil, "", fmt.Errorf("invalid tag_mode '%s', must be one of: any, all, none", modeParam)
+		}
+	}
+
+	return tags, mode, nil
+}
+
+// parseTimeParam reads an optional RFC3339 timestamp query parameter
+func parseTimeParam(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s', must be an RFC3339 timestamp", name, value)
+	}
+	return &t, nil
+}
+
+// parseBoolParam reads an optional boolean query parameter
+func parseBoolParam(r *http.Request, name string) (*bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s', must be true or false", name, value)
+	}
+	return &b, nil
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pagination holds the paging and sorting query parameters of a list request
+type pagination struct {
+	limit     int
+	offset    int
+	cursor    *string
+	sortBy    repository.SortField
+	sortOrder repository.SortOrder
+}
+
+// parsePagination reads the limit, page, offset, cursor, sort and order query parameters.
+// A page or offset cannot be combined with a cursor.
+func parsePagination(r *http.Request) (pagination, error) {
+	query := r.URL.Query()
+	p := pagination{limit: defaultPageSize}
+
+	if limitParam := query.Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return p, fmt.Errorf("invalid limit '%s', must be between 1 and %d", limitParam, maxPageSize)
+		}
+		p.limit = limit
+	}
+
+	if pageParam := query.Get("page"); pageParam != "" {
+		page, err := strconv.Atoi(pageParam)
+		if err != nil || page < 1 {
+			return p, fmt.Errorf("invalid page '%s', must be a positive number", pageParam)
+		}
+		p.offset = (page - 1) * p.limit
+	}
+
+	if offsetParam := query.Get("offset"); offsetParam != "" {
+		offset, err := strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			return p, fmt.Errorf("invalid offset '%s', must not be negative", offsetParam)
+		}
+		p.offset = offset
+	}
+
+	if cursorParam := query.Get("cursor"); cursorParam != "" {
+		if query.Has("page") || query.Has("offset") {
+			return p, fmt.Errorf("cursor cannot be combined with page or offset")
+		}
+		p.cursor = &cursorParam
+	}
+
+	if sortParam := query.Get("sort"); sortParam != "" {
+		p.sortBy = repository.SortField(sortParam)
+		if !p.sortBy.Valid() {
+			return p, fmt.Errorf("invalid sort '%s', must be one of: title, created_at, updated_at", sortParam)
+		}
+	}
+
+	if orderParam := query.Get("order"); orderParam != "" {
+		p.sortOrder = repository.SortOrder(orderParam)
+		if !p.sortOrder.Valid() {
+			return p, fmt.Errorf("invalid order '%s', must be one of: asc, desc", orderParam)
+		}
+	}
+
+	return p, nil
+}
+
+// newListResponse builds the response for one page of a listing with total matching items.
+// cursorOf makes the cursor continuing after an item.
+func newListResponse[T, R any](items []T, responses []R, total int, p pagination, cursorOf func(T) string) models.ListResponse[R] {
+	response := models.ListResponse[R]{
+		Data:       responses,
+		Total:      total,
+		PageSize:   p.limit,
+		TotalPages: (total + p.limit - 1) / p.limit,
+	}
+
+	if p.cursor == nil {
+		response.Page = p.offset/p.limit + 1
+	}
+
+	// A full page may be followed by more items
+	if len(items) == p.limit {
+		next := cursorOf(items[len(items)-1])
+		response.NextCursor = &next
+	}
+
+	return response
+}
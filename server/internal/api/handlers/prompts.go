@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
 	"github.com/dikkadev/proompt/server/internal/logging"
 	domainModels "github.com/dikkadev/proompt/server/internal/models"
 	"github.com/dikkadev/proompt/server/internal/repository"
@@ -15,15 +18,17 @@ import (
 
 // PromptHandlers contains handlers for prompt operations
 type PromptHandlers struct {
-	repo   repository.Repository
-	logger *slog.Logger
+	repo       repository.Repository
+	gitService git.GitService
+	logger     *slog.Logger
 }
 
 // NewPromptHandlers creates a new prompt handlers instance
-func NewPromptHandlers(repo repository.Repository) *PromptHandlers {
+func NewPromptHandlers(repo repository.Repository, gitService git.GitService) *PromptHandlers {
 	return &PromptHandlers{
-		repo:   repo,
-		logger: logging.NewLogger("handlers.prompts"),
+		repo:       repo,
+		gitService: gitService,
+		logger:     logging.NewLogger("handlers.prompts"),
 	}
 }
 
@@ -87,6 +92,7 @@ func (h *PromptHandlers) CreatePrompt(w http.ResponseWriter, r *http.Request) {
 
 	// Return created prompt
 	response := models.FromPrompt(prompt)
+	setETag(w, prompt.UpdatedAt)
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 
@@ -95,14 +101,16 @@ func (h *PromptHandlers) CreatePrompt(w http.ResponseWriter, r *http.Request) {
 
 // GetPrompt godoc
 // @Summary Get a prompt by ID
-// @Description Retrieve a specific prompt by its unique identifier
+// @Description Retrieve a specific prompt by its unique identifier. With version, the prompt is read from git at a release label such as "production" or at a commit hash.
 // @Tags prompts
 // @Accept json
 // @Produce json
 // @Param id path string true "Prompt ID" format(uuid)
+// @Param version query string false "Release label or commit hash; the live prompt when omitted or current"
 // @Success 200 {object} models.PromptResponse "Prompt details"
+// @Header 200 {string} ETag "Version of the prompt, for If-Match on updates; only set for the live prompt"
 // @Failure 400 {object} models.ErrorResponse "Invalid prompt ID"
-// @Failure 404 {object} models.ErrorResponse "Prompt not found"
+// @Failure 404 {object} models.ErrorResponse "Prompt or version not found"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /prompts/{id} [get]
 func (h *PromptHandlers) GetPrompt(w http.ResponseWriter, r *http.Request) {
@@ -115,6 +123,11 @@ func (h *PromptHandlers) GetPrompt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if version := r.URL.Query().Get("version"); version != "" && version != currentVersion {
+		h.getPromptAt(w, r, id, version)
+		return
+	}
+
 	prompt, err := h.repo.Prompts().GetByID(r.Context(), id)
 	if err != nil {
 		h.logger.Debug("Prompt not found in repository", "prompt_id", id, "error", err)
@@ -128,22 +141,47 @@ func (h *PromptHandlers) GetPrompt(w http.ResponseWriter, r *http.Request) {
 		"type", prompt.Type)
 
 	response := models.FromPrompt(prompt)
+	setETag(w, prompt.UpdatedAt)
 	json.NewEncoder(w).Encode(response)
 
 	h.logger.Debug("GetPrompt handler completed successfully", "prompt_id", id)
 }
 
+// getPromptAt writes a prompt as stored in git at a release label or commit. No ETag is
+// set, since a past version cannot be the base of an update.
+func (h *PromptHandlers) getPromptAt(w http.ResponseWriter, r *http.Request, id, version string) {
+	commit, err := resolvePromptVersion(r.Context(), h.gitService, id, version)
+	if err != nil {
+		h.logger.Error("Failed to resolve prompt release", "prompt_id", id, "version", version, "error", err)
+		models.WriteInternalError(w, "Failed to get prompt")
+		return
+	}
+
+	prompt, err := h.gitService.GetPromptVersion(r.Context(), id, commit)
+	if err != nil {
+		h.logger.Debug("Prompt version not found", "prompt_id", id, "version", version, "error", err)
+		models.WriteNotFound(w, "Prompt version")
+		return
+	}
+
+	h.logger.Debug("Successfully retrieved prompt version", "prompt_id", id, "version", version, "commit", commit)
+	json.NewEncoder(w).Encode(models.FromPrompt(prompt))
+}
+
 // UpdatePrompt godoc
 // @Summary Update a prompt
-// @Description Update an existing prompt with new data
+// @Description Update an existing prompt with new data. With If-Match the update only succeeds if the prompt is still at that version; otherwise both versions are returned, with a three-way merge of the content when it was changed.
 // @Tags prompts
 // @Accept json
 // @Produce json
 // @Param id path string true "Prompt ID" format(uuid)
+// @Param If-Match header string false "ETags of the versions the update may be based on, or * for any version"
 // @Param request body models.UpdatePromptRequest true "Prompt update data"
 // @Success 200 {object} models.PromptResponse "Updated prompt"
+// @Header 200 {string} ETag "Version of the updated prompt"
 // @Failure 400 {object} models.ErrorResponse "Invalid request data"
 // @Failure 404 {object} models.ErrorResponse "Prompt not found"
+// @Failure 409 {object} models.PromptConflictResponse "Prompt was modified since the If-Match version"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /prompts/{id} [put]
 func (h *PromptHandlers) UpdatePrompt(w http.ResponseWriter, r *http.Request) {
@@ -156,6 +194,12 @@ func (h *PromptHandlers) UpdatePrompt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tags, err := parseIfMatch(r)
+	if err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+
 	// Get existing prompt
 	existing, err := h.repo.Prompts().GetByID(r.Context(), id)
 	if err != nil {
@@ -167,6 +211,7 @@ func (h *PromptHandlers) UpdatePrompt(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("Retrieved existing prompt for update",
 		"prompt_id", id,
 		"current_title", existing.Title)
+	expected := ifMatchVersion(tags, existing.UpdatedAt)
 
 	var req models.UpdatePromptRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -215,8 +260,18 @@ func (h *PromptHandlers) UpdatePrompt(w http.ResponseWriter, r *http.Request) {
 		"prompt_id", id,
 		"updated_fields", updatedFields)
 
-	// Update prompt
-	if err := h.repo.Prompts().Update(r.Context(), existing); err != nil {
+	// Update prompt, only if it is unchanged since the client read it when If-Match is set
+	if expected != nil {
+		err = h.repo.Prompts().UpdateIfUnchanged(r.Context(), existing, *expected)
+	} else {
+		err = h.repo.Prompts().Update(r.Context(), existing)
+	}
+	if errors.Is(err, repository.ErrVersionConflict) {
+		h.logger.Debug("Prompt changed since the If-Match version", "prompt_id", id, "error", err)
+		h.writePromptConflict(w, r, existing, req.Content, *expected)
+		return
+	}
+	if err != nil {
 		h.logger.Error("Failed to update prompt in repository",
 			"prompt_id", id,
 			"updated_fields", updatedFields,
@@ -231,11 +286,62 @@ func (h *PromptHandlers) UpdatePrompt(w http.ResponseWriter, r *http.Request) {
 
 	// Return updated prompt
 	response := models.FromPrompt(existing)
+	setETag(w, existing.UpdatedAt)
 	json.NewEncoder(w).Encode(response)
 
 	h.logger.Debug("UpdatePrompt handler completed successfully", "prompt_id", id)
 }
 
+// writePromptConflict answers a rejected conditional update with the current prompt and the
+// update applied to it. When the update changed the content and the version it was based
+// on is still in the history, the two contents are merged three-way.
+func (h *PromptHandlers) writePromptConflict(w http.ResponseWriter, r *http.Request, yours *domainModels.Prompt, content *string, expected time.Time) {
+	current, err := h.repo.Prompts().GetByID(r.Context(), yours.ID)
+	if err != nil {
+		h.logger.Error("Failed to get prompt after conflict", "prompt_id", yours.ID, "error", err)
+		models.WriteInternalError(w, "Failed to update prompt")
+		return
+	}
+
+	response := &models.PromptConflictResponse{
+		ErrorResponse: conflictError("Prompt"),
+		Current:       models.FromPrompt(current),
+		Yours:         models.FromPrompt(yours),
+	}
+	if content != nil && !expected.IsZero() {
+		if baseHash, base, ok := h.promptBase(r.Context(), yours.ID, expected); ok {
+			response.Merge = mergeContent(baseHash, base.Content, *content, current.Content)
+		}
+	}
+
+	writeConflict(w, current.UpdatedAt, response)
+}
+
+// promptBase finds the commit that stored the prompt version updated at updatedAt
+func (h *PromptHandlers) promptBase(ctx context.Context, id string, updatedAt time.Time) (string, *domainModels.Prompt, bool) {
+	commits, err := h.gitService.GetPromptHistory(ctx, id)
+	if err != nil {
+		h.logger.Debug("Failed to get prompt history for merge", "prompt_id", id, "error", err)
+		return "", nil, false
+	}
+
+	// History is newest first, so stop once versions are older than the one looked for
+	for _, commit := range commits {
+		version, err := h.gitService.GetPromptVersion(ctx, id, commit.Hash)
+		if err != nil {
+			h.logger.Debug("Failed to get prompt version for merge", "prompt_id", id, "commit", commit.Hash, "error", err)
+			return "", nil, false
+		}
+		if version.UpdatedAt.Equal(updatedAt) {
+			return commit.Hash, version, true
+		}
+		if version.UpdatedAt.Before(updatedAt) {
+			break
+		}
+	}
+	return "", nil, false
+}
+
 // DeletePrompt godoc
 // @Summary Delete a prompt
 // @Description Delete a prompt by its ID
@@ -271,10 +377,20 @@ func (h *PromptHandlers) DeletePrompt(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param page query int false "Page number (default: 1)" minimum(1)
 // @Param limit query int false "Items per page (default: 20, max: 100)" minimum(1) maximum(100)
+// @Param offset query int false "Number of items to skip, instead of page" minimum(0)
+// @Param cursor query string false "Continue after the next_cursor of a previous page, instead of page or offset"
+// @Param sort query string false "Sort field (default: updated_at)" Enums(title,created_at,updated_at)
+// @Param order query string false "Sort order (default: asc for title, desc otherwise)" Enums(asc,desc)
 // @Param search query string false "Search term for title/content"
 // @Param type query string false "Filter by prompt type" Enums(system,user,image,video)
 // @Param use_case query string false "Filter by use case"
 // @Param tags query string false "Filter by tags (comma-separated)"
+// @Param tag_mode query string false "How tags are matched (default: any)" Enums(any,all,none)
+// @Param has_variables query bool false "Only prompts with (true) or without (false) {{variable}} placeholders"
+// @Param created_after query string false "Only prompts created after this RFC3339 timestamp"
+// @Param created_before query string false "Only prompts created before this RFC3339 timestamp"
+// @Param updated_after query string false "Only prompts updated after this RFC3339 timestamp"
+// @Param updated_before query string false "Only prompts updated before this RFC3339 timestamp"
 // @Success 200 {object} models.PromptListResponse "List of prompts"
 // @Failure 400 {object} models.ErrorResponse "Invalid query parameters"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
@@ -291,28 +407,79 @@ func (h *PromptHandlers) ListPrompts(w http.ResponseWriter, r *http.Request) {
 	if useCaseParam := r.URL.Query().Get("use_case"); useCaseParam != "" {
 		filters.UseCase = &useCaseParam
 	}
-	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
-		if limit, err := strconv.Atoi(limitParam); err == nil {
-			filters.Limit = &limit
-		} else {
-			h.logger.Debug("Invalid limit parameter", "limit", limitParam, "error", err)
-		}
+	tags, tagMode, err := parseTagFilter(r)
+	if err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
 	}
-	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
-		if offset, err := strconv.Atoi(offsetParam); err == nil {
-			filters.Offset = &offset
-		} else {
-			h.logger.Debug("Invalid offset parameter", "offset", offsetParam, "error", err)
-		}
+	filters.Tags = tags
+	filters.TagMode = tagMode
+	if filters.HasVariables, err = parseBoolParam(r, "has_variables"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	if filters.CreatedAfter, err = parseTimeParam(r, "created_after"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	if filters.CreatedBefore, err = parseTimeParam(r, "created_before"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	if filters.UpdatedAfter, err = parseTimeParam(r, "updated_after"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	if filters.UpdatedBefore, err = parseTimeParam(r, "updated_before"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	page, err := parsePagination(r)
+	if err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	filters.SortBy = page.sortBy
+	filters.SortOrder = page.sortOrder
+
+	// The total ignores pagination, so count before the page is set
+	total, err := h.repo.Prompts().Count(r.Context(), filters)
+	if err != nil {
+		h.logger.Error("Failed to count prompts in repository",
+			"filters", filters,
+			"error", err)
+		models.WriteInternalError(w, "Failed to list prompts")
+		return
+	}
+
+	filters.Limit = &page.limit
+	if page.cursor != nil {
+		filters.Cursor = page.cursor
+	} else {
+		filters.Offset = &page.offset
 	}
 
 	h.logger.Debug("Parsed query filters",
 		"type", filters.Type,
 		"use_case", filters.UseCase,
+		"tags", filters.Tags,
+		"tag_mode", filters.TagMode,
+		"has_variables", filters.HasVariables,
+		"created_after", filters.CreatedAfter,
+		"created_before", filters.CreatedBefore,
+		"updated_after", filters.UpdatedAfter,
+		"updated_before", filters.UpdatedBefore,
+		"sort", filters.SortBy,
+		"order", filters.SortOrder,
 		"limit", filters.Limit,
-		"offset", filters.Offset)
+		"offset", filters.Offset,
+		"cursor", filters.Cursor)
 
 	prompts, err := h.repo.Prompts().List(r.Context(), filters)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
 	if err != nil {
 		h.logger.Error("Failed to list prompts from repository",
 			"filters", filters,
@@ -326,14 +493,9 @@ func (h *PromptHandlers) ListPrompts(w http.ResponseWriter, r *http.Request) {
 
 	responses := models.FromPrompts(prompts)
 
-	// Create list response
-	listResponse := models.ListResponse[*models.PromptResponse]{
-		Data:       responses,
-		Total:      len(responses), // TODO: Get actual total count
-		Page:       1,              // TODO: Calculate from offset/limit
-		PageSize:   len(responses),
-		TotalPages: 1, // TODO: Calculate from total/page_size
-	}
+	listResponse := newListResponse(prompts, responses, total, page, func(prompt *domainModels.Prompt) string {
+		return repository.PromptCursor(prompt, filters.SortBy)
+	})
 
 	json.NewEncoder(w).Encode(listResponse)
 
@@ -5,11 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
 	domainModels "github.com/dikkadev/proompt/server/internal/models"
 	"github.com/dikkadev/proompt/server/internal/repository"
 )
@@ -37,7 +40,9 @@ func (m *mockPromptRepository) GetByID(ctx context.Context, id string) (*domainM
 	if !exists {
 		return nil, ErrNotFound
 	}
-	return prompt, nil
+	// Return a copy, so handlers changing it do not change the stored prompt
+	copied := *prompt
+	return &copied, nil
 }
 
 func (m *mockPromptRepository) Update(ctx context.Context, prompt *domainModels.Prompt) error {
@@ -48,6 +53,19 @@ func (m *mockPromptRepository) Update(ctx context.Context, prompt *domainModels.
 	return nil
 }
 
+func (m *mockPromptRepository) UpdateIfUnchanged(ctx context.Context, prompt *domainModels.Prompt, expected time.Time) error {
+	stored, exists := m.prompts[prompt.ID]
+	if !exists {
+		return ErrNotFound
+	}
+	if !stored.UpdatedAt.Equal(expected) {
+		return repository.ErrVersionConflict
+	}
+	prompt.UpdatedAt = time.Now()
+	m.prompts[prompt.ID] = prompt
+	return nil
+}
+
 func (m *mockPromptRepository) Delete(ctx context.Context, id string) error {
 	if _, exists := m.prompts[id]; !exists {
 		return ErrNotFound
@@ -64,10 +82,30 @@ func (m *mockPromptRepository) List(ctx context.Context, filters repository.Prom
 	return result, nil
 }
 
+func (m *mockPromptRepository) Count(ctx context.Context, filters repository.PromptFilters) (int, error) {
+	return len(m.prompts), nil
+}
+
 func (m *mockPromptRepository) Search(ctx context.Context, query string) ([]*domainModels.Prompt, error) {
 	return nil, nil // Not implemented for tests
 }
 
+func (m *mockPromptRepository) Restore(ctx context.Context, id string, commitHash string) (*domainModels.Prompt, error) {
+	return nil, nil // Not implemented for tests
+}
+
+func (m *mockPromptRepository) Import(ctx context.Context, snapshots []*git.PromptSnapshot) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockPromptRepository) SetGitRef(ctx context.Context, id string, commitHash string) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockPromptRepository) PromoteDraft(ctx context.Context, id, name, message string) (*domainModels.Prompt, error) {
+	return nil, nil // Not implemented for tests
+}
+
 func (m *mockPromptRepository) CreateLink(ctx context.Context, link *domainModels.PromptLink) error {
 	return nil // Not implemented for tests
 }
@@ -102,12 +140,14 @@ func (m *mockPromptRepository) ListAllTags(ctx context.Context) ([]string, error
 
 // mockRepository implements Repository for testing
 type mockRepository struct {
-	prompts *mockPromptRepository
+	prompts  *mockPromptRepository
+	snippets *mockSnippetRepository
 }
 
 func newMockRepository() *mockRepository {
 	return &mockRepository{
-		prompts: newMockPromptRepository(),
+		prompts:  newMockPromptRepository(),
+		snippets: newMockSnippetRepository(),
 	}
 }
 
@@ -116,13 +156,17 @@ func (m *mockRepository) Prompts() repository.PromptRepository {
 }
 
 func (m *mockRepository) Snippets() repository.SnippetRepository {
-	return nil // Not needed for prompt tests
+	return m.snippets
 }
 
 func (m *mockRepository) Notes() repository.NoteRepository {
 	return nil // Not needed for prompt tests
 }
 
+func (m *mockRepository) Search() repository.SearchRepository {
+	return nil // Not needed for these tests
+}
+
 func (m *mockRepository) WithTx(ctx context.Context, fn func(repository.Repository) error) error {
 	return fn(m) // Simple implementation for tests
 }
@@ -133,7 +177,7 @@ func (m *mockRepository) Close() error {
 
 func TestCreatePrompt(t *testing.T) {
 	repo := newMockRepository()
-	handlers := NewPromptHandlers(repo)
+	handlers := NewPromptHandlers(repo, newMockGitService())
 
 	// Test valid request
 	reqBody := models.CreatePromptRequest{
@@ -169,7 +213,7 @@ func TestCreatePrompt(t *testing.T) {
 
 func TestCreatePromptInvalidJSON(t *testing.T) {
 	repo := newMockRepository()
-	handlers := NewPromptHandlers(repo)
+	handlers := NewPromptHandlers(repo, newMockGitService())
 
 	req := httptest.NewRequest(http.MethodPost, "/api/prompts", bytes.NewReader([]byte("invalid json")))
 	req.Header.Set("Content-Type", "application/json")
@@ -184,7 +228,7 @@ func TestCreatePromptInvalidJSON(t *testing.T) {
 
 func TestCreatePromptMissingTitle(t *testing.T) {
 	repo := newMockRepository()
-	handlers := NewPromptHandlers(repo)
+	handlers := NewPromptHandlers(repo, newMockGitService())
 
 	reqBody := models.CreatePromptRequest{
 		Content: "This is a test prompt",
@@ -206,7 +250,7 @@ func TestCreatePromptMissingTitle(t *testing.T) {
 
 func TestGetPrompt(t *testing.T) {
 	repo := newMockRepository()
-	handlers := NewPromptHandlers(repo)
+	handlers := NewPromptHandlers(repo, newMockGitService())
 
 	// Create a test prompt
 	prompt := &domainModels.Prompt{
@@ -239,7 +283,7 @@ func TestGetPrompt(t *testing.T) {
 
 func TestGetPromptNotFound(t *testing.T) {
 	repo := newMockRepository()
-	handlers := NewPromptHandlers(repo)
+	handlers := NewPromptHandlers(repo, newMockGitService())
 
 	req := httptest.NewRequest(http.MethodGet, "/api/prompts/nonexistent", nil)
 	req.SetPathValue("id", "nonexistent")
@@ -254,7 +298,7 @@ func TestGetPromptNotFound(t *testing.T) {
 
 func TestListPrompts(t *testing.T) {
 	repo := newMockRepository()
-	handlers := NewPromptHandlers(repo)
+	handlers := NewPromptHandlers(repo, newMockGitService())
 
 	// Create test prompts
 	prompt1 := &domainModels.Prompt{
@@ -290,3 +334,220 @@ func TestListPrompts(t *testing.T) {
 		t.Errorf("Expected 2 prompts, got %d", len(response.Data))
 	}
 }
+
+func TestListPromptsInvalidTagMode(t *testing.T) {
+	repo := newMockRepository()
+	handlers := NewPromptHandlers(repo, newMockGitService())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/prompts?tags=a,b&tag_mode=some", nil)
+	w := httptest.NewRecorder()
+
+	handlers.ListPrompts(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestListPromptsInvalidTimeFilter(t *testing.T) {
+	repo := newMockRepository()
+	handlers := NewPromptHandlers(repo, newMockGitService())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/prompts?updated_after=yesterday", nil)
+	w := httptest.NewRecorder()
+
+	handlers.ListPrompts(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestListPromptsPagination(t *testing.T) {
+	repo := newMockRepository()
+	handlers := NewPromptHandlers(repo, newMockGitService())
+
+	for i := 0; i < 5; i++ {
+		repo.prompts.Create(context.Background(), &domainModels.Prompt{
+			ID:      fmt.Sprintf("test-id-%d", i),
+			Title:   fmt.Sprintf("Test Prompt %d", i),
+			Content: "Test content",
+			Type:    domainModels.PromptTypeUser,
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/prompts?limit=2&page=2&sort=title", nil)
+	w := httptest.NewRecorder()
+
+	handlers.ListPrompts(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response models.ListResponse[*models.PromptResponse]
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// The mock ignores pagination and returns every prompt
+	if response.Total != 5 || response.Page != 2 || response.PageSize != 2 || response.TotalPages != 3 {
+		t.Errorf("Unexpected pagination metadata: total=%d page=%d page_size=%d total_pages=%d",
+			response.Total, response.Page, response.PageSize, response.TotalPages)
+	}
+}
+
+func TestListPromptsInvalidPagination(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown sort field", "sort=content"},
+		{"unknown order", "order=random"},
+		{"limit above maximum", "limit=500"},
+		{"page below one", "page=0"},
+		{"cursor with page", "cursor=abc&page=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockRepository()
+			handlers := NewPromptHandlers(repo, newMockGitService())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/prompts?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handlers.ListPrompts(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestUpdatePromptIfMatch(t *testing.T) {
+	read := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	changed := read.Add(time.Minute)
+
+	// The prompt was read at the base commit and later changed in the newer one
+	setup := func(stored string) (*mockRepository, *PromptHandlers) {
+		gitService := newMockGitService()
+		gitService.history["test-id"] = []git.GitCommit{{Hash: "newer"}, {Hash: "base"}}
+		gitService.versions["newer"] = &domainModels.Prompt{ID: "test-id", Title: "Test Prompt", Content: "line one\nline two\nline THREE\n", UpdatedAt: changed}
+		gitService.versions["base"] = &domainModels.Prompt{ID: "test-id", Title: "Test Prompt", Content: "line one\nline two\nline three\n", UpdatedAt: read}
+
+		repo := newMockRepository()
+		prompt := *gitService.versions[stored]
+		repo.prompts.Create(context.Background(), &prompt)
+		return repo, NewPromptHandlers(repo, gitService)
+	}
+
+	update := func(handlers *PromptHandlers, ifMatch, content string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.UpdatePromptRequest{Content: &content})
+		req := httptest.NewRequest(http.MethodPut, "/api/prompts/test-id", bytes.NewReader(body))
+		req.SetPathValue("id", "test-id")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		handlers.UpdatePrompt(w, req)
+		return w
+	}
+
+	t.Run("get returns etag", func(t *testing.T) {
+		_, handlers := setup("base")
+		req := httptest.NewRequest(http.MethodGet, "/api/prompts/test-id", nil)
+		req.SetPathValue("id", "test-id")
+		w := httptest.NewRecorder()
+		handlers.GetPrompt(w, req)
+
+		if got := w.Header().Get("ETag"); got != etag(read) {
+			t.Errorf("Expected ETag %s, got %q", etag(read), got)
+		}
+	})
+
+	t.Run("matching version", func(t *testing.T) {
+		repo, handlers := setup("base")
+		w := update(handlers, etag(read), "line ONE\nline two\nline three\n")
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		stored := repo.prompts.prompts["test-id"]
+		if stored.Content != "line ONE\nline two\nline three\n" {
+			t.Errorf("Expected content to be updated, got %q", stored.Content)
+		}
+		if got := w.Header().Get("ETag"); got != etag(stored.UpdatedAt) {
+			t.Errorf("Expected ETag of the new version, got %q", got)
+		}
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		repo, handlers := setup("newer")
+		w := update(handlers, etag(read), "line ONE\nline two\nline three\n")
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("ETag"); got != etag(changed) {
+			t.Errorf("Expected ETag of the current version, got %q", got)
+		}
+		if stored := repo.prompts.prompts["test-id"]; stored.Content != "line one\nline two\nline THREE\n" {
+			t.Errorf("Expected stored content to be left alone, got %q", stored.Content)
+		}
+
+		var response models.PromptConflictResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Current == nil || response.Yours == nil || response.Yours.Content != "line ONE\nline two\nline three\n" {
+			t.Fatalf("Expected both versions in the conflict, got %+v", response)
+		}
+		if response.Merge == nil || response.Merge.Base != "base" || !response.Merge.Clean {
+			t.Fatalf("Expected a clean merge against the base commit, got %+v", response.Merge)
+		}
+		if response.Merge.Content != "line ONE\nline two\nline THREE\n" {
+			t.Errorf("Expected both changes in the merged content, got %q", response.Merge.Content)
+		}
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		_, handlers := setup("base")
+		if w := update(handlers, "not-an-etag", "changed"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("wildcard is unconditional", func(t *testing.T) {
+		_, handlers := setup("newer")
+		if w := update(handlers, "*", "changed"); w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("list with current version", func(t *testing.T) {
+		_, handlers := setup("newer")
+		if w := update(handlers, etag(read)+", "+etag(changed), "changed"); w.Code != http.StatusOK {
+			t.Errorf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+	})
+
+	// Weak tags and tags of other servers are valid but never match
+	for _, ifMatch := range []string{"W/" + etag(changed), `"not-a-version"`} {
+		t.Run("unmatched tag "+ifMatch, func(t *testing.T) {
+			repo, handlers := setup("newer")
+			w := update(handlers, ifMatch, "changed")
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("ETag"); got != etag(changed) {
+				t.Errorf("Expected ETag of the current version, got %q", got)
+			}
+			if stored := repo.prompts.prompts["test-id"]; stored.Content == "changed" {
+				t.Error("Expected stored content to be left alone")
+			}
+		})
+	}
+}
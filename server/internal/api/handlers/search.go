@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/logging"
+	domainModels "github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// maxSearchLimit caps the number of results returned by a single search
+const maxSearchLimit = 100
+
+// SearchHandlers contains handlers for full-text search
+type SearchHandlers struct {
+	repo   repository.Repository
+	logger *slog.Logger
+}
+
+// NewSearchHandlers creates a new search handlers instance
+func NewSearchHandlers(repo repository.Repository) *SearchHandlers {
+	return &SearchHandlers{
+		repo:   repo,
+		logger: logging.NewLogger("handlers.search"),
+	}
+}
+
+// Search godoc
+// @Summary Search prompts, snippets and notes
+// @Description Full-text search ranked by relevance. Every word must match, as a prefix. The snippet is HTML: its text is escaped and matched terms are wrapped in <mark> tags.
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param q query string true "Search query"
+// @Param types query string false "Restrict results to these types (comma-separated: prompt,snippet,note)"
+// @Param limit query int false "Maximum number of results (default: 20, max: 100)" minimum(1) maximum(100)
+// @Success 200 {object} models.SearchListResponse "Ranked search results"
+// @Failure 400 {object} models.ErrorResponse "Invalid query parameters"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /search [get]
+func (h *SearchHandlers) Search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		models.WriteBadRequest(w, "Query parameter 'q' is required")
+		return
+	}
+
+	filters := repository.SearchFilters{}
+
+	if typesParam := r.URL.Query().Get("types"); typesParam != "" {
+		for _, t := range strings.Split(typesParam, ",") {
+			resultType := domainModels.SearchResultType(strings.TrimSpace(t))
+			if !resultType.Valid() {
+				models.WriteBadRequest(w, "Invalid type '"+string(resultType)+"', must be one of: prompt, snippet, note")
+				return
+			}
+			filters.Types = append(filters.Types, resultType)
+		}
+	}
+
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			models.WriteBadRequest(w, "Limit must be a number between 1 and 100")
+			return
+		}
+		filters.Limit = &limit
+	}
+
+	results, err := h.repo.Search().Search(r.Context(), query, filters)
+	if err != nil {
+		h.logger.Error("Failed to search", "query", query, "error", err)
+		models.WriteInternalError(w, "Failed to search")
+		return
+	}
+
+	responses := models.FromSearchResults(results)
+
+	listResponse := models.ListResponse[*models.SearchResultResponse]{
+		Data:       responses,
+		Total:      len(responses),
+		Page:       1,
+		PageSize:   len(responses),
+		TotalPages: 1,
+	}
+
+	json.NewEncoder(w).Encode(listResponse)
+}
@@ -1,28 +1,34 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
 	"github.com/dikkadev/proompt/server/internal/logging"
+	domainModels "github.com/dikkadev/proompt/server/internal/models"
 	"github.com/dikkadev/proompt/server/internal/repository"
 	"github.com/google/uuid"
 )
 
 // SnippetHandlers contains handlers for snippet operations
 type SnippetHandlers struct {
-	repo   repository.Repository
-	logger *slog.Logger
+	repo       repository.Repository
+	gitService git.GitService
+	logger     *slog.Logger
 }
 
 // NewSnippetHandlers creates a new snippet handlers instance
-func NewSnippetHandlers(repo repository.Repository) *SnippetHandlers {
+func NewSnippetHandlers(repo repository.Repository, gitService git.GitService) *SnippetHandlers {
 	return &SnippetHandlers{
-		repo:   repo,
-		logger: logging.NewLogger("handlers.snippets"),
+		repo:       repo,
+		gitService: gitService,
+		logger:     logging.NewLogger("handlers.snippets"),
 	}
 }
 
@@ -84,6 +90,7 @@ func (h *SnippetHandlers) CreateSnippet(w http.ResponseWriter, r *http.Request)
 
 	// Return created snippet
 	response := models.FromSnippet(snippet)
+	setETag(w, snippet.UpdatedAt)
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
@@ -96,6 +103,7 @@ func (h *SnippetHandlers) CreateSnippet(w http.ResponseWriter, r *http.Request)
 // @Produce json
 // @Param id path string true "Snippet ID" format(uuid)
 // @Success 200 {object} models.SnippetResponse "Snippet details"
+// @Header 200 {string} ETag "Version of the snippet, for If-Match on updates"
 // @Failure 400 {object} models.ErrorResponse "Invalid snippet ID"
 // @Failure 404 {object} models.ErrorResponse "Snippet not found"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
@@ -114,20 +122,24 @@ func (h *SnippetHandlers) GetSnippet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := models.FromSnippet(snippet)
+	setETag(w, snippet.UpdatedAt)
 	json.NewEncoder(w).Encode(response)
 }
 
 // UpdateSnippet godoc
 // @Summary Update a snippet
-// @Description Update an existing snippet with new data
+// @Description Update an existing snippet with new data. With If-Match the update only succeeds if the snippet is still at that version; otherwise both versions are returned, with a three-way merge of the content when it was changed.
 // @Tags snippets
 // @Accept json
 // @Produce json
 // @Param id path string true "Snippet ID" format(uuid)
+// @Param If-Match header string false "ETags of the versions the update may be based on, or * for any version"
 // @Param request body models.UpdateSnippetRequest true "Snippet update data"
 // @Success 200 {object} models.SnippetResponse "Updated snippet"
+// @Header 200 {string} ETag "Version of the updated snippet"
 // @Failure 400 {object} models.ErrorResponse "Invalid request data"
 // @Failure 404 {object} models.ErrorResponse "Snippet not found"
+// @Failure 409 {object} models.SnippetConflictResponse "Snippet was modified since the If-Match version"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /snippets/{id} [put]
 func (h *SnippetHandlers) UpdateSnippet(w http.ResponseWriter, r *http.Request) {
@@ -137,12 +149,19 @@ func (h *SnippetHandlers) UpdateSnippet(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	tags, err := parseIfMatch(r)
+	if err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+
 	// Get existing snippet
 	existing, err := h.repo.Snippets().GetByID(r.Context(), id)
 	if err != nil {
 		models.WriteNotFound(w, "Snippet")
 		return
 	}
+	expected := ifMatchVersion(tags, existing.UpdatedAt)
 
 	var req models.UpdateSnippetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -161,17 +180,76 @@ func (h *SnippetHandlers) UpdateSnippet(w http.ResponseWriter, r *http.Request)
 		existing.Description = req.Description
 	}
 
-	// Update snippet
-	if err := h.repo.Snippets().Update(r.Context(), existing); err != nil {
+	// Update snippet, only if it is unchanged since the client read it when If-Match is set
+	if expected != nil {
+		err = h.repo.Snippets().UpdateIfUnchanged(r.Context(), existing, *expected)
+	} else {
+		err = h.repo.Snippets().Update(r.Context(), existing)
+	}
+	if errors.Is(err, repository.ErrVersionConflict) {
+		h.writeSnippetConflict(w, r, existing, req.Content, *expected)
+		return
+	}
+	if err != nil {
 		models.WriteInternalError(w, "Failed to update snippet")
 		return
 	}
 
 	// Return updated snippet
 	response := models.FromSnippet(existing)
+	setETag(w, existing.UpdatedAt)
 	json.NewEncoder(w).Encode(response)
 }
 
+// writeSnippetConflict answers a rejected conditional update with the current snippet and
+// the update applied to it, merging the contents like writePromptConflict
+func (h *SnippetHandlers) writeSnippetConflict(w http.ResponseWriter, r *http.Request, yours *domainModels.Snippet, content *string, expected time.Time) {
+	current, err := h.repo.Snippets().GetByID(r.Context(), yours.ID)
+	if err != nil {
+		h.logger.Error("Failed to get snippet after conflict", "snippet_id", yours.ID, "error", err)
+		models.WriteInternalError(w, "Failed to update snippet")
+		return
+	}
+
+	response := &models.SnippetConflictResponse{
+		ErrorResponse: conflictError("Snippet"),
+		Current:       models.FromSnippet(current),
+		Yours:         models.FromSnippet(yours),
+	}
+	if content != nil && !expected.IsZero() {
+		if baseHash, base, ok := h.snippetBase(r.Context(), yours.ID, expected); ok {
+			response.Merge = mergeContent(baseHash, base.Content, *content, current.Content)
+		}
+	}
+
+	writeConflict(w, current.UpdatedAt, response)
+}
+
+// snippetBase finds the commit that stored the snippet version updated at updatedAt
+func (h *SnippetHandlers) snippetBase(ctx context.Context, id string, updatedAt time.Time) (string, *domainModels.Snippet, bool) {
+	commits, err := h.gitService.GetSnippetHistory(ctx, id)
+	if err != nil {
+		h.logger.Debug("Failed to get snippet history for merge", "snippet_id", id, "error", err)
+		return "", nil, false
+	}
+
+	// History is newest first, so stop once versions are older than the one looked for
+	for _, commit := range commits {
+		version, err := h.gitService.GetSnippetVersion(ctx, id, commit.Hash)
+		if err != nil {
+			h.logger.Debug("Failed to get snippet version for merge", "snippet_id", id, "commit", commit.Hash, "error", err)
+			return "", nil, false
+		}
+		if version.UpdatedAt.Equal(updatedAt) {
+			return commit.Hash, version, true
+		}
+		if version.UpdatedAt.Before(updatedAt) {
+			break
+		}
+	}
+	return "", nil, false
+}
+
 // DeleteSnippet godoc
 // @Summary Delete a snippet
 // @Description Delete a snippet by its ID
@@ -207,8 +285,18 @@ func (h *SnippetHandlers) DeleteSnippet(w http.ResponseWriter, r *http.Request)
 // @Produce json
 // @Param page query int false "Page number (default: 1)" minimum(1)
 // @Param limit query int false "Items per page (default: 20, max: 100)" minimum(1) maximum(100)
+// @Param offset query int false "Number of items to skip, instead of page" minimum(0)
+// @Param cursor query string false "Continue after the next_cursor of a previous page, instead of page or offset"
+// @Param sort query string false "Sort field (default: updated_at)" Enums(title,created_at,updated_at)
+// @Param order query string false "Sort order (default: asc for title, desc otherwise)" Enums(asc,desc)
 // @Param search query string false "Search term for title/content"
 // @Param tags query string false "Filter by tags (comma-separated)"
+// @Param tag_mode query string false "How tags are matched (default: any)" Enums(any,all,none)
+// @Param has_variables query bool false "Only snippets with (true) or without (false) {{variable}} placeholders"
+// @Param created_after query string false "Only snippets created after this RFC3339 timestamp"
+// @Param created_before query string false "Only snippets created before this RFC3339 timestamp"
+// @Param updated_after query string false "Only snippets updated after this RFC3339 timestamp"
+// @Param updated_before query string false "Only snippets updated before this RFC3339 timestamp"
 // @Success 200 {object} models.SnippetListResponse "List of snippets"
 // @Failure 400 {object} models.ErrorResponse "Invalid query parameters"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
@@ -217,18 +305,61 @@ func (h *SnippetHandlers) ListSnippets(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	filters := repository.SnippetFilters{}
 
-	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
-		if limit, err := strconv.Atoi(limitParam); err == nil {
-			filters.Limit = &limit
-		}
+	tags, tagMode, err := parseTagFilter(r)
+	if err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
 	}
-	if offsetParam := r.URL.Query().Get("offset"); offsetParam != "" {
-		if offset, err := strconv.Atoi(offsetParam); err == nil {
-			filters.Offset = &offset
-		}
+	filters.Tags = tags
+	filters.TagMode = tagMode
+	if filters.HasVariables, err = parseBoolParam(r, "has_variables"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	if filters.CreatedAfter, err = parseTimeParam(r, "created_after"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	if filters.CreatedBefore, err = parseTimeParam(r, "created_before"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	if filters.UpdatedAfter, err = parseTimeParam(r, "updated_after"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	if filters.UpdatedBefore, err = parseTimeParam(r, "updated_before"); err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	page, err := parsePagination(r)
+	if err != nil {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
+	filters.SortBy = page.sortBy
+	filters.SortOrder = page.sortOrder
+
+	// The total ignores pagination, so count before the page is set
+	total, err := h.repo.Snippets().Count(r.Context(), filters)
+	if err != nil {
+		models.WriteInternalError(w, "Failed to list snippets")
+		return
+	}
+
+	filters.Limit = &page.limit
+	if page.cursor != nil {
+		filters.Cursor = page.cursor
+	} else {
+		filters.Offset = &page.offset
 	}
 
 	snippets, err := h.repo.Snippets().List(r.Context(), filters)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		models.WriteBadRequest(w, err.Error())
+		return
+	}
 	if err != nil {
 		models.WriteInternalError(w, "Failed to list snippets")
 		return
@@ -236,14 +367,9 @@ func (h *SnippetHandlers) ListSnippets(w http.ResponseWriter, r *http.Request) {
 
 	responses := models.FromSnippets(snippets)
 
-	// Create list response
-	listResponse := models.ListResponse[*models.SnippetResponse]{
-		Data:       responses,
-		Total:      len(responses), // TODO: Get actual total count
-		Page:       1,              // TODO: Calculate from offset/limit
-		PageSize:   len(responses),
-		TotalPages: 1, // TODO: Calculate from total/page_size
-	}
+	listResponse := newListResponse(snippets, responses, total, page, func(snippet *domainModels.Snippet) string {
+		return repository.SnippetCursor(snippet, filters.SortBy)
+	})
 
 	json.NewEncoder(w).Encode(listResponse)
 }
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/dikkadev/proompt/server/internal/api/models"
+	domainModels "github.com/dikkadev/proompt/server/internal/models"
 	"github.com/dikkadev/proompt/server/internal/repository"
 	"github.com/dikkadev/proompt/server/internal/template"
 )
@@ -29,7 +31,7 @@ func NewTemplateHandler(repo repository.Repository) *TemplateHandler {
 // @Produce json
 // @Param request body models.TemplatePreviewRequest true "Template preview data"
 // @Success 200 {object} models.TemplatePreviewResponse "Template preview result"
-// @Failure 400 {object} models.ErrorResponse "Invalid request data or template syntax"
+// @Failure 400 {object} models.ErrorResponse "Invalid request data, or variable values that do not fit their types"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /template/preview [post]
 func (h *TemplateHandler) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
@@ -52,41 +54,120 @@ func (h *TemplateHandler) PreviewTemplate(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	response, errors := previewTemplate(snippets, req.Content, req.Variables)
+	if len(errors) > 0 {
+		models.WriteValidationError(w, errors)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// previewTemplate resolves content with the given snippets and variables. When a value does
+// not fit the declared type of its variable, it returns the errors by field instead.
+func previewTemplate(snippets []*domainModels.Snippet, content string, variables map[string]string) (models.TemplatePreviewResponse, map[string]string) {
 	// Create snippet resolver
-	snippetResolver := template.NewSnippetResolver(snippets, req.Variables)
+	snippetResolver := template.NewSnippetResolver(snippets, variables)
+
+	// Reject values that do not fit their variable's type
+	if variableErrors := snippetResolver.ValidateWithSnippets(content); len(variableErrors) > 0 {
+		errors := make(map[string]string, len(variableErrors))
+		for _, e := range variableErrors {
+			errors["variables."+e.Variable] = e.Message
+		}
+		return models.TemplatePreviewResponse{}, errors
+	}
 
 	// Resolve template with snippets and variables
-	result := snippetResolver.ResolveWithSnippets(req.Content)
+	result := snippetResolver.ResolveWithSnippets(content)
+
+	return models.TemplatePreviewResponse{
+		ResolvedContent: result.Content,
+		Variables:       templateVariables(snippetResolver, content),
+		Warnings:        result.Warnings,
+		Diagnostics:     templateDiagnostics(result.Diagnostics),
+	}, nil
+}
 
-	// Get variable status
-	allVariables := snippetResolver.GetAllVariables(req.Content)
-	variableStatus := snippetResolver.GetVariableStatusWithSnippets(req.Content)
+// templateVariables lists the variables of content and its snippets with their status
+func templateVariables(snippetResolver *template.SnippetResolver, content string) []models.TemplateVariable {
+	allVariables := snippetResolver.GetAllVariables(content)
+	variableStatus := snippetResolver.GetVariableStatusWithSnippets(content)
 
 	// Convert to response format
 	var responseVars []models.TemplateVariable
 	for _, v := range allVariables {
 		status := variableStatus[v.Name]
-		responseVars = append(responseVars, models.TemplateVariable{
+		variable := models.TemplateVariable{
 			Name:         v.Name,
 			DefaultValue: v.DefaultValue,
 			HasDefault:   v.HasDefault,
 			Status:       status,
+			Condition:    v.Condition,
+			List:         v.List,
+			Type:         v.TypeName(),
+		}
+		if v.Type != nil {
+			variable.Options = v.Type.Options
+			variable.Min = v.Type.Min
+			variable.Max = v.Type.Max
+		}
+		responseVars = append(responseVars, variable)
+	}
+	return responseVars
+}
+
+// templateBlocks converts the blocks of a template to response format
+func templateBlocks(blocks []template.Block) []models.TemplateBlock {
+	if len(blocks) == 0 {
+		return nil
+	}
+	responseBlocks := make([]models.TemplateBlock, 0, len(blocks))
+	for _, b := range blocks {
+		responseBlocks = append(responseBlocks, models.TemplateBlock{
+			Kind:     b.Kind,
+			Variable: b.Variable,
+			HasElse:  b.HasElse,
+			Active:   b.Active,
+			Items:    b.Items,
+			Blocks:   templateBlocks(b.Blocks),
 		})
 	}
+	return responseBlocks
+}
 
-	response := models.TemplatePreviewResponse{
-		ResolvedContent: result.Content,
-		Variables:       responseVars,
-		Warnings:        result.Warnings,
+// templateDiagnostics converts the diagnostics of a template to response format
+func templateDiagnostics(diagnostics []template.Diagnostic) []models.TemplateDiagnostic {
+	responseDiagnostics := make([]models.TemplateDiagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		diagnostic := models.TemplateDiagnostic{
+			Severity: d.Severity,
+			Code:     d.Code,
+			Message:  d.Message,
+			Span:     templateSpan(d.Span),
+		}
+		for _, include := range d.Includes {
+			diagnostic.Includes = append(diagnostic.Includes, models.TemplateInclude{
+				Snippet: include.Snippet,
+				Span:    templateSpan(include.Span),
+			})
+		}
+		responseDiagnostics = append(responseDiagnostics, diagnostic)
 	}
+	return responseDiagnostics
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+func templateSpan(span template.Span) models.TemplateSpan {
+	return models.TemplateSpan{
+		Start: models.TemplatePosition(span.Start),
+		End:   models.TemplatePosition(span.End),
+	}
 }
 
 // AnalyzeTemplate godoc
 // @Summary Analyze template structure
-// @Description Analyze a template to extract variables, functions, and structure information
+// @Description Analyze a template to extract variables, its {{#if}} and {{#each}} blocks, and structure information. Diagnostics locate each problem by line and column, with the chain of snippets for problems inside snippets.
 // @Tags templates
 // @Accept json
 // @Produce json
@@ -118,29 +199,21 @@ func (h *TemplateHandler) AnalyzeTemplate(w http.ResponseWriter, r *http.Request
 	// Create snippet resolver
 	snippetResolver := template.NewSnippetResolver(snippets, req.Variables)
 
-	// Analyze without resolving
-	allVariables := snippetResolver.GetAllVariables(req.Content)
-	variableStatus := snippetResolver.GetVariableStatusWithSnippets(req.Content)
-
 	// Get snippet insertion result for warnings
 	snippetResult := snippetResolver.InsertSnippets(req.Content)
 
-	// Convert to response format
-	var responseVars []models.TemplateVariable
-	for _, v := range allVariables {
-		status := variableStatus[v.Name]
-		responseVars = append(responseVars, models.TemplateVariable{
-			Name:         v.Name,
-			DefaultValue: v.DefaultValue,
-			HasDefault:   v.HasDefault,
-			Status:       status,
-		})
+	// Report malformed blocks and invalid values along with snippet problems
+	warnings := append(snippetResult.Warnings, template.Parse(snippetResult.Content).Warnings...)
+	for _, e := range snippetResolver.ValidateWithSnippets(req.Content) {
+		warnings = append(warnings, fmt.Sprintf("Variable '%s': %s", e.Variable, e.Message))
 	}
 
 	response := models.TemplatePreviewResponse{
 		ResolvedContent: snippetResult.Content, // Content with snippets inserted but variables not resolved
-		Variables:       responseVars,
-		Warnings:        snippetResult.Warnings,
+		Variables:       templateVariables(snippetResolver, req.Content),
+		Blocks:          templateBlocks(snippetResolver.GetBlocksWithSnippets(req.Content)),
+		Warnings:        warnings,
+		Diagnostics:     templateDiagnostics(snippetResolver.Diagnose(req.Content)),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
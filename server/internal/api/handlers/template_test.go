@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/dikkadev/proompt/server/internal/api/models"
 	domainModels "github.com/dikkadev/proompt/server/internal/models"
@@ -45,6 +47,19 @@ func (m *mockSnippetRepository) Update(ctx context.Context, snippet *domainModel
 	return nil
 }
 
+func (m *mockSnippetRepository) UpdateIfUnchanged(ctx context.Context, snippet *domainModels.Snippet, expected time.Time) error {
+	stored, exists := m.snippets[snippet.ID]
+	if !exists {
+		return ErrNotFound
+	}
+	if !stored.UpdatedAt.Equal(expected) {
+		return repository.ErrVersionConflict
+	}
+	snippet.UpdatedAt = time.Now()
+	m.snippets[snippet.ID] = snippet
+	return nil
+}
+
 func (m *mockSnippetRepository) Delete(ctx context.Context, id string) error {
 	if _, exists := m.snippets[id]; !exists {
 		return ErrNotFound
@@ -61,10 +76,26 @@ func (m *mockSnippetRepository) List(ctx context.Context, filters repository.Sni
 	return result, nil
 }
 
+func (m *mockSnippetRepository) Count(ctx context.Context, filters repository.SnippetFilters) (int, error) {
+	return len(m.snippets), nil
+}
+
 func (m *mockSnippetRepository) Search(ctx context.Context, query string) ([]*domainModels.Snippet, error) {
 	return nil, nil // Not implemented for tests
 }
 
+func (m *mockSnippetRepository) Restore(ctx context.Context, id string, commitHash string) (*domainModels.Snippet, error) {
+	return nil, nil // Not implemented for tests
+}
+
+func (m *mockSnippetRepository) Import(ctx context.Context, snippets []*domainModels.Snippet) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockSnippetRepository) SetGitRef(ctx context.Context, id string, commitHash string) error {
+	return nil // Not implemented for tests
+}
+
 func (m *mockSnippetRepository) AddTag(ctx context.Context, snippetID, tagName string) error {
 	return nil // Not implemented for tests
 }
@@ -104,6 +135,10 @@ func (m *mockTemplateRepository) Notes() repository.NoteRepository {
 	return nil // Not needed for template tests
 }
 
+func (m *mockTemplateRepository) Search() repository.SearchRepository {
+	return nil // Not needed for these tests
+}
+
 func (m *mockTemplateRepository) WithTx(ctx context.Context, fn func(repository.Repository) error) error {
 	return fn(m) // Simple implementation for tests
 }
@@ -335,3 +370,173 @@ func TestTemplateAnalyze(t *testing.T) {
 		t.Error("Expected to find 'user' variable")
 	}
 }
+
+func TestTemplateAnalyzeBlocks(t *testing.T) {
+	repo := newMockTemplateRepository()
+	handler := NewTemplateHandler(repo)
+
+	requestBody := models.TemplatePreviewRequest{
+		Content: "{{#if formal}}Dear{{else}}Hi{{/if}} {{name}}\n{{#each rules}}\n- {{this}}\n{{/each}}\n{{#if extra}}",
+		Variables: map[string]string{
+			"formal": "true",
+			"rules":  `["Be brief", "Be kind"]`,
+		},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	req := httptest.NewRequest("POST", "/api/template/analyze", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	handler.AnalyzeTemplate(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response models.TemplatePreviewResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	expectedBlocks := []models.TemplateBlock{
+		{Kind: "if", Variable: "formal", HasElse: true, Active: true},
+		{Kind: "each", Variable: "rules", Active: true, Items: 2},
+	}
+	if !reflect.DeepEqual(response.Blocks, expectedBlocks) {
+		t.Errorf("Expected blocks %+v, got %+v", expectedBlocks, response.Blocks)
+	}
+
+	// The unclosed {{#if extra}} is reported
+	if len(response.Warnings) != 1 {
+		t.Errorf("Expected 1 warning, got %v", response.Warnings)
+	}
+
+	for _, v := range response.Variables {
+		if v.Name == "formal" && !v.Condition {
+			t.Error("Expected formal to be a condition")
+		}
+		if v.Name == "rules" && !v.List {
+			t.Error("Expected rules to be a list")
+		}
+		if v.Name == "this" {
+			t.Error("Expected the loop item not to be reported as a variable")
+		}
+	}
+}
+
+func TestTemplateAnalyzeDiagnostics(t *testing.T) {
+	repo := newMockTemplateRepository()
+	repo.snippets.snippets["footer"] = &domainModels.Snippet{
+		ID:      "footer",
+		Title:   "footer",
+		Content: "Thanks,\n{{author}} @missing",
+	}
+	handler := NewTemplateHandler(repo)
+
+	requestBody := models.TemplatePreviewRequest{
+		Content:   "Hi {{name}}\n@footer",
+		Variables: map[string]string{"name": "Alice"},
+	}
+
+	body, _ := json.Marshal(requestBody)
+	req := httptest.NewRequest("POST", "/api/template/analyze", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	handler.AnalyzeTemplate(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response models.TemplatePreviewResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	// Both problems are in the footer, so they point at its reference on line 2
+	reference := models.TemplateSpan{
+		Start: models.TemplatePosition{Offset: 12, Line: 2, Column: 1},
+		End:   models.TemplatePosition{Offset: 19, Line: 2, Column: 8},
+	}
+	expected := []models.TemplateDiagnostic{
+		{
+			Severity: "warning",
+			Code:     "unknown-snippet",
+			Message:  "Snippet 'missing' not found",
+			Span:     reference,
+			Includes: []models.TemplateInclude{{
+				Snippet: "footer",
+				Span: models.TemplateSpan{
+					Start: models.TemplatePosition{Offset: 19, Line: 2, Column: 12},
+					End:   models.TemplatePosition{Offset: 27, Line: 2, Column: 20},
+				},
+			}},
+		},
+		{
+			Severity: "warning",
+			Code:     "undefined-variable",
+			Message:  "Variable 'author' is not defined and has no default value",
+			Span:     reference,
+			Includes: []models.TemplateInclude{{
+				Snippet: "footer",
+				Span: models.TemplateSpan{
+					Start: models.TemplatePosition{Offset: 8, Line: 2, Column: 1},
+					End:   models.TemplatePosition{Offset: 18, Line: 2, Column: 11},
+				},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(response.Diagnostics, expected) {
+		t.Errorf("Expected diagnostics %+v, got %+v", expected, response.Diagnostics)
+	}
+}
+
+func TestTemplatePreviewTypedVariables(t *testing.T) {
+	repo := newMockTemplateRepository()
+	handler := NewTemplateHandler(repo)
+
+	preview := func(variables map[string]string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.TemplatePreviewRequest{
+			Content:   "Write {{count:int(1..5)=3}} {{tone:enum(formal|casual)}} ideas",
+			Variables: variables,
+		})
+		req := httptest.NewRequest("POST", "/api/template/preview", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		handler.PreviewTemplate(w, req)
+		return w
+	}
+
+	w := preview(map[string]string{"count": "7", "tone": "rude"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	var validation models.ValidationErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &validation); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(validation.Fields) != 2 || validation.Fields["variables.count"] == "" || validation.Fields["variables.tone"] == "" {
+		t.Errorf("Expected errors for count and tone, got %v", validation.Fields)
+	}
+
+	w = preview(map[string]string{"tone": "casual"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response models.TemplatePreviewResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.ResolvedContent != "Write 3 casual ideas" {
+		t.Errorf("Expected resolved content, got %q", response.ResolvedContent)
+	}
+	for _, v := range response.Variables {
+		switch v.Name {
+		case "count":
+			if v.Type != "int" || v.Min == nil || *v.Min != 1 || v.Max == nil || *v.Max != 5 {
+				t.Errorf("Expected count to be an int from 1 to 5, got %+v", v)
+			}
+		case "tone":
+			if v.Type != "enum" || !reflect.DeepEqual(v.Options, []string{"formal", "casual"}) {
+				t.Errorf("Expected tone to be an enum, got %+v", v)
+			}
+		}
+	}
+}
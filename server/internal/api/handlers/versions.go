@@ -0,0 +1,529 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/logging"
+	domainModels "github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// currentVersion is the version name that refers to the live database row instead of a commit
+const currentVersion = "current"
+
+// VersionHandlers contains handlers for git version history of prompts and snippets
+type VersionHandlers struct {
+	repo       repository.Repository
+	gitService git.GitService
+	logger     *slog.Logger
+}
+
+// NewVersionHandlers creates a new version handlers instance
+func NewVersionHandlers(repo repository.Repository, gitService git.GitService) *VersionHandlers {
+	return &VersionHandlers{
+		repo:       repo,
+		gitService: gitService,
+		logger:     logging.NewLogger("handlers.versions"),
+	}
+}
+
+// GetPromptHistory godoc
+// @Summary Get prompt version history
+// @Description Get the git commit history of a prompt, newest first
+// @Tags prompt-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Success 200 {object} models.CommitListResponse "List of commits"
+// @Failure 400 {object} models.ErrorResponse "Invalid prompt ID"
+// @Failure 404 {object} models.ErrorResponse "Prompt not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/history [get]
+func (h *VersionHandlers) GetPromptHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		models.WriteBadRequest(w, "Prompt ID is required")
+		return
+	}
+
+	if _, err := h.repo.Prompts().GetByID(r.Context(), id); err != nil {
+		h.logger.Debug("Prompt not found for history", "prompt_id", id, "error", err)
+		models.WriteNotFound(w, "Prompt")
+		return
+	}
+
+	commits, err := h.gitService.GetPromptHistory(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get prompt history", "prompt_id", id, "error", err)
+		models.WriteInternalError(w, "Failed to get prompt history")
+		return
+	}
+
+	writeCommitList(w, commits)
+}
+
+// GetPromptVersion godoc
+// @Summary Get a prompt at a specific version
+// @Description Get the prompt as it was stored in the given git commit, including its notes and outgoing links at that point
+// @Tags prompt-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param hash path string true "Commit hash or release label"
+// @Success 200 {object} models.PromptVersionResponse "Prompt at the requested version"
+// @Failure 400 {object} models.ErrorResponse "Invalid parameters"
+// @Failure 404 {object} models.ErrorResponse "Prompt version not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/versions/{hash} [get]
+func (h *VersionHandlers) GetPromptVersion(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	hash := r.PathValue("hash")
+	if id == "" || hash == "" {
+		models.WriteBadRequest(w, "Both prompt ID and commit hash are required")
+		return
+	}
+
+	commit, err := resolvePromptVersion(r.Context(), h.gitService, id, hash)
+	if err != nil {
+		h.logger.Error("Failed to resolve prompt release", "prompt_id", id, "version", hash, "error", err)
+		models.WriteInternalError(w, "Failed to get prompt version")
+		return
+	}
+
+	snapshot, err := h.gitService.GetPromptSnapshot(r.Context(), id, commit)
+	if err != nil {
+		h.logger.Debug("Prompt version not found", "prompt_id", id, "hash", hash, "error", err)
+		models.WriteNotFound(w, "Prompt version")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.FromPromptSnapshot(snapshot))
+}
+
+// RestorePromptVersion godoc
+// @Summary Restore a prompt to a previous version
+// @Description Restore a prompt to the content stored in the given git commit. The restore is recorded as a new commit.
+// @Tags prompt-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param hash path string true "Commit hash to restore"
+// @Success 200 {object} models.PromptResponse "Restored prompt"
+// @Failure 400 {object} models.ErrorResponse "Invalid parameters"
+// @Failure 404 {object} models.ErrorResponse "Prompt or version not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/versions/{hash}/restore [post]
+func (h *VersionHandlers) RestorePromptVersion(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	hash := r.PathValue("hash")
+	if id == "" || hash == "" {
+		models.WriteBadRequest(w, "Both prompt ID and commit hash are required")
+		return
+	}
+
+	if _, err := h.repo.Prompts().GetByID(r.Context(), id); err != nil {
+		models.WriteNotFound(w, "Prompt")
+		return
+	}
+
+	if _, err := h.gitService.GetPromptVersion(r.Context(), id, hash); err != nil {
+		h.logger.Debug("Prompt version not found for restore", "prompt_id", id, "hash", hash, "error", err)
+		models.WriteNotFound(w, "Prompt version")
+		return
+	}
+
+	prompt, err := h.repo.Prompts().Restore(r.Context(), id, hash)
+	if err != nil {
+		h.logger.Error("Failed to restore prompt", "prompt_id", id, "hash", hash, "error", err)
+		models.WriteInternalError(w, "Failed to restore prompt")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.FromPrompt(prompt))
+}
+
+// ListPromptReleases godoc
+// @Summary List releases of a prompt
+// @Description List the labels, such as "v1.2" or "production", that mark versions of a prompt
+// @Tags prompt-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Success 200 {object} models.ReleaseListResponse "List of releases, sorted by label"
+// @Failure 404 {object} models.ErrorResponse "Prompt not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/releases [get]
+func (h *VersionHandlers) ListPromptReleases(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.repo.Prompts().GetByID(r.Context(), id); err != nil {
+		models.WriteNotFound(w, "Prompt")
+		return
+	}
+
+	releases, err := h.gitService.ListPromptReleases(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to list prompt releases", "prompt_id", id, "error", err)
+		models.WriteInternalError(w, "Failed to list releases")
+		return
+	}
+
+	responses := models.FromPromptReleases(releases)
+	json.NewEncoder(w).Encode(models.ListResponse[*models.ReleaseResponse]{
+		Data:       responses,
+		Total:      len(responses),
+		Page:       1,
+		PageSize:   len(responses),
+		TotalPages: 1,
+	})
+}
+
+// CreatePromptRelease godoc
+// @Summary Label a version of a prompt
+// @Description Mark a version of a prompt with a label such as "v1.2" or "production", stored as a git tag. Without a commit the current version is labelled. An existing label is only moved when force is set.
+// @Tags prompt-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param release body models.CreateReleaseRequest true "Release to create"
+// @Success 201 {object} models.ReleaseResponse "Created release"
+// @Failure 400 {object} models.ErrorResponse "Invalid request data or label"
+// @Failure 404 {object} models.ErrorResponse "Prompt or version not found"
+// @Failure 409 {object} models.ErrorResponse "Label already exists"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/releases [post]
+func (h *VersionHandlers) CreatePromptRelease(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var req models.CreateReleaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		models.WriteBadRequest(w, "Invalid JSON body")
+		return
+	}
+	if req.Label == "" {
+		models.WriteValidationError(w, map[string]string{"label": "Label is required"})
+		return
+	}
+	if req.Label == currentVersion {
+		models.WriteBadRequest(w, "Label '"+currentVersion+"' is reserved for the live prompt")
+		return
+	}
+
+	if _, err := h.repo.Prompts().GetByID(r.Context(), id); err != nil {
+		models.WriteNotFound(w, "Prompt")
+		return
+	}
+	if req.Commit != "" {
+		if _, err := h.gitService.GetPromptVersion(r.Context(), id, req.Commit); err != nil {
+			h.logger.Debug("Prompt version not found for release", "prompt_id", id, "hash", req.Commit, "error", err)
+			models.WriteNotFound(w, "Prompt version")
+			return
+		}
+	}
+
+	release, err := h.gitService.CreatePromptRelease(r.Context(), id, req.Label, req.Commit, req.Force)
+	switch {
+	case errors.Is(err, git.ErrReleaseExists):
+		models.WriteError(w, http.StatusConflict, "Label "+req.Label+" already exists; set force to move it")
+		return
+	case errors.Is(err, git.ErrInvalidReleaseLabel):
+		models.WriteBadRequest(w, err.Error())
+		return
+	case err != nil:
+		h.logger.Error("Failed to create prompt release", "prompt_id", id, "label", req.Label, "error", err)
+		models.WriteInternalError(w, "Failed to create release")
+		return
+	}
+
+	h.logger.Info("Prompt release created", "prompt_id", id, "label", release.Label, "commit", release.Commit)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.FromPromptRelease(release))
+}
+
+// DeletePromptRelease godoc
+// @Summary Delete a release of a prompt
+// @Description Remove a label from a prompt. The labelled version stays in the history.
+// @Tags prompt-versions
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param label path string true "Release label"
+// @Success 204 "Release deleted"
+// @Failure 404 {object} models.ErrorResponse "Release not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /prompts/{id}/releases/{label} [delete]
+func (h *VersionHandlers) DeletePromptRelease(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	label := r.PathValue("label")
+
+	err := h.gitService.DeletePromptRelease(r.Context(), id, label)
+	if errors.Is(err, git.ErrReleaseNotFound) {
+		models.WriteNotFound(w, "Release")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to delete prompt release", "prompt_id", id, "label", label, "error", err)
+		models.WriteInternalError(w, "Failed to delete release")
+		return
+	}
+
+	h.logger.Info("Prompt release deleted", "prompt_id", id, "label", label)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DiffPromptVersions godoc
+// @Summary Diff two versions of a prompt
+// @Description Compare two versions of a prompt field by field, with a unified diff of the content. Either side may be a release label, or "current" to compare against the live prompt.
+// @Tags prompt-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Prompt ID" format(uuid)
+// @Param from query string true "Commit hash or release label of the older version, or current"
+// @Param to query string false "Commit hash or release label of the newer version, or current" default(current)
+// @Success 200 {object} models.DiffResponse "Diff between the two versions"
+// @Failure 400 {object} models.ErrorResponse "Invalid parameters"
+// @Failure 404 {object} models.ErrorResponse "Prompt or version not found"
+// @Router /prompts/{id}/diff [get]
+func (h *VersionHandlers) DiffPromptVersions(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		models.WriteBadRequest(w, "Prompt ID is required")
+		return
+	}
+
+	from, to, ok := parseDiffRange(w, r)
+	if !ok {
+		return
+	}
+
+	fromPrompt, err := h.promptAt(r.Context(), id, from)
+	if err != nil {
+		h.logger.Debug("Prompt version not found for diff", "prompt_id", id, "version", from, "error", err)
+		models.WriteNotFound(w, "Prompt version")
+		return
+	}
+
+	toPrompt, err := h.promptAt(r.Context(), id, to)
+	if err != nil {
+		h.logger.Debug("Prompt version not found for diff", "prompt_id", id, "version", to, "error", err)
+		models.WriteNotFound(w, "Prompt version")
+		return
+	}
+
+	diff := git.DiffPrompts(fromPrompt, toPrompt, from, to)
+	json.NewEncoder(w).Encode(models.FromGitDiff(from, to, diff))
+}
+
+// GetSnippetHistory godoc
+// @Summary Get snippet version history
+// @Description Get the git commit history of a snippet, newest first
+// @Tags snippet-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Snippet ID" format(uuid)
+// @Success 200 {object} models.CommitListResponse "List of commits"
+// @Failure 400 {object} models.ErrorResponse "Invalid snippet ID"
+// @Failure 404 {object} models.ErrorResponse "Snippet not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /snippets/{id}/history [get]
+func (h *VersionHandlers) GetSnippetHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		models.WriteBadRequest(w, "Snippet ID is required")
+		return
+	}
+
+	if _, err := h.repo.Snippets().GetByID(r.Context(), id); err != nil {
+		h.logger.Debug("Snippet not found for history", "snippet_id", id, "error", err)
+		models.WriteNotFound(w, "Snippet")
+		return
+	}
+
+	commits, err := h.gitService.GetSnippetHistory(r.Context(), id)
+	if err != nil {
+		h.logger.Error("Failed to get snippet history", "snippet_id", id, "error", err)
+		models.WriteInternalError(w, "Failed to get snippet history")
+		return
+	}
+
+	writeCommitList(w, commits)
+}
+
+// GetSnippetVersion godoc
+// @Summary Get a snippet at a specific version
+// @Description Get the snippet as it was stored in the given git commit
+// @Tags snippet-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Snippet ID" format(uuid)
+// @Param hash path string true "Commit hash"
+// @Success 200 {object} models.SnippetResponse "Snippet at the requested version"
+// @Failure 400 {object} models.ErrorResponse "Invalid parameters"
+// @Failure 404 {object} models.ErrorResponse "Snippet version not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /snippets/{id}/versions/{hash} [get]
+func (h *VersionHandlers) GetSnippetVersion(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	hash := r.PathValue("hash")
+	if id == "" || hash == "" {
+		models.WriteBadRequest(w, "Both snippet ID and commit hash are required")
+		return
+	}
+
+	snippet, err := h.gitService.GetSnippetVersion(r.Context(), id, hash)
+	if err != nil {
+		h.logger.Debug("Snippet version not found", "snippet_id", id, "hash", hash, "error", err)
+		models.WriteNotFound(w, "Snippet version")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.FromSnippet(snippet))
+}
+
+// RestoreSnippetVersion godoc
+// @Summary Restore a snippet to a previous version
+// @Description Restore a snippet to the content stored in the given git commit. The restore is recorded as a new commit.
+// @Tags snippet-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Snippet ID" format(uuid)
+// @Param hash path string true "Commit hash to restore"
+// @Success 200 {object} models.SnippetResponse "Restored snippet"
+// @Failure 400 {object} models.ErrorResponse "Invalid parameters"
+// @Failure 404 {object} models.ErrorResponse "Snippet or version not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /snippets/{id}/versions/{hash}/restore [post]
+func (h *VersionHandlers) RestoreSnippetVersion(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	hash := r.PathValue("hash")
+	if id == "" || hash == "" {
+		models.WriteBadRequest(w, "Both snippet ID and commit hash are required")
+		return
+	}
+
+	if _, err := h.repo.Snippets().GetByID(r.Context(), id); err != nil {
+		models.WriteNotFound(w, "Snippet")
+		return
+	}
+
+	if _, err := h.gitService.GetSnippetVersion(r.Context(), id, hash); err != nil {
+		h.logger.Debug("Snippet version not found for restore", "snippet_id", id, "hash", hash, "error", err)
+		models.WriteNotFound(w, "Snippet version")
+		return
+	}
+
+	snippet, err := h.repo.Snippets().Restore(r.Context(), id, hash)
+	if err != nil {
+		h.logger.Error("Failed to restore snippet", "snippet_id", id, "hash", hash, "error", err)
+		models.WriteInternalError(w, "Failed to restore snippet")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.FromSnippet(snippet))
+}
+
+// DiffSnippetVersions godoc
+// @Summary Diff two versions of a snippet
+// @Description Compare two versions of a snippet field by field, with a unified diff of the content. Either side may be "current" to compare against the live snippet.
+// @Tags snippet-versions
+// @Accept json
+// @Produce json
+// @Param id path string true "Snippet ID" format(uuid)
+// @Param from query string true "Commit hash of the older version, or current"
+// @Param to query string false "Commit hash of the newer version, or current" default(current)
+// @Success 200 {object} models.DiffResponse "Diff between the two versions"
+// @Failure 400 {object} models.ErrorResponse "Invalid parameters"
+// @Failure 404 {object} models.ErrorResponse "Snippet or version not found"
+// @Router /snippets/{id}/diff [get]
+func (h *VersionHandlers) DiffSnippetVersions(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		models.WriteBadRequest(w, "Snippet ID is required")
+		return
+	}
+
+	from, to, ok := parseDiffRange(w, r)
+	if !ok {
+		return
+	}
+
+	fromSnippet, err := h.snippetAt(r.Context(), id, from)
+	if err != nil {
+		h.logger.Debug("Snippet version not found for diff", "snippet_id", id, "version", from, "error", err)
+		models.WriteNotFound(w, "Snippet version")
+		return
+	}
+
+	toSnippet, err := h.snippetAt(r.Context(), id, to)
+	if err != nil {
+		h.logger.Debug("Snippet version not found for diff", "snippet_id", id, "version", to, "error", err)
+		models.WriteNotFound(w, "Snippet version")
+		return
+	}
+
+	diff := git.DiffSnippets(fromSnippet, toSnippet, from, to)
+	json.NewEncoder(w).Encode(models.FromGitDiff(from, to, diff))
+}
+
+// parseDiffRange reads the from and to query parameters, writing a bad request response if from is missing
+func parseDiffRange(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	if from == "" {
+		models.WriteBadRequest(w, "Query parameter 'from' is required")
+		return "", "", false
+	}
+	if to == "" {
+		to = currentVersion
+	}
+	return from, to, true
+}
+
+// promptAt loads a prompt at a commit or release, or the live prompt for "current"
+func (h *VersionHandlers) promptAt(ctx context.Context, id, version string) (*domainModels.Prompt, error) {
+	if version == currentVersion {
+		return h.repo.Prompts().GetByID(ctx, id)
+	}
+	commit, err := resolvePromptVersion(ctx, h.gitService, id, version)
+	if err != nil {
+		return nil, err
+	}
+	return h.gitService.GetPromptVersion(ctx, id, commit)
+}
+
+// resolvePromptVersion turns a release label of a prompt into the commit it points at.
+// Anything that is not a label is returned as is, to be read as a commit hash; a label
+// therefore takes precedence over a commit hash spelled the same way.
+func resolvePromptVersion(ctx context.Context, gitService git.GitService, id, version string) (string, error) {
+	commit, err := gitService.ResolvePromptRelease(ctx, id, version)
+	if errors.Is(err, git.ErrReleaseNotFound) {
+		return version, nil
+	}
+	return commit, err
+}
+
+// snippetAt loads a snippet at a commit, or the live snippet for "current"
+func (h *VersionHandlers) snippetAt(ctx context.Context, id, version string) (*domainModels.Snippet, error) {
+	if version == currentVersion {
+		return h.repo.Snippets().GetByID(ctx, id)
+	}
+	return h.gitService.GetSnippetVersion(ctx, id, version)
+}
+
+// writeCommitList writes commits as a list response
+func writeCommitList(w http.ResponseWriter, commits []git.GitCommit) {
+	responses := models.FromGitCommits(commits)
+
+	listResponse := models.ListResponse[*models.CommitResponse]{
+		Data:       responses,
+		Total:      len(responses),
+		Page:       1,
+		PageSize:   len(responses),
+		TotalPages: 1,
+	}
+
+	json.NewEncoder(w).Encode(listResponse)
+}
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/proompt/server/internal/api/models"
+	"github.com/dikkadev/proompt/server/internal/git"
+	domainModels "github.com/dikkadev/proompt/server/internal/models"
+)
+
+// mockGitService implements GitService for testing
+type mockGitService struct {
+	history  map[string][]git.GitCommit
+	versions map[string]*domainModels.Prompt
+	drafts   map[string]*git.PromptDraft
+	releases map[string]string
+}
+
+func newMockGitService() *mockGitService {
+	return &mockGitService{
+		history:  make(map[string][]git.GitCommit),
+		versions: make(map[string]*domainModels.Prompt),
+		drafts:   make(map[string]*git.PromptDraft),
+		releases: make(map[string]string),
+	}
+}
+
+func (m *mockGitService) InitializeRepo(ctx context.Context) error {
+	return nil
+}
+
+func (m *mockGitService) CreatePromptBranch(ctx context.Context, prompt *domainModels.Prompt, userNote string) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockGitService) UpdatePromptBranch(ctx context.Context, prompt *domainModels.Prompt, userNote string) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockGitService) DeletePromptBranch(ctx context.Context, promptID string) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockGitService) RestorePromptBranch(ctx context.Context, prompt *domainModels.Prompt, commitHash string, userNote string) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockGitService) UpdatePromptTags(ctx context.Context, prompt *domainModels.Prompt, added, removed []string) error {
+	return nil
+}
+
+func (m *mockGitService) CreateSnippetBranch(ctx context.Context, snippet *domainModels.Snippet, userNote string) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockGitService) UpdateSnippetBranch(ctx context.Context, snippet *domainModels.Snippet, userNote string) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockGitService) DeleteSnippetBranch(ctx context.Context, snippetID string) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockGitService) RestoreSnippetBranch(ctx context.Context, snippet *domainModels.Snippet, commitHash string, userNote string) error {
+	return nil // Not implemented for tests
+}
+
+func (m *mockGitService) UpdateSnippetTags(ctx context.Context, snippet *domainModels.Snippet, added, removed []string) error {
+	return nil
+}
+
+func (m *mockGitService) GetPromptHistory(ctx context.Context, promptID string) ([]git.GitCommit, error) {
+	commits, exists := m.history[promptID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return commits, nil
+}
+
+func (m *mockGitService) GetSnippetHistory(ctx context.Context, snippetID string) ([]git.GitCommit, error) {
+	return nil, ErrNotFound // Not implemented for tests
+}
+
+func (m *mockGitService) GetPromptVersion(ctx context.Context, promptID string, commitHash string) (*domainModels.Prompt, error) {
+	prompt, exists := m.versions[commitHash]
+	if !exists || prompt.ID != promptID {
+		return nil, ErrNotFound
+	}
+	return prompt, nil
+}
+
+func (m *mockGitService) UpdatePromptNotesAndLinks(ctx context.Context, promptID string, notes []*domainModels.Note, links []*domainModels.PromptLink, message string) error {
+	return nil
+}
+
+func (m *mockGitService) CreatePromptDraft(ctx context.Context, promptID, name string) (*git.PromptDraft, error) {
+	if _, exists := m.drafts[git.DraftBranch(promptID, name)]; exists {
+		return nil, git.ErrDraftExists
+	}
+	draft := &git.PromptDraft{Name: name, Prompt: &domainModels.Prompt{ID: promptID}}
+	m.drafts[git.DraftBranch(promptID, name)] = draft
+	return draft, nil
+}
+
+func (m *mockGitService) GetPromptDraft(ctx context.Context, promptID, name string) (*git.PromptDraft, error) {
+	draft, exists := m.drafts[git.DraftBranch(promptID, name)]
+	if !exists {
+		return nil, git.ErrDraftNotFound
+	}
+	copied := *draft
+	prompt := *draft.Prompt
+	copied.Prompt = &prompt
+	return &copied, nil
+}
+
+func (m *mockGitService) ListPromptDrafts(ctx context.Context, promptID string) ([]git.PromptDraft, error) {
+	drafts := []git.PromptDraft{}
+	for _, draft := range m.drafts {
+		if draft.Prompt.ID == promptID {
+			drafts = append(drafts, *draft)
+		}
+	}
+	return drafts, nil
+}
+
+func (m *mockGitService) UpdatePromptDraft(ctx context.Context, prompt *domainModels.Prompt, name string, userNote string) error {
+	draft, exists := m.drafts[git.DraftBranch(prompt.ID, name)]
+	if !exists {
+		return git.ErrDraftNotFound
+	}
+	updated := *prompt
+	draft.Prompt = &updated
+	return nil
+}
+
+func (m *mockGitService) DeletePromptDraft(ctx context.Context, promptID, name string) error {
+	if _, exists := m.drafts[git.DraftBranch(promptID, name)]; !exists {
+		return git.ErrDraftNotFound
+	}
+	delete(m.drafts, git.DraftBranch(promptID, name))
+	return nil
+}
+
+func (m *mockGitService) PromotePromptDraft(ctx context.Context, prompt *domainModels.Prompt, draft *git.PromptDraft, userNote string) error {
+	return nil
+}
+
+func (m *mockGitService) CreatePromptRelease(ctx context.Context, promptID, label, commitHash string, force bool) (*git.PromptRelease, error) {
+	if _, exists := m.releases[git.ReleaseTag(promptID, label)]; exists && !force {
+		return nil, git.ErrReleaseExists
+	}
+	m.releases[git.ReleaseTag(promptID, label)] = commitHash
+	return &git.PromptRelease{Label: label, Commit: commitHash}, nil
+}
+
+func (m *mockGitService) ListPromptReleases(ctx context.Context, promptID string) ([]git.PromptRelease, error) {
+	return []git.PromptRelease{}, nil // Not implemented for tests
+}
+
+func (m *mockGitService) DeletePromptRelease(ctx context.Context, promptID, label string) error {
+	if _, exists := m.releases[git.ReleaseTag(promptID, label)]; !exists {
+		return git.ErrReleaseNotFound
+	}
+	delete(m.releases, git.ReleaseTag(promptID, label))
+	return nil
+}
+
+func (m *mockGitService) ResolvePromptRelease(ctx context.Context, promptID, label string) (string, error) {
+	commit, exists := m.releases[git.ReleaseTag(promptID, label)]
+	if !exists {
+		return "", git.ErrReleaseNotFound
+	}
+	return commit, nil
+}
+
+func (m *mockGitService) GetPromptSnapshot(ctx context.Context, promptID string, commitHash string) (*git.PromptSnapshot, error) {
+	prompt, err := m.GetPromptVersion(ctx, promptID, commitHash)
+	if err != nil {
+		return nil, err
+	}
+	return &git.PromptSnapshot{Prompt: prompt}, nil
+}
+
+func (m *mockGitService) GetSnippetVersion(ctx context.Context, snippetID string, commitHash string) (*domainModels.Snippet, error) {
+	return nil, ErrNotFound // Not implemented for tests
+}
+
+func (m *mockGitService) ListPromptIDs(ctx context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *mockGitService) ListSnippetIDs(ctx context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *mockGitService) BranchHead(ctx context.Context, branch string) (string, error) {
+	return "", nil
+}
+
+func (m *mockGitService) ResetBranch(ctx context.Context, branch string, expected string, commitHash string) error {
+	return nil
+}
+
+func (m *mockGitService) PullRemote(ctx context.Context, remote string) (*git.PullResult, error) {
+	return nil, git.ErrUnknownRemote
+}
+
+func (m *mockGitService) PushRemote(ctx context.Context, remote string, branches []string) error {
+	return git.ErrUnknownRemote
+}
+
+func (m *mockGitService) ValidateRepo(ctx context.Context) error {
+	return nil
+}
+
+func TestGetPromptHistory(t *testing.T) {
+	repo := newMockRepository()
+	gitService := newMockGitService()
+	handlers := NewVersionHandlers(repo, gitService)
+
+	repo.prompts.Create(context.Background(), &domainModels.Prompt{
+		ID:      "test-id",
+		Title:   "Test Prompt",
+		Content: "Test content",
+		Type:    domainModels.PromptTypeUser,
+	})
+	gitService.history["test-id"] = []git.GitCommit{
+		{Hash: "bbbb", Message: "Update: Test Prompt", Timestamp: time.Now()},
+		{Hash: "aaaa", Message: "Create: Test Prompt", Timestamp: time.Now().Add(-time.Hour)},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/prompts/test-id/history", nil)
+	req.SetPathValue("id", "test-id")
+	w := httptest.NewRecorder()
+
+	handlers.GetPromptHistory(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response models.ListResponse[*models.CommitResponse]
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response.Data) != 2 {
+		t.Fatalf("Expected 2 commits, got %d", len(response.Data))
+	}
+	if response.Data[0].Hash != "bbbb" {
+		t.Errorf("Expected newest commit first, got %s", response.Data[0].Hash)
+	}
+}
+
+func TestGetPromptHistoryNotFound(t *testing.T) {
+	handlers := NewVersionHandlers(newMockRepository(), newMockGitService())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/prompts/nonexistent/history", nil)
+	req.SetPathValue("id", "nonexistent")
+	w := httptest.NewRecorder()
+
+	handlers.GetPromptHistory(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetPromptVersion(t *testing.T) {
+	gitService := newMockGitService()
+	handlers := NewVersionHandlers(newMockRepository(), gitService)
+
+	gitService.versions["aaaa"] = &domainModels.Prompt{
+		ID:      "test-id",
+		Title:   "Old Title",
+		Content: "Old content",
+		Type:    domainModels.PromptTypeUser,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/prompts/test-id/versions/aaaa", nil)
+	req.SetPathValue("id", "test-id")
+	req.SetPathValue("hash", "aaaa")
+	w := httptest.NewRecorder()
+
+	handlers.GetPromptVersion(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response models.PromptResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Title != "Old Title" {
+		t.Errorf("Expected title 'Old Title', got %s", response.Title)
+	}
+
+	// A commit belonging to another prompt must not be served
+	req = httptest.NewRequest(http.MethodGet, "/api/prompts/other-id/versions/aaaa", nil)
+	req.SetPathValue("id", "other-id")
+	req.SetPathValue("hash", "aaaa")
+	w = httptest.NewRecorder()
+
+	handlers.GetPromptVersion(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestDiffPromptVersions(t *testing.T) {
+	repo := newMockRepository()
+	gitService := newMockGitService()
+	handlers := NewVersionHandlers(repo, gitService)
+
+	repo.prompts.Create(context.Background(), &domainModels.Prompt{
+		ID:      "test-id",
+		Title:   "New Title",
+		Content: "Hello\nWorld",
+		Type:    domainModels.PromptTypeUser,
+	})
+	gitService.versions["aaaa"] = &domainModels.Prompt{
+		ID:      "test-id",
+		Title:   "Old Title",
+		Content: "Hello\nThere",
+		Type:    domainModels.PromptTypeUser,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/prompts/test-id/diff?from=aaaa", nil)
+	req.SetPathValue("id", "test-id")
+	w := httptest.NewRecorder()
+
+	handlers.DiffPromptVersions(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response models.DiffResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.To != "current" {
+		t.Errorf("Expected 'to' to default to current, got %s", response.To)
+	}
+	if len(response.Fields) != 1 || response.Fields[0].Field != "title" {
+		t.Errorf("Expected a single title change, got %+v", response.Fields)
+	}
+	if response.Additions != 1 || response.Deletions != 1 {
+		t.Errorf("Expected 1 addition and 1 deletion, got %d and %d", response.Additions, response.Deletions)
+	}
+
+	// Missing 'from' is rejected
+	req = httptest.NewRequest(http.MethodGet, "/api/prompts/test-id/diff", nil)
+	req.SetPathValue("id", "test-id")
+	w = httptest.NewRecorder()
+
+	handlers.DiffPromptVersions(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestPromptReleases(t *testing.T) {
+	repo := newMockRepository()
+	gitService := newMockGitService()
+	versionHandlers := NewVersionHandlers(repo, gitService)
+	promptHandlers := NewPromptHandlers(repo, gitService)
+
+	repo.prompts.Create(context.Background(), &domainModels.Prompt{
+		ID:      "test-id",
+		Title:   "New Title",
+		Content: "New content",
+		Type:    domainModels.PromptTypeUser,
+	})
+	gitService.versions["aaaa"] = &domainModels.Prompt{
+		ID:      "test-id",
+		Title:   "Old Title",
+		Content: "Old content",
+		Type:    domainModels.PromptTypeUser,
+	}
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/prompts/test-id/releases", bytes.NewBufferString(body))
+		req.SetPathValue("id", "test-id")
+		w := httptest.NewRecorder()
+		versionHandlers.CreatePromptRelease(w, req)
+		return w
+	}
+	get := func(version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/prompts/test-id?version="+version, nil)
+		req.SetPathValue("id", "test-id")
+		w := httptest.NewRecorder()
+		promptHandlers.GetPrompt(w, req)
+		return w
+	}
+
+	if w := create(`{"label": "production", "commit": "aaaa"}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if w := create(`{"label": "production", "commit": "aaaa"}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for an existing label, got %d", http.StatusConflict, w.Code)
+	}
+	if w := create(`{"label": "production", "commit": "aaaa", "force": true}`); w.Code != http.StatusCreated {
+		t.Errorf("Expected status %d when moving a label, got %d", http.StatusCreated, w.Code)
+	}
+	if w := create(`{"label": "current"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a reserved label, got %d", http.StatusBadRequest, w.Code)
+	}
+	if w := create(`{"label": "v2", "commit": "ffff"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown commit, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// The prompt is served at the label, at a commit, or live
+	for version, title := range map[string]string{"production": "Old Title", "aaaa": "Old Title", "current": "New Title"} {
+		w := get(version)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d for version %s, got %d", http.StatusOK, version, w.Code)
+		}
+		var response models.PromptResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Title != title {
+			t.Errorf("Expected title %q at version %s, got %q", title, version, response.Title)
+		}
+		if live := version == "current"; (w.Header().Get("ETag") != "") != live {
+			t.Errorf("Expected an ETag only for the live prompt, got %q at version %s", w.Header().Get("ETag"), version)
+		}
+	}
+	if w := get("staging"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown label, got %d", http.StatusNotFound, w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/prompts/test-id/releases/production", nil)
+	req.SetPathValue("id", "test-id")
+	req.SetPathValue("label", "production")
+	w := httptest.NewRecorder()
+	versionHandlers.DeletePromptRelease(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if w := get("production"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d after deleting the label, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -30,6 +30,45 @@ type UpdatePromptRequest struct {
 	Notes                  *string        `json:"notes,omitempty"`
 }
 
+// CreateDraftRequest represents the request body for creating a draft of a prompt
+type CreateDraftRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=64"`
+}
+
+// UpdateDraftRequest represents the request body for updating a draft. Only fields
+// versioned in git can be changed on a draft.
+type UpdateDraftRequest struct {
+	Title                  *string        `json:"title,omitempty" validate:"omitempty,min=1,max=255"`
+	Content                *string        `json:"content,omitempty" validate:"omitempty,min=1"`
+	Type                   *string        `json:"type,omitempty" validate:"omitempty,oneof=system user image video"`
+	UseCase                *string        `json:"use_case,omitempty" validate:"omitempty,min=1,max=100"`
+	ModelCompatibilityTags []string       `json:"model_compatibility_tags,omitempty"`
+	OtherParameters        map[string]any `json:"other_parameters,omitempty"`
+	Tags                   []string       `json:"tags,omitempty"`
+	// Message is recorded in the commit of the draft
+	Message string `json:"message,omitempty"`
+}
+
+// DraftPreviewRequest represents the request body for previewing a draft
+type DraftPreviewRequest struct {
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// PromoteDraftRequest represents the request body for promoting a draft into its prompt
+type PromoteDraftRequest struct {
+	// Message is recorded in the merge commit
+	Message string `json:"message,omitempty"`
+}
+
+// CreateReleaseRequest represents the request body for labelling a version of a prompt
+type CreateReleaseRequest struct {
+	Label string `json:"label" validate:"required,min=1,max=64"`
+	// Commit is the version to label; the current version when empty
+	Commit string `json:"commit,omitempty"`
+	// Force moves the label if it already exists
+	Force bool `json:"force,omitempty"`
+}
+
 // CreateSnippetRequest represents the request body for creating a snippet
 type CreateSnippetRequest struct {
 	Title       string `json:"title" validate:"required,min=1,max=255"`
@@ -76,6 +115,40 @@ func (r *CreatePromptRequest) ToPrompt() *models.Prompt {
 	}
 }
 
+// ApplyTo sets the fields present in the request on prompt and returns their names
+func (r *UpdateDraftRequest) ApplyTo(prompt *models.Prompt) []string {
+	var updated []string
+	if r.Title != nil {
+		prompt.Title = *r.Title
+		updated = append(updated, "title")
+	}
+	if r.Content != nil {
+		prompt.Content = *r.Content
+		updated = append(updated, "content")
+	}
+	if r.Type != nil {
+		prompt.Type = models.PromptType(*r.Type)
+		updated = append(updated, "type")
+	}
+	if r.UseCase != nil {
+		prompt.UseCase = r.UseCase
+		updated = append(updated, "use_case")
+	}
+	if r.ModelCompatibilityTags != nil {
+		prompt.ModelCompatibilityTags = models.StringSlice(r.ModelCompatibilityTags)
+		updated = append(updated, "model_compatibility_tags")
+	}
+	if r.OtherParameters != nil {
+		prompt.OtherParameters = models.JSONMap(r.OtherParameters)
+		updated = append(updated, "other_parameters")
+	}
+	if r.Tags != nil {
+		prompt.Tags = r.Tags
+		updated = append(updated, "tags")
+	}
+	return updated
+}
+
 // ToSnippet converts CreateSnippetRequest to domain model
 func (r *CreateSnippetRequest) ToSnippet() *models.Snippet {
 	var description *string
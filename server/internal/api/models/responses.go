@@ -3,7 +3,9 @@ package models
 import (
 	"time"
 
+	"github.com/dikkadev/proompt/server/internal/git"
 	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/reconcile"
 )
 
 // PromptResponse represents a prompt in API responses
@@ -19,6 +21,7 @@ type PromptResponse struct {
 	CreatedAt              time.Time      `json:"created_at"`
 	UpdatedAt              time.Time      `json:"updated_at"`
 	GitRef                 *string        `json:"git_ref"`
+	Tags                   []string       `json:"tags,omitempty"`
 }
 
 // SnippetResponse represents a snippet in API responses
@@ -30,6 +33,7 @@ type SnippetResponse struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	GitRef      *string   `json:"git_ref"`
+	Tags        []string  `json:"tags,omitempty"`
 }
 
 // NoteResponse represents a note in API responses
@@ -42,13 +46,15 @@ type NoteResponse struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// ListResponse represents a paginated list response
+// ListResponse represents a paginated list response.
+// NextCursor is set when more items may follow; Page is 0 for pages fetched by cursor.
 type ListResponse[T any] struct {
-	Data       []T `json:"data"`
-	Total      int `json:"total"`
-	Page       int `json:"page"`
-	PageSize   int `json:"page_size"`
-	TotalPages int `json:"total_pages"`
+	Data       []T     `json:"data"`
+	Total      int     `json:"total"`
+	Page       int     `json:"page"`
+	PageSize   int     `json:"page_size"`
+	TotalPages int     `json:"total_pages"`
+	NextCursor *string `json:"next_cursor,omitempty"`
 }
 
 // HealthResponse represents the health check response
@@ -82,6 +88,7 @@ func FromPrompt(p *models.Prompt) *PromptResponse {
 		CreatedAt:              p.CreatedAt,
 		UpdatedAt:              p.UpdatedAt,
 		GitRef:                 p.GitRef,
+		Tags:                   p.Tags,
 	}
 }
 
@@ -95,6 +102,7 @@ func FromSnippet(s *models.Snippet) *SnippetResponse {
 		CreatedAt:   s.CreatedAt,
 		UpdatedAt:   s.UpdatedAt,
 		GitRef:      s.GitRef,
+		Tags:        s.Tags,
 	}
 }
 
@@ -137,19 +145,92 @@ func FromNotes(notes []*models.Note) []*NoteResponse {
 	return responses
 }
 
+// SearchResultResponse represents a full-text search hit in API responses
+type SearchResultResponse struct {
+	Type     string  `json:"type"`
+	ID       string  `json:"id"`
+	PromptID *string `json:"prompt_id,omitempty"`
+	Title    string  `json:"title"`
+	Snippet  string  `json:"snippet"`
+	Score    float64 `json:"score"`
+}
+
+// FromSearchResults converts slice of search results to API responses
+func FromSearchResults(results []*models.SearchResult) []*SearchResultResponse {
+	responses := make([]*SearchResultResponse, len(results))
+	for i, res := range results {
+		responses[i] = &SearchResultResponse{
+			Type:     string(res.Type),
+			ID:       res.ID,
+			PromptID: res.PromptID,
+			Title:    res.Title,
+			Snippet:  res.Snippet,
+			Score:    res.Score,
+		}
+	}
+	return responses
+}
+
 // TemplateVariable represents a variable in template responses
 type TemplateVariable struct {
-	Name         string `json:"name"`
-	DefaultValue string `json:"default_value,omitempty"`
-	HasDefault   bool   `json:"has_default"`
-	Status       string `json:"status"` // "provided", "default", "missing"
+	Name         string   `json:"name"`
+	DefaultValue string   `json:"default_value,omitempty"`
+	HasDefault   bool     `json:"has_default"`
+	Status       string   `json:"status"`              // "provided", "default", "missing", "argument"
+	Condition    bool     `json:"condition,omitempty"` // Only tested by {{#if}} blocks
+	List         bool     `json:"list,omitempty"`      // Listed by an {{#each}} block
+	Type         string   `json:"type"`                // "string", "int", "number", "bool", "enum", "list"
+	Options      []string `json:"options,omitempty"`   // The allowed values of an enum
+	Min          *float64 `json:"min,omitempty"`       // The bounds of an int or number
+	Max          *float64 `json:"max,omitempty"`
+}
+
+// TemplateBlock represents an {{#if}} or {{#each}} block in template responses
+type TemplateBlock struct {
+	Kind     string          `json:"kind"` // "if", "each"
+	Variable string          `json:"variable"`
+	HasElse  bool            `json:"has_else"`
+	Active   bool            `json:"active"` // Whether the body renders with the given variables
+	Items    int             `json:"items,omitempty"`
+	Blocks   []TemplateBlock `json:"blocks,omitempty"`
+}
+
+// TemplatePosition represents a location in template content. Line and column start at 1,
+// and the column counts characters.
+type TemplatePosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// TemplateSpan represents a range of template content
+type TemplateSpan struct {
+	Start TemplatePosition `json:"start"`
+	End   TemplatePosition `json:"end"`
+}
+
+// TemplateInclude represents a snippet on the way to a problem inside nested snippets
+type TemplateInclude struct {
+	Snippet string       `json:"snippet"`
+	Span    TemplateSpan `json:"span"` // In the snippet's content
+}
+
+// TemplateDiagnostic represents a problem found in a template
+type TemplateDiagnostic struct {
+	Severity string            `json:"severity"` // "error", "warning"
+	Code     string            `json:"code"`
+	Message  string            `json:"message"`
+	Span     TemplateSpan      `json:"span"` // In the request content
+	Includes []TemplateInclude `json:"includes,omitempty"`
 }
 
 // TemplatePreviewResponse represents the response for template preview
 type TemplatePreviewResponse struct {
-	ResolvedContent string             `json:"resolved_content"`
-	Variables       []TemplateVariable `json:"variables"`
-	Warnings        []string           `json:"warnings"`
+	ResolvedContent string               `json:"resolved_content"`
+	Variables       []TemplateVariable   `json:"variables"`
+	Blocks          []TemplateBlock      `json:"blocks,omitempty"`
+	Warnings        []string             `json:"warnings"`
+	Diagnostics     []TemplateDiagnostic `json:"diagnostics"`
 }
 
 // PromptLinkResponse represents a prompt link in API responses
@@ -179,6 +260,280 @@ func FromPromptLinks(links []*models.PromptLink) []*PromptLinkResponse {
 	return responses
 }
 
+// PromptVersionResponse represents a prompt as stored in a git commit, together with
+// its notes and outgoing links. Notes and links are omitted for versions recorded
+// before they were stored in git.
+type PromptVersionResponse struct {
+	*PromptResponse
+	Notes []*NoteResponse       `json:"notes,omitempty"`
+	Links []*PromptLinkResponse `json:"links,omitempty"`
+}
+
+// FromPromptSnapshot converts a git prompt snapshot to API response
+func FromPromptSnapshot(s *git.PromptSnapshot) *PromptVersionResponse {
+	response := &PromptVersionResponse{PromptResponse: FromPrompt(s.Prompt)}
+	if s.Notes != nil {
+		response.Notes = FromNotes(s.Notes)
+	}
+	if s.Links != nil {
+		response.Links = FromPromptLinks(s.Links)
+	}
+	return response
+}
+
+// CommitResponse represents a git commit in version history responses
+type CommitResponse struct {
+	Hash      string    `json:"hash"`
+	Message   string    `json:"message"`
+	Author    string    `json:"author"`
+	Email     string    `json:"email"`
+	Timestamp time.Time `json:"timestamp"`
+	Body      string    `json:"body,omitempty"`
+}
+
+// FromGitCommit converts a git commit to API response
+func FromGitCommit(c git.GitCommit) *CommitResponse {
+	return &CommitResponse{
+		Hash:      c.Hash,
+		Message:   c.Message,
+		Author:    c.Author,
+		Email:     c.Email,
+		Timestamp: c.Timestamp,
+		Body:      c.Body,
+	}
+}
+
+// FromGitCommits converts slice of git commits to API responses
+func FromGitCommits(commits []git.GitCommit) []*CommitResponse {
+	responses := make([]*CommitResponse, len(commits))
+	for i, c := range commits {
+		responses[i] = FromGitCommit(c)
+	}
+	return responses
+}
+
+// FieldChangeResponse represents a single changed field in a diff
+type FieldChangeResponse struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// DiffResponse represents a diff between two versions of a prompt or snippet
+type DiffResponse struct {
+	From      string                `json:"from"`
+	To        string                `json:"to"`
+	Changed   bool                  `json:"changed"`
+	Fields    []FieldChangeResponse `json:"fields"`
+	Content   string                `json:"content_diff"`
+	Additions int                   `json:"additions"`
+	Deletions int                   `json:"deletions"`
+}
+
+// FromGitDiff converts a git diff to API response
+func FromGitDiff(from, to string, d *git.Diff) *DiffResponse {
+	fields := make([]FieldChangeResponse, len(d.Fields))
+	for i, change := range d.Fields {
+		fields[i] = FieldChangeResponse{
+			Field: change.Field,
+			Old:   change.Old,
+			New:   change.New,
+		}
+	}
+
+	return &DiffResponse{
+		From:      from,
+		To:        to,
+		Changed:   d.HasChanges(),
+		Fields:    fields,
+		Content:   d.Content.Unified,
+		Additions: d.Content.Additions,
+		Deletions: d.Content.Deletions,
+	}
+}
+
+// FsckIssueResponse represents a disagreement between the database and git
+type FsckIssueResponse struct {
+	Kind     string   `json:"kind"`
+	ID       string   `json:"id"`
+	Title    string   `json:"title"`
+	Problem  string   `json:"problem"`
+	Fields   []string `json:"fields,omitempty"`
+	Repaired bool     `json:"repaired"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// FsckReportResponse represents the result of a consistency check or repair
+type FsckReportResponse struct {
+	Clean    bool                 `json:"clean"`
+	Prompts  int                  `json:"prompts"`
+	Snippets int                  `json:"snippets"`
+	Branches int                  `json:"branches"`
+	Issues   []*FsckIssueResponse `json:"issues"`
+}
+
+// FromFsckReport converts a consistency report to API response
+func FromFsckReport(r *reconcile.Report) *FsckReportResponse {
+	issues := make([]*FsckIssueResponse, len(r.Issues))
+	for i, issue := range r.Issues {
+		issues[i] = &FsckIssueResponse{
+			Kind:     string(issue.Kind),
+			ID:       issue.ID,
+			Title:    issue.Title,
+			Problem:  string(issue.Problem),
+			Fields:   issue.Fields,
+			Repaired: issue.Repaired,
+			Error:    issue.Error,
+		}
+	}
+
+	return &FsckReportResponse{
+		Clean:    r.Clean(),
+		Prompts:  r.Prompts,
+		Snippets: r.Snippets,
+		Branches: r.Branches,
+		Issues:   issues,
+	}
+}
+
+// SyncConflictResponse represents a branch that changed both locally and on the remote
+type SyncConflictResponse struct {
+	Branch string `json:"branch"`
+	Local  string `json:"local,omitempty"`
+	Remote string `json:"remote,omitempty"`
+}
+
+// SyncResponse represents the result of syncing with a git remote
+type SyncResponse struct {
+	Remote    string                  `json:"remote"`
+	Pulled    []string                `json:"pulled"`
+	Pushed    []string                `json:"pushed"`
+	Deleted   []string                `json:"deleted"`
+	Conflicts []*SyncConflictResponse `json:"conflicts"`
+	UpToDate  int                     `json:"up_to_date"`
+}
+
+// FromSyncResult converts a sync result to API response
+func FromSyncResult(r *reconcile.SyncResult) *SyncResponse {
+	conflicts := make([]*SyncConflictResponse, len(r.Conflicts))
+	for i, conflict := range r.Conflicts {
+		conflicts[i] = &SyncConflictResponse{
+			Branch: conflict.Branch,
+			Local:  conflict.Local,
+			Remote: conflict.Remote,
+		}
+	}
+
+	return &SyncResponse{
+		Remote:    r.Remote,
+		Pulled:    r.Pulled,
+		Pushed:    r.Pushed,
+		Deleted:   r.Deleted,
+		Conflicts: conflicts,
+		UpToDate:  r.UpToDate,
+	}
+}
+
+// ContentMergeResponse represents a three-way merge of the content of a conflicting update.
+// Base is the commit the client's version was read from.
+type ContentMergeResponse struct {
+	Base      string `json:"base"`
+	Content   string `json:"content"`
+	Conflicts int    `json:"conflicts"`
+	Clean     bool   `json:"clean"`
+}
+
+// FromContentMerge converts a content merge against the given base commit to API response
+func FromContentMerge(base string, m *git.ContentMerge) *ContentMergeResponse {
+	return &ContentMergeResponse{
+		Base:      base,
+		Content:   m.Content,
+		Conflicts: m.Conflicts,
+		Clean:     m.Clean(),
+	}
+}
+
+// PromptConflictResponse is returned when an update was based on an outdated version of a
+// prompt. Current is the stored prompt, Yours the rejected update applied to it.
+type PromptConflictResponse struct {
+	ErrorResponse
+	Current *PromptResponse       `json:"current"`
+	Yours   *PromptResponse       `json:"yours"`
+	Merge   *ContentMergeResponse `json:"merge,omitempty"`
+}
+
+// SnippetConflictResponse is returned when an update was based on an outdated version of a
+// snippet. Current is the stored snippet, Yours the rejected update applied to it.
+type SnippetConflictResponse struct {
+	ErrorResponse
+	Current *SnippetResponse      `json:"current"`
+	Yours   *SnippetResponse      `json:"yours"`
+	Merge   *ContentMergeResponse `json:"merge,omitempty"`
+}
+
+// DraftResponse represents a draft of a prompt. Base is the commit of the prompt's branch
+// the draft's changes are relative to, Head the latest commit on the draft.
+type DraftResponse struct {
+	Name   string          `json:"name"`
+	Base   string          `json:"base"`
+	Head   string          `json:"head"`
+	Prompt *PromptResponse `json:"prompt"`
+}
+
+// FromPromptDraft converts a git prompt draft to API response
+func FromPromptDraft(d *git.PromptDraft) *DraftResponse {
+	return &DraftResponse{
+		Name:   d.Name,
+		Base:   d.Base,
+		Head:   d.Head,
+		Prompt: FromPrompt(d.Prompt),
+	}
+}
+
+// FromPromptDrafts converts slice of git prompt drafts to API responses
+func FromPromptDrafts(drafts []git.PromptDraft) []*DraftResponse {
+	responses := make([]*DraftResponse, len(drafts))
+	for i := range drafts {
+		responses[i] = FromPromptDraft(&drafts[i])
+	}
+	return responses
+}
+
+// DraftConflictResponse is returned when a draft cannot be promoted because its content
+// conflicts with changes made to the prompt since the draft was forked
+type DraftConflictResponse struct {
+	ErrorResponse
+	Current *PromptResponse       `json:"current"`
+	Draft   *DraftResponse        `json:"draft"`
+	Merge   *ContentMergeResponse `json:"merge"`
+}
+
+// ReleaseResponse represents a label on a version of a prompt. Timestamp is when the
+// labelled version was committed.
+type ReleaseResponse struct {
+	Label     string    `json:"label"`
+	Commit    string    `json:"commit"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// FromPromptRelease converts a git prompt release to API response
+func FromPromptRelease(r *git.PromptRelease) *ReleaseResponse {
+	return &ReleaseResponse{
+		Label:     r.Label,
+		Commit:    r.Commit,
+		Timestamp: r.Timestamp,
+	}
+}
+
+// FromPromptReleases converts slice of git prompt releases to API responses
+func FromPromptReleases(releases []git.PromptRelease) []*ReleaseResponse {
+	responses := make([]*ReleaseResponse, len(releases))
+	for i := range releases {
+		responses[i] = FromPromptRelease(&releases[i])
+	}
+	return responses
+}
+
 // TagResponse represents a tag in API responses
 type TagResponse struct {
 	Name      string    `json:"name"`
@@ -196,6 +551,16 @@ type PromptListResponse struct {
 	Page       int              `json:"page"`
 	PageSize   int              `json:"page_size"`
 	TotalPages int              `json:"total_pages"`
+	NextCursor *string          `json:"next_cursor,omitempty"`
+}
+
+// SearchListResponse represents a list of search results
+type SearchListResponse struct {
+	Data       []SearchResultResponse `json:"data"`
+	Total      int                    `json:"total"`
+	Page       int                    `json:"page"`
+	PageSize   int                    `json:"page_size"`
+	TotalPages int                    `json:"total_pages"`
 }
 
 // SnippetListResponse represents a list of snippets
@@ -205,6 +570,7 @@ type SnippetListResponse struct {
 	Page       int               `json:"page"`
 	PageSize   int               `json:"page_size"`
 	TotalPages int               `json:"total_pages"`
+	NextCursor *string           `json:"next_cursor,omitempty"`
 }
 
 // NoteListResponse represents a list of notes
@@ -233,3 +599,30 @@ type PromptLinkListResponse struct {
 	PageSize   int                  `json:"page_size"`
 	TotalPages int                  `json:"total_pages"`
 }
+
+// CommitListResponse represents a list of commits
+type CommitListResponse struct {
+	Data       []CommitResponse `json:"data"`
+	Total      int              `json:"total"`
+	Page       int              `json:"page"`
+	PageSize   int              `json:"page_size"`
+	TotalPages int              `json:"total_pages"`
+}
+
+// DraftListResponse represents a list of prompt drafts
+type DraftListResponse struct {
+	Data       []DraftResponse `json:"data"`
+	Total      int             `json:"total"`
+	Page       int             `json:"page"`
+	PageSize   int             `json:"page_size"`
+	TotalPages int             `json:"total_pages"`
+}
+
+// ReleaseListResponse represents a list of prompt releases
+type ReleaseListResponse struct {
+	Data       []ReleaseResponse `json:"data"`
+	Total      int               `json:"total"`
+	Page       int               `json:"page"`
+	PageSize   int               `json:"page_size"`
+	TotalPages int               `json:"total_pages"`
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/dikkadev/proompt/server/internal/api/handlers"
 	"github.com/dikkadev/proompt/server/internal/config"
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/reconcile"
 	"github.com/dikkadev/proompt/server/internal/repository"
 
 	// Swagger documentation
@@ -23,7 +25,7 @@ type Server struct {
 }
 
 // New creates a new HTTP server
-func New(cfg *config.Config, repo repository.Repository, logger *slog.Logger) *Server {
+func New(cfg *config.Config, repo repository.Repository, gitService git.GitService, logger *slog.Logger) *Server {
 	mux := http.NewServeMux()
 
 	// Swagger documentation endpoint
@@ -33,10 +35,14 @@ func New(cfg *config.Config, repo repository.Repository, logger *slog.Logger) *S
 	mux.HandleFunc("GET /api/health", handlers.Health)
 
 	// Create handlers
-	promptHandlers := handlers.NewPromptHandlers(repo)
-	snippetHandlers := handlers.NewSnippetHandlers(repo)
+	promptHandlers := handlers.NewPromptHandlers(repo, gitService)
+	snippetHandlers := handlers.NewSnippetHandlers(repo, gitService)
 	noteHandlers := handlers.NewNoteHandlers(repo)
 	templateHandlers := handlers.NewTemplateHandler(repo)
+	versionHandlers := handlers.NewVersionHandlers(repo, gitService)
+	draftHandlers := handlers.NewDraftHandlers(repo, gitService)
+	searchHandlers := handlers.NewSearchHandlers(repo)
+	adminHandlers := handlers.NewAdminHandlers(reconcile.New(repo, gitService))
 
 	// Prompts endpoints
 	mux.HandleFunc("GET /api/prompts", promptHandlers.ListPrompts)
@@ -57,6 +63,26 @@ func New(cfg *config.Config, repo repository.Repository, logger *slog.Logger) *S
 	mux.HandleFunc("GET /api/prompts/{id}/tags", promptHandlers.GetPromptTags)
 	mux.HandleFunc("GET /api/prompts/tags", promptHandlers.ListAllPromptTags)
 
+	// Prompt version history endpoints
+	mux.HandleFunc("GET /api/prompts/{id}/history", versionHandlers.GetPromptHistory)
+	mux.HandleFunc("GET /api/prompts/{id}/versions/{hash}", versionHandlers.GetPromptVersion)
+	mux.HandleFunc("POST /api/prompts/{id}/versions/{hash}/restore", versionHandlers.RestorePromptVersion)
+	mux.HandleFunc("GET /api/prompts/{id}/diff", versionHandlers.DiffPromptVersions)
+
+	// Prompt release endpoints
+	mux.HandleFunc("GET /api/prompts/{id}/releases", versionHandlers.ListPromptReleases)
+	mux.HandleFunc("POST /api/prompts/{id}/releases", versionHandlers.CreatePromptRelease)
+	mux.HandleFunc("DELETE /api/prompts/{id}/releases/{label}", versionHandlers.DeletePromptRelease)
+
+	// Prompt drafts endpoints
+	mux.HandleFunc("GET /api/prompts/{id}/drafts", draftHandlers.ListPromptDrafts)
+	mux.HandleFunc("POST /api/prompts/{id}/drafts", draftHandlers.CreatePromptDraft)
+	mux.HandleFunc("GET /api/prompts/{id}/drafts/{name}", draftHandlers.GetPromptDraft)
+	mux.HandleFunc("PUT /api/prompts/{id}/drafts/{name}", draftHandlers.UpdatePromptDraft)
+	mux.HandleFunc("DELETE /api/prompts/{id}/drafts/{name}", draftHandlers.DeletePromptDraft)
+	mux.HandleFunc("POST /api/prompts/{id}/drafts/{name}/preview", draftHandlers.PreviewPromptDraft)
+	mux.HandleFunc("POST /api/prompts/{id}/drafts/{name}/promote", draftHandlers.PromotePromptDraft)
+
 	// Snippets endpoints
 	mux.HandleFunc("GET /api/snippets", snippetHandlers.ListSnippets)
 	mux.HandleFunc("POST /api/snippets", snippetHandlers.CreateSnippet)
@@ -70,6 +96,12 @@ func New(cfg *config.Config, repo repository.Repository, logger *slog.Logger) *S
 	mux.HandleFunc("GET /api/snippets/{id}/tags", snippetHandlers.GetSnippetTags)
 	mux.HandleFunc("GET /api/snippets/tags", snippetHandlers.ListAllSnippetTags)
 
+	// Snippet version history endpoints
+	mux.HandleFunc("GET /api/snippets/{id}/history", versionHandlers.GetSnippetHistory)
+	mux.HandleFunc("GET /api/snippets/{id}/versions/{hash}", versionHandlers.GetSnippetVersion)
+	mux.HandleFunc("POST /api/snippets/{id}/versions/{hash}/restore", versionHandlers.RestoreSnippetVersion)
+	mux.HandleFunc("GET /api/snippets/{id}/diff", versionHandlers.DiffSnippetVersions)
+
 	// Notes endpoints
 	mux.HandleFunc("GET /api/prompts/{id}/notes", noteHandlers.ListNotesForPrompt)
 	mux.HandleFunc("POST /api/prompts/{id}/notes", noteHandlers.CreateNote)
@@ -77,10 +109,18 @@ func New(cfg *config.Config, repo repository.Repository, logger *slog.Logger) *S
 	mux.HandleFunc("PUT /api/notes/{id}", noteHandlers.UpdateNote)
 	mux.HandleFunc("DELETE /api/notes/{id}", noteHandlers.DeleteNote)
 
+	// Search endpoint
+	mux.HandleFunc("GET /api/search", searchHandlers.Search)
+
 	// Template endpoints
 	mux.HandleFunc("POST /api/template/preview", templateHandlers.PreviewTemplate)
 	mux.HandleFunc("POST /api/template/analyze", templateHandlers.AnalyzeTemplate)
 
+	// Admin endpoints
+	mux.HandleFunc("GET /api/admin/fsck", adminHandlers.Fsck)
+	mux.HandleFunc("POST /api/admin/fsck/repair", adminHandlers.RepairFsck)
+	mux.HandleFunc("POST /api/git/sync", adminHandlers.SyncGit)
+
 	// Create middleware stack
 	stack := CreateStack(
 		LoggingMiddleware(logger),
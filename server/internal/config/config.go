@@ -48,17 +48,29 @@ type LocalDatabase struct {
 }
 
 type TursoDatabase struct {
-	URL   string `xml:"url,attr" validate:"required,url"`
-	Token string `xml:"token,attr" validate:"required"`
+	URL        string `xml:"url,attr" validate:"required,url"`
+	Token      string `xml:"token,attr" validate:"required"`
+	Migrations string `xml:"migrations,attr" validate:"required"`
 }
 
 type RawStorage struct {
-	Environment string `xml:"environment,attr"`
-	ReposDir    string `xml:"repos_dir,attr" validate:"required"`
+	Environment string   `xml:"environment,attr"`
+	ReposDir    string   `xml:"repos_dir,attr" validate:"required"`
+	Remotes     []Remote `xml:"remote"`
 }
 
 type Storage struct {
-	ReposDir string `validate:"required"`
+	ReposDir string   `validate:"required"`
+	Remotes  []Remote `validate:"unique=Name,dive"`
+}
+
+// Remote is a git remote the versioning repository is synced with. Username and token
+// are used for HTTP(S) remotes; SSH remotes authenticate through the SSH agent.
+type Remote struct {
+	Name     string `xml:"name,attr" validate:"required"`
+	URL      string `xml:"url,attr" validate:"required"`
+	Username string `xml:"username,attr"`
+	Token    string `xml:"token,attr"`
 }
 
 type RawServer struct {
@@ -248,6 +260,8 @@ func formatValidationErrors(errs validator.ValidationErrors) error {
 			return fmt.Errorf("database: must configure exactly one database type (local or turso), not both or neither")
 		case "required":
 			return fmt.Errorf("%s cannot be empty", getFieldPath(err))
+		case "unique":
+			return fmt.Errorf("%s must have unique names", getFieldPath(err))
 		case "url":
 			return fmt.Errorf("%s must be a valid URL", getFieldPath(err))
 		case "min":
@@ -275,15 +289,24 @@ func getFieldPath(err validator.FieldError) string {
 		return "database.turso.url"
 	case "Config.Database.Turso.Token":
 		return "database.turso.token"
+	case "Config.Database.Turso.Migrations":
+		return "database.turso.migrations"
 	case "Config.Storage.ReposDir":
 		return "storage.repos_dir"
+	case "Config.Storage.Remotes":
+		return "storage.remote"
 	case "Config.Server.Host":
 		return "server.host"
 	case "Config.Server.Port":
 		return "server.port"
-	default:
-		return err.Field()
 	}
+
+	// Remotes are a list, so their namespace carries an index
+	if strings.HasPrefix(namespace, "Config.Storage.Remotes[") {
+		return "storage.remote." + strings.ToLower(err.Field())
+	}
+
+	return err.Field()
 }
 
 // DatabaseType returns the configured database type
@@ -407,6 +430,7 @@ func selectStorage(storages []RawStorage, environment string) (*Storage, error)
 
 	return &Storage{
 		ReposDir: selected.ReposDir,
+		Remotes:  selected.Remotes,
 	}, nil
 }
 
@@ -37,8 +37,9 @@ func TestConfigValidation(t *testing.T) {
 			config: Config{
 				Database: Database{
 					Turso: &TursoDatabase{
-						URL:   "https://test.turso.io",
-						Token: "test-token",
+						URL:        "https://test.turso.io",
+						Token:      "test-token",
+						Migrations: "/tmp/migrations",
 					},
 				},
 				Storage: Storage{
@@ -60,8 +61,9 @@ func TestConfigValidation(t *testing.T) {
 						Migrations: "/tmp/migrations",
 					},
 					Turso: &TursoDatabase{
-						URL:   "https://test.turso.io",
-						Token: "test-token",
+						URL:        "https://test.turso.io",
+						Token:      "test-token",
+						Migrations: "/tmp/migrations",
 					},
 				},
 				Storage: Storage{
@@ -135,8 +137,9 @@ func TestConfigValidation(t *testing.T) {
 			config: Config{
 				Database: Database{
 					Turso: &TursoDatabase{
-						URL:   "not-a-url", // invalid URL
-						Token: "test-token",
+						URL:        "not-a-url", // invalid URL
+						Token:      "test-token",
+						Migrations: "/tmp/migrations",
 					},
 				},
 				Storage: Storage{
@@ -150,6 +153,71 @@ func TestConfigValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "must be a valid URL",
 		},
+		{
+			name: "turso without migrations - should fail",
+			config: Config{
+				Database: Database{
+					Turso: &TursoDatabase{
+						URL:   "https://test.turso.io",
+						Token: "test-token",
+					},
+				},
+				Storage: Storage{
+					ReposDir: "/tmp/repos",
+				},
+				Server: Server{
+					Host: "localhost",
+					Port: 8080,
+				},
+			},
+			wantErr: true,
+			errMsg:  "database.turso.migrations",
+		},
+		{
+			name: "remote without url - should fail",
+			config: Config{
+				Database: Database{
+					Local: &LocalDatabase{
+						Path:       "/tmp/test.db",
+						Migrations: "/tmp/migrations",
+					},
+				},
+				Storage: Storage{
+					ReposDir: "/tmp/repos",
+					Remotes:  []Remote{{Name: "origin"}},
+				},
+				Server: Server{
+					Host: "localhost",
+					Port: 8080,
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage.remote.url cannot be empty",
+		},
+		{
+			name: "duplicate remote names - should fail",
+			config: Config{
+				Database: Database{
+					Local: &LocalDatabase{
+						Path:       "/tmp/test.db",
+						Migrations: "/tmp/migrations",
+					},
+				},
+				Storage: Storage{
+					ReposDir: "/tmp/repos",
+					Remotes: []Remote{
+						{Name: "origin", URL: "/srv/a.git"},
+						{Name: "origin", URL: "/srv/b.git"},
+					},
+				},
+				Server: Server{
+					Host: "localhost",
+					Port: 8080,
+				},
+			},
+			wantErr: true,
+			errMsg:  "storage.remote must have unique names",
+		},
 	}
 
 	for _, tt := range tests {
@@ -383,3 +451,52 @@ func TestLoggingDefaults(t *testing.T) {
 		t.Errorf("Default file logging should be disabled")
 	}
 }
+
+func TestStorageRemotes(t *testing.T) {
+	xmlContent := `<?xml version="1.0" encoding="UTF-8"?>
+<proompt>
+    <database>
+        <local path="./test.db" migrations="./migrations" />
+    </database>
+    <storage environment="dev" repos_dir="./repos">
+        <remote name="origin" url="https://git.example.com/team/prompts.git" username="bot" token="secret" />
+        <remote name="backup" url="/srv/git/prompts.git" />
+    </storage>
+    <storage repos_dir="./repos" />
+    <server host="localhost" port="8080" />
+</proompt>`
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "test.xml")
+
+	err := os.WriteFile(configPath, []byte(xmlContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	config, err := Load(configPath, "dev")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	remotes := config.Storage.Remotes
+	if len(remotes) != 2 {
+		t.Fatalf("Expected 2 remotes, got %d", len(remotes))
+	}
+	want := Remote{Name: "origin", URL: "https://git.example.com/team/prompts.git", Username: "bot", Token: "secret"}
+	if remotes[0] != want {
+		t.Errorf("Remotes[0] = %+v, want %+v", remotes[0], want)
+	}
+	if remotes[1].Name != "backup" || remotes[1].URL != "/srv/git/prompts.git" {
+		t.Errorf("Unexpected second remote: %+v", remotes[1])
+	}
+
+	// Other environments fall back to the storage without remotes
+	config, err = Load(configPath, "prod")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(config.Storage.Remotes) != 0 {
+		t.Errorf("Expected no remotes for prod, got %d", len(config.Storage.Remotes))
+	}
+}
@@ -1,9 +1,12 @@
 package db
 
 import (
+	"database/sql"
 	"fmt"
 	"path/filepath"
+	"strings"
 
+	"github.com/dikkadev/proompt/server/internal/db/hrana"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -17,7 +20,16 @@ type DB struct {
 
 // NewLocal creates a new local SQLite database connection
 func NewLocal(dbPath string) (*DB, error) {
-	db, err := sqlx.Connect("sqlite", dbPath)
+	// Store times in a format SQLite's date functions understand, the same one the
+	// Turso driver uses, so both backends can filter on timestamps in SQL
+	dsn := dbPath
+	if strings.Contains(dsn, "?") {
+		dsn += "&_time_format=sqlite"
+	} else {
+		dsn += "?_time_format=sqlite"
+	}
+
+	db, err := sqlx.Connect("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to local database: %w", err)
 	}
@@ -30,10 +42,26 @@ func NewLocal(dbPath string) (*DB, error) {
 	return &DB{DB: db}, nil
 }
 
-// NewTurso creates a new Turso database connection
+// NewTurso creates a new Turso (libSQL) database connection over HTTP
 func NewTurso(url, token string) (*DB, error) {
-	// TODO: Implement Turso connection
-	return nil, fmt.Errorf("turso database not implemented yet")
+	connector, err := hrana.NewConnector(hrana.Config{
+		URL:       url,
+		AuthToken: token,
+		// Every HTTP stream is a fresh connection, so foreign keys are enabled per stream
+		StreamInit: []string{"PRAGMA foreign_keys = ON"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure turso database: %w", err)
+	}
+
+	// Queries use the same SQLite dialect and placeholders as the local database
+	db := sqlx.NewDb(sql.OpenDB(connector), "sqlite")
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to turso database: %w", err)
+	}
+
+	return &DB{DB: db}, nil
 }
 
 // RunMigrations applies database migrations
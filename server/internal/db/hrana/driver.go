@@ -0,0 +1,563 @@
+package hrana
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config configures a connection to a libSQL server
+type Config struct {
+	// URL of the database, e.g. libsql://name.turso.io or http://localhost:8080
+	URL string
+	// AuthToken is sent as a bearer token when set
+	AuthToken string
+	// StreamInit statements are run at the start of every stream, before any other
+	// statement. Per-connection settings such as PRAGMA foreign_keys go here.
+	StreamInit []string
+	// HTTPClient is used for requests; http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// Connector creates connections to a libSQL server
+type Connector struct {
+	pipelineURL string
+	cfg         Config
+}
+
+// NewConnector creates a connector for use with sql.OpenDB
+func NewConnector(cfg Config) (*Connector, error) {
+	pipelineURL, err := pipelineURL(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &Connector{pipelineURL: pipelineURL, cfg: cfg}, nil
+}
+
+// Connect returns a new connection. No request is made until the first statement.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{connector: c, url: c.pipelineURL}, nil
+}
+
+// Driver returns the underlying driver
+func (c *Connector) Driver() driver.Driver {
+	return Driver{}
+}
+
+// Driver opens connections from a DSN of the form <url>?authToken=<token>
+type Driver struct{}
+
+// Open returns a new connection to the database
+func (d Driver) Open(dsn string) (driver.Conn, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid libsql DSN: %w", err)
+	}
+	query := u.Query()
+	token := query.Get("authToken")
+	query.Del("authToken")
+	u.RawQuery = query.Encode()
+
+	connector, err := NewConnector(Config{URL: u.String(), AuthToken: token})
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+func init() {
+	sql.Register("libsql", Driver{})
+}
+
+// pipelineURL converts a database URL to the HTTP pipeline endpoint
+func pipelineURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid database URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "libsql", "wss", "https":
+		u.Scheme = "https"
+	case "ws", "http":
+		u.Scheme = "http"
+	default:
+		return "", fmt.Errorf("unsupported database URL scheme %q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return "", fmt.Errorf("database URL %q has no host", rawURL)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v2/pipeline"
+	return u.String(), nil
+}
+
+// conn is a single database connection. Outside a transaction every request opens
+// and closes its own stream; inside a transaction the stream is kept open via the baton.
+type conn struct {
+	connector *Connector
+	url       string
+	baton     string
+	inTx      bool
+	closed    bool
+}
+
+var (
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+)
+
+// pipeline sends requests on the connection's stream and returns their results in order.
+// Any request error is returned as an error.
+func (c *conn) pipeline(ctx context.Context, requests ...StreamRequest) ([]StreamResult, error) {
+	if c.closed {
+		return nil, driver.ErrBadConn
+	}
+
+	newStream := c.baton == ""
+	offset := 0
+	if newStream && len(c.connector.cfg.StreamInit) > 0 {
+		initRequests := make([]StreamRequest, 0, len(c.connector.cfg.StreamInit)+len(requests))
+		for _, init := range c.connector.cfg.StreamInit {
+			initRequests = append(initRequests, executeRequest(init))
+		}
+		offset = len(initRequests)
+		requests = append(initRequests, requests...)
+	}
+	if !c.inTx {
+		requests = append(requests, StreamRequest{Type: "close"})
+	}
+
+	req := PipelineRequest{Requests: requests}
+	if !newStream {
+		baton := c.baton
+		req.Baton = &baton
+	}
+
+	resp, err := c.send(ctx, &req)
+	if err != nil {
+		// The stream is lost, so the connection cannot be reused
+		c.baton = ""
+		if c.inTx {
+			c.closed = true
+			return nil, fmt.Errorf("libsql stream lost inside transaction: %w", err)
+		}
+		return nil, err
+	}
+
+	if c.inTx && resp.Baton != nil {
+		c.baton = *resp.Baton
+	} else {
+		c.baton = ""
+		c.url = c.connector.pipelineURL
+	}
+	if resp.BaseURL != nil && *resp.BaseURL != "" && c.baton != "" {
+		c.url = strings.TrimSuffix(*resp.BaseURL, "/") + "/v2/pipeline"
+	}
+
+	if len(resp.Results) != len(requests) {
+		return nil, fmt.Errorf("libsql: expected %d results, got %d", len(requests), len(resp.Results))
+	}
+
+	for _, result := range resp.Results {
+		if result.Type == "error" {
+			if result.Error != nil {
+				return nil, result.Error
+			}
+			return nil, errors.New("libsql: unknown stream error")
+		}
+	}
+
+	return resp.Results[offset:], nil
+}
+
+// send posts a pipeline request to the server
+func (c *conn) send(ctx context.Context, pipelineReq *PipelineRequest) (*PipelineResponse, error) {
+	body, err := json.Marshal(pipelineReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pipeline request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.connector.cfg.AuthToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.connector.cfg.AuthToken)
+	}
+
+	httpResp, err := c.connector.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("libsql request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read libsql response: %w", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("libsql request failed with status %d: %s", httpResp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var resp PipelineResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode libsql response: %w", err)
+	}
+
+	return &resp, nil
+}
+
+// ExecContext executes a statement. Statements without arguments are sent as a
+// sequence so multi-statement scripts such as migrations work.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) == 0 {
+		results, err := c.pipeline(ctx,
+			StreamRequest{Type: "sequence", SQL: &query},
+			executeRequest("SELECT changes(), last_insert_rowid()"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return sequenceResult(results[1])
+	}
+
+	stmt, err := newStmt(query, args, false)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := c.pipeline(ctx, StreamRequest{Type: "execute", Stmt: stmt})
+	if err != nil {
+		return nil, err
+	}
+
+	stmtResult := results[0].Response.Result
+	res := &result{rowsAffected: stmtResult.AffectedRowCount}
+	if stmtResult.LastInsertRowID != nil {
+		res.lastInsertID, _ = strconv.ParseInt(*stmtResult.LastInsertRowID, 10, 64)
+	}
+	return res, nil
+}
+
+// QueryContext executes a query. All rows are returned in a single response.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmt, err := newStmt(query, args, true)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := c.pipeline(ctx, StreamRequest{Type: "execute", Stmt: stmt})
+	if err != nil {
+		return nil, err
+	}
+
+	return newRows(results[0].Response.Result), nil
+}
+
+// BeginTx starts a transaction, keeping the stream open until commit or rollback
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.inTx {
+		return nil, errors.New("libsql: transaction already in progress")
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, fmt.Errorf("libsql: unsupported isolation level %d", opts.Isolation)
+	}
+
+	begin := "BEGIN"
+	if opts.ReadOnly {
+		begin = "BEGIN DEFERRED"
+	}
+
+	c.inTx = true
+	if _, err := c.pipeline(ctx, executeRequest(begin)); err != nil {
+		c.inTx = false
+		return nil, err
+	}
+
+	return &tx{conn: c}, nil
+}
+
+// Begin starts a transaction
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// PrepareContext returns a statement that is sent to the server on execution
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+// Prepare returns a statement that is sent to the server on execution
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// Ping checks that the server is reachable
+func (c *conn) Ping(ctx context.Context) error {
+	_, err := c.pipeline(ctx, executeRequest("SELECT 1"))
+	return err
+}
+
+// IsValid reports whether the connection can be reused by the pool
+func (c *conn) IsValid() bool {
+	return !c.closed && !c.inTx
+}
+
+// Close releases the open stream, if any
+func (c *conn) Close() error {
+	if c.closed {
+		return nil
+	}
+	if c.baton != "" {
+		c.inTx = false
+		_, err := c.pipeline(context.Background())
+		c.closed = true
+		return err
+	}
+	c.closed = true
+	return nil
+}
+
+// tx finishes a transaction and closes its stream
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	return t.finish("COMMIT")
+}
+
+func (t *tx) Rollback() error {
+	return t.finish("ROLLBACK")
+}
+
+func (t *tx) finish(statement string) error {
+	t.conn.inTx = false
+	_, err := t.conn.pipeline(context.Background(), executeRequest(statement))
+	return err
+}
+
+// stmt is a prepared statement; preparation happens server side on every execution
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+// result reports the outcome of an exec
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r *result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r *result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// sequenceResult reads the changes() and last_insert_rowid() row that follows a sequence
+func sequenceResult(res StreamResult) (driver.Result, error) {
+	stmtResult := res.Response.Result
+	if stmtResult == nil || len(stmtResult.Rows) != 1 || len(stmtResult.Rows[0]) != 2 {
+		return nil, errors.New("libsql: malformed result for changes()")
+	}
+
+	changes, err := stmtResult.Rows[0][0].Decode()
+	if err != nil {
+		return nil, err
+	}
+	lastID, err := stmtResult.Rows[0][1].Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &result{}
+	r.rowsAffected, _ = changes.(int64)
+	r.lastInsertID, _ = lastID.(int64)
+	return r, nil
+}
+
+// rows iterates over a fully buffered result set
+type rows struct {
+	cols      []string
+	decltypes []string
+	values    [][]Value
+	pos       int
+}
+
+func newRows(res *StmtResult) *rows {
+	r := &rows{values: res.Rows}
+	for _, col := range res.Cols {
+		name, decltype := "", ""
+		if col.Name != nil {
+			name = *col.Name
+		}
+		if col.Decltype != nil {
+			decltype = strings.ToUpper(*col.Decltype)
+		}
+		r.cols = append(r.cols, name)
+		r.decltypes = append(r.decltypes, decltype)
+	}
+	return r
+}
+
+func (r *rows) Columns() []string {
+	return r.cols
+}
+
+func (r *rows) Close() error {
+	r.pos = len(r.values)
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+
+	row := r.values[r.pos]
+	r.pos++
+
+	for i := range dest {
+		if i >= len(row) {
+			dest[i] = nil
+			continue
+		}
+		v, err := row[i].Decode()
+		if err != nil {
+			return fmt.Errorf("failed to decode column %s: %w", r.cols[i], err)
+		}
+		// Like the local driver, date columns stored as text are returned as time.Time
+		if s, ok := v.(string); ok && isTimeDecltype(r.decltypes[i]) {
+			if t, ok := parseTime(s); ok {
+				v = t
+			}
+		}
+		dest[i] = v
+	}
+
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName returns the declared type of a column
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.decltypes[index]
+}
+
+// newStmt builds a Hrana statement from driver arguments
+func newStmt(query string, args []driver.NamedValue, wantRows bool) (*Stmt, error) {
+	stmt := &Stmt{SQL: query, WantRows: wantRows}
+	for _, arg := range args {
+		v, err := EncodeValue(arg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", arg.Ordinal, err)
+		}
+		if arg.Name != "" {
+			stmt.NamedArgs = append(stmt.NamedArgs, NamedArg{Name: ":" + arg.Name, Value: v})
+		} else {
+			stmt.Args = append(stmt.Args, v)
+		}
+	}
+	return stmt, nil
+}
+
+// executeRequest builds an execute request for a statement without arguments
+func executeRequest(query string) StreamRequest {
+	return StreamRequest{Type: "execute", Stmt: &Stmt{SQL: query, WantRows: true}}
+}
+
+// namedValues converts positional driver values to named values
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+// isTimeDecltype reports whether a declared column type holds dates
+func isTimeDecltype(decltype string) bool {
+	switch decltype {
+	case "DATE", "DATETIME", "TIMESTAMP":
+		return true
+	}
+	return false
+}
+
+// parseTimeFormats are the text encodings of dates accepted by SQLite
+var parseTimeFormats = []string{
+	timeFormat,
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseTime parses a date stored as text, including the time.Time.String()
+// format written by the local driver
+func parseTime(s string) (time.Time, bool) {
+	if i := strings.Index(s, " m="); i > 0 {
+		s = s[:i]
+	}
+	if t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s); err == nil {
+		return t, true
+	}
+
+	s = strings.TrimSuffix(s, "Z")
+	for _, format := range parseTimeFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,116 @@
+package hrana_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/proompt/server/internal/db/hrana"
+	"github.com/dikkadev/proompt/server/internal/db/hrana/hranatest"
+)
+
+func openTestDB(t *testing.T, token string) *sql.DB {
+	server, err := hranatest.NewServer(filepath.Join(t.TempDir(), "test.db"), "secret")
+	if err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	connector, err := hrana.NewConnector(hrana.Config{URL: server.URL, AuthToken: token})
+	if err != nil {
+		t.Fatalf("Failed to create connector: %v", err)
+	}
+
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestNewConnectorURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"libsql://example.turso.io", true},
+		{"https://example.turso.io", true},
+		{"http://localhost:8080", true},
+		{"wss://example.turso.io", true},
+		{"ftp://example.turso.io", false},
+		{"libsql://", false},
+	}
+
+	for _, tt := range tests {
+		_, err := hrana.NewConnector(hrana.Config{URL: tt.url})
+		if (err == nil) != tt.valid {
+			t.Errorf("NewConnector(%q) error = %v, want valid %v", tt.url, err, tt.valid)
+		}
+	}
+}
+
+func TestQueryAndTransaction(t *testing.T) {
+	db := openTestDB(t, "secret")
+	ctx := context.Background()
+
+	// Multi-statement scripts are sent as a sequence
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, weight REAL, data BLOB, created_at DATETIME);
+		CREATE INDEX idx_items_name ON items(name);
+	`); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	res, err := db.ExecContext(ctx, "INSERT INTO items (name, weight, data, created_at) VALUES (?, ?, ?, ?)",
+		"apple", 1.5, []byte{1, 2, 3}, created)
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if id, _ := res.LastInsertId(); id != 1 {
+		t.Errorf("Expected last insert id 1, got %d", id)
+	}
+
+	var (
+		name      string
+		weight    float64
+		data      []byte
+		createdAt time.Time
+	)
+	err = db.QueryRowContext(ctx, "SELECT name, weight, data, created_at FROM items WHERE id = ?", 1).
+		Scan(&name, &weight, &data, &createdAt)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if name != "apple" || weight != 1.5 || len(data) != 3 || !createdAt.Equal(created) {
+		t.Errorf("Unexpected row: %s %v %v %v", name, weight, data, createdAt)
+	}
+
+	// Rolled back writes are not visible
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", "pear"); err != nil {
+		t.Fatalf("Failed to insert in transaction: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM items").Scan(&count); err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 item after rollback, got %d", count)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	db := openTestDB(t, "wrong")
+
+	if err := db.Ping(); err == nil {
+		t.Error("Expected ping with a wrong token to fail")
+	}
+}
@@ -0,0 +1,267 @@
+// Package hranatest provides an in-process libSQL server for tests.
+// It implements the Hrana over HTTP pipeline endpoint on top of a local SQLite file.
+package hranatest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dikkadev/proompt/server/internal/db/hrana"
+	_ "modernc.org/sqlite"
+)
+
+// Server is a fake libSQL server. Each stream is backed by its own SQLite connection,
+// so transactions behave as they do against a real server.
+type Server struct {
+	*httptest.Server
+
+	db    *sql.DB
+	token string
+
+	mu        sync.Mutex
+	streams   map[string]*sql.Conn
+	nextBaton int
+}
+
+// NewServer starts a server storing data in the SQLite file at dbPath.
+// When token is not empty, requests must carry it as a bearer token.
+func NewServer(dbPath, token string) (*Server, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)", dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	s := &Server{
+		db:      db,
+		token:   token,
+		streams: make(map[string]*sql.Conn),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v2/pipeline", s.handlePipeline)
+	s.Server = httptest.NewServer(mux)
+
+	return s, nil
+}
+
+// Close shuts down the server and releases all open streams
+func (s *Server) Close() {
+	s.Server.Close()
+
+	s.mu.Lock()
+	for baton, conn := range s.streams {
+		closeStream(conn)
+		delete(s.streams, baton)
+	}
+	s.mu.Unlock()
+
+	s.db.Close()
+}
+
+// handlePipeline executes a pipeline request on a new or existing stream
+func (s *Server) handlePipeline(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req hrana.PipelineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := s.stream(req.Baton)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	// Streams outlive the request, so statements must not use the request context
+	ctx := context.Background()
+
+	resp := hrana.PipelineResponse{Results: make([]hrana.StreamResult, 0, len(req.Requests))}
+	closed := false
+	for _, streamReq := range req.Requests {
+		if closed {
+			resp.Results = append(resp.Results, errorResult(fmt.Errorf("stream is closed")))
+			continue
+		}
+
+		switch streamReq.Type {
+		case "execute":
+			resp.Results = append(resp.Results, execute(ctx, conn, streamReq.Stmt))
+		case "sequence":
+			resp.Results = append(resp.Results, sequence(ctx, conn, streamReq.SQL))
+		case "close":
+			closed = true
+			resp.Results = append(resp.Results, hrana.StreamResult{
+				Type:     "ok",
+				Response: &hrana.StreamResponse{Type: "close"},
+			})
+		default:
+			resp.Results = append(resp.Results, errorResult(fmt.Errorf("unsupported request type %q", streamReq.Type)))
+		}
+	}
+
+	if closed {
+		closeStream(conn)
+	} else {
+		baton := s.keep(conn)
+		resp.Baton = &baton
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// stream returns the connection for a baton, or a new connection when baton is nil
+func (s *Server) stream(baton *string) (*sql.Conn, error) {
+	if baton == nil {
+		return s.db.Conn(context.Background())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conn, ok := s.streams[*baton]
+	if !ok {
+		return nil, fmt.Errorf("unknown baton")
+	}
+	delete(s.streams, *baton)
+	return conn, nil
+}
+
+// keep stores an open stream and returns a fresh baton for it
+func (s *Server) keep(conn *sql.Conn) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextBaton++
+	baton := strconv.Itoa(s.nextBaton)
+	s.streams[baton] = conn
+	return baton
+}
+
+// closeStream returns a stream's connection to the pool, rolling back any
+// transaction the client left open
+func closeStream(conn *sql.Conn) {
+	conn.ExecContext(context.Background(), "ROLLBACK")
+	conn.Close()
+}
+
+// execute runs a single statement
+func execute(ctx context.Context, conn *sql.Conn, stmt *hrana.Stmt) hrana.StreamResult {
+	if stmt == nil {
+		return errorResult(fmt.Errorf("missing stmt"))
+	}
+
+	args := make([]interface{}, 0, len(stmt.Args)+len(stmt.NamedArgs))
+	for _, arg := range stmt.Args {
+		v, err := arg.Decode()
+		if err != nil {
+			return errorResult(err)
+		}
+		args = append(args, v)
+	}
+	for _, arg := range stmt.NamedArgs {
+		v, err := arg.Value.Decode()
+		if err != nil {
+			return errorResult(err)
+		}
+		args = append(args, sql.Named(arg.Name[1:], v))
+	}
+
+	result := &hrana.StmtResult{Cols: []hrana.Col{}, Rows: [][]hrana.Value{}}
+
+	if !stmt.WantRows {
+		res, err := conn.ExecContext(ctx, stmt.SQL, args...)
+		if err != nil {
+			return errorResult(err)
+		}
+		result.AffectedRowCount, _ = res.RowsAffected()
+		if id, err := res.LastInsertId(); err == nil {
+			lastID := strconv.FormatInt(id, 10)
+			result.LastInsertRowID = &lastID
+		}
+		return okResult(result)
+	}
+
+	rows, err := conn.QueryContext(ctx, stmt.SQL, args...)
+	if err != nil {
+		return errorResult(err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return errorResult(err)
+	}
+	for _, columnType := range columnTypes {
+		name := columnType.Name()
+		decltype := columnType.DatabaseTypeName()
+		result.Cols = append(result.Cols, hrana.Col{Name: &name, Decltype: &decltype})
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(columnTypes))
+		pointers := make([]interface{}, len(columnTypes))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return errorResult(err)
+		}
+
+		row := make([]hrana.Value, len(values))
+		for i, v := range values {
+			// The local driver parses date columns; send them back as text like a real server
+			if t, ok := v.(time.Time); ok {
+				v = t.Format("2006-01-02 15:04:05.999999999-07:00")
+			}
+			encoded, err := hrana.EncodeValue(v)
+			if err != nil {
+				return errorResult(err)
+			}
+			row[i] = encoded
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return errorResult(err)
+	}
+
+	return okResult(result)
+}
+
+// sequence runs a script of statements without arguments
+func sequence(ctx context.Context, conn *sql.Conn, script *string) hrana.StreamResult {
+	if script == nil {
+		return errorResult(fmt.Errorf("missing sql"))
+	}
+	if _, err := conn.ExecContext(ctx, *script); err != nil {
+		return errorResult(err)
+	}
+	return hrana.StreamResult{Type: "ok", Response: &hrana.StreamResponse{Type: "sequence"}}
+}
+
+func okResult(result *hrana.StmtResult) hrana.StreamResult {
+	return hrana.StreamResult{
+		Type:     "ok",
+		Response: &hrana.StreamResponse{Type: "execute", Result: result},
+	}
+}
+
+func errorResult(err error) hrana.StreamResult {
+	return hrana.StreamResult{
+		Type:  "error",
+		Error: &hrana.Error{Message: err.Error(), Code: "SQLITE_ERROR"},
+	}
+}
@@ -0,0 +1,163 @@
+// Package hrana implements a database/sql driver for libSQL servers (Turso, sqld)
+// speaking the Hrana protocol over HTTP.
+package hrana
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// PipelineRequest is the body of a POST to /v2/pipeline
+type PipelineRequest struct {
+	Baton    *string         `json:"baton"`
+	Requests []StreamRequest `json:"requests"`
+}
+
+// PipelineResponse is the response to a pipeline request
+type PipelineResponse struct {
+	Baton   *string        `json:"baton"`
+	BaseURL *string        `json:"base_url"`
+	Results []StreamResult `json:"results"`
+}
+
+// StreamRequest is a single request executed on a stream
+type StreamRequest struct {
+	Type string  `json:"type"` // "execute", "sequence" or "close"
+	Stmt *Stmt   `json:"stmt,omitempty"`
+	SQL  *string `json:"sql,omitempty"`
+}
+
+// StreamResult is the outcome of a single stream request
+type StreamResult struct {
+	Type     string          `json:"type"` // "ok" or "error"
+	Response *StreamResponse `json:"response,omitempty"`
+	Error    *Error          `json:"error,omitempty"`
+}
+
+// StreamResponse is the payload of a successful stream request
+type StreamResponse struct {
+	Type   string      `json:"type"`
+	Result *StmtResult `json:"result,omitempty"`
+}
+
+// Stmt is a SQL statement with its arguments
+type Stmt struct {
+	SQL       string     `json:"sql"`
+	Args      []Value    `json:"args,omitempty"`
+	NamedArgs []NamedArg `json:"named_args,omitempty"`
+	WantRows  bool       `json:"want_rows"`
+}
+
+// NamedArg is a named statement argument
+type NamedArg struct {
+	Name  string `json:"name"`
+	Value Value  `json:"value"`
+}
+
+// StmtResult is the result of executing a statement
+type StmtResult struct {
+	Cols             []Col     `json:"cols"`
+	Rows             [][]Value `json:"rows"`
+	AffectedRowCount int64     `json:"affected_row_count"`
+	LastInsertRowID  *string   `json:"last_insert_rowid"`
+}
+
+// Col describes a result column
+type Col struct {
+	Name     *string `json:"name"`
+	Decltype *string `json:"decltype"`
+}
+
+// Error is an error reported by the server
+type Error struct {
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return e.Message
+}
+
+// Value is a typed SQLite value.
+// Integers are encoded as strings to avoid losing precision in JSON.
+type Value struct {
+	Type   string          `json:"type"` // "null", "integer", "float", "text" or "blob"
+	Value  json.RawMessage `json:"value,omitempty"`
+	Base64 string          `json:"base64,omitempty"`
+}
+
+// timeFormat is the format used to store time.Time values, matching the
+// "sqlite" write format of the local driver so both backends read it back
+const timeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// EncodeValue converts a Go value to a Hrana value
+func EncodeValue(v interface{}) (Value, error) {
+	switch x := v.(type) {
+	case nil:
+		return Value{Type: "null"}, nil
+	case int64:
+		return rawValue("integer", strconv.FormatInt(x, 10))
+	case int:
+		return rawValue("integer", strconv.Itoa(x))
+	case bool:
+		if x {
+			return rawValue("integer", "1")
+		}
+		return rawValue("integer", "0")
+	case float64:
+		return rawValue("float", x)
+	case string:
+		return rawValue("text", x)
+	case []byte:
+		return Value{Type: "blob", Base64: base64.StdEncoding.EncodeToString(x)}, nil
+	case time.Time:
+		return rawValue("text", x.Format(timeFormat))
+	default:
+		return Value{}, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// Decode converts a Hrana value to a Go value
+func (v Value) Decode() (interface{}, error) {
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "integer":
+		var s string
+		if err := json.Unmarshal(v.Value, &s); err != nil {
+			return nil, fmt.Errorf("invalid integer value: %w", err)
+		}
+		return strconv.ParseInt(s, 10, 64)
+	case "float":
+		var f float64
+		if err := json.Unmarshal(v.Value, &f); err != nil {
+			return nil, fmt.Errorf("invalid float value: %w", err)
+		}
+		return f, nil
+	case "text":
+		var s string
+		if err := json.Unmarshal(v.Value, &s); err != nil {
+			return nil, fmt.Errorf("invalid text value: %w", err)
+		}
+		return s, nil
+	case "blob":
+		return base64.StdEncoding.DecodeString(v.Base64)
+	default:
+		return nil, fmt.Errorf("unknown value type %q", v.Type)
+	}
+}
+
+// rawValue builds a value whose JSON payload is v
+func rawValue(typ string, v interface{}) (Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Value{}, fmt.Errorf("failed to encode %s value: %w", typ, err)
+	}
+	return Value{Type: typ, Value: data}, nil
+}
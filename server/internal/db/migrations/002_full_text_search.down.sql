@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS notes_fts_delete;
+DROP TRIGGER IF EXISTS notes_fts_update;
+DROP TRIGGER IF EXISTS notes_fts_insert;
+DROP TRIGGER IF EXISTS snippets_fts_delete;
+DROP TRIGGER IF EXISTS snippets_fts_update;
+DROP TRIGGER IF EXISTS snippets_fts_insert;
+DROP TRIGGER IF EXISTS prompts_fts_delete;
+DROP TRIGGER IF EXISTS prompts_fts_update;
+DROP TRIGGER IF EXISTS prompts_fts_insert;
+
+DROP TABLE IF EXISTS notes_fts;
+DROP TABLE IF EXISTS snippets_fts;
+DROP TABLE IF EXISTS prompts_fts;
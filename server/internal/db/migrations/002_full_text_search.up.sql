@@ -0,0 +1,84 @@
+-- Full-text search indexes. Each FTS table stores the row id as an unindexed
+-- column and is kept in sync with its source table by triggers.
+
+CREATE VIRTUAL TABLE prompts_fts USING fts5(
+    id UNINDEXED,
+    title,
+    content,
+    use_case,
+    tokenize = 'porter unicode61'
+);
+
+CREATE VIRTUAL TABLE snippets_fts USING fts5(
+    id UNINDEXED,
+    title,
+    content,
+    description,
+    tokenize = 'porter unicode61'
+);
+
+CREATE VIRTUAL TABLE notes_fts USING fts5(
+    id UNINDEXED,
+    prompt_id UNINDEXED,
+    title,
+    body,
+    tokenize = 'porter unicode61'
+);
+
+-- Prompts
+CREATE TRIGGER prompts_fts_insert AFTER INSERT ON prompts BEGIN
+    INSERT INTO prompts_fts (id, title, content, use_case)
+    VALUES (new.id, new.title, new.content, COALESCE(new.use_case, ''));
+END;
+
+CREATE TRIGGER prompts_fts_update AFTER UPDATE OF title, content, use_case ON prompts BEGIN
+    DELETE FROM prompts_fts WHERE id = old.id;
+    INSERT INTO prompts_fts (id, title, content, use_case)
+    VALUES (new.id, new.title, new.content, COALESCE(new.use_case, ''));
+END;
+
+CREATE TRIGGER prompts_fts_delete AFTER DELETE ON prompts BEGIN
+    DELETE FROM prompts_fts WHERE id = old.id;
+END;
+
+-- Snippets
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (id, title, content, description)
+    VALUES (new.id, new.title, new.content, COALESCE(new.description, ''));
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, content, description ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE id = old.id;
+    INSERT INTO snippets_fts (id, title, content, description)
+    VALUES (new.id, new.title, new.content, COALESCE(new.description, ''));
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE id = old.id;
+END;
+
+-- Notes
+CREATE TRIGGER notes_fts_insert AFTER INSERT ON notes BEGIN
+    INSERT INTO notes_fts (id, prompt_id, title, body)
+    VALUES (new.id, new.prompt_id, new.title, COALESCE(new.body, ''));
+END;
+
+CREATE TRIGGER notes_fts_update AFTER UPDATE OF title, body ON notes BEGIN
+    DELETE FROM notes_fts WHERE id = old.id;
+    INSERT INTO notes_fts (id, prompt_id, title, body)
+    VALUES (new.id, new.prompt_id, new.title, COALESCE(new.body, ''));
+END;
+
+CREATE TRIGGER notes_fts_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM notes_fts WHERE id = old.id;
+END;
+
+-- Index existing rows
+INSERT INTO prompts_fts (id, title, content, use_case)
+SELECT id, title, content, COALESCE(use_case, '') FROM prompts;
+
+INSERT INTO snippets_fts (id, title, content, description)
+SELECT id, title, content, COALESCE(description, '') FROM snippets;
+
+INSERT INTO notes_fts (id, prompt_id, title, body)
+SELECT id, prompt_id, title, COALESCE(body, '') FROM notes;
@@ -0,0 +1,3 @@
+-- The normalized timestamps are still readable by the application, so there is
+-- nothing to undo.
+SELECT 1;
@@ -0,0 +1,40 @@
+-- Timestamps used to be written in Go's time.String() format, for example
+-- "2024-05-01 12:30:00.123 +0200 CEST m=+0.01", which SQLite's date functions
+-- cannot parse. Rewrite them as "2024-05-01 12:30:00.123+02:00".
+--
+-- The zone offset follows the first space after the time of day.
+
+UPDATE prompts
+SET created_at = substr(created_at, 1, 18 + instr(substr(created_at, 20), ' '))
+    || substr(created_at, 20 + instr(substr(created_at, 20), ' '), 3) || ':' || substr(created_at, 23 + instr(substr(created_at, 20), ' '), 2)
+WHERE created_at GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]* [+-][0-9][0-9][0-9][0-9] *';
+
+UPDATE prompts
+SET updated_at = substr(updated_at, 1, 18 + instr(substr(updated_at, 20), ' '))
+    || substr(updated_at, 20 + instr(substr(updated_at, 20), ' '), 3) || ':' || substr(updated_at, 23 + instr(substr(updated_at, 20), ' '), 2)
+WHERE updated_at GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]* [+-][0-9][0-9][0-9][0-9] *';
+
+UPDATE snippets
+SET created_at = substr(created_at, 1, 18 + instr(substr(created_at, 20), ' '))
+    || substr(created_at, 20 + instr(substr(created_at, 20), ' '), 3) || ':' || substr(created_at, 23 + instr(substr(created_at, 20), ' '), 2)
+WHERE created_at GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]* [+-][0-9][0-9][0-9][0-9] *';
+
+UPDATE snippets
+SET updated_at = substr(updated_at, 1, 18 + instr(substr(updated_at, 20), ' '))
+    || substr(updated_at, 20 + instr(substr(updated_at, 20), ' '), 3) || ':' || substr(updated_at, 23 + instr(substr(updated_at, 20), ' '), 2)
+WHERE updated_at GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]* [+-][0-9][0-9][0-9][0-9] *';
+
+UPDATE notes
+SET created_at = substr(created_at, 1, 18 + instr(substr(created_at, 20), ' '))
+    || substr(created_at, 20 + instr(substr(created_at, 20), ' '), 3) || ':' || substr(created_at, 23 + instr(substr(created_at, 20), ' '), 2)
+WHERE created_at GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]* [+-][0-9][0-9][0-9][0-9] *';
+
+UPDATE notes
+SET updated_at = substr(updated_at, 1, 18 + instr(substr(updated_at, 20), ' '))
+    || substr(updated_at, 20 + instr(substr(updated_at, 20), ' '), 3) || ':' || substr(updated_at, 23 + instr(substr(updated_at, 20), ' '), 2)
+WHERE updated_at GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]* [+-][0-9][0-9][0-9][0-9] *';
+
+UPDATE prompt_links
+SET created_at = substr(created_at, 1, 18 + instr(substr(created_at, 20), ' '))
+    || substr(created_at, 20 + instr(substr(created_at, 20), ' '), 3) || ':' || substr(created_at, 23 + instr(substr(created_at, 20), ' '), 2)
+WHERE created_at GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]* [+-][0-9][0-9][0-9][0-9] *';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_snippets_has_variables;
+DROP INDEX IF EXISTS idx_prompts_has_variables;
+
+ALTER TABLE snippets DROP COLUMN has_variables;
+ALTER TABLE prompts DROP COLUMN has_variables;
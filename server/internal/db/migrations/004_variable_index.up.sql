@@ -0,0 +1,9 @@
+-- Whether the content contains {{variable}} placeholders, so listings can filter on it
+-- in SQL. Finding placeholders takes the template parser, so the repository fills the
+-- column in on write; NULL marks rows written before it existed.
+
+ALTER TABLE prompts ADD COLUMN has_variables BOOLEAN;
+ALTER TABLE snippets ADD COLUMN has_variables BOOLEAN;
+
+CREATE INDEX idx_prompts_has_variables ON prompts(has_variables);
+CREATE INDEX idx_snippets_has_variables ON snippets(has_variables);
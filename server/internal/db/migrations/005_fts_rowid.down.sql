@@ -0,0 +1,59 @@
+-- Go back to linking full-text search rows by id
+
+DROP TRIGGER IF EXISTS prompts_fts_insert;
+DROP TRIGGER IF EXISTS prompts_fts_update;
+DROP TRIGGER IF EXISTS prompts_fts_delete;
+DROP TRIGGER IF EXISTS snippets_fts_insert;
+DROP TRIGGER IF EXISTS snippets_fts_update;
+DROP TRIGGER IF EXISTS snippets_fts_delete;
+DROP TRIGGER IF EXISTS notes_fts_insert;
+DROP TRIGGER IF EXISTS notes_fts_update;
+DROP TRIGGER IF EXISTS notes_fts_delete;
+
+-- Prompts
+CREATE TRIGGER prompts_fts_insert AFTER INSERT ON prompts BEGIN
+    INSERT INTO prompts_fts (id, title, content, use_case)
+    VALUES (new.id, new.title, new.content, COALESCE(new.use_case, ''));
+END;
+
+CREATE TRIGGER prompts_fts_update AFTER UPDATE OF title, content, use_case ON prompts BEGIN
+    DELETE FROM prompts_fts WHERE id = old.id;
+    INSERT INTO prompts_fts (id, title, content, use_case)
+    VALUES (new.id, new.title, new.content, COALESCE(new.use_case, ''));
+END;
+
+CREATE TRIGGER prompts_fts_delete AFTER DELETE ON prompts BEGIN
+    DELETE FROM prompts_fts WHERE id = old.id;
+END;
+
+-- Snippets
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (id, title, content, description)
+    VALUES (new.id, new.title, new.content, COALESCE(new.description, ''));
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, content, description ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE id = old.id;
+    INSERT INTO snippets_fts (id, title, content, description)
+    VALUES (new.id, new.title, new.content, COALESCE(new.description, ''));
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE id = old.id;
+END;
+
+-- Notes
+CREATE TRIGGER notes_fts_insert AFTER INSERT ON notes BEGIN
+    INSERT INTO notes_fts (id, prompt_id, title, body)
+    VALUES (new.id, new.prompt_id, new.title, COALESCE(new.body, ''));
+END;
+
+CREATE TRIGGER notes_fts_update AFTER UPDATE OF title, body ON notes BEGIN
+    DELETE FROM notes_fts WHERE id = old.id;
+    INSERT INTO notes_fts (id, prompt_id, title, body)
+    VALUES (new.id, new.prompt_id, new.title, COALESCE(new.body, ''));
+END;
+
+CREATE TRIGGER notes_fts_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM notes_fts WHERE id = old.id;
+END;
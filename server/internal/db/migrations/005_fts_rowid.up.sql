@@ -0,0 +1,75 @@
+-- Link full-text search rows to their source rows by rowid. The id columns of the FTS
+-- tables are unindexed, so deleting by id scanned the whole index on every update and
+-- delete. The rowid is the source row's, which SQLite keeps for the life of the row as
+-- long as the database is not vacuumed.
+
+DROP TRIGGER IF EXISTS prompts_fts_insert;
+DROP TRIGGER IF EXISTS prompts_fts_update;
+DROP TRIGGER IF EXISTS prompts_fts_delete;
+DROP TRIGGER IF EXISTS snippets_fts_insert;
+DROP TRIGGER IF EXISTS snippets_fts_update;
+DROP TRIGGER IF EXISTS snippets_fts_delete;
+DROP TRIGGER IF EXISTS notes_fts_insert;
+DROP TRIGGER IF EXISTS notes_fts_update;
+DROP TRIGGER IF EXISTS notes_fts_delete;
+
+-- Prompts
+CREATE TRIGGER prompts_fts_insert AFTER INSERT ON prompts BEGIN
+    INSERT INTO prompts_fts (rowid, id, title, content, use_case)
+    VALUES (new.rowid, new.id, new.title, new.content, COALESCE(new.use_case, ''));
+END;
+
+CREATE TRIGGER prompts_fts_update AFTER UPDATE OF title, content, use_case ON prompts BEGIN
+    DELETE FROM prompts_fts WHERE rowid = old.rowid;
+    INSERT INTO prompts_fts (rowid, id, title, content, use_case)
+    VALUES (new.rowid, new.id, new.title, new.content, COALESCE(new.use_case, ''));
+END;
+
+CREATE TRIGGER prompts_fts_delete AFTER DELETE ON prompts BEGIN
+    DELETE FROM prompts_fts WHERE rowid = old.rowid;
+END;
+
+-- Snippets
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (rowid, id, title, content, description)
+    VALUES (new.rowid, new.id, new.title, new.content, COALESCE(new.description, ''));
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, content, description ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE rowid = old.rowid;
+    INSERT INTO snippets_fts (rowid, id, title, content, description)
+    VALUES (new.rowid, new.id, new.title, new.content, COALESCE(new.description, ''));
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    DELETE FROM snippets_fts WHERE rowid = old.rowid;
+END;
+
+-- Notes
+CREATE TRIGGER notes_fts_insert AFTER INSERT ON notes BEGIN
+    INSERT INTO notes_fts (rowid, id, prompt_id, title, body)
+    VALUES (new.rowid, new.id, new.prompt_id, new.title, COALESCE(new.body, ''));
+END;
+
+CREATE TRIGGER notes_fts_update AFTER UPDATE OF title, body ON notes BEGIN
+    DELETE FROM notes_fts WHERE rowid = old.rowid;
+    INSERT INTO notes_fts (rowid, id, prompt_id, title, body)
+    VALUES (new.rowid, new.id, new.prompt_id, new.title, COALESCE(new.body, ''));
+END;
+
+CREATE TRIGGER notes_fts_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM notes_fts WHERE rowid = old.rowid;
+END;
+
+-- Reindex existing rows under their source rowids
+DELETE FROM prompts_fts;
+INSERT INTO prompts_fts (rowid, id, title, content, use_case)
+SELECT rowid, id, title, content, COALESCE(use_case, '') FROM prompts;
+
+DELETE FROM snippets_fts;
+INSERT INTO snippets_fts (rowid, id, title, content, description)
+SELECT rowid, id, title, content, COALESCE(description, '') FROM snippets;
+
+DELETE FROM notes_fts;
+INSERT INTO notes_fts (rowid, id, prompt_id, title, body)
+SELECT rowid, id, prompt_id, title, COALESCE(body, '') FROM notes;
@@ -0,0 +1,345 @@
+package git
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dikkadev/proompt/server/internal/models"
+)
+
+// diffContextLines is the number of unchanged lines shown around each hunk
+const diffContextLines = 3
+
+// FieldChange describes a change to a single field between two versions
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// ContentDiff is a line-level diff of the content field
+type ContentDiff struct {
+	Unified   string `json:"unified"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// Diff is a structured diff between two versions of a prompt or snippet
+type Diff struct {
+	Fields  []FieldChange `json:"fields"`
+	Content ContentDiff   `json:"content"`
+}
+
+// HasChanges reports whether the two versions differ at all
+func (d *Diff) HasChanges() bool {
+	return len(d.Fields) > 0 || d.Content.Additions > 0 || d.Content.Deletions > 0
+}
+
+// DiffPrompts compares two prompts using the fields stored in git.
+// fromLabel and toLabel are used in the unified diff header.
+func DiffPrompts(from, to *models.Prompt, fromLabel, toLabel string) *Diff {
+	a := newPromptContent(from)
+	b := newPromptContent(to)
+
+	diff := &Diff{Fields: []FieldChange{}}
+	diff.addIfChanged("title", a.Title, b.Title)
+	diff.addIfChanged("type", a.Type, b.Type)
+	diff.addIfChanged("use_case", a.UseCase, b.UseCase)
+	diff.addIfChanged("model_compatibility", []string(a.ModelCompatibility), []string(b.ModelCompatibility))
+	diff.addIfChanged("tags", []string(a.Tags), []string(b.Tags))
+	diff.addParameterChanges(a.Parameters, b.Parameters)
+	diff.Content = diffContent(a.Content, b.Content, fromLabel, toLabel)
+
+	return diff
+}
+
+// DiffSnippets compares two snippets using the fields stored in git.
+// fromLabel and toLabel are used in the unified diff header.
+func DiffSnippets(from, to *models.Snippet, fromLabel, toLabel string) *Diff {
+	a := newSnippetContent(from)
+	b := newSnippetContent(to)
+
+	diff := &Diff{Fields: []FieldChange{}}
+	diff.addIfChanged("title", a.Title, b.Title)
+	diff.addIfChanged("tags", []string(a.Tags), []string(b.Tags))
+	diff.Content = diffContent(a.Content, b.Content, fromLabel, toLabel)
+
+	return diff
+}
+
+// addIfChanged records a field change when the values differ.
+// Empty slices are treated the same as nil.
+func (d *Diff) addIfChanged(field string, oldValue, newValue interface{}) {
+	if oldSlice, ok := oldValue.([]string); ok {
+		newSlice, _ := newValue.([]string)
+		if len(oldSlice) == 0 && len(newSlice) == 0 {
+			return
+		}
+	}
+
+	if reflect.DeepEqual(oldValue, newValue) {
+		return
+	}
+
+	d.Fields = append(d.Fields, FieldChange{Field: field, Old: oldValue, New: newValue})
+}
+
+// addParameterChanges records one change per added, removed or modified parameter key
+func (d *Diff) addParameterChanges(oldParams, newParams models.JSONMap) {
+	keys := make(map[string]struct{})
+	for key := range oldParams {
+		keys[key] = struct{}{}
+	}
+	for key := range newParams {
+		keys[key] = struct{}{}
+	}
+
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	for _, key := range sortedKeys {
+		oldValue, oldExists := oldParams[key]
+		newValue, newExists := newParams[key]
+		if oldExists && newExists && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		d.Fields = append(d.Fields, FieldChange{Field: "parameters." + key, Old: oldValue, New: newValue})
+	}
+}
+
+// diffLine is a single line of an edit script
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// diffContent produces a unified diff of two texts
+func diffContent(from, to, fromLabel, toLabel string) ContentDiff {
+	if from == to {
+		return ContentDiff{}
+	}
+
+	lines := diffLines(diffableLines(from), diffableLines(to))
+
+	result := ContentDiff{}
+	for _, line := range lines {
+		switch line.op {
+		case '+':
+			result.Additions++
+		case '-':
+			result.Deletions++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromLabel, toLabel)
+	writeHunks(&sb, lines)
+	result.Unified = sb.String()
+
+	return result
+}
+
+// splitLines splits text into lines without their trailing newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// noNewlineMarker follows a last line that has no newline, as in git's unified diffs
+const noNewlineMarker = "\n\\ No newline at end of file"
+
+// diffableLines splits text into lines for diffContent. A last line without a newline
+// carries noNewlineMarker, so adding or removing the final newline is a change to it.
+func diffableLines(text string) []string {
+	lines := splitLines(text)
+	if len(lines) > 0 && !strings.HasSuffix(text, "\n") {
+		lines[len(lines)-1] += noNewlineMarker
+	}
+	return lines
+}
+
+// diffLines computes a line edit script using the longest common subsequence. It uses
+// Hirschberg's algorithm, so memory stays linear in the length of the texts.
+func diffLines(a, b []string) []diffLine {
+	// Strip common prefix and suffix to keep the work small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		lines = append(lines, diffLine{op: ' ', text: line})
+	}
+
+	lines = diffMiddle(lines, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{op: ' ', text: line})
+	}
+
+	return groupChanges(lines)
+}
+
+// diffMiddle appends the edit script from a to b, splitting a in half and b where the
+// halves' common subsequences meet
+func diffMiddle(lines []diffLine, a, b []string) []diffLine {
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			lines = append(lines, diffLine{op: '+', text: line})
+		}
+		return lines
+	case len(b) == 0:
+		for _, line := range a {
+			lines = append(lines, diffLine{op: '-', text: line})
+		}
+		return lines
+	case len(a) == 1:
+		for j, line := range b {
+			if line == a[0] {
+				lines = diffMiddle(lines, nil, b[:j])
+				lines = append(lines, diffLine{op: ' ', text: line})
+				return diffMiddle(lines, nil, b[j+1:])
+			}
+		}
+		lines = append(lines, diffLine{op: '-', text: a[0]})
+		return diffMiddle(lines, nil, b)
+	}
+
+	mid := len(a) / 2
+	left := lcsLengths(a[:mid], b, false)
+	right := lcsLengths(a[mid:], b, true)
+
+	split, best := 0, -1
+	for j := 0; j <= len(b); j++ {
+		if length := left[j] + right[len(b)-j]; length > best {
+			split, best = j, length
+		}
+	}
+
+	lines = diffMiddle(lines, a[:mid], b[:split])
+	return diffMiddle(lines, a[mid:], b[split:])
+}
+
+// lcsLengths returns the LCS length of a with every prefix of b, or with every suffix of b
+// when backward is set, keeping only one row of the LCS table at a time
+func lcsLengths(a, b []string, backward bool) []int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for i := range a {
+		lineA := a[i]
+		if backward {
+			lineA = a[len(a)-1-i]
+		}
+		for j := range b {
+			lineB := b[j]
+			if backward {
+				lineB = b[len(b)-1-j]
+			}
+			if lineA == lineB {
+				curr[j+1] = prev[j] + 1
+			} else {
+				curr[j+1] = max(prev[j+1], curr[j])
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev
+}
+
+// groupChanges moves the deletions of each run of changes before its additions
+func groupChanges(lines []diffLine) []diffLine {
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		end := start
+		for end < len(lines) && lines[end].op != ' ' {
+			end++
+		}
+		sort.SliceStable(lines[start:end], func(i, j int) bool {
+			return lines[start+i].op == '-' && lines[start+j].op == '+'
+		})
+		start = end
+	}
+	return lines
+}
+
+// writeHunks writes the edit script as unified diff hunks with surrounding context
+func writeHunks(sb *strings.Builder, lines []diffLine) {
+	for start := 0; start < len(lines); {
+		// Find the next change
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			return
+		}
+
+		// Extend the hunk while changes are within twice the context of each other
+		last := first
+		for next := first; next < len(lines); next++ {
+			if lines[next].op != ' ' {
+				if next-last > 2*diffContextLines {
+					break
+				}
+				last = next
+			}
+		}
+
+		hunkStart := max(first-diffContextLines, start)
+		hunkEnd := min(last+diffContextLines+1, len(lines))
+
+		// Line numbers are 1-based positions in the old and new texts
+		oldLine, newLine := 1, 1
+		for _, line := range lines[:hunkStart] {
+			if line.op != '+' {
+				oldLine++
+			}
+			if line.op != '-' {
+				newLine++
+			}
+		}
+
+		oldCount, newCount := 0, 0
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.op != '+' {
+				oldCount++
+			}
+			if line.op != '-' {
+				newCount++
+			}
+		}
+
+		// An empty range points at the line before it, as in GNU diff
+		if oldCount == 0 {
+			oldLine--
+		}
+		if newCount == 0 {
+			newLine--
+		}
+
+		fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, line := range lines[hunkStart:hunkEnd] {
+			sb.WriteByte(line.op)
+			sb.WriteString(line.text)
+			sb.WriteByte('\n')
+		}
+
+		start = hunkEnd
+	}
+}
@@ -0,0 +1,189 @@
+package git
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dikkadev/proompt/server/internal/models"
+)
+
+func TestDiffPrompts(t *testing.T) {
+	useCase := "support"
+	from := &models.Prompt{
+		ID:                     "p1",
+		Title:                  "Greeting",
+		Content:                "line 1\nline 2\nline 3\n",
+		Type:                   models.PromptTypeUser,
+		ModelCompatibilityTags: models.StringSlice{"gpt-4"},
+		OtherParameters:        models.JSONMap{"max_tokens": float64(100), "top_p": float64(1)},
+	}
+	to := &models.Prompt{
+		ID:                     "p1",
+		Title:                  "Greeting v2",
+		Content:                "line 1\nline two\nline 3\nline 4\n",
+		Type:                   models.PromptTypeUser,
+		UseCase:                &useCase,
+		ModelCompatibilityTags: models.StringSlice{"gpt-4"},
+		OtherParameters:        models.JSONMap{"max_tokens": float64(200), "stop": "END"},
+	}
+
+	diff := DiffPrompts(from, to, "aaaa", "bbbb")
+
+	changed := make(map[string]FieldChange)
+	for _, change := range diff.Fields {
+		changed[change.Field] = change
+	}
+
+	for _, field := range []string{"title", "use_case", "parameters.max_tokens", "parameters.stop", "parameters.top_p"} {
+		if _, ok := changed[field]; !ok {
+			t.Errorf("Expected change for field %s", field)
+		}
+	}
+	for _, field := range []string{"type", "model_compatibility"} {
+		if _, ok := changed[field]; ok {
+			t.Errorf("Did not expect change for field %s", field)
+		}
+	}
+
+	if diff.Content.Additions != 2 || diff.Content.Deletions != 1 {
+		t.Errorf("Expected 2 additions and 1 deletion, got %d and %d", diff.Content.Additions, diff.Content.Deletions)
+	}
+
+	expected := "--- aaaa\n+++ bbbb\n" +
+		"@@ -1,3 +1,4 @@\n" +
+		" line 1\n" +
+		"-line 2\n" +
+		"+line two\n" +
+		" line 3\n" +
+		"+line 4\n"
+	if diff.Content.Unified != expected {
+		t.Errorf("Unexpected unified diff:\n%s\nwant:\n%s", diff.Content.Unified, expected)
+	}
+}
+
+func TestDiffPromptsUnchanged(t *testing.T) {
+	prompt := &models.Prompt{
+		ID:      "p1",
+		Title:   "Same",
+		Content: "content",
+		Type:    models.PromptTypeSystem,
+	}
+
+	diff := DiffPrompts(prompt, prompt, "aaaa", "current")
+	if diff.HasChanges() {
+		t.Errorf("Expected no changes, got %+v", diff)
+	}
+	if diff.Content.Unified != "" {
+		t.Errorf("Expected empty unified diff, got %q", diff.Content.Unified)
+	}
+}
+
+func TestDiffContentSeparateHunks(t *testing.T) {
+	var from, to string
+	for i := 1; i <= 20; i++ {
+		line := string(rune('a'+i-1)) + "\n"
+		from += line
+		switch i {
+		case 2:
+			to += "changed b\n"
+		case 18:
+			to += "changed r\n"
+		default:
+			to += line
+		}
+	}
+
+	result := diffContent(from, to, "a", "b")
+
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+changed b\n c\n d\n e\n" +
+		"@@ -15,6 +15,6 @@\n o\n p\n q\n-r\n+changed r\n s\n t\n"
+	if result.Unified != expected {
+		t.Errorf("Unexpected unified diff:\n%s\nwant:\n%s", result.Unified, expected)
+	}
+}
+
+func TestDiffContentTrailingNewline(t *testing.T) {
+	result := diffContent("a\nb\n", "a\nb", "a", "b")
+
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n"
+	if result.Unified != expected {
+		t.Errorf("Unexpected unified diff:\n%s\nwant:\n%s", result.Unified, expected)
+	}
+	if result.Additions != 1 || result.Deletions != 1 {
+		t.Errorf("Expected the last line to change, got +%d -%d", result.Additions, result.Deletions)
+	}
+
+	diff := &Diff{Content: result}
+	if !diff.HasChanges() {
+		t.Error("Expected a removed trailing newline to count as a change")
+	}
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		changes int // Lines added plus lines deleted
+	}{
+		{a: "a b c d e f", b: "a x c d y f", changes: 4},
+		{a: "a b c a b b a", b: "c b a b a c", changes: 5},
+		{a: "x y z", b: "p q", changes: 5},
+		{a: "a b c d", b: "d c b a", changes: 6},
+		{a: "", b: "a b", changes: 2},
+	}
+
+	for _, tt := range tests {
+		a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+		lines := diffLines(a, b)
+
+		var gotA, gotB []string
+		changes := 0
+		for _, line := range lines {
+			if line.op != '+' {
+				gotA = append(gotA, line.text)
+			}
+			if line.op != '-' {
+				gotB = append(gotB, line.text)
+			}
+			if line.op != ' ' {
+				changes++
+			}
+		}
+		if strings.Join(gotA, " ") != tt.a || strings.Join(gotB, " ") != tt.b {
+			t.Errorf("Edit script for %q -> %q does not reproduce both texts: %v", tt.a, tt.b, lines)
+		}
+		if changes != tt.changes {
+			t.Errorf("Expected %d changes for %q -> %q, got %d", tt.changes, tt.a, tt.b, changes)
+		}
+	}
+}
+
+func TestPromptContentVariablesAndTags(t *testing.T) {
+	prompt := &models.Prompt{
+		ID:      "p1",
+		Title:   "Greeting",
+		Content: "Hello {{name}}, welcome to {{place:home}}. Bye {{name}}",
+		Tags:    []string{"zeta", "alpha"},
+	}
+
+	content := newPromptContent(prompt)
+
+	if got := strings.Join(content.Variables, ","); got != "name,place" {
+		t.Errorf("Expected variables name,place, got %s", got)
+	}
+	if got := strings.Join(content.Tags, ","); got != "alpha,zeta" {
+		t.Errorf("Expected sorted tags alpha,zeta, got %s", got)
+	}
+	if prompt.Tags[0] != "zeta" {
+		t.Errorf("Expected the prompt's tags to be left unsorted, got %v", prompt.Tags)
+	}
+
+	// Tag changes show up in diffs
+	tagged := *prompt
+	tagged.Tags = []string{"alpha"}
+	diff := DiffPrompts(prompt, &tagged, "a", "b")
+	if len(diff.Fields) != 1 || diff.Fields[0].Field != "tags" {
+		t.Errorf("Expected a tags change, got %+v", diff.Fields)
+	}
+}
@@ -0,0 +1,212 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// refNamePattern limits draft names and release labels to characters that are safe in a
+// ref name and a URL path
+var refNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// validateRefName rejects names that would not make a valid last component of a ref,
+// returning invalid wrapped with an explanation
+func validateRefName(name string, invalid error) error {
+	if !refNamePattern.MatchString(name) || strings.Contains(name, "..") || strings.HasSuffix(name, ".") || strings.HasSuffix(name, ".lock") {
+		return fmt.Errorf("%w '%s': use up to 64 letters, digits, '.', '_' or '-'", invalid, name)
+	}
+	return nil
+}
+
+// CreatePromptDraft forks a draft from the current head of a prompt's branch. The draft
+// starts out identical to the prompt.
+func (s *gitService) CreatePromptDraft(ctx context.Context, promptID, name string) (*PromptDraft, error) {
+	if err := validateRefName(name, ErrInvalidDraftName); err != nil {
+		return nil, err
+	}
+
+	branchName := DraftBranch(promptID, name)
+	s.logger.Debug("Creating prompt draft", "branch", branchName)
+
+	if err := s.forkBranch(PromptBranch(promptID), branchName); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Prompt draft created successfully", "branch", branchName)
+	return s.GetPromptDraft(ctx, promptID, name)
+}
+
+// GetPromptDraft returns a draft of a prompt with the prompt as it is on the draft
+func (s *gitService) GetPromptDraft(ctx context.Context, promptID, name string) (*PromptDraft, error) {
+	head, err := s.BranchHead(ctx, DraftBranch(promptID, name))
+	if err != nil {
+		return nil, err
+	}
+	if head == "" {
+		return nil, fmt.Errorf("%w: %s", ErrDraftNotFound, name)
+	}
+
+	base, err := s.draftBase(promptID, head)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := s.GetPromptVersion(ctx, promptID, head)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PromptDraft{
+		Name:   name,
+		Base:   base,
+		Head:   head,
+		Prompt: prompt,
+	}, nil
+}
+
+// ListPromptDrafts returns the drafts of a prompt, sorted by name
+func (s *gitService) ListPromptDrafts(ctx context.Context, promptID string) ([]PromptDraft, error) {
+	names, err := s.listBranchIDs(DraftBranch(promptID, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	drafts := make([]PromptDraft, 0, len(names))
+	for _, name := range names {
+		draft, err := s.GetPromptDraft(ctx, promptID, name)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, *draft)
+	}
+	return drafts, nil
+}
+
+// UpdatePromptDraft commits a new version of prompt on one of its drafts. Notes and links
+// stay as they were when the draft was created.
+func (s *gitService) UpdatePromptDraft(ctx context.Context, prompt *models.Prompt, name string, userNote string) error {
+	branchName := DraftBranch(prompt.ID, name)
+	s.logger.Debug("Updating prompt draft", "branch", branchName, "title", prompt.Title)
+
+	head, err := s.BranchHead(ctx, branchName)
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		return fmt.Errorf("%w: %s", ErrDraftNotFound, name)
+	}
+
+	commitMessage := fmt.Sprintf("Update draft %s: %s", name, prompt.Title)
+	if userNote != "" {
+		commitMessage += "\n\n" + userNote
+	}
+
+	if err := s.updateBranchWithContent(branchName, "content.json", newPromptContent(prompt), commitMessage); err != nil {
+		return fmt.Errorf("failed to update branch with content: %w", err)
+	}
+
+	s.logger.Info("Prompt draft updated successfully", "branch", branchName, "title", prompt.Title)
+	return nil
+}
+
+// DeletePromptDraft deletes the branch of a draft. Commits already promoted stay reachable
+// from the prompt's branch.
+func (s *gitService) DeletePromptDraft(ctx context.Context, promptID, name string) error {
+	branchName := DraftBranch(promptID, name)
+	s.logger.Debug("Deleting prompt draft", "branch", branchName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	branchRef := plumbing.NewBranchReferenceName(branchName)
+	if _, err := s.repo.Storer.Reference(branchRef); err == plumbing.ErrReferenceNotFound {
+		return fmt.Errorf("%w: %s", ErrDraftNotFound, name)
+	} else if err != nil {
+		return fmt.Errorf("failed to get branch reference: %w", err)
+	}
+
+	if err := s.repo.Storer.RemoveReference(branchRef); err != nil {
+		return fmt.Errorf("failed to delete branch: %w", err)
+	}
+
+	s.logger.Info("Prompt draft deleted successfully", "branch", branchName)
+	return nil
+}
+
+// PromotePromptDraft records prompt, the result of merging draft into it, as a merge commit
+// on the prompt's branch whose parents are the branch head and the draft's head. Only
+// content.json changes, so the notes and links on the prompt's branch are kept.
+func (s *gitService) PromotePromptDraft(ctx context.Context, prompt *models.Prompt, draft *PromptDraft, userNote string) error {
+	branchName := PromptBranch(prompt.ID)
+	s.logger.Debug("Promoting prompt draft", "branch", branchName, "draft", draft.Name, "head", draft.Head)
+
+	commitMessage := fmt.Sprintf("Promote draft %s: %s", draft.Name, prompt.Title)
+	if userNote != "" {
+		commitMessage += "\n\n" + userNote
+	}
+
+	files := map[string]interface{}{"content.json": newPromptContent(prompt)}
+	if err := s.updateBranchFiles(branchName, files, nil, commitMessage, plumbing.NewHash(draft.Head)); err != nil {
+		return fmt.Errorf("failed to merge draft: %w", err)
+	}
+
+	s.logger.Info("Prompt draft promoted successfully", "branch", branchName, "draft", draft.Name)
+	return nil
+}
+
+// forkBranch creates branch pointing at the head of the existing branch from
+func (s *gitService) forkBranch(from, branch string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	source, err := s.repo.Storer.Reference(plumbing.NewBranchReferenceName(from))
+	if err != nil {
+		return fmt.Errorf("failed to get branch reference: %w", err)
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	if _, err := s.repo.Storer.Reference(branchRef); err == nil {
+		return fmt.Errorf("%w: %s", ErrDraftExists, branch)
+	} else if err != plumbing.ErrReferenceNotFound {
+		return fmt.Errorf("failed to get branch reference: %w", err)
+	}
+
+	if err := s.repo.Storer.SetReference(plumbing.NewHashReference(branchRef, source.Hash())); err != nil {
+		return fmt.Errorf("failed to create branch: %w", err)
+	}
+	return nil
+}
+
+// draftBase returns the best common ancestor of a draft's head and its prompt's branch.
+// After a promotion that is the promoted draft head, so later changes merge on top of it.
+func (s *gitService) draftBase(promptID, head string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ref, err := s.repo.Storer.Reference(plumbing.NewBranchReferenceName(PromptBranch(promptID)))
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch reference: %w", err)
+	}
+	promptCommit, err := s.repo.CommitObject(ref.Hash())
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch head commit: %w", err)
+	}
+	draftCommit, err := s.repo.CommitObject(plumbing.NewHash(head))
+	if err != nil {
+		return "", fmt.Errorf("failed to get draft head commit: %w", err)
+	}
+
+	bases, err := draftCommit.MergeBase(promptCommit)
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge base: %w", err)
+	}
+	if len(bases) == 0 {
+		return "", fmt.Errorf("draft at %s shares no history with prompt %s", shortHash(head), promptID)
+	}
+	return bases[0].Hash.String(), nil
+}
@@ -2,6 +2,7 @@ package git
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/dikkadev/proompt/server/internal/models"
@@ -16,22 +17,103 @@ type GitService interface {
 	CreatePromptBranch(ctx context.Context, prompt *models.Prompt, userNote string) error
 	UpdatePromptBranch(ctx context.Context, prompt *models.Prompt, userNote string) error
 	DeletePromptBranch(ctx context.Context, promptID string) error
+	RestorePromptBranch(ctx context.Context, prompt *models.Prompt, commitHash string, userNote string) error
+	// UpdatePromptTags records a tag change; prompt.Tags holds the tags after the change
+	UpdatePromptTags(ctx context.Context, prompt *models.Prompt, added, removed []string) error
+	// UpdatePromptNotesAndLinks records the notes and outgoing links a prompt has after a change
+	UpdatePromptNotesAndLinks(ctx context.Context, promptID string, notes []*models.Note, links []*models.PromptLink, message string) error
+
+	// Prompt drafts: experimental variants kept on their own branches until promoted
+	// CreatePromptDraft forks a draft from the current head of a prompt's branch
+	CreatePromptDraft(ctx context.Context, promptID, name string) (*PromptDraft, error)
+	GetPromptDraft(ctx context.Context, promptID, name string) (*PromptDraft, error)
+	ListPromptDrafts(ctx context.Context, promptID string) ([]PromptDraft, error)
+	// UpdatePromptDraft commits a new version of prompt on one of its drafts
+	UpdatePromptDraft(ctx context.Context, prompt *models.Prompt, name string, userNote string) error
+	DeletePromptDraft(ctx context.Context, promptID, name string) error
+	// PromotePromptDraft records prompt, the result of merging draft into it, as a merge
+	// commit on the prompt's branch. The draft's branch is left in place.
+	PromotePromptDraft(ctx context.Context, prompt *models.Prompt, draft *PromptDraft, userNote string) error
+
+	// Prompt releases: labels such as "v1.2" or "production" on a version, stored as git tags
+	// CreatePromptRelease labels a version of a prompt, its current head when commitHash is "".
+	// An existing label is only moved when force is set.
+	CreatePromptRelease(ctx context.Context, promptID, label, commitHash string, force bool) (*PromptRelease, error)
+	ListPromptReleases(ctx context.Context, promptID string) ([]PromptRelease, error)
+	DeletePromptRelease(ctx context.Context, promptID, label string) error
+	// ResolvePromptRelease returns the commit a label of a prompt points at
+	ResolvePromptRelease(ctx context.Context, promptID, label string) (string, error)
 
 	// Snippet operations
 	CreateSnippetBranch(ctx context.Context, snippet *models.Snippet, userNote string) error
 	UpdateSnippetBranch(ctx context.Context, snippet *models.Snippet, userNote string) error
 	DeleteSnippetBranch(ctx context.Context, snippetID string) error
+	RestoreSnippetBranch(ctx context.Context, snippet *models.Snippet, commitHash string, userNote string) error
+	// UpdateSnippetTags records a tag change; snippet.Tags holds the tags after the change
+	UpdateSnippetTags(ctx context.Context, snippet *models.Snippet, added, removed []string) error
 
 	// History and versioning
 	GetPromptHistory(ctx context.Context, promptID string) ([]GitCommit, error)
 	GetSnippetHistory(ctx context.Context, snippetID string) ([]GitCommit, error)
 	GetPromptVersion(ctx context.Context, promptID string, commitHash string) (*models.Prompt, error)
 	GetSnippetVersion(ctx context.Context, snippetID string, commitHash string) (*models.Snippet, error)
+	GetPromptSnapshot(ctx context.Context, promptID string, commitHash string) (*PromptSnapshot, error)
+
+	// ListPromptIDs returns the ids of all prompts that have a branch
+	ListPromptIDs(ctx context.Context) ([]string, error)
+	// ListSnippetIDs returns the ids of all snippets that have a branch
+	ListSnippetIDs(ctx context.Context) ([]string, error)
+
+	// Branch refs, used to undo commits whose database transaction failed
+	// BranchHead returns the commit a branch points to, or "" if the branch does not exist
+	BranchHead(ctx context.Context, branch string) (string, error)
+	// ResetBranch points a branch at commitHash, deleting the branch when commitHash is "".
+	// It returns ErrBranchMoved unless the branch still points at expected, where ""
+	// means the branch must not exist.
+	ResetBranch(ctx context.Context, branch string, expected string, commitHash string) error
+
+	// Remote sync
+	// PullRemote fetches the prompt and snippet branches of a configured remote ("" for the
+	// first one) and fast-forwards local branches that are behind or missing. Branches that
+	// need a push, a deletion or a merge are only reported.
+	PullRemote(ctx context.Context, remote string) (*PullResult, error)
+	// PushRemote makes branches on a remote match the local ones, deleting those that no
+	// longer exist locally. Updates that are not fast-forwards are rejected.
+	PushRemote(ctx context.Context, remote string, branches []string) error
 
 	// Repository health
 	ValidateRepo(ctx context.Context) error
 }
 
+// PromptBranch returns the name of the branch holding a prompt's history
+func PromptBranch(promptID string) string {
+	return "prompts/" + promptID
+}
+
+// SnippetBranch returns the name of the branch holding a snippet's history
+func SnippetBranch(snippetID string) string {
+	return "snippets/" + snippetID
+}
+
+// DraftBranch returns the name of the branch holding a draft of a prompt. Drafts live
+// outside prompts/, since a prompt's branch name cannot also be a directory of branches.
+func DraftBranch(promptID, name string) string {
+	return "drafts/" + PromptBranch(promptID) + "/" + name
+}
+
+// ReleaseTag returns the name of the tag marking a release of a prompt
+func ReleaseTag(promptID, label string) string {
+	return PromptBranch(promptID) + "/" + label
+}
+
+// Files stored next to content.json on a prompt's branch
+const (
+	// notesDir holds one <note id>.json file per note
+	notesDir = "notes"
+	// linksFile holds the prompt's outgoing links
+	linksFile = "links.json"
+)
+
 // GitCommit represents a git commit with metadata
 type GitCommit struct {
 	Hash      string    `json:"hash"`
@@ -67,3 +149,104 @@ type SnippetContent struct {
 	CreatedAt time.Time          `json:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at"`
 }
+
+// NoteContent represents the content stored in git for a note, on its prompt's branch
+type NoteContent struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Body      *string   `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LinkContent represents an outgoing link stored in git on the source prompt's branch
+type LinkContent struct {
+	ToPromptID string    `json:"to_prompt_id"`
+	LinkType   string    `json:"link_type"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PromptSnapshot is a prompt with its notes and outgoing links at one version.
+// Notes and Links are nil for versions committed before they were stored in git.
+type PromptSnapshot struct {
+	Prompt *models.Prompt
+	Notes  []*models.Note
+	Links  []*models.PromptLink
+}
+
+// PromptDraft is an experimental variant of a prompt, kept on its own branch
+type PromptDraft struct {
+	Name string
+	// Base is the latest commit of the prompt's branch the draft contains, the version
+	// its changes are relative to
+	Base string
+	// Head is the latest commit on the draft's branch
+	Head   string
+	Prompt *models.Prompt
+}
+
+// PromptRelease is a label pointing at a version of a prompt
+type PromptRelease struct {
+	Label  string
+	Commit string
+	// Timestamp is when the labelled version was committed
+	Timestamp time.Time
+}
+
+// ErrUnknownRemote is returned when a remote is not configured
+var ErrUnknownRemote = errors.New("unknown remote")
+
+// ErrBranchMoved is returned by ResetBranch when the branch no longer points at the
+// expected commit
+var ErrBranchMoved = errors.New("branch moved")
+
+var (
+	// ErrDraftNotFound is returned when a prompt has no draft with the given name
+	ErrDraftNotFound = errors.New("draft not found")
+	// ErrDraftExists is returned when creating a draft whose name is taken
+	ErrDraftExists = errors.New("draft already exists")
+	// ErrInvalidDraftName is returned for names that cannot be used in a branch name
+	ErrInvalidDraftName = errors.New("invalid draft name")
+
+	// ErrReleaseNotFound is returned when a prompt has no release with the given label
+	ErrReleaseNotFound = errors.New("release not found")
+	// ErrReleaseExists is returned when creating a release whose label is taken
+	ErrReleaseExists = errors.New("release already exists")
+	// ErrInvalidReleaseLabel is returned for labels that cannot be used in a tag name
+	ErrInvalidReleaseLabel = errors.New("invalid release label")
+)
+
+// SyncState describes how a local branch relates to the same branch on a remote
+type SyncState string
+
+const (
+	SyncUpToDate SyncState = "up_to_date"
+	// SyncPulled branches were fast-forwarded to, or created from, the remote
+	SyncPulled SyncState = "pulled"
+	// SyncAhead branches have local commits the remote lacks
+	SyncAhead SyncState = "ahead"
+	// SyncLocalOnly branches have never been pushed
+	SyncLocalOnly SyncState = "local_only"
+	// SyncDeletedLocally branches were deleted locally since the last sync
+	SyncDeletedLocally SyncState = "deleted_locally"
+	// SyncDeletedRemotely branches were deleted on the remote and are unchanged locally
+	SyncDeletedRemotely SyncState = "deleted_remotely"
+	// SyncDiverged branches changed on both sides and need a manual merge
+	SyncDiverged SyncState = "diverged"
+)
+
+// BranchSync is the state of one branch after pulling from a remote
+type BranchSync struct {
+	Branch string    `json:"branch"`
+	State  SyncState `json:"state"`
+	// Local is the local head before the pull, or "" if the branch did not exist locally
+	Local string `json:"local,omitempty"`
+	// Remote is the head on the remote, or "" if the branch does not exist there
+	Remote string `json:"remote,omitempty"`
+}
+
+// PullResult lists every prompt and snippet branch known locally or on the remote
+type PullResult struct {
+	Remote   string
+	Branches []BranchSync
+}
@@ -0,0 +1,190 @@
+package git
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/dikkadev/proompt/server/internal/models"
+)
+
+// Labels of the two sides in the conflict markers of a draft promotion
+const (
+	draftLabel   = "draft"
+	currentLabel = "current"
+)
+
+// ContentMerge is the result of a three-way merge of the content field
+type ContentMerge struct {
+	// Content is the merged text; conflicting regions are wrapped in conflict markers
+	Content   string `json:"content"`
+	Conflicts int    `json:"conflicts"`
+}
+
+// Clean reports whether the merge needed no manual resolution
+func (m *ContentMerge) Clean() bool {
+	return m.Conflicts == 0
+}
+
+// MergeContent merges the changes ours and theirs made to base, line by line. Regions
+// both sides changed differently are kept as conflicts, labelled with oursLabel and
+// theirsLabel the way git labels conflict markers.
+func MergeContent(base, ours, theirs, oursLabel, theirsLabel string) *ContentMerge {
+	baseLines := splitLines(base)
+	oursChanges := lineChanges(diffLines(baseLines, splitLines(ours)))
+	theirsChanges := lineChanges(diffLines(baseLines, splitLines(theirs)))
+
+	merge := &ContentMerge{}
+	var out []string
+	pos := 0
+
+	for len(oursChanges) > 0 || len(theirsChanges) > 0 {
+		// Collect the next group of changes, from either side, whose base ranges touch
+		var groupOurs, groupTheirs []lineChange
+		start, end := -1, -1
+		for {
+			fromOurs := len(oursChanges) > 0 && (len(theirsChanges) == 0 || oursChanges[0].start <= theirsChanges[0].start)
+			var next lineChange
+			switch {
+			case fromOurs:
+				next = oursChanges[0]
+			case len(theirsChanges) > 0:
+				next = theirsChanges[0]
+			default:
+				next.start = len(baseLines) + 1
+			}
+			if start >= 0 && next.start > end {
+				break
+			}
+
+			if fromOurs {
+				groupOurs = append(groupOurs, next)
+				oursChanges = oursChanges[1:]
+			} else {
+				groupTheirs = append(groupTheirs, next)
+				theirsChanges = theirsChanges[1:]
+			}
+			if start < 0 {
+				start = next.start
+			}
+			end = max(end, next.end)
+		}
+
+		out = append(out, baseLines[pos:start]...)
+		pos = end
+
+		oursText := applyChanges(baseLines, start, end, groupOurs)
+		theirsText := applyChanges(baseLines, start, end, groupTheirs)
+		switch {
+		case len(groupTheirs) == 0:
+			out = append(out, oursText...)
+		case len(groupOurs) == 0, slices.Equal(oursText, theirsText):
+			out = append(out, theirsText...)
+		default:
+			merge.Conflicts++
+			out = append(out, "<<<<<<< "+oursLabel)
+			out = append(out, oursText...)
+			out = append(out, "=======")
+			out = append(out, theirsText...)
+			out = append(out, ">>>>>>> "+theirsLabel)
+		}
+	}
+	out = append(out, baseLines[pos:]...)
+
+	merge.Content = strings.Join(out, "\n")
+	if len(out) > 0 && (strings.HasSuffix(ours, "\n") || strings.HasSuffix(theirs, "\n")) {
+		merge.Content += "\n"
+	}
+	return merge
+}
+
+// MergePrompt applies the changes a draft made to base onto current, the prompt as it is on
+// its own branch now. Every versioned field the draft changed takes the draft's value; the
+// content is merged line by line when both sides changed it. Fields git does not store
+// are taken from current.
+func MergePrompt(base, current, draft *models.Prompt) (*models.Prompt, *ContentMerge) {
+	from := newPromptContent(base)
+	to := newPromptContent(draft)
+	merged := *current
+
+	if to.Title != from.Title {
+		merged.Title = draft.Title
+	}
+	if to.Type != from.Type {
+		merged.Type = draft.Type
+	}
+	if to.UseCase != from.UseCase {
+		merged.UseCase = draft.UseCase
+	}
+	if !slices.Equal(to.ModelCompatibility, from.ModelCompatibility) {
+		merged.ModelCompatibilityTags = draft.ModelCompatibilityTags
+	}
+	if !slices.Equal(to.Tags, from.Tags) {
+		merged.Tags = draft.Tags
+	}
+	if (len(to.Parameters) > 0 || len(from.Parameters) > 0) && !reflect.DeepEqual(to.Parameters, from.Parameters) {
+		merged.OtherParameters = draft.OtherParameters
+	}
+
+	var content *ContentMerge
+	switch {
+	case draft.Content == base.Content:
+		content = &ContentMerge{Content: current.Content}
+	case current.Content == base.Content:
+		content = &ContentMerge{Content: draft.Content}
+	default:
+		content = MergeContent(base.Content, draft.Content, current.Content, draftLabel, currentLabel)
+	}
+	merged.Content = content.Content
+
+	return &merged, content
+}
+
+// lineChange replaces base lines [start, end) with lines
+type lineChange struct {
+	start, end int
+	lines      []string
+}
+
+// lineChanges turns an edit script against base into the base ranges it replaces
+func lineChanges(script []diffLine) []lineChange {
+	var changes []lineChange
+	var current *lineChange
+	pos := 0
+
+	for _, line := range script {
+		if line.op == ' ' {
+			if current != nil {
+				changes = append(changes, *current)
+				current = nil
+			}
+			pos++
+			continue
+		}
+		if current == nil {
+			current = &lineChange{start: pos, end: pos}
+		}
+		if line.op == '-' {
+			pos++
+			current.end = pos
+		} else {
+			current.lines = append(current.lines, line.text)
+		}
+	}
+	if current != nil {
+		changes = append(changes, *current)
+	}
+	return changes
+}
+
+// applyChanges returns base lines [start, end) with the changes of one side applied
+func applyChanges(base []string, start, end int, changes []lineChange) []string {
+	result := []string{}
+	pos := start
+	for _, change := range changes {
+		result = append(result, base[pos:change.start]...)
+		result = append(result, change.lines...)
+		pos = change.end
+	}
+	return append(result, base[pos:end]...)
+}
@@ -0,0 +1,105 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/dikkadev/proompt/server/internal/models"
+)
+
+func TestMergeContent(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\nfive\n"
+
+	tests := []struct {
+		name      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{
+			name:   "changes to different lines",
+			ours:   "ONE\ntwo\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\nthree\nfour\nFIVE\n",
+			want:   "ONE\ntwo\nthree\nfour\nFIVE\n",
+		},
+		{
+			name:   "same change on both sides",
+			ours:   "one\nTWO\nthree\nfour\nfive\n",
+			theirs: "one\nTWO\nthree\nfour\nfive\n",
+			want:   "one\nTWO\nthree\nfour\nfive\n",
+		},
+		{
+			name:   "insertion and deletion",
+			ours:   "zero\none\ntwo\nthree\nfour\nfive\n",
+			theirs: "one\ntwo\nfour\nfive\n",
+			want:   "zero\none\ntwo\nfour\nfive\n",
+		},
+		{
+			name:      "conflicting changes",
+			ours:      "one\ntwo\nmine\nfour\nfive\n",
+			theirs:    "one\ntwo\ntheirs\nfour\nfive\n",
+			want:      "one\ntwo\n<<<<<<< yours\nmine\n=======\ntheirs\n>>>>>>> current\nfour\nfive\n",
+			conflicts: 1,
+		},
+		{
+			name:      "appends on both sides",
+			ours:      base + "six\n",
+			theirs:    base + "6\n",
+			want:      base + "<<<<<<< yours\nsix\n=======\n6\n>>>>>>> current\n",
+			conflicts: 1,
+		},
+		{
+			name:   "only one side changed",
+			ours:   base,
+			theirs: "one\nthree\nfive\n",
+			want:   "one\nthree\nfive\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merge := MergeContent(base, tt.ours, tt.theirs, "yours", "current")
+			if merge.Content != tt.want {
+				t.Errorf("Content = %q, want %q", merge.Content, tt.want)
+			}
+			if merge.Conflicts != tt.conflicts {
+				t.Errorf("Conflicts = %d, want %d", merge.Conflicts, tt.conflicts)
+			}
+			if merge.Clean() != (tt.conflicts == 0) {
+				t.Errorf("Clean() = %v with %d conflicts", merge.Clean(), merge.Conflicts)
+			}
+		})
+	}
+}
+
+func TestMergePrompt(t *testing.T) {
+	useCase := "chat"
+	base := &models.Prompt{ID: "p1", Title: "Base", Content: "one\ntwo\nthree\n", Type: models.PromptTypeUser, UseCase: &useCase}
+
+	current := *base
+	temperature := 0.5
+	current.Content = "ONE\ntwo\nthree\n"
+	current.TemperatureSuggestion = &temperature
+
+	draft := *base
+	draft.Title = "Draft"
+	draft.Content = "one\ntwo\nTHREE\n"
+	draft.Tags = []string{"experimental"}
+
+	merged, content := MergePrompt(base, &current, &draft)
+	if !content.Clean() || merged.Content != "ONE\ntwo\nTHREE\n" {
+		t.Errorf("Expected a clean content merge, got %q with %d conflicts", merged.Content, content.Conflicts)
+	}
+	if merged.Title != "Draft" || len(merged.Tags) != 1 {
+		t.Errorf("Expected the draft's title and tags, got %q and %v", merged.Title, merged.Tags)
+	}
+	if merged.UseCase != &useCase || merged.TemperatureSuggestion != &temperature {
+		t.Error("Expected fields the draft did not change to come from current")
+	}
+
+	draft.Content = "ONE!\ntwo\nthree\n"
+	merged, content = MergePrompt(base, &current, &draft)
+	if content.Conflicts != 1 || merged.Content != "<<<<<<< draft\nONE!\n=======\nONE\n>>>>>>> current\ntwo\nthree\n" {
+		t.Errorf("Expected one conflict, got %q with %d conflicts", merged.Content, content.Conflicts)
+	}
+}
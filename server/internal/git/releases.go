@@ -0,0 +1,174 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// CreatePromptRelease labels a version of a prompt with a lightweight tag. The version is
+// the head of the prompt's branch when commitHash is "", and must belong to the prompt
+// otherwise. An existing label is only moved when force is set.
+func (s *gitService) CreatePromptRelease(ctx context.Context, promptID, label, commitHash string, force bool) (*PromptRelease, error) {
+	if err := validateRefName(label, ErrInvalidReleaseLabel); err != nil {
+		return nil, err
+	}
+
+	tagName := ReleaseTag(promptID, label)
+	s.logger.Debug("Creating prompt release", "tag", tagName, "commit", commitHash)
+
+	if commitHash == "" {
+		head, err := s.BranchHead(ctx, PromptBranch(promptID))
+		if err != nil {
+			return nil, err
+		}
+		if head == "" {
+			return nil, fmt.Errorf("prompt %s has no branch", promptID)
+		}
+		commitHash = head
+	}
+
+	// Reading the version checks the commit belongs to the prompt and resolves abbreviated hashes
+	version, err := s.GetPromptVersion(ctx, promptID, commitHash)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := s.resolveCommit(*version.GitRef)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.setTag(tagName, commit.Hash, force); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Prompt release created successfully", "tag", tagName, "commit", commit.Hash)
+	return &PromptRelease{
+		Label:     label,
+		Commit:    commit.Hash.String(),
+		Timestamp: commit.Committer.When,
+	}, nil
+}
+
+// ListPromptReleases returns the releases of a prompt, sorted by label
+func (s *gitService) ListPromptReleases(ctx context.Context, promptID string) ([]PromptRelease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags, err := s.repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer tags.Close()
+
+	prefix := ReleaseTag(promptID, "")
+	releases := []PromptRelease{}
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		label, ok := strings.CutPrefix(ref.Name().Short(), prefix)
+		if !ok {
+			return nil
+		}
+		commit, err := s.repo.CommitObject(ref.Hash())
+		if err != nil {
+			return fmt.Errorf("failed to get commit of release %s: %w", label, err)
+		}
+		releases = append(releases, PromptRelease{
+			Label:     label,
+			Commit:    commit.Hash.String(),
+			Timestamp: commit.Committer.When,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	sort.Slice(releases, func(i, j int) bool { return releases[i].Label < releases[j].Label })
+	return releases, nil
+}
+
+// DeletePromptRelease removes the tag of a release; the labelled commit stays in history
+func (s *gitService) DeletePromptRelease(ctx context.Context, promptID, label string) error {
+	tagName := ReleaseTag(promptID, label)
+	s.logger.Debug("Deleting prompt release", "tag", tagName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tagRef := plumbing.NewTagReferenceName(tagName)
+	if _, err := s.repo.Storer.Reference(tagRef); err == plumbing.ErrReferenceNotFound {
+		return fmt.Errorf("%w: %s", ErrReleaseNotFound, label)
+	} else if err != nil {
+		return fmt.Errorf("failed to get tag reference: %w", err)
+	}
+
+	if err := s.repo.Storer.RemoveReference(tagRef); err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	s.logger.Info("Prompt release deleted successfully", "tag", tagName)
+	return nil
+}
+
+// ResolvePromptRelease returns the commit a label of a prompt points at
+func (s *gitService) ResolvePromptRelease(ctx context.Context, promptID, label string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ref, err := s.repo.Storer.Reference(plumbing.NewTagReferenceName(ReleaseTag(promptID, label)))
+	if err == plumbing.ErrReferenceNotFound {
+		return "", fmt.Errorf("%w: %s", ErrReleaseNotFound, label)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get tag reference: %w", err)
+	}
+	return ref.Hash().String(), nil
+}
+
+// setTag points a tag at commit, replacing an existing tag only when force is set
+func (s *gitService) setTag(tagName string, commit plumbing.Hash, force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tagRef := plumbing.NewTagReferenceName(tagName)
+	if _, err := s.repo.Storer.Reference(tagRef); err == nil && !force {
+		return fmt.Errorf("%w: %s", ErrReleaseExists, tagName)
+	} else if err != nil && err != plumbing.ErrReferenceNotFound {
+		return fmt.Errorf("failed to get tag reference: %w", err)
+	}
+
+	if err := s.repo.Storer.SetReference(plumbing.NewHashReference(tagRef, commit)); err != nil {
+		return fmt.Errorf("failed to create tag: %w", err)
+	}
+	return nil
+}
+
+// deleteReleaseTags removes every release tag of a prompt. The caller must hold s.mu.
+func (s *gitService) deleteReleaseTags(promptID string) error {
+	tags, err := s.repo.Tags()
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer tags.Close()
+
+	var names []plumbing.ReferenceName
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().Short(), ReleaseTag(promptID, "")) {
+			names = append(names, ref.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	for _, name := range names {
+		if err := s.repo.Storer.RemoveReference(name); err != nil {
+			return fmt.Errorf("failed to delete tag: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dikkadev/proompt/server/internal/config"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// branchPrefixes are the branch namespaces synced with remotes
+var branchPrefixes = []string{PromptBranch(""), SnippetBranch("")}
+
+// PullRemote fetches the prompt and snippet branches of a remote and fast-forwards local
+// branches that are behind or missing. Deletions are told apart from new branches by the
+// remote-tracking refs left by the previous sync. The fetch only writes objects and
+// remote-tracking refs, so local branches are locked just for the fast-forward.
+func (s *gitService) PullRemote(ctx context.Context, remote string) (*PullResult, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	cfg, err := s.remoteConfig(remote)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Debug("Pulling from remote", "remote", cfg.Name, "url", cfg.URL)
+
+	if err := s.ensureRemote(cfg); err != nil {
+		return nil, err
+	}
+
+	trackingPrefix := "refs/remotes/" + cfg.Name + "/"
+	previous, err := s.branchHeads(trackingPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: cfg.Name,
+		RefSpecs:   fetchRefSpecs(cfg.Name),
+		Auth:       remoteAuth(cfg),
+		Prune:      true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		s.logger.Error("Failed to fetch from remote", "remote", cfg.Name, "error", err)
+		return nil, fmt.Errorf("failed to fetch from %s: %w", cfg.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	local, err := s.branchHeads("refs/heads/")
+	if err != nil {
+		return nil, err
+	}
+	remoteHeads, err := s.branchHeads(trackingPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(local)+len(remoteHeads))
+	for branch := range local {
+		names = append(names, branch)
+	}
+	for branch := range remoteHeads {
+		if _, ok := local[branch]; !ok {
+			names = append(names, branch)
+		}
+	}
+	sort.Strings(names)
+
+	result := &PullResult{Remote: cfg.Name, Branches: make([]BranchSync, 0, len(names))}
+	for _, branch := range names {
+		status, err := s.pullBranch(branch, local, remoteHeads, previous)
+		if err != nil {
+			return nil, err
+		}
+		result.Branches = append(result.Branches, status)
+	}
+
+	s.logger.Info("Pulled from remote", "remote", cfg.Name, "branches", len(result.Branches))
+	return result, nil
+}
+
+// pullBranch compares one branch with the remote and fast-forwards it when possible
+func (s *gitService) pullBranch(branch string, local, remoteHeads, previous map[string]plumbing.Hash) (BranchSync, error) {
+	localHash, hasLocal := local[branch]
+	remoteHash, hasRemote := remoteHeads[branch]
+	previousHash, seen := previous[branch]
+
+	status := BranchSync{Branch: branch}
+	if hasLocal {
+		status.Local = localHash.String()
+	}
+	if hasRemote {
+		status.Remote = remoteHash.String()
+	}
+
+	switch {
+	case !hasRemote:
+		switch {
+		case !seen:
+			status.State = SyncLocalOnly
+		case localHash == previousHash:
+			status.State = SyncDeletedRemotely
+		default:
+			status.State = SyncDiverged
+		}
+		return status, nil
+
+	case !hasLocal:
+		switch {
+		case !seen:
+			status.State = SyncPulled
+		case remoteHash == previousHash:
+			status.State = SyncDeletedLocally
+			return status, nil
+		default:
+			status.State = SyncDiverged
+			return status, nil
+		}
+
+	case localHash == remoteHash:
+		status.State = SyncUpToDate
+		return status, nil
+
+	default:
+		behind, err := s.isAncestor(localHash, remoteHash)
+		if err != nil {
+			return status, err
+		}
+		if !behind {
+			ahead, err := s.isAncestor(remoteHash, localHash)
+			if err != nil {
+				return status, err
+			}
+			if ahead {
+				status.State = SyncAhead
+			} else {
+				status.State = SyncDiverged
+			}
+			return status, nil
+		}
+		status.State = SyncPulled
+	}
+
+	// Fast-forward, or create the branch when it is new on the remote
+	refName := plumbing.NewBranchReferenceName(branch)
+	newRef := plumbing.NewHashReference(refName, remoteHash)
+	var err error
+	if hasLocal {
+		err = s.repo.Storer.CheckAndSetReference(newRef, plumbing.NewHashReference(refName, localHash))
+	} else {
+		err = s.repo.Storer.SetReference(newRef)
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to fast-forward %s: %w", branch, err)
+	}
+
+	s.logger.Debug("Branch pulled", "branch", branch, "from", status.Local, "to", status.Remote)
+	return status, nil
+}
+
+// PushRemote pushes the given branches, deleting the ones that no longer exist locally.
+// Local branches are not locked while pushing; a branch that moves meanwhile is pushed as
+// it was when the push read it, and the next sync picks up the rest.
+func (s *gitService) PushRemote(ctx context.Context, remote string, branches []string) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	cfg, err := s.remoteConfig(remote)
+	if err != nil {
+		return err
+	}
+	if len(branches) == 0 {
+		return nil
+	}
+	s.logger.Debug("Pushing to remote", "remote", cfg.Name, "branches", len(branches))
+
+	if err := s.ensureRemote(cfg); err != nil {
+		return err
+	}
+
+	refSpecs, err := s.pushRefSpecs(branches)
+	if err != nil {
+		return err
+	}
+
+	// Pushing also moves the remote-tracking refs, so the next pull sees these branches as synced
+	err = s.repo.PushContext(ctx, &git.PushOptions{
+		RemoteName: cfg.Name,
+		RefSpecs:   refSpecs,
+		Auth:       remoteAuth(cfg),
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		s.logger.Error("Failed to push to remote", "remote", cfg.Name, "error", err)
+		return fmt.Errorf("failed to push to %s: %w", cfg.Name, err)
+	}
+
+	s.logger.Info("Pushed to remote", "remote", cfg.Name, "branches", len(branches))
+	return nil
+}
+
+// pushRefSpecs maps each branch to itself on the remote, or to a deletion when it no
+// longer exists locally
+func (s *gitService) pushRefSpecs(branches []string) ([]gitconfig.RefSpec, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	refSpecs := make([]gitconfig.RefSpec, 0, len(branches))
+	for _, branch := range branches {
+		refName := plumbing.NewBranchReferenceName(branch)
+		_, err := s.repo.Storer.Reference(refName)
+		switch {
+		case err == nil:
+			refSpecs = append(refSpecs, gitconfig.RefSpec(refName+":"+refName))
+		case errors.Is(err, plumbing.ErrReferenceNotFound):
+			refSpecs = append(refSpecs, gitconfig.RefSpec(":"+refName))
+		default:
+			return nil, fmt.Errorf("failed to get branch reference: %w", err)
+		}
+	}
+	return refSpecs, nil
+}
+
+// remoteConfig returns the configured remote with the given name, or the first one for ""
+func (s *gitService) remoteConfig(name string) (*config.Remote, error) {
+	remotes := s.config.Storage.Remotes
+	if len(remotes) == 0 {
+		return nil, fmt.Errorf("%w: no remotes configured", ErrUnknownRemote)
+	}
+	if name == "" {
+		return &remotes[0], nil
+	}
+	for i := range remotes {
+		if remotes[i].Name == name {
+			return &remotes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownRemote, name)
+}
+
+// ensureRemote adds the remote to the repository, or updates it when its URL changed
+func (s *gitService) ensureRemote(cfg *config.Remote) error {
+	existing, err := s.repo.Remote(cfg.Name)
+	switch {
+	case err == nil:
+		urls := existing.Config().URLs
+		if len(urls) == 1 && urls[0] == cfg.URL {
+			return nil
+		}
+		if err := s.repo.DeleteRemote(cfg.Name); err != nil {
+			return fmt.Errorf("failed to update remote %s: %w", cfg.Name, err)
+		}
+	case !errors.Is(err, git.ErrRemoteNotFound):
+		return fmt.Errorf("failed to get remote %s: %w", cfg.Name, err)
+	}
+
+	_, err = s.repo.CreateRemote(&gitconfig.RemoteConfig{
+		Name:  cfg.Name,
+		URLs:  []string{cfg.URL},
+		Fetch: fetchRefSpecs(cfg.Name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add remote %s: %w", cfg.Name, err)
+	}
+	return nil
+}
+
+// fetchRefSpecs maps the remote's prompt and snippet branches to remote-tracking refs
+func fetchRefSpecs(remote string) []gitconfig.RefSpec {
+	refSpecs := make([]gitconfig.RefSpec, 0, len(branchPrefixes))
+	for _, prefix := range branchPrefixes {
+		refSpecs = append(refSpecs, gitconfig.RefSpec(fmt.Sprintf("+refs/heads/%s*:refs/remotes/%s/%s*", prefix, remote, prefix)))
+	}
+	return refSpecs
+}
+
+// remoteAuth returns the credentials for a remote; without a token go-git falls back to the SSH agent
+func remoteAuth(cfg *config.Remote) transport.AuthMethod {
+	if cfg.Token == "" {
+		return nil
+	}
+	username := cfg.Username
+	if username == "" {
+		// Most hosts ignore the user name when a token is given, but it must not be empty
+		username = "proompt"
+	}
+	return &http.BasicAuth{Username: username, Password: cfg.Token}
+}
+
+// branchHeads returns the heads of the prompt and snippet branches whose refs start with
+// prefix, keyed by branch name
+func (s *gitService) branchHeads(prefix string) (map[string]plumbing.Hash, error) {
+	refs, err := s.repo.References()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list references: %w", err)
+	}
+	defer refs.Close()
+
+	heads := make(map[string]plumbing.Hash)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		branch, ok := strings.CutPrefix(ref.Name().String(), prefix)
+		if !ok || ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		for _, branchPrefix := range branchPrefixes {
+			if strings.HasPrefix(branch, branchPrefix) {
+				heads[branch] = ref.Hash()
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list references: %w", err)
+	}
+	return heads, nil
+}
+
+// isAncestor reports whether ancestor is reachable from descendant
+func (s *gitService) isAncestor(ancestor, descendant plumbing.Hash) (bool, error) {
+	a, err := s.repo.CommitObject(ancestor)
+	if err != nil {
+		return false, fmt.Errorf("failed to get commit %s: %w", ancestor, err)
+	}
+	d, err := s.repo.CommitObject(descendant)
+	if err != nil {
+		return false, fmt.Errorf("failed to get commit %s: %w", descendant, err)
+	}
+	return a.IsAncestor(d)
+}
@@ -3,17 +3,24 @@ package git
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dikkadev/proompt/server/internal/config"
 	"github.com/dikkadev/proompt/server/internal/logging"
 	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/template"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
 	"github.com/spf13/afero"
 )
 
@@ -24,6 +31,13 @@ type gitService struct {
 	logger   *slog.Logger
 	repo     *git.Repository
 	repoPath string
+
+	// mu serializes writes to branches and the object store; reads of refs hold it shared
+	mu sync.RWMutex
+
+	// syncMu serializes pulls and pushes, which own the remote-tracking refs. They only
+	// take mu around local refs, so a slow remote does not hold up other git access.
+	syncMu sync.Mutex
 }
 
 // NewGitService creates a new git service instance
@@ -110,30 +124,23 @@ func (s *gitService) createInitialCommit(repo *git.Repository) error {
 
 // CreatePromptBranch creates a new orphan branch for a prompt
 func (s *gitService) CreatePromptBranch(ctx context.Context, prompt *models.Prompt, userNote string) error {
-	branchName := fmt.Sprintf("prompts/%s", prompt.ID)
+	branchName := PromptBranch(prompt.ID)
 	s.logger.Debug("Creating prompt branch", "branch", branchName, "title", prompt.Title)
 
 	// Create orphan branch and commit content
-	content := &PromptContent{
-		ID:                 prompt.ID,
-		Title:              prompt.Title,
-		Content:            prompt.Content,
-		Type:               string(prompt.Type),
-		UseCase:            getStringValue(prompt.UseCase),
-		ModelCompatibility: prompt.ModelCompatibilityTags,
-		Parameters:         prompt.OtherParameters,
-		Variables:          models.StringSlice{}, // TODO: Extract from content
-		Tags:               models.StringSlice{}, // TODO: Get from tags table
-		CreatedAt:          prompt.CreatedAt,
-		UpdatedAt:          prompt.UpdatedAt,
-	}
+	content := newPromptContent(prompt)
 
 	commitMessage := fmt.Sprintf("Create: %s", prompt.Title)
 	if userNote != "" {
 		commitMessage += "\n\n" + userNote
 	}
 
-	if err := s.createOrphanBranchWithContent(branchName, "content.json", content, commitMessage); err != nil {
+	// New prompts start without links; the links file also marks the branch as versioning notes and links
+	files := map[string]interface{}{
+		"content.json": content,
+		linksFile:      []LinkContent{},
+	}
+	if err := s.createOrphanBranchWithContent(branchName, files, commitMessage); err != nil {
 		return fmt.Errorf("failed to create orphan branch with content: %w", err)
 	}
 
@@ -143,23 +150,11 @@ func (s *gitService) CreatePromptBranch(ctx context.Context, prompt *models.Prom
 
 // UpdatePromptBranch updates an existing prompt branch
 func (s *gitService) UpdatePromptBranch(ctx context.Context, prompt *models.Prompt, userNote string) error {
-	branchName := fmt.Sprintf("prompts/%s", prompt.ID)
+	branchName := PromptBranch(prompt.ID)
 	s.logger.Debug("Updating prompt branch", "branch", branchName, "title", prompt.Title)
 
 	// Update branch with new content
-	content := &PromptContent{
-		ID:                 prompt.ID,
-		Title:              prompt.Title,
-		Content:            prompt.Content,
-		Type:               string(prompt.Type),
-		UseCase:            getStringValue(prompt.UseCase),
-		ModelCompatibility: prompt.ModelCompatibilityTags,
-		Parameters:         prompt.OtherParameters,
-		Variables:          models.StringSlice{}, // TODO: Extract from content
-		Tags:               models.StringSlice{}, // TODO: Get from tags table
-		CreatedAt:          prompt.CreatedAt,
-		UpdatedAt:          prompt.UpdatedAt,
-	}
+	content := newPromptContent(prompt)
 
 	commitMessage := fmt.Sprintf("Update: %s", prompt.Title)
 	if userNote != "" {
@@ -174,42 +169,133 @@ func (s *gitService) UpdatePromptBranch(ctx context.Context, prompt *models.Prom
 	return nil
 }
 
-// DeletePromptBranch deletes a prompt branch
+// DeletePromptBranch deletes a prompt branch along with the branches of its drafts and
+// the tags of its releases
 func (s *gitService) DeletePromptBranch(ctx context.Context, promptID string) error {
-	branchName := fmt.Sprintf("prompts/%s", promptID)
+	branchName := PromptBranch(promptID)
 	s.logger.Debug("Deleting prompt branch", "branch", branchName)
 
+	drafts, err := s.listBranchIDs(DraftBranch(promptID, ""))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Delete the branch
 	if err := s.repo.Storer.RemoveReference(plumbing.NewBranchReferenceName(branchName)); err != nil {
 		return fmt.Errorf("failed to delete branch: %w", err)
 	}
+	for _, name := range drafts {
+		if err := s.repo.Storer.RemoveReference(plumbing.NewBranchReferenceName(DraftBranch(promptID, name))); err != nil {
+			return fmt.Errorf("failed to delete draft branch: %w", err)
+		}
+	}
+	if err := s.deleteReleaseTags(promptID); err != nil {
+		return err
+	}
 
 	s.logger.Info("Prompt branch deleted successfully", "branch", branchName)
 	return nil
 }
 
+// RestorePromptBranch records a commit restoring a prompt to an earlier version.
+// The restored content is committed on top of the branch, so history is never rewritten.
+func (s *gitService) RestorePromptBranch(ctx context.Context, prompt *models.Prompt, commitHash string, userNote string) error {
+	branchName := PromptBranch(prompt.ID)
+	s.logger.Debug("Restoring prompt branch", "branch", branchName, "title", prompt.Title, "commit", commitHash)
+
+	content := newPromptContent(prompt)
+
+	commitMessage := fmt.Sprintf("Restore: %s to %s", prompt.Title, shortHash(commitHash))
+	if userNote != "" {
+		commitMessage += "\n\n" + userNote
+	}
+
+	files := map[string]interface{}{"content.json": content}
+	var remove func(path string) bool
+
+	// Notes and links are restored along with the content, unless the commit predates their versioning
+	tree, err := s.commitTree(commitHash)
+	if err != nil {
+		return err
+	}
+	if _, err := tree.File(linksFile); err == nil {
+		err := tree.Files().ForEach(func(file *object.File) error {
+			if !isNotesOrLinksFile(file.Name) {
+				return nil
+			}
+			data, err := file.Contents()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", file.Name, err)
+			}
+			files[file.Name] = json.RawMessage(data)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		remove = isNotesOrLinksFile
+	}
+
+	if err := s.updateBranchFiles(branchName, files, remove, commitMessage); err != nil {
+		return fmt.Errorf("failed to update branch with content: %w", err)
+	}
+
+	s.logger.Info("Prompt branch restored successfully", "branch", branchName, "title", prompt.Title, "commit", commitHash)
+	return nil
+}
+
+// UpdatePromptTags records a change to a prompt's tags as a new commit
+func (s *gitService) UpdatePromptTags(ctx context.Context, prompt *models.Prompt, added, removed []string) error {
+	branchName := PromptBranch(prompt.ID)
+	s.logger.Debug("Updating prompt tags", "branch", branchName, "added", added, "removed", removed)
+
+	content := newPromptContent(prompt)
+
+	commitMessage := fmt.Sprintf("Tags: %s (%s)", prompt.Title, describeTagChange(added, removed))
+	if err := s.updateBranchWithContent(branchName, "content.json", content, commitMessage); err != nil {
+		return fmt.Errorf("failed to update branch with content: %w", err)
+	}
+
+	s.logger.Info("Prompt tags updated successfully", "branch", branchName, "tags", prompt.Tags)
+	return nil
+}
+
+// UpdatePromptNotesAndLinks replaces the notes and links stored on a prompt's branch
+// with the given ones and commits the change with message as its subject
+func (s *gitService) UpdatePromptNotesAndLinks(ctx context.Context, promptID string, notes []*models.Note, links []*models.PromptLink, message string) error {
+	branchName := PromptBranch(promptID)
+	s.logger.Debug("Updating prompt notes and links", "branch", branchName, "notes", len(notes), "links", len(links))
+
+	files := map[string]interface{}{linksFile: newLinksContent(links)}
+	for _, note := range notes {
+		files[notePath(note.ID)] = newNoteContent(note)
+	}
+
+	if err := s.updateBranchFiles(branchName, files, isNotesOrLinksFile, message); err != nil {
+		return fmt.Errorf("failed to update branch with content: %w", err)
+	}
+
+	s.logger.Info("Prompt notes and links updated successfully", "branch", branchName, "message", message)
+	return nil
+}
+
 // CreateSnippetBranch creates a new orphan branch for a snippet
 func (s *gitService) CreateSnippetBranch(ctx context.Context, snippet *models.Snippet, userNote string) error {
-	branchName := fmt.Sprintf("snippets/%s", snippet.ID)
+	branchName := SnippetBranch(snippet.ID)
 	s.logger.Debug("Creating snippet branch", "branch", branchName, "title", snippet.Title)
 
 	// Create orphan branch and commit content
-	content := &SnippetContent{
-		ID:        snippet.ID,
-		Title:     snippet.Title,
-		Content:   snippet.Content,
-		Variables: models.StringSlice{}, // TODO: Extract from content
-		Tags:      models.StringSlice{}, // TODO: Get from tags table
-		CreatedAt: snippet.CreatedAt,
-		UpdatedAt: snippet.UpdatedAt,
-	}
+	content := newSnippetContent(snippet)
 
 	commitMessage := fmt.Sprintf("Create: %s", snippet.Title)
 	if userNote != "" {
 		commitMessage += "\n\n" + userNote
 	}
 
-	if err := s.createOrphanBranchWithContent(branchName, "content.json", content, commitMessage); err != nil {
+	if err := s.createOrphanBranchWithContent(branchName, map[string]interface{}{"content.json": content}, commitMessage); err != nil {
 		return fmt.Errorf("failed to create orphan branch with content: %w", err)
 	}
 
@@ -219,19 +305,11 @@ func (s *gitService) CreateSnippetBranch(ctx context.Context, snippet *models.Sn
 
 // UpdateSnippetBranch updates an existing snippet branch
 func (s *gitService) UpdateSnippetBranch(ctx context.Context, snippet *models.Snippet, userNote string) error {
-	branchName := fmt.Sprintf("snippets/%s", snippet.ID)
+	branchName := SnippetBranch(snippet.ID)
 	s.logger.Debug("Updating snippet branch", "branch", branchName, "title", snippet.Title)
 
 	// Update branch with new content
-	content := &SnippetContent{
-		ID:        snippet.ID,
-		Title:     snippet.Title,
-		Content:   snippet.Content,
-		Variables: models.StringSlice{}, // TODO: Extract from content
-		Tags:      models.StringSlice{}, // TODO: Get from tags table
-		CreatedAt: snippet.CreatedAt,
-		UpdatedAt: snippet.UpdatedAt,
-	}
+	content := newSnippetContent(snippet)
 
 	commitMessage := fmt.Sprintf("Update: %s", snippet.Title)
 	if userNote != "" {
@@ -248,9 +326,12 @@ func (s *gitService) UpdateSnippetBranch(ctx context.Context, snippet *models.Sn
 
 // DeleteSnippetBranch deletes a snippet branch
 func (s *gitService) DeleteSnippetBranch(ctx context.Context, snippetID string) error {
-	branchName := fmt.Sprintf("snippets/%s", snippetID)
+	branchName := SnippetBranch(snippetID)
 	s.logger.Debug("Deleting snippet branch", "branch", branchName)
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Delete the branch
 	if err := s.repo.Storer.RemoveReference(plumbing.NewBranchReferenceName(branchName)); err != nil {
 		return fmt.Errorf("failed to delete branch: %w", err)
@@ -260,48 +341,65 @@ func (s *gitService) DeleteSnippetBranch(ctx context.Context, snippetID string)
 	return nil
 }
 
+// RestoreSnippetBranch records a commit restoring a snippet to an earlier version
+func (s *gitService) RestoreSnippetBranch(ctx context.Context, snippet *models.Snippet, commitHash string, userNote string) error {
+	branchName := SnippetBranch(snippet.ID)
+	s.logger.Debug("Restoring snippet branch", "branch", branchName, "title", snippet.Title, "commit", commitHash)
+
+	content := newSnippetContent(snippet)
+
+	commitMessage := fmt.Sprintf("Restore: %s to %s", snippet.Title, shortHash(commitHash))
+	if userNote != "" {
+		commitMessage += "\n\n" + userNote
+	}
+
+	if err := s.updateBranchWithContent(branchName, "content.json", content, commitMessage); err != nil {
+		return fmt.Errorf("failed to update branch with content: %w", err)
+	}
+
+	s.logger.Info("Snippet branch restored successfully", "branch", branchName, "title", snippet.Title, "commit", commitHash)
+	return nil
+}
+
+// UpdateSnippetTags records a change to a snippet's tags as a new commit
+func (s *gitService) UpdateSnippetTags(ctx context.Context, snippet *models.Snippet, added, removed []string) error {
+	branchName := SnippetBranch(snippet.ID)
+	s.logger.Debug("Updating snippet tags", "branch", branchName, "added", added, "removed", removed)
+
+	content := newSnippetContent(snippet)
+
+	commitMessage := fmt.Sprintf("Tags: %s (%s)", snippet.Title, describeTagChange(added, removed))
+	if err := s.updateBranchWithContent(branchName, "content.json", content, commitMessage); err != nil {
+		return fmt.Errorf("failed to update branch with content: %w", err)
+	}
+
+	s.logger.Info("Snippet tags updated successfully", "branch", branchName, "tags", snippet.Tags)
+	return nil
+}
+
 // GetPromptHistory retrieves commit history for a prompt
 func (s *gitService) GetPromptHistory(ctx context.Context, promptID string) ([]GitCommit, error) {
-	branchName := fmt.Sprintf("prompts/%s", promptID)
+	branchName := PromptBranch(promptID)
 	return s.getBranchHistory(branchName)
 }
 
 // GetSnippetHistory retrieves commit history for a snippet
 func (s *gitService) GetSnippetHistory(ctx context.Context, snippetID string) ([]GitCommit, error) {
-	branchName := fmt.Sprintf("snippets/%s", snippetID)
+	branchName := SnippetBranch(snippetID)
 	return s.getBranchHistory(branchName)
 }
 
 // GetPromptVersion retrieves a specific version of a prompt
 func (s *gitService) GetPromptVersion(ctx context.Context, promptID string, commitHash string) (*models.Prompt, error) {
-	// Get the commit
-	hash := plumbing.NewHash(commitHash)
-	commit, err := s.repo.CommitObject(hash)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get commit: %w", err)
-	}
-
-	// Get the tree
-	tree, err := commit.Tree()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get tree: %w", err)
-	}
-
-	// Get content.json file
-	file, err := tree.File("content.json")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get content.json: %w", err)
-	}
-
-	content, err := file.Contents()
+	var promptContent PromptContent
+	hash, err := s.readCommitContent(commitHash, "content.json", &promptContent)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file contents: %w", err)
+		return nil, err
 	}
 
-	// Parse JSON content
-	var promptContent PromptContent
-	if err := json.Unmarshal([]byte(content), &promptContent); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	// Make sure the commit actually belongs to this prompt's branch
+	if promptContent.ID != promptID {
+		return nil, fmt.Errorf("commit %s does not belong to prompt %s", commitHash, promptID)
 	}
 
 	// Convert to models.Prompt
@@ -315,41 +413,92 @@ func (s *gitService) GetPromptVersion(ctx context.Context, promptID string, comm
 		OtherParameters:        promptContent.Parameters,
 		CreatedAt:              promptContent.CreatedAt,
 		UpdatedAt:              promptContent.UpdatedAt,
+		GitRef:                 &hash,
+		Tags:                   promptContent.Tags,
 	}
 
 	return prompt, nil
 }
 
-// GetSnippetVersion retrieves a specific version of a snippet
-func (s *gitService) GetSnippetVersion(ctx context.Context, snippetID string, commitHash string) (*models.Snippet, error) {
-	// Get the commit
-	hash := plumbing.NewHash(commitHash)
-	commit, err := s.repo.CommitObject(hash)
+// GetPromptSnapshot retrieves a prompt with its notes and links at a specific version
+func (s *gitService) GetPromptSnapshot(ctx context.Context, promptID string, commitHash string) (*PromptSnapshot, error) {
+	prompt, err := s.GetPromptVersion(ctx, promptID, commitHash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get commit: %w", err)
+		return nil, err
 	}
+	snapshot := &PromptSnapshot{Prompt: prompt}
 
-	// Get the tree
-	tree, err := commit.Tree()
+	tree, err := s.commitTree(commitHash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get tree: %w", err)
+		return nil, err
 	}
 
-	// Get content.json file
-	file, err := tree.File("content.json")
+	// Commits made before notes and links were versioned have no links file
+	file, err := tree.File(linksFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get content.json: %w", err)
+		return snapshot, nil
 	}
 
-	content, err := file.Contents()
+	data, err := file.Contents()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file contents: %w", err)
+		return nil, fmt.Errorf("failed to read %s: %w", linksFile, err)
+	}
+	var links []LinkContent
+	if err := json.Unmarshal([]byte(data), &links); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", linksFile, err)
 	}
 
-	// Parse JSON content
+	snapshot.Links = make([]*models.PromptLink, 0, len(links))
+	for _, link := range links {
+		snapshot.Links = append(snapshot.Links, &models.PromptLink{
+			FromPromptID: promptID,
+			ToPromptID:   link.ToPromptID,
+			LinkType:     link.LinkType,
+			CreatedAt:    link.CreatedAt,
+		})
+	}
+
+	snapshot.Notes = []*models.Note{}
+	err = tree.Files().ForEach(func(file *object.File) error {
+		if !strings.HasPrefix(file.Name, notesDir+"/") {
+			return nil
+		}
+		data, err := file.Contents()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		var note NoteContent
+		if err := json.Unmarshal([]byte(data), &note); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", file.Name, err)
+		}
+		snapshot.Notes = append(snapshot.Notes, &models.Note{
+			ID:        note.ID,
+			PromptID:  promptID,
+			Title:     note.Title,
+			Body:      note.Body,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// GetSnippetVersion retrieves a specific version of a snippet
+func (s *gitService) GetSnippetVersion(ctx context.Context, snippetID string, commitHash string) (*models.Snippet, error) {
 	var snippetContent SnippetContent
-	if err := json.Unmarshal([]byte(content), &snippetContent); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	hash, err := s.readCommitContent(commitHash, "content.json", &snippetContent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the commit actually belongs to this snippet's branch
+	if snippetContent.ID != snippetID {
+		return nil, fmt.Errorf("commit %s does not belong to snippet %s", commitHash, snippetID)
 	}
 
 	// Convert to models.Snippet
@@ -359,11 +508,88 @@ func (s *gitService) GetSnippetVersion(ctx context.Context, snippetID string, co
 		Content:   snippetContent.Content,
 		CreatedAt: snippetContent.CreatedAt,
 		UpdatedAt: snippetContent.UpdatedAt,
+		GitRef:    &hash,
+		Tags:      snippetContent.Tags,
 	}
 
 	return snippet, nil
 }
 
+// ListPromptIDs returns the ids of all prompts that have a branch
+func (s *gitService) ListPromptIDs(ctx context.Context) ([]string, error) {
+	return s.listBranchIDs(PromptBranch(""))
+}
+
+// ListSnippetIDs returns the ids of all snippets that have a branch
+func (s *gitService) ListSnippetIDs(ctx context.Context) ([]string, error) {
+	return s.listBranchIDs(SnippetBranch(""))
+}
+
+// BranchHead returns the commit a branch points to, or "" if the branch does not exist
+func (s *gitService) BranchHead(ctx context.Context, branch string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ref, err := s.repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err == plumbing.ErrReferenceNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch reference: %w", err)
+	}
+	return ref.Hash().String(), nil
+}
+
+// ResetBranch points a branch at commitHash, deleting the branch when commitHash is "".
+// The branch must still point at expected, or not exist when expected is "", so a commit
+// made by someone else since is never thrown away. Commits made after commitHash stay in
+// the object store but are no longer reachable.
+func (s *gitService) ResetBranch(ctx context.Context, branch string, expected string, commitHash string) error {
+	s.logger.Debug("Resetting branch", "branch", branch, "expected", expected, "commit", commitHash)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refName := plumbing.NewBranchReferenceName(branch)
+	current, err := s.repo.Storer.Reference(refName)
+	if err == plumbing.ErrReferenceNotFound {
+		current = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get branch reference: %w", err)
+	}
+
+	head := ""
+	if current != nil {
+		head = current.Hash().String()
+	}
+	if head != expected {
+		return fmt.Errorf("%w: %s points at %q, expected %q", ErrBranchMoved, branch, head, expected)
+	}
+
+	switch {
+	case commitHash == "" && current == nil:
+	case commitHash == "":
+		if err := s.repo.Storer.RemoveReference(refName); err != nil {
+			return fmt.Errorf("failed to delete branch: %w", err)
+		}
+	case current == nil:
+		if err := s.repo.Storer.SetReference(plumbing.NewHashReference(refName, plumbing.NewHash(commitHash))); err != nil {
+			return fmt.Errorf("failed to reset branch: %w", err)
+		}
+	default:
+		err := s.repo.Storer.CheckAndSetReference(plumbing.NewHashReference(refName, plumbing.NewHash(commitHash)), current)
+		if errors.Is(err, storage.ErrReferenceHasChanged) {
+			return fmt.Errorf("%w: %s changed while resetting", ErrBranchMoved, branch)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to reset branch: %w", err)
+		}
+	}
+
+	s.logger.Info("Branch reset successfully", "branch", branch, "commit", commitHash)
+	return nil
+}
+
 // ValidateRepo validates the git repository health
 func (s *gitService) ValidateRepo(ctx context.Context) error {
 	if s.repo == nil {
@@ -381,157 +607,259 @@ func (s *gitService) ValidateRepo(ctx context.Context) error {
 
 // Helper methods
 
-// createOrphanBranchWithContent creates a new orphan branch with content
-func (s *gitService) createOrphanBranchWithContent(branchName, filename string, content interface{}, commitMessage string) error {
-	s.logger.Debug("Creating orphan branch with content", "branch", branchName, "file", filename)
-
-	// Store current HEAD to restore later (if it exists)
-	var originalHead *plumbing.Reference
-	if head, err := s.repo.Head(); err == nil {
-		originalHead = head
-	}
+// createOrphanBranchWithContent creates a new orphan branch whose first commit holds files,
+// keyed by their path in the tree
+func (s *gitService) createOrphanBranchWithContent(branchName string, files map[string]interface{}, commitMessage string) error {
+	s.logger.Debug("Creating orphan branch with content", "branch", branchName, "files", len(files))
 
-	// Get worktree
-	worktree, err := s.repo.Worktree()
-	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
-	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Create orphan branch by setting HEAD to point to new branch (this is the key insight from the research!)
 	branchRef := plumbing.NewBranchReferenceName(branchName)
-	if err := s.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branchRef)); err != nil {
-		return fmt.Errorf("failed to create orphan branch: %w", err)
-	}
 
-	// Clear the index and working tree to make it truly orphan
-	if err := s.clearWorktree(worktree); err != nil {
-		// Restore original HEAD on error (if it existed)
-		if originalHead != nil {
-			s.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, originalHead.Name()))
+	// A branch left over from an earlier item with the same id keeps its history;
+	// the new commit replaces its whole tree
+	var parent *object.Commit
+	existing, err := s.repo.Storer.Reference(branchRef)
+	if err == nil {
+		parent, err = s.repo.CommitObject(existing.Hash())
+		if err != nil {
+			return fmt.Errorf("failed to get branch head commit: %w", err)
 		}
-		return fmt.Errorf("failed to clear worktree: %w", err)
+	} else if err != plumbing.ErrReferenceNotFound {
+		return fmt.Errorf("failed to get branch reference: %w", err)
 	}
 
-	// Write content and commit
-	if err := s.writeContentAndCommit(worktree, filename, content, commitMessage); err != nil {
-		// Restore original HEAD on error (if it existed)
-		if originalHead != nil {
-			s.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, originalHead.Name()))
-		}
-		return fmt.Errorf("failed to write content and commit: %w", err)
+	removeAll := func(string) bool { return true }
+	commitHash, err := s.writeCommit(parent, files, removeAll, commitMessage)
+	if err != nil {
+		return err
 	}
 
-	s.logger.Debug("Orphan branch created successfully", "branch", branchName)
+	newRef := plumbing.NewHashReference(branchRef, commitHash)
+	if existing != nil {
+		err = s.repo.Storer.CheckAndSetReference(newRef, existing)
+	} else {
+		err = s.repo.Storer.SetReference(newRef)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create branch: %w", err)
+	}
+
+	s.logger.Debug("Orphan branch created successfully", "branch", branchName, "commit", commitHash)
 	return nil
 }
 
 // updateBranchWithContent updates an existing branch with new content
 func (s *gitService) updateBranchWithContent(branchName, filename string, content interface{}, commitMessage string) error {
-	s.logger.Debug("Updating branch with content", "branch", branchName, "file", filename)
+	return s.updateBranchFiles(branchName, map[string]interface{}{filename: content}, nil, commitMessage)
+}
 
-	// Store current HEAD to restore later (if it exists)
-	var originalHead *plumbing.Reference
-	if head, err := s.repo.Head(); err == nil {
-		originalHead = head
+// updateBranchFiles commits changes to several files of an existing branch. Files matched
+// by remove are deleted before files are written, so a set of files can be replaced.
+// Commits in merged are recorded as further parents of the new commit.
+func (s *gitService) updateBranchFiles(branchName string, files map[string]interface{}, remove func(path string) bool, commitMessage string, merged ...plumbing.Hash) error {
+	s.logger.Debug("Updating branch with content", "branch", branchName, "files", len(files))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	branchRef := plumbing.NewBranchReferenceName(branchName)
+	ref, err := s.repo.Storer.Reference(branchRef)
+	if err != nil {
+		return fmt.Errorf("failed to get branch reference: %w", err)
 	}
 
-	// Get worktree
-	worktree, err := s.repo.Worktree()
+	parent, err := s.repo.CommitObject(ref.Hash())
 	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+		return fmt.Errorf("failed to get branch head commit: %w", err)
 	}
 
-	// Switch to the branch
-	branchRef := plumbing.NewBranchReferenceName(branchName)
-	if err := s.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branchRef)); err != nil {
-		return fmt.Errorf("failed to switch to branch: %w", err)
+	commitHash, err := s.writeCommit(parent, files, remove, commitMessage, merged...)
+	if err != nil {
+		return err
 	}
 
-	// Checkout the branch content
-	checkoutOptions := &git.CheckoutOptions{
-		Branch: branchRef,
-		Force:  true,
+	// Only move the branch if it still points at the parent
+	if err := s.repo.Storer.CheckAndSetReference(plumbing.NewHashReference(branchRef, commitHash), ref); err != nil {
+		return fmt.Errorf("failed to update branch: %w", err)
 	}
-	if err := worktree.Checkout(checkoutOptions); err != nil {
-		// Restore original HEAD on error (if it existed)
-		if originalHead != nil {
-			s.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, originalHead.Name()))
+
+	s.logger.Debug("Branch updated successfully", "branch", branchName, "commit", commitHash)
+	return nil
+}
+
+// writeCommit stores a commit on top of parent, or a root commit when parent is nil. Its
+// tree is the parent's tree without the files matched by remove, with files written as
+// JSON. Commits in merged become further parents, making it a merge commit. Only objects
+// are written; no reference, HEAD or worktree is touched.
+func (s *gitService) writeCommit(parent *object.Commit, files map[string]interface{}, remove func(path string) bool, commitMessage string, merged ...plumbing.Hash) (plumbing.Hash, error) {
+	blobs := make(map[string]plumbing.Hash)
+	var parents []plumbing.Hash
+
+	if parent != nil {
+		tree, err := parent.Tree()
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to get tree: %w", err)
+		}
+		err = tree.Files().ForEach(func(file *object.File) error {
+			if remove == nil || !remove(file.Name) {
+				blobs[file.Name] = file.Hash
+			}
+			return nil
+		})
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to read tree: %w", err)
 		}
-		return fmt.Errorf("failed to checkout branch: %w", err)
+		parents = append([]plumbing.Hash{parent.Hash}, merged...)
 	}
 
-	// Write updated content and commit
-	if err := s.writeContentAndCommit(worktree, filename, content, commitMessage); err != nil {
-		// Restore original HEAD on error (if it existed)
-		if originalHead != nil {
-			s.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, originalHead.Name()))
+	for filename, content := range files {
+		jsonData, err := json.MarshalIndent(content, "", "  ")
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to marshal %s: %w", filename, err)
+		}
+		hash, err := s.storeBlob(jsonData)
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to store %s: %w", filename, err)
 		}
-		return fmt.Errorf("failed to write content and commit: %w", err)
+		blobs[filename] = hash
 	}
 
-	s.logger.Debug("Branch updated successfully", "branch", branchName)
-	return nil
+	treeHash, err := s.storeTree(blobs)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	signature := object.Signature{
+		Name:  "Proompt",
+		Email: "proompt@local",
+		When:  time.Now(),
+	}
+	commit := &object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      commitMessage,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+
+	commitHash, err := s.storeObject(commit)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to commit: %w", err)
+	}
+	return commitHash, nil
 }
 
-// clearWorktree removes all files from the worktree (for orphan branches)
-func (s *gitService) clearWorktree(worktree *git.Worktree) error {
-	// Get the status to see what files exist
-	status, err := worktree.Status()
+// storeBlob writes data to the object store as a blob
+func (s *gitService) storeBlob(data []byte) (plumbing.Hash, error) {
+	obj := s.repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(data)))
+
+	writer, err := obj.Writer()
 	if err != nil {
-		return fmt.Errorf("failed to get worktree status: %w", err)
+		return plumbing.ZeroHash, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := writer.Close(); err != nil {
+		return plumbing.ZeroHash, err
 	}
 
-	// Remove each file
-	for file := range status {
-		if _, err := worktree.Remove(file); err != nil {
-			s.logger.Warn("Failed to remove file", "file", file, "error", err)
+	return s.repo.Storer.SetEncodedObject(obj)
+}
+
+// storeTree writes the trees for a flat map of file paths to blob hashes and returns the
+// hash of the root tree
+func (s *gitService) storeTree(blobs map[string]plumbing.Hash) (plumbing.Hash, error) {
+	tree := &object.Tree{}
+	dirs := make(map[string]map[string]plumbing.Hash)
+
+	for path, hash := range blobs {
+		dir, rest, nested := strings.Cut(path, "/")
+		if !nested {
+			tree.Entries = append(tree.Entries, object.TreeEntry{Name: path, Mode: filemode.Regular, Hash: hash})
+			continue
+		}
+		if dirs[dir] == nil {
+			dirs[dir] = make(map[string]plumbing.Hash)
 		}
+		dirs[dir][rest] = hash
 	}
 
-	return nil
-}
+	for dir, children := range dirs {
+		hash, err := s.storeTree(children)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: hash})
+	}
 
-// writeContentAndCommit writes content to a file and commits it
-func (s *gitService) writeContentAndCommit(worktree *git.Worktree, filename string, content interface{}, commitMessage string) error {
-	s.logger.Debug("Writing content and committing", "file", filename, "message", commitMessage)
+	// Git orders entries by name, comparing directories as if they ended in a slash
+	sortKey := func(entry object.TreeEntry) string {
+		if entry.Mode == filemode.Dir {
+			return entry.Name + "/"
+		}
+		return entry.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortKey(tree.Entries[i]) < sortKey(tree.Entries[j])
+	})
 
-	// Marshal content to JSON
-	jsonData, err := json.MarshalIndent(content, "", "  ")
+	hash, err := s.storeObject(tree)
 	if err != nil {
-		return fmt.Errorf("failed to marshal content: %w", err)
+		return plumbing.ZeroHash, fmt.Errorf("failed to store tree: %w", err)
 	}
+	return hash, nil
+}
 
-	// Write file
-	filePath := filepath.Join(worktree.Filesystem.Root(), filename)
-	if err := afero.WriteFile(s.fs, filePath, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+// storeObject encodes a tree or commit into the object store
+func (s *gitService) storeObject(o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := s.repo.Storer.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
 	}
+	return s.repo.Storer.SetEncodedObject(obj)
+}
 
-	// Add file to git
-	if _, err := worktree.Add(filename); err != nil {
-		return fmt.Errorf("failed to add file to git: %w", err)
+// listBranchIDs returns the sorted names of the branches under prefix, without the prefix
+func (s *gitService) listBranchIDs(prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	branches, err := s.repo.Branches()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
 	}
+	defer branches.Close()
 
-	// Commit
-	_, err = worktree.Commit(commitMessage, &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  "Proompt",
-			Email: "proompt@local",
-			When:  time.Now(),
-		},
+	ids := []string{}
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		if id, ok := strings.CutPrefix(ref.Name().Short(), prefix); ok {
+			ids = append(ids, id)
+		}
+		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to commit: %w", err)
+		return nil, fmt.Errorf("failed to list branches: %w", err)
 	}
 
-	s.logger.Debug("Content written and committed successfully", "file", filename)
-	return nil
+	sort.Strings(ids)
+	return ids, nil
 }
 
 // getBranchHistory retrieves commit history for a branch
 func (s *gitService) getBranchHistory(branchName string) ([]GitCommit, error) {
 	s.logger.Debug("Getting branch history", "branch", branchName)
 
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	// Get branch reference
 	ref, err := s.repo.Reference(plumbing.NewBranchReferenceName(branchName), true)
 	if err != nil {
@@ -549,12 +877,15 @@ func (s *gitService) getBranchHistory(branchName string) ([]GitCommit, error) {
 
 	var commits []GitCommit
 	err = commitIter.ForEach(func(commit *object.Commit) error {
+		// Split the subject line from the optional user note
+		subject, body, _ := strings.Cut(commit.Message, "\n")
 		gitCommit := GitCommit{
 			Hash:      commit.Hash.String(),
-			Message:   commit.Message,
+			Message:   subject,
 			Author:    commit.Author.Name,
 			Email:     commit.Author.Email,
 			Timestamp: commit.Author.When,
+			Body:      strings.TrimSpace(body),
 		}
 		commits = append(commits, gitCommit)
 		return nil
@@ -568,8 +899,176 @@ func (s *gitService) getBranchHistory(branchName string) ([]GitCommit, error) {
 	return commits, nil
 }
 
+// readCommitContent reads and decodes a JSON file from the tree of the given commit and
+// returns the full hash of that commit
+func (s *gitService) readCommitContent(commitHash, filename string, v interface{}) (string, error) {
+	commit, err := s.resolveCommit(commitHash)
+	if err != nil {
+		return "", err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get tree: %w", err)
+	}
+
+	file, err := tree.File(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s: %w", filename, err)
+	}
+
+	content, err := file.Contents()
+	if err != nil {
+		return "", fmt.Errorf("failed to read file contents: %w", err)
+	}
+
+	// Parse JSON content
+	if err := json.Unmarshal([]byte(content), v); err != nil {
+		return "", fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	return commit.Hash.String(), nil
+}
+
+// commitTree returns the tree of the given commit
+func (s *gitService) commitTree(commitHash string) (*object.Tree, error) {
+	commit, err := s.resolveCommit(commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the tree
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree: %w", err)
+	}
+
+	return tree, nil
+}
+
+// resolveCommit returns the commit with the given full or abbreviated hash
+func (s *gitService) resolveCommit(commitHash string) (*object.Commit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash, err := s.repo.ResolveRevision(plumbing.Revision(commitHash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve commit %s: %w", commitHash, err)
+	}
+
+	commit, err := s.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit: %w", err)
+	}
+	return commit, nil
+}
+
 // Helper utility functions
 
+// newPromptContent builds the git representation of a prompt
+func newPromptContent(prompt *models.Prompt) *PromptContent {
+	return &PromptContent{
+		ID:                 prompt.ID,
+		Title:              prompt.Title,
+		Content:            prompt.Content,
+		Type:               string(prompt.Type),
+		UseCase:            getStringValue(prompt.UseCase),
+		ModelCompatibility: prompt.ModelCompatibilityTags,
+		Parameters:         prompt.OtherParameters,
+		Variables:          variableNames(prompt.Content),
+		Tags:               sortedTags(prompt.Tags),
+		CreatedAt:          prompt.CreatedAt,
+		UpdatedAt:          prompt.UpdatedAt,
+	}
+}
+
+// newSnippetContent builds the git representation of a snippet
+func newSnippetContent(snippet *models.Snippet) *SnippetContent {
+	return &SnippetContent{
+		ID:        snippet.ID,
+		Title:     snippet.Title,
+		Content:   snippet.Content,
+		Variables: variableNames(snippet.Content),
+		Tags:      sortedTags(snippet.Tags),
+		CreatedAt: snippet.CreatedAt,
+		UpdatedAt: snippet.UpdatedAt,
+	}
+}
+
+// newNoteContent builds the git representation of a note
+func newNoteContent(note *models.Note) *NoteContent {
+	return &NoteContent{
+		ID:        note.ID,
+		Title:     note.Title,
+		Body:      note.Body,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+	}
+}
+
+// newLinksContent builds the git representation of a prompt's outgoing links, sorted by target
+func newLinksContent(links []*models.PromptLink) []LinkContent {
+	content := make([]LinkContent, 0, len(links))
+	for _, link := range links {
+		content = append(content, LinkContent{
+			ToPromptID: link.ToPromptID,
+			LinkType:   link.LinkType,
+			CreatedAt:  link.CreatedAt,
+		})
+	}
+	sort.Slice(content, func(i, j int) bool {
+		return content[i].ToPromptID < content[j].ToPromptID
+	})
+	return content
+}
+
+// notePath is the path of a note's file on its prompt's branch
+func notePath(noteID string) string {
+	return notesDir + "/" + noteID + ".json"
+}
+
+// isNotesOrLinksFile reports whether a path holds a prompt's notes or links
+func isNotesOrLinksFile(path string) bool {
+	return path == linksFile || strings.HasPrefix(path, notesDir+"/")
+}
+
+// variableNames lists the names of the {{variable}} placeholders in content
+func variableNames(content string) models.StringSlice {
+	variables := template.ExtractVariables(content)
+	names := make(models.StringSlice, 0, len(variables))
+	for _, variable := range variables {
+		names = append(names, variable.Name)
+	}
+	return names
+}
+
+// sortedTags returns a sorted copy of tags, so snapshots only change when the tags do
+func sortedTags(tags []string) models.StringSlice {
+	sorted := make(models.StringSlice, len(tags))
+	copy(sorted, tags)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// describeTagChange summarizes added and removed tags for a commit message, e.g. "+go, -python"
+func describeTagChange(added, removed []string) string {
+	changes := make([]string, 0, len(added)+len(removed))
+	for _, tag := range added {
+		changes = append(changes, "+"+tag)
+	}
+	for _, tag := range removed {
+		changes = append(changes, "-"+tag)
+	}
+	return strings.Join(changes, ", ")
+}
+
+// shortHash abbreviates a commit hash for use in commit messages
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
 // getStringValue safely gets string value from pointer
 func getStringValue(s *string) string {
 	if s == nil {
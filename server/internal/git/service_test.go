@@ -0,0 +1,442 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dikkadev/proompt/server/internal/config"
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// setupGitService creates a git service on its own repository
+func setupGitService(t *testing.T) *gitService {
+	cfg := &config.Config{
+		Storage: config.Storage{
+			ReposDir: t.TempDir(),
+		},
+	}
+	service, err := NewGitService(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create git service: %v", err)
+	}
+	return service.(*gitService)
+}
+
+func TestConcurrentPromptUpdates(t *testing.T) {
+	service := setupGitService(t)
+	ctx := context.Background()
+
+	head, err := service.repo.Head()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+
+	const promptCount = 4
+	const updatesPerPrompt = 25
+
+	ids := make([]string, promptCount)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("prompt-%d", i)
+		prompt := &models.Prompt{ID: ids[i], Title: "Initial", Content: "Hello", Type: models.PromptTypeUser}
+		if err := service.CreatePromptBranch(ctx, prompt, ""); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+	}
+
+	// Every prompt gets updated from several goroutines at once, interleaved with the other prompts
+	var wg sync.WaitGroup
+	errs := make(chan error, promptCount*updatesPerPrompt)
+	for _, id := range ids {
+		for n := 0; n < updatesPerPrompt; n++ {
+			wg.Add(1)
+			go func(id string, n int) {
+				defer wg.Done()
+				prompt := &models.Prompt{ID: id, Title: fmt.Sprintf("Update %d", n), Content: id, Type: models.PromptTypeUser}
+				if err := service.UpdatePromptBranch(ctx, prompt, ""); err != nil {
+					errs <- err
+				}
+			}(id, n)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Concurrent update failed: %v", err)
+	}
+
+	for _, id := range ids {
+		history, err := service.GetPromptHistory(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to get history: %v", err)
+		}
+		if len(history) != 1+updatesPerPrompt {
+			t.Errorf("Expected %d commits for %s, got %d", 1+updatesPerPrompt, id, len(history))
+		}
+
+		// No commit may hold another prompt's content or extra files
+		for _, commit := range history {
+			version, err := service.GetPromptVersion(ctx, id, commit.Hash)
+			if err != nil {
+				t.Fatalf("Failed to get version %s: %v", commit.Hash, err)
+			}
+			if version.ID != id {
+				t.Errorf("Commit %s on %s holds prompt %s", commit.Hash, id, version.ID)
+			}
+			tree, err := service.commitTree(commit.Hash)
+			if err != nil {
+				t.Fatalf("Failed to get tree: %v", err)
+			}
+			if len(tree.Entries) != 2 || tree.Entries[0].Name != "content.json" || tree.Entries[1].Name != linksFile {
+				t.Errorf("Unexpected tree for commit %s: %+v", commit.Hash, tree.Entries)
+			}
+		}
+	}
+
+	after, err := service.repo.Head()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	if after.Name() != head.Name() || after.Hash() != head.Hash() {
+		t.Errorf("Expected HEAD to stay at %s, got %s", head, after)
+	}
+}
+
+func TestNotesAndLinksTree(t *testing.T) {
+	service := setupGitService(t)
+	ctx := context.Background()
+
+	prompt := &models.Prompt{ID: "p1", Title: "Notes", Content: "Hello", Type: models.PromptTypeUser}
+	if err := service.CreatePromptBranch(ctx, prompt, ""); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+
+	notes := []*models.Note{{ID: "b", Title: "Second"}, {ID: "a", Title: "First"}}
+	links := []*models.PromptLink{{FromPromptID: "p1", ToPromptID: "p2", LinkType: "followup"}}
+	if err := service.UpdatePromptNotesAndLinks(ctx, "p1", notes, links, "Add notes"); err != nil {
+		t.Fatalf("Failed to update notes: %v", err)
+	}
+	if err := service.UpdatePromptNotesAndLinks(ctx, "p1", notes[1:], nil, "Remove note"); err != nil {
+		t.Fatalf("Failed to update notes: %v", err)
+	}
+
+	history, err := service.GetPromptHistory(ctx, "p1")
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+
+	withBoth, err := service.GetPromptSnapshot(ctx, "p1", history[1].Hash)
+	if err != nil {
+		t.Fatalf("Failed to get snapshot: %v", err)
+	}
+	if len(withBoth.Notes) != 2 || len(withBoth.Links) != 1 || withBoth.Prompt.Title != "Notes" {
+		t.Errorf("Unexpected snapshot: %d notes, %d links, title %q", len(withBoth.Notes), len(withBoth.Links), withBoth.Prompt.Title)
+	}
+
+	latest, err := service.GetPromptSnapshot(ctx, "p1", history[0].Hash)
+	if err != nil {
+		t.Fatalf("Failed to get snapshot: %v", err)
+	}
+	if len(latest.Notes) != 1 || latest.Notes[0].ID != "a" || len(latest.Links) != 0 {
+		t.Errorf("Expected only note a and no links, got %d notes and %d links", len(latest.Notes), len(latest.Links))
+	}
+
+	// Git tools must be able to read the trees we build, so entries have to be sorted
+	commit, err := service.repo.CommitObject(plumbing.NewHash(history[1].Hash))
+	if err != nil {
+		t.Fatalf("Failed to get commit: %v", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatalf("Failed to get tree: %v", err)
+	}
+	var names []string
+	for _, entry := range tree.Entries {
+		names = append(names, entry.Name)
+	}
+	if fmt.Sprint(names) != "[content.json links.json notes]" {
+		t.Errorf("Unexpected tree entries: %v", names)
+	}
+	if _, err := tree.File("notes/b.json"); err != nil {
+		t.Errorf("Expected nested note file: %v", err)
+	}
+	if _, err := object.GetTree(service.repo.Storer, tree.Entries[2].Hash); err != nil {
+		t.Errorf("Expected notes subtree: %v", err)
+	}
+}
+
+func TestCreateExistingBranchReplacesTree(t *testing.T) {
+	service := setupGitService(t)
+	ctx := context.Background()
+
+	prompt := &models.Prompt{ID: "p1", Title: "First", Content: "Hi", Type: models.PromptTypeUser}
+	if err := service.CreatePromptBranch(ctx, prompt, ""); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if err := service.UpdatePromptNotesAndLinks(ctx, "p1", []*models.Note{{ID: "n1", Title: "Note"}}, nil, "Add note"); err != nil {
+		t.Fatalf("Failed to update notes: %v", err)
+	}
+
+	prompt.Title = "Second"
+	if err := service.CreatePromptBranch(ctx, prompt, ""); err != nil {
+		t.Fatalf("Failed to create existing branch: %v", err)
+	}
+
+	history, err := service.GetPromptHistory(ctx, "p1")
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != 3 {
+		t.Errorf("Expected history to be kept, got %d commits", len(history))
+	}
+	tree, err := service.commitTree(history[0].Hash)
+	if err != nil {
+		t.Fatalf("Failed to get tree: %v", err)
+	}
+	if len(tree.Entries) != 2 || tree.Entries[0].Name != "content.json" || tree.Entries[1].Name != linksFile {
+		t.Errorf("Expected notes to be dropped on create, got %+v", tree.Entries)
+	}
+}
+
+func TestPromptDrafts(t *testing.T) {
+	service := setupGitService(t)
+	ctx := context.Background()
+
+	prompt := &models.Prompt{ID: "p1", Title: "Main", Content: "Hello\nWorld\n", Type: models.PromptTypeUser}
+	if err := service.CreatePromptBranch(ctx, prompt, ""); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if err := service.UpdatePromptNotesAndLinks(ctx, "p1", []*models.Note{{ID: "n1", Title: "Note"}}, nil, "Add note"); err != nil {
+		t.Fatalf("Failed to update notes: %v", err)
+	}
+
+	if _, err := service.CreatePromptDraft(ctx, "p1", "../x"); !errors.Is(err, ErrInvalidDraftName) {
+		t.Errorf("Expected ErrInvalidDraftName, got %v", err)
+	}
+	forked, err := service.CreatePromptDraft(ctx, "p1", "shorter")
+	if err != nil {
+		t.Fatalf("Failed to create draft: %v", err)
+	}
+	if forked.Base != forked.Head || forked.Prompt.Title != "Main" {
+		t.Errorf("Expected the draft to start at the prompt's head, got %+v", forked)
+	}
+	if _, err := service.CreatePromptDraft(ctx, "p1", "shorter"); !errors.Is(err, ErrDraftExists) {
+		t.Errorf("Expected ErrDraftExists, got %v", err)
+	}
+
+	// The draft and the prompt move on independently
+	edited := *forked.Prompt
+	edited.Content = "Hi\nWorld\n"
+	if err := service.UpdatePromptDraft(ctx, &edited, "shorter", "Try a shorter greeting"); err != nil {
+		t.Fatalf("Failed to update draft: %v", err)
+	}
+	prompt.Title = "Main v2"
+	if err := service.UpdatePromptBranch(ctx, prompt, ""); err != nil {
+		t.Fatalf("Failed to update branch: %v", err)
+	}
+
+	drafts, err := service.ListPromptDrafts(ctx, "p1")
+	if err != nil {
+		t.Fatalf("Failed to list drafts: %v", err)
+	}
+	if len(drafts) != 1 || drafts[0].Base != forked.Head || drafts[0].Prompt.Content != "Hi\nWorld\n" {
+		t.Fatalf("Unexpected drafts: %+v", drafts)
+	}
+	draft := drafts[0]
+
+	merged, content := MergePrompt(forked.Prompt, prompt, draft.Prompt)
+	if !content.Clean() {
+		t.Fatalf("Expected a clean merge, got %q", content.Content)
+	}
+	if err := service.PromotePromptDraft(ctx, merged, &draft, "Shorter is better"); err != nil {
+		t.Fatalf("Failed to promote draft: %v", err)
+	}
+
+	head, err := service.BranchHead(ctx, PromptBranch("p1"))
+	if err != nil {
+		t.Fatalf("Failed to get branch head: %v", err)
+	}
+	commit, err := service.repo.CommitObject(plumbing.NewHash(head))
+	if err != nil {
+		t.Fatalf("Failed to get commit: %v", err)
+	}
+	if len(commit.ParentHashes) != 2 || commit.ParentHashes[1].String() != draft.Head {
+		t.Errorf("Expected a merge commit of the draft, got parents %v", commit.ParentHashes)
+	}
+	snapshot, err := service.GetPromptSnapshot(ctx, "p1", head)
+	if err != nil {
+		t.Fatalf("Failed to get snapshot: %v", err)
+	}
+	if snapshot.Prompt.Title != "Main v2" || snapshot.Prompt.Content != "Hi\nWorld\n" || len(snapshot.Notes) != 1 {
+		t.Errorf("Unexpected promoted version: %+v with %d notes", snapshot.Prompt, len(snapshot.Notes))
+	}
+
+	// Once promoted, the draft's head is the base of further changes
+	promoted, err := service.GetPromptDraft(ctx, "p1", "shorter")
+	if err != nil {
+		t.Fatalf("Failed to get draft: %v", err)
+	}
+	if promoted.Base != draft.Head {
+		t.Errorf("Expected base %s after promotion, got %s", draft.Head, promoted.Base)
+	}
+
+	// Deleting the prompt takes its drafts along
+	if err := service.DeletePromptBranch(ctx, "p1"); err != nil {
+		t.Fatalf("Failed to delete branch: %v", err)
+	}
+	if _, err := service.GetPromptDraft(ctx, "p1", "shorter"); !errors.Is(err, ErrDraftNotFound) {
+		t.Errorf("Expected ErrDraftNotFound, got %v", err)
+	}
+}
+
+func TestPromptReleases(t *testing.T) {
+	service := setupGitService(t)
+	ctx := context.Background()
+
+	prompt := &models.Prompt{ID: "p1", Title: "First", Content: "Hello", Type: models.PromptTypeUser}
+	if err := service.CreatePromptBranch(ctx, prompt, ""); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	first, err := service.BranchHead(ctx, PromptBranch("p1"))
+	if err != nil {
+		t.Fatalf("Failed to get branch head: %v", err)
+	}
+
+	if _, err := service.CreatePromptRelease(ctx, "p1", "v1.0", "", false); err != nil {
+		t.Fatalf("Failed to create release: %v", err)
+	}
+
+	prompt.Title = "Second"
+	if err := service.UpdatePromptBranch(ctx, prompt, ""); err != nil {
+		t.Fatalf("Failed to update branch: %v", err)
+	}
+
+	// Labels can point at older versions, given by abbreviated hash
+	production, err := service.CreatePromptRelease(ctx, "p1", "production", first[:7], false)
+	if err != nil {
+		t.Fatalf("Failed to create release: %v", err)
+	}
+	if production.Commit != first {
+		t.Errorf("Expected release at %s, got %s", first, production.Commit)
+	}
+
+	if _, err := service.CreatePromptRelease(ctx, "p1", "production", "", false); !errors.Is(err, ErrReleaseExists) {
+		t.Errorf("Expected ErrReleaseExists, got %v", err)
+	}
+	if _, err := service.CreatePromptRelease(ctx, "p1", "prod/x", "", false); !errors.Is(err, ErrInvalidReleaseLabel) {
+		t.Errorf("Expected ErrInvalidReleaseLabel, got %v", err)
+	}
+
+	// Moving a label needs force
+	moved, err := service.CreatePromptRelease(ctx, "p1", "production", "", true)
+	if err != nil {
+		t.Fatalf("Failed to move release: %v", err)
+	}
+	commit, err := service.ResolvePromptRelease(ctx, "p1", "production")
+	if err != nil {
+		t.Fatalf("Failed to resolve release: %v", err)
+	}
+	if commit != moved.Commit || commit == first {
+		t.Errorf("Expected production to move to the head, got %s", commit)
+	}
+	version, err := service.GetPromptVersion(ctx, "p1", commit)
+	if err != nil || version.Title != "Second" {
+		t.Errorf("Expected the labelled version to be the second, got %v, %v", version, err)
+	}
+
+	releases, err := service.ListPromptReleases(ctx, "p1")
+	if err != nil {
+		t.Fatalf("Failed to list releases: %v", err)
+	}
+	if len(releases) != 2 || releases[0].Label != "production" || releases[1].Label != "v1.0" || releases[1].Commit != first {
+		t.Errorf("Unexpected releases: %+v", releases)
+	}
+
+	if err := service.DeletePromptRelease(ctx, "p1", "v1.0"); err != nil {
+		t.Fatalf("Failed to delete release: %v", err)
+	}
+	if _, err := service.ResolvePromptRelease(ctx, "p1", "v1.0"); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("Expected ErrReleaseNotFound, got %v", err)
+	}
+
+	// Releases are not shared between prompts and go away with their prompt
+	other := &models.Prompt{ID: "p10", Title: "Other", Content: "Hi", Type: models.PromptTypeUser}
+	if err := service.CreatePromptBranch(ctx, other, ""); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if _, err := service.CreatePromptRelease(ctx, "p10", "production", "", false); err != nil {
+		t.Fatalf("Failed to create release: %v", err)
+	}
+	if err := service.DeletePromptBranch(ctx, "p1"); err != nil {
+		t.Fatalf("Failed to delete branch: %v", err)
+	}
+	if _, err := service.ResolvePromptRelease(ctx, "p1", "production"); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("Expected ErrReleaseNotFound after deleting the prompt, got %v", err)
+	}
+	if _, err := service.ResolvePromptRelease(ctx, "p10", "production"); err != nil {
+		t.Errorf("Expected the other prompt's release to be kept: %v", err)
+	}
+}
+
+func TestPullDoesNotBlockOnSlowRemote(t *testing.T) {
+	service := setupGitService(t)
+	ctx := context.Background()
+
+	prompt := &models.Prompt{ID: "p1", Title: "Initial", Content: "Hello", Type: models.PromptTypeUser}
+	if err := service.CreatePromptBranch(ctx, prompt, ""); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+
+	// A remote that does not answer until the test is done with it
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		<-release
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer remote.Close()
+	service.config.Storage.Remotes = []config.Remote{{Name: "origin", URL: remote.URL + "/repo.git"}}
+
+	pulled := make(chan error, 1)
+	go func() {
+		_, err := service.PullRemote(ctx, "")
+		pulled <- err
+	}()
+	<-requested
+
+	// Reads and writes go on while the fetch waits for the remote
+	done := make(chan error, 1)
+	go func() {
+		if _, err := service.BranchHead(ctx, PromptBranch(prompt.ID)); err != nil {
+			done <- err
+			return
+		}
+		prompt.Title = "Updated"
+		done <- service.UpdatePromptBranch(ctx, prompt, "")
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Failed to use git during a pull: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Git access blocked while pulling from a slow remote")
+	}
+
+	close(release)
+	if err := <-pulled; err == nil {
+		t.Error("Expected the pull from an unavailable remote to fail")
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+// SearchResultType identifies the kind of item a search result refers to
+type SearchResultType string
+
+const (
+	SearchResultPrompt  SearchResultType = "prompt"
+	SearchResultSnippet SearchResultType = "snippet"
+	SearchResultNote    SearchResultType = "note"
+)
+
+func (t SearchResultType) Valid() bool {
+	switch t {
+	case SearchResultPrompt, SearchResultSnippet, SearchResultNote:
+		return true
+	}
+	return false
+}
+
+// SearchResult is a single full-text search hit
+type SearchResult struct {
+	Type     SearchResultType `json:"type" db:"type"`
+	ID       string           `json:"id" db:"id"`
+	PromptID *string          `json:"prompt_id" db:"prompt_id"`
+	Title    string           `json:"title" db:"title"`
+	Snippet  string           `json:"snippet" db:"snippet"` // HTML-escaped, with matches in <mark> tags
+	Score    float64          `json:"score" db:"score"`
+}
@@ -12,6 +12,9 @@ type Snippet struct {
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 	GitRef      *string   `json:"git_ref" db:"git_ref"`
+
+	// Tags are stored in snippet_tags; they are loaded by GetByID but not by List
+	Tags []string `json:"tags,omitempty" db:"-"`
 }
 
 type SnippetTag struct {
@@ -0,0 +1,100 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// ErrDatabaseNotEmpty is returned by Rebuild when the database already holds prompts or snippets
+var ErrDatabaseNotEmpty = errors.New("database is not empty")
+
+// RebuildResult counts what Rebuild restored
+type RebuildResult struct {
+	Prompts  int
+	Snippets int
+	Tags     int
+	Notes    int
+	Links    int
+}
+
+// Rebuild fills an empty, migrated database from the tips of all prompt and snippet
+// branches. Notes and links are restored for prompts whose branch stores them. Fields
+// git does not store, like temperature suggestions and snippet descriptions, stay empty.
+func (c *Checker) Rebuild(ctx context.Context) (*RebuildResult, error) {
+	c.logger.Debug("Rebuilding database from git")
+
+	prompts, err := c.repo.Prompts().Count(ctx, repository.PromptFilters{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count prompts: %w", err)
+	}
+	snippetCount, err := c.repo.Snippets().Count(ctx, repository.SnippetFilters{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count snippets: %w", err)
+	}
+	if prompts > 0 || snippetCount > 0 {
+		return nil, fmt.Errorf("%w: %d prompts and %d snippets", ErrDatabaseNotEmpty, prompts, snippetCount)
+	}
+
+	result := &RebuildResult{}
+
+	promptIDs, err := c.gitService.ListPromptIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hasBranch := make(map[string]bool, len(promptIDs))
+	for _, id := range promptIDs {
+		hasBranch[id] = true
+	}
+
+	snapshots := make([]*git.PromptSnapshot, 0, len(promptIDs))
+	for _, id := range promptIDs {
+		snapshot, err := c.promptTip(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+		result.Tags += len(snapshot.Prompt.Tags)
+		result.Notes += len(snapshot.Notes)
+		// Links to prompts without a branch cannot be restored and are skipped by Import
+		for _, link := range snapshot.Links {
+			if hasBranch[link.ToPromptID] {
+				result.Links++
+			}
+		}
+	}
+
+	snippetIDs, err := c.gitService.ListSnippetIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snippets := make([]*models.Snippet, 0, len(snippetIDs))
+	for _, id := range snippetIDs {
+		snippet, err := c.snippetTip(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, snippet)
+		result.Tags += len(snippet.Tags)
+	}
+
+	err = c.repo.WithTx(ctx, func(tx repository.Repository) error {
+		if err := tx.Prompts().Import(ctx, snapshots); err != nil {
+			return err
+		}
+		return tx.Snippets().Import(ctx, snippets)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import from git: %w", err)
+	}
+
+	result.Prompts = len(snapshots)
+	result.Snippets = len(snippets)
+
+	c.logger.Info("Database rebuilt from git", "prompts", result.Prompts, "snippets", result.Snippets, "notes", result.Notes, "links", result.Links)
+	return result, nil
+}
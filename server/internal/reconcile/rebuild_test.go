@@ -0,0 +1,90 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+func TestRebuild(t *testing.T) {
+	repo, gitService, checker := setupChecker(t)
+	ctx := context.Background()
+
+	source := &models.Prompt{Title: "Source", Content: "Hello {{name}}", Type: models.PromptTypeUser, Tags: []string{"greeting"}}
+	target := &models.Prompt{Title: "Target", Content: "Bye", Type: models.PromptTypeSystem}
+	for _, p := range []*models.Prompt{source, target} {
+		if err := repo.Prompts().Create(ctx, p); err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+	}
+	body := "Keep it short"
+	note := &models.Note{PromptID: source.ID, Title: "Style", Body: &body}
+	if err := repo.Notes().Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if err := repo.Prompts().CreateLink(ctx, &models.PromptLink{FromPromptID: source.ID, ToPromptID: target.ID, LinkType: "followup"}); err != nil {
+		t.Fatalf("Failed to create link: %v", err)
+	}
+	snippet := &models.Snippet{Title: "Footer", Content: "Thanks", Tags: []string{"a", "b"}}
+	if err := repo.Snippets().Create(ctx, snippet); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+
+	if _, err := checker.Rebuild(ctx); !errors.Is(err, ErrDatabaseNotEmpty) {
+		t.Fatalf("Expected rebuild into a used database to fail, got %v", err)
+	}
+
+	// A lost database is rebuilt from the same git repository
+	fresh := repository.New(newTestDatabase(t), gitService)
+	defer fresh.Close()
+	rebuilder := New(fresh, gitService)
+
+	result, err := rebuilder.Rebuild(ctx)
+	if err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	expected := RebuildResult{Prompts: 2, Snippets: 1, Tags: 3, Notes: 1, Links: 1}
+	if *result != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *result)
+	}
+
+	restored, err := fresh.Prompts().GetByID(ctx, source.ID)
+	if err != nil {
+		t.Fatalf("Failed to get rebuilt prompt: %v", err)
+	}
+	if restored.Title != source.Title || restored.Content != source.Content || len(restored.Tags) != 1 {
+		t.Errorf("Unexpected rebuilt prompt: %+v", restored)
+	}
+	if !restored.CreatedAt.Equal(source.CreatedAt) {
+		t.Errorf("Expected created_at %v, got %v", source.CreatedAt, restored.CreatedAt)
+	}
+
+	notes, err := fresh.Notes().ListByPromptID(ctx, source.ID)
+	if err != nil {
+		t.Fatalf("Failed to list notes: %v", err)
+	}
+	if len(notes) != 1 || notes[0].ID != note.ID || *notes[0].Body != body {
+		t.Errorf("Unexpected rebuilt notes: %+v", notes)
+	}
+
+	links, err := fresh.Prompts().GetLinksFrom(ctx, source.ID)
+	if err != nil {
+		t.Fatalf("Failed to get links: %v", err)
+	}
+	if len(links) != 1 || links[0].ToPromptID != target.ID {
+		t.Errorf("Unexpected rebuilt links: %+v", links)
+	}
+
+	tags, err := fresh.Snippets().GetTags(ctx, snippet.ID)
+	if err != nil {
+		t.Fatalf("Failed to get snippet tags: %v", err)
+	}
+	if len(tags) != 2 {
+		t.Errorf("Expected 2 snippet tags, got %v", tags)
+	}
+
+	assertClean(t, rebuilder)
+}
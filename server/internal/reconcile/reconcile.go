@@ -0,0 +1,450 @@
+// Package reconcile finds and repairs drift between the database and the git branches
+// that version prompts and snippets.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/logging"
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// repairNote is added to the commits made while repairing branches
+const repairNote = "Repaired by fsck to match the database"
+
+// Kind is the kind of item an issue is about
+type Kind string
+
+const (
+	KindPrompt  Kind = "prompt"
+	KindSnippet Kind = "snippet"
+)
+
+// Problem describes how the database and git disagree about an item
+type Problem string
+
+const (
+	// MissingBranch is a database row without a branch
+	MissingBranch Problem = "missing_branch"
+	// OrphanedBranch is a branch without a database row
+	OrphanedBranch Problem = "orphaned_branch"
+	// ContentDrift is a row whose fields differ from its branch tip
+	ContentDrift Problem = "content_drift"
+	// StaleGitRef is a row matching its branch tip whose git_ref is not that tip
+	StaleGitRef Problem = "stale_git_ref"
+)
+
+// Source selects which store wins when repairing
+type Source string
+
+const (
+	// FromDatabase rebuilds branches from database rows
+	FromDatabase Source = "database"
+	// FromGit rebuilds database rows from branch tips
+	FromGit Source = "git"
+)
+
+// Valid reports whether s is a known repair source
+func (s Source) Valid() bool {
+	return s == FromDatabase || s == FromGit
+}
+
+// Issue is a single disagreement between the database and git
+type Issue struct {
+	Kind    Kind
+	ID      string
+	Title   string
+	Problem Problem
+	// Fields lists the differing fields of a ContentDrift issue
+	Fields []string
+	// Repaired is set once a repair fixed the issue; Error holds why it could not be
+	Repaired bool
+	Error    string
+}
+
+// Report is the result of a check or repair
+type Report struct {
+	Prompts  int
+	Snippets int
+	Branches int
+	Issues   []*Issue
+}
+
+// Clean reports whether no issues were found, or all of them were repaired
+func (r *Report) Clean() bool {
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+// Checker compares the database with the git branches
+type Checker struct {
+	repo       repository.Repository
+	gitService git.GitService
+	logger     *slog.Logger
+}
+
+// New creates a new checker
+func New(repo repository.Repository, gitService git.GitService) *Checker {
+	return &Checker{
+		repo:       repo,
+		gitService: gitService,
+		logger:     logging.NewLogger("reconcile"),
+	}
+}
+
+// Check walks all prompts, snippets and their branches and reports where they disagree
+func (c *Checker) Check(ctx context.Context) (*Report, error) {
+	c.logger.Debug("Checking database against git")
+
+	report := &Report{Issues: []*Issue{}}
+	if err := c.checkPrompts(ctx, report); err != nil {
+		return nil, err
+	}
+	if err := c.checkSnippets(ctx, report); err != nil {
+		return nil, err
+	}
+
+	c.logger.Info("Check completed", "prompts", report.Prompts, "snippets", report.Snippets, "branches", report.Branches, "issues", len(report.Issues))
+	return report, nil
+}
+
+// Repair checks both stores and fixes every issue found, taking source as the truth.
+// Rows without a branch always get one created, since git has nothing to rebuild them from.
+func (c *Checker) Repair(ctx context.Context, source Source) (*Report, error) {
+	if !source.Valid() {
+		return nil, fmt.Errorf("invalid repair source: %s", source)
+	}
+
+	report, err := c.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Debug("Repairing", "source", source, "issues", len(report.Issues))
+
+	var imported []*Issue
+	var snapshots []*git.PromptSnapshot
+	var snippets []*models.Snippet
+
+	for _, issue := range report.Issues {
+		var err error
+		switch {
+		case issue.Problem == MissingBranch:
+			err = c.createBranch(ctx, issue)
+		case issue.Problem == StaleGitRef:
+			err = c.setGitRef(ctx, issue.Kind, issue.ID)
+		case source == FromDatabase && issue.Problem == OrphanedBranch:
+			err = c.deleteBranch(ctx, issue)
+		case source == FromDatabase:
+			err = c.updateBranch(ctx, issue)
+		case issue.Kind == KindPrompt:
+			var snapshot *git.PromptSnapshot
+			if snapshot, err = c.promptTip(ctx, issue.ID); err == nil {
+				snapshots = append(snapshots, snapshot)
+				imported = append(imported, issue)
+			}
+		default:
+			var snippet *models.Snippet
+			if snippet, err = c.snippetTip(ctx, issue.ID); err == nil {
+				snippets = append(snippets, snippet)
+				imported = append(imported, issue)
+			}
+		}
+		c.record(issue, err)
+	}
+
+	// Rows are imported in one go, so links between imported prompts resolve
+	if len(snapshots) > 0 || len(snippets) > 0 {
+		err := c.repo.WithTx(ctx, func(tx repository.Repository) error {
+			if err := tx.Prompts().Import(ctx, snapshots); err != nil {
+				return err
+			}
+			return tx.Snippets().Import(ctx, snippets)
+		})
+		for _, issue := range imported {
+			c.record(issue, err)
+		}
+	}
+
+	c.logger.Info("Repair completed", "source", source, "issues", len(report.Issues), "clean", report.Clean())
+	return report, nil
+}
+
+// record marks an issue as repaired, or stores why the repair failed
+func (c *Checker) record(issue *Issue, err error) {
+	if err != nil {
+		c.logger.Error("Failed to repair issue", "error", err, "kind", issue.Kind, "id", issue.ID, "problem", issue.Problem)
+		issue.Repaired = false
+		issue.Error = err.Error()
+		return
+	}
+	issue.Repaired = true
+}
+
+// checkPrompts adds the issues of all prompts to report
+func (c *Checker) checkPrompts(ctx context.Context, report *Report) error {
+	rows, err := c.repo.Prompts().List(ctx, repository.PromptFilters{})
+	if err != nil {
+		return fmt.Errorf("failed to list prompts: %w", err)
+	}
+	branches, err := c.gitService.ListPromptIDs(ctx)
+	if err != nil {
+		return err
+	}
+	report.Prompts += len(rows)
+	report.Branches += len(branches)
+
+	hasBranch := make(map[string]bool, len(branches))
+	for _, id := range branches {
+		hasBranch[id] = true
+	}
+
+	hasRow := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		hasRow[row.ID] = true
+		issue := &Issue{Kind: KindPrompt, ID: row.ID, Title: row.Title}
+
+		if !hasBranch[row.ID] {
+			issue.Problem = MissingBranch
+			report.Issues = append(report.Issues, issue)
+			continue
+		}
+
+		// List does not load tags
+		prompt, err := c.repo.Prompts().GetByID(ctx, row.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get prompt %s: %w", row.ID, err)
+		}
+		tip, err := c.promptTip(ctx, row.ID)
+		if err != nil {
+			return err
+		}
+		if fields := changedFields(git.DiffPrompts(tip.Prompt, prompt, "git", "database")); len(fields) > 0 {
+			issue.Problem = ContentDrift
+			issue.Fields = fields
+			report.Issues = append(report.Issues, issue)
+		} else if !sameRef(prompt.GitRef, tip.Prompt.GitRef) {
+			issue.Problem = StaleGitRef
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	for _, id := range branches {
+		if hasRow[id] {
+			continue
+		}
+		tip, err := c.promptTip(ctx, id)
+		if err != nil {
+			return err
+		}
+		report.Issues = append(report.Issues, &Issue{Kind: KindPrompt, ID: id, Title: tip.Prompt.Title, Problem: OrphanedBranch})
+	}
+
+	return nil
+}
+
+// checkSnippets adds the issues of all snippets to report
+func (c *Checker) checkSnippets(ctx context.Context, report *Report) error {
+	rows, err := c.repo.Snippets().List(ctx, repository.SnippetFilters{})
+	if err != nil {
+		return fmt.Errorf("failed to list snippets: %w", err)
+	}
+	branches, err := c.gitService.ListSnippetIDs(ctx)
+	if err != nil {
+		return err
+	}
+	report.Snippets += len(rows)
+	report.Branches += len(branches)
+
+	hasBranch := make(map[string]bool, len(branches))
+	for _, id := range branches {
+		hasBranch[id] = true
+	}
+
+	hasRow := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		hasRow[row.ID] = true
+		issue := &Issue{Kind: KindSnippet, ID: row.ID, Title: row.Title}
+
+		if !hasBranch[row.ID] {
+			issue.Problem = MissingBranch
+			report.Issues = append(report.Issues, issue)
+			continue
+		}
+
+		// List does not load tags
+		snippet, err := c.repo.Snippets().GetByID(ctx, row.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get snippet %s: %w", row.ID, err)
+		}
+		tip, err := c.snippetTip(ctx, row.ID)
+		if err != nil {
+			return err
+		}
+		if fields := changedFields(git.DiffSnippets(tip, snippet, "git", "database")); len(fields) > 0 {
+			issue.Problem = ContentDrift
+			issue.Fields = fields
+			report.Issues = append(report.Issues, issue)
+		} else if !sameRef(snippet.GitRef, tip.GitRef) {
+			issue.Problem = StaleGitRef
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	for _, id := range branches {
+		if hasRow[id] {
+			continue
+		}
+		tip, err := c.snippetTip(ctx, id)
+		if err != nil {
+			return err
+		}
+		report.Issues = append(report.Issues, &Issue{Kind: KindSnippet, ID: id, Title: tip.Title, Problem: OrphanedBranch})
+	}
+
+	return nil
+}
+
+// promptTip reads a prompt with its notes and links from the tip of its branch
+func (c *Checker) promptTip(ctx context.Context, id string) (*git.PromptSnapshot, error) {
+	head, err := c.gitService.BranchHead(ctx, git.PromptBranch(id))
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := c.gitService.GetPromptSnapshot(ctx, id, head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read branch of prompt %s: %w", id, err)
+	}
+	return snapshot, nil
+}
+
+// snippetTip reads a snippet from the tip of its branch
+func (c *Checker) snippetTip(ctx context.Context, id string) (*models.Snippet, error) {
+	head, err := c.gitService.BranchHead(ctx, git.SnippetBranch(id))
+	if err != nil {
+		return nil, err
+	}
+	snippet, err := c.gitService.GetSnippetVersion(ctx, id, head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read branch of snippet %s: %w", id, err)
+	}
+	return snippet, nil
+}
+
+// createBranch creates the missing branch of a row from the database
+func (c *Checker) createBranch(ctx context.Context, issue *Issue) error {
+	if issue.Kind == KindSnippet {
+		snippet, err := c.repo.Snippets().GetByID(ctx, issue.ID)
+		if err != nil {
+			return err
+		}
+		if err := c.gitService.CreateSnippetBranch(ctx, snippet, repairNote); err != nil {
+			return err
+		}
+		return c.setGitRef(ctx, issue.Kind, issue.ID)
+	}
+
+	prompt, err := c.repo.Prompts().GetByID(ctx, issue.ID)
+	if err != nil {
+		return err
+	}
+	if err := c.gitService.CreatePromptBranch(ctx, prompt, repairNote); err != nil {
+		return err
+	}
+	if err := c.updateNotesAndLinks(ctx, issue.ID); err != nil {
+		return err
+	}
+	return c.setGitRef(ctx, issue.Kind, issue.ID)
+}
+
+// updateBranch commits the database version of a drifted row on its branch
+func (c *Checker) updateBranch(ctx context.Context, issue *Issue) error {
+	if issue.Kind == KindSnippet {
+		snippet, err := c.repo.Snippets().GetByID(ctx, issue.ID)
+		if err != nil {
+			return err
+		}
+		if err := c.gitService.UpdateSnippetBranch(ctx, snippet, repairNote); err != nil {
+			return err
+		}
+		return c.setGitRef(ctx, issue.Kind, issue.ID)
+	}
+
+	prompt, err := c.repo.Prompts().GetByID(ctx, issue.ID)
+	if err != nil {
+		return err
+	}
+	if err := c.gitService.UpdatePromptBranch(ctx, prompt, repairNote); err != nil {
+		return err
+	}
+	return c.setGitRef(ctx, issue.Kind, issue.ID)
+}
+
+// setGitRef points the git_ref of a row at the tip of its branch
+func (c *Checker) setGitRef(ctx context.Context, kind Kind, id string) error {
+	if kind == KindSnippet {
+		head, err := c.gitService.BranchHead(ctx, git.SnippetBranch(id))
+		if err != nil {
+			return err
+		}
+		return c.repo.Snippets().SetGitRef(ctx, id, head)
+	}
+
+	head, err := c.gitService.BranchHead(ctx, git.PromptBranch(id))
+	if err != nil {
+		return err
+	}
+	return c.repo.Prompts().SetGitRef(ctx, id, head)
+}
+
+// deleteBranch removes a branch whose row no longer exists
+func (c *Checker) deleteBranch(ctx context.Context, issue *Issue) error {
+	if issue.Kind == KindSnippet {
+		return c.gitService.DeleteSnippetBranch(ctx, issue.ID)
+	}
+	return c.gitService.DeletePromptBranch(ctx, issue.ID)
+}
+
+// updateNotesAndLinks records a prompt's notes and outgoing links from the database on its branch
+func (c *Checker) updateNotesAndLinks(ctx context.Context, promptID string) error {
+	notes, err := c.repo.Notes().ListByPromptID(ctx, promptID)
+	if err != nil {
+		return err
+	}
+	links, err := c.repo.Prompts().GetLinksFrom(ctx, promptID)
+	if err != nil {
+		return err
+	}
+	if len(notes) == 0 && len(links) == 0 {
+		return nil
+	}
+	return c.gitService.UpdatePromptNotesAndLinks(ctx, promptID, notes, links, "Notes and links: restored from the database")
+}
+
+// sameRef reports whether two optional commit hashes are equal
+func sameRef(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// changedFields lists the fields that differ in diff, including the content
+func changedFields(diff *git.Diff) []string {
+	var fields []string
+	for _, change := range diff.Fields {
+		fields = append(fields, change.Field)
+	}
+	if diff.Content.Additions > 0 || diff.Content.Deletions > 0 {
+		fields = append(fields, "content")
+	}
+	return fields
+}
@@ -0,0 +1,251 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dikkadev/proompt/server/internal/config"
+	"github.com/dikkadev/proompt/server/internal/db"
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// newTestDatabase creates an empty, migrated in-memory database
+func newTestDatabase(t *testing.T) *db.DB {
+	database, err := db.NewLocal(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.RunMigrations("../db/migrations"); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	return database
+}
+
+// setupChecker creates a repository on an in-memory database and its own git repo,
+// configured with the given remotes
+func setupChecker(t *testing.T, remotes ...config.Remote) (repository.Repository, git.GitService, *Checker) {
+	database := newTestDatabase(t)
+
+	cfg := &config.Config{
+		Storage: config.Storage{
+			ReposDir: t.TempDir(),
+			Remotes:  remotes,
+		},
+	}
+	gitService, err := git.NewGitService(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create git service: %v", err)
+	}
+
+	repo := repository.New(database, gitService)
+	t.Cleanup(func() { repo.Close() })
+
+	return repo, gitService, New(repo, gitService)
+}
+
+// introduceDrift creates one prompt per problem and returns their ids:
+// a row without branch, a branch without row and a row differing from its branch
+func introduceDrift(t *testing.T, repo repository.Repository, gitService git.GitService) (missing, orphaned, drifted string) {
+	ctx := context.Background()
+
+	noBranch := &models.Prompt{Title: "No branch", Content: "Hello", Type: models.PromptTypeUser}
+	if err := repo.Prompts().Create(ctx, noBranch); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+	if err := gitService.DeletePromptBranch(ctx, noBranch.ID); err != nil {
+		t.Fatalf("Failed to delete branch: %v", err)
+	}
+
+	noRow := &models.Prompt{ID: "orphan", Title: "No row", Content: "Hi {{name}}", Type: models.PromptTypeUser, Tags: []string{"kept"}}
+	if err := gitService.CreatePromptBranch(ctx, noRow, ""); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+
+	changed := &models.Prompt{Title: "Database title", Content: "Same", Type: models.PromptTypeUser}
+	if err := repo.Prompts().Create(ctx, changed); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+	branchVersion := *changed
+	branchVersion.Title = "Branch title"
+	if err := gitService.UpdatePromptBranch(ctx, &branchVersion, ""); err != nil {
+		t.Fatalf("Failed to update branch: %v", err)
+	}
+
+	return noBranch.ID, noRow.ID, changed.ID
+}
+
+func TestCheck(t *testing.T) {
+	repo, gitService, checker := setupChecker(t)
+	ctx := context.Background()
+
+	snippet := &models.Snippet{Title: "In sync", Content: "Fine"}
+	if err := repo.Snippets().Create(ctx, snippet); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+
+	report, err := checker.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("Expected no issues, got %d", len(report.Issues))
+	}
+
+	missing, orphaned, drifted := introduceDrift(t, repo, gitService)
+
+	report, err = checker.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if report.Prompts != 2 || report.Snippets != 1 || report.Branches != 3 {
+		t.Errorf("Unexpected counts: %d prompts, %d snippets, %d branches", report.Prompts, report.Snippets, report.Branches)
+	}
+
+	problems := make(map[string]*Issue)
+	for _, issue := range report.Issues {
+		problems[issue.ID] = issue
+	}
+	if len(problems) != 3 {
+		t.Fatalf("Expected 3 issues, got %d", len(report.Issues))
+	}
+	if problems[missing].Problem != MissingBranch {
+		t.Errorf("Expected missing branch for %s, got %s", missing, problems[missing].Problem)
+	}
+	if problems[orphaned].Problem != OrphanedBranch || problems[orphaned].Title != "No row" {
+		t.Errorf("Expected orphaned branch for %s, got %+v", orphaned, problems[orphaned])
+	}
+	drift := problems[drifted]
+	if drift.Problem != ContentDrift || len(drift.Fields) != 1 || drift.Fields[0] != "title" {
+		t.Errorf("Expected title drift for %s, got %+v", drifted, drift)
+	}
+	if report.Clean() {
+		t.Error("Expected report with issues not to be clean")
+	}
+}
+
+func TestRepairFromGit(t *testing.T) {
+	repo, gitService, checker := setupChecker(t)
+	ctx := context.Background()
+
+	missing, orphaned, drifted := introduceDrift(t, repo, gitService)
+
+	report, err := checker.Repair(ctx, FromGit)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if !report.Clean() {
+		t.Fatalf("Expected all issues repaired, got %+v", report.Issues)
+	}
+
+	// Rows follow the branches; the row without branch keeps its data
+	imported, err := repo.Prompts().GetByID(ctx, orphaned)
+	if err != nil {
+		t.Fatalf("Expected orphaned branch to be imported: %v", err)
+	}
+	if imported.Title != "No row" || len(imported.Tags) != 1 || imported.Tags[0] != "kept" {
+		t.Errorf("Unexpected imported prompt: %+v", imported)
+	}
+	updated, err := repo.Prompts().GetByID(ctx, drifted)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	if updated.Title != "Branch title" {
+		t.Errorf("Expected title from branch, got %q", updated.Title)
+	}
+	if head, _ := gitService.BranchHead(ctx, git.PromptBranch(missing)); head == "" {
+		t.Error("Expected missing branch to be created")
+	}
+
+	assertClean(t, checker)
+}
+
+func TestRepairFromDatabase(t *testing.T) {
+	repo, gitService, checker := setupChecker(t)
+	ctx := context.Background()
+
+	missing, orphaned, drifted := introduceDrift(t, repo, gitService)
+
+	report, err := checker.Repair(ctx, FromDatabase)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if !report.Clean() {
+		t.Fatalf("Expected all issues repaired, got %+v", report.Issues)
+	}
+
+	// Branches follow the rows
+	if head, _ := gitService.BranchHead(ctx, git.PromptBranch(orphaned)); head != "" {
+		t.Error("Expected orphaned branch to be deleted")
+	}
+	if head, _ := gitService.BranchHead(ctx, git.PromptBranch(missing)); head == "" {
+		t.Error("Expected missing branch to be created")
+	}
+	history, err := gitService.GetPromptHistory(ctx, drifted)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if history[0].Message != "Update: Database title" || history[0].Body != repairNote {
+		t.Errorf("Unexpected repair commit: %+v", history[0])
+	}
+
+	assertClean(t, checker)
+}
+
+func TestRepairInvalidSource(t *testing.T) {
+	_, _, checker := setupChecker(t)
+
+	if _, err := checker.Repair(context.Background(), Source("both")); err == nil {
+		t.Error("Expected invalid source to fail")
+	}
+}
+
+func TestRepairStaleGitRef(t *testing.T) {
+	repo, gitService, checker := setupChecker(t)
+	ctx := context.Background()
+
+	prompt := &models.Prompt{Title: "Pinned", Content: "Hello", Type: models.PromptTypeUser}
+	if err := repo.Prompts().Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+	// Rows written before git_ref was maintained have none
+	if err := repo.Prompts().SetGitRef(ctx, prompt.ID, ""); err != nil {
+		t.Fatalf("Failed to clear git ref: %v", err)
+	}
+
+	report, err := checker.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Problem != StaleGitRef {
+		t.Fatalf("Expected a stale git ref, got %+v", report.Issues)
+	}
+
+	if _, err := checker.Repair(ctx, FromDatabase); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	stored, err := repo.Prompts().GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	head, _ := gitService.BranchHead(ctx, git.PromptBranch(prompt.ID))
+	if stored.GitRef == nil || *stored.GitRef != head {
+		t.Errorf("Expected git ref %s, got %v", head, stored.GitRef)
+	}
+
+	assertClean(t, checker)
+}
+
+// assertClean fails the test when a fresh check still finds issues
+func assertClean(t *testing.T, checker *Checker) {
+	t.Helper()
+
+	report, err := checker.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("Expected no issues after repair, got %+v", report.Issues)
+	}
+}
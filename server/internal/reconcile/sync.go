@@ -0,0 +1,135 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/repository"
+)
+
+// SyncResult describes what Sync exchanged with a remote. Pulled, Pushed and Deleted
+// hold branch names.
+type SyncResult struct {
+	Remote string
+	Pulled []string
+	Pushed []string
+	// Deleted branches were deleted on the remote and removed locally along with their rows
+	Deleted []string
+	// Conflicts changed on both sides; they are neither pulled nor pushed
+	Conflicts []git.BranchSync
+	UpToDate  int
+}
+
+// Sync pulls a remote ("" for the first configured one), imports the pulled branches into
+// the database and pushes local changes back. Branches that diverged are reported and left
+// alone on both sides.
+func (c *Checker) Sync(ctx context.Context, remote string) (*SyncResult, error) {
+	c.logger.Debug("Syncing with remote", "remote", remote)
+
+	pull, err := c.gitService.PullRemote(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{
+		Remote:    pull.Remote,
+		Pulled:    []string{},
+		Pushed:    []string{},
+		Deleted:   []string{},
+		Conflicts: []git.BranchSync{},
+	}
+
+	var pulled []git.BranchSync
+	for _, branch := range pull.Branches {
+		switch branch.State {
+		case git.SyncUpToDate:
+			result.UpToDate++
+		case git.SyncPulled:
+			pulled = append(pulled, branch)
+			result.Pulled = append(result.Pulled, branch.Branch)
+		case git.SyncAhead, git.SyncLocalOnly, git.SyncDeletedLocally:
+			result.Pushed = append(result.Pushed, branch.Branch)
+		case git.SyncDeletedRemotely:
+			result.Deleted = append(result.Deleted, branch.Branch)
+		case git.SyncDiverged:
+			result.Conflicts = append(result.Conflicts, branch)
+		}
+	}
+
+	if err := c.importPulled(ctx, result.Pulled, result.Deleted); err != nil {
+		// Move the pulled branches back, so the next sync pulls them again
+		for _, branch := range pulled {
+			if resetErr := c.gitService.ResetBranch(ctx, branch.Branch, branch.Remote, branch.Local); resetErr != nil {
+				c.logger.Error("Failed to reset pulled branch", "branch", branch.Branch, "error", resetErr)
+			}
+		}
+		return nil, fmt.Errorf("failed to import changes from %s: %w", pull.Remote, err)
+	}
+
+	if err := c.gitService.PushRemote(ctx, pull.Remote, result.Pushed); err != nil {
+		return nil, err
+	}
+
+	c.logger.Info("Sync completed", "remote", result.Remote, "pulled", len(result.Pulled), "pushed", len(result.Pushed), "deleted", len(result.Deleted), "conflicts", len(result.Conflicts))
+	return result, nil
+}
+
+// importPulled writes the tips of the pulled branches to the database and deletes the
+// rows of branches deleted on the remote, all in one transaction
+func (c *Checker) importPulled(ctx context.Context, pulled, deleted []string) error {
+	if len(pulled) == 0 && len(deleted) == 0 {
+		return nil
+	}
+
+	var snapshots []*git.PromptSnapshot
+	var snippets []*models.Snippet
+	for _, branch := range pulled {
+		kind, id := branchItem(branch)
+		if kind == KindPrompt {
+			snapshot, err := c.promptTip(ctx, id)
+			if err != nil {
+				return err
+			}
+			snapshots = append(snapshots, snapshot)
+		} else {
+			snippet, err := c.snippetTip(ctx, id)
+			if err != nil {
+				return err
+			}
+			snippets = append(snippets, snippet)
+		}
+	}
+
+	return c.repo.WithTx(ctx, func(tx repository.Repository) error {
+		if err := tx.Prompts().Import(ctx, snapshots); err != nil {
+			return err
+		}
+		if err := tx.Snippets().Import(ctx, snippets); err != nil {
+			return err
+		}
+		for _, branch := range deleted {
+			kind, id := branchItem(branch)
+			var err error
+			if kind == KindPrompt {
+				err = tx.Prompts().Delete(ctx, id)
+			} else {
+				err = tx.Snippets().Delete(ctx, id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// branchItem returns the kind and id of the item a prompt or snippet branch belongs to
+func branchItem(branch string) (Kind, string) {
+	if id, ok := strings.CutPrefix(branch, git.PromptBranch("")); ok {
+		return KindPrompt, id
+	}
+	return KindSnippet, strings.TrimPrefix(branch, git.SnippetBranch(""))
+}
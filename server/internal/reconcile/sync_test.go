@@ -0,0 +1,152 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dikkadev/proompt/server/internal/config"
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/repository"
+	gogit "github.com/go-git/go-git/v5"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+
+	remoteDir := t.TempDir()
+	if _, err := gogit.PlainInit(remoteDir, true); err != nil {
+		t.Fatalf("Failed to create bare remote: %v", err)
+	}
+	remote := config.Remote{Name: "origin", URL: remoteDir}
+
+	// Two installations sharing one remote
+	repoA, _, checkerA := setupChecker(t, remote)
+	repoB, _, checkerB := setupChecker(t, remote)
+
+	prompt := &models.Prompt{Title: "Shared", Content: "Hello {{name}}", Type: models.PromptTypeUser}
+	if err := repoA.Prompts().Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+	if err := repoA.Notes().Create(ctx, &models.Note{PromptID: prompt.ID, Title: "Travels along"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	snippet := &models.Snippet{Title: "Greeting", Content: "Hi"}
+	if err := repoA.Snippets().Create(ctx, snippet); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+
+	result := runSync(t, checkerA, "")
+	if result.Remote != "origin" || len(result.Pushed) != 2 || len(result.Pulled) != 0 {
+		t.Errorf("Expected both branches pushed to origin, got %+v", result)
+	}
+
+	result = runSync(t, checkerB, "origin")
+	if len(result.Pulled) != 2 || len(result.Pushed) != 0 {
+		t.Errorf("Expected both branches pulled, got %+v", result)
+	}
+	pulled, err := repoB.Prompts().GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Expected pulled prompt to be imported: %v", err)
+	}
+	if pulled.Title != "Shared" {
+		t.Errorf("Unexpected pulled prompt: %+v", pulled)
+	}
+	notes, err := repoB.Notes().ListByPromptID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to list notes: %v", err)
+	}
+	if len(notes) != 1 || notes[0].Title != "Travels along" {
+		t.Errorf("Expected note to be pulled with its prompt, got %d notes", len(notes))
+	}
+
+	// An update made on B fast-forwards A
+	pulled.Title = "Shared and edited"
+	if err := repoB.Prompts().Update(ctx, pulled); err != nil {
+		t.Fatalf("Failed to update prompt: %v", err)
+	}
+	if result := runSync(t, checkerB, ""); len(result.Pushed) != 1 || result.UpToDate != 1 {
+		t.Errorf("Expected the edited prompt to be pushed, got %+v", result)
+	}
+	if result := runSync(t, checkerA, ""); len(result.Pulled) != 1 || result.Pulled[0] != git.PromptBranch(prompt.ID) {
+		t.Errorf("Expected the edited prompt to be pulled, got %+v", result)
+	}
+	updated, err := repoA.Prompts().GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	if updated.Title != "Shared and edited" {
+		t.Errorf("Expected fast-forwarded title, got %q", updated.Title)
+	}
+
+	// Edits on both sides are reported and left alone
+	editSnippet(t, repoA, snippet.ID, "Hi from A")
+	editSnippet(t, repoB, snippet.ID, "Hi from B")
+	runSync(t, checkerA, "")
+	result = runSync(t, checkerB, "")
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Branch != git.SnippetBranch(snippet.ID) || result.Conflicts[0].State != git.SyncDiverged {
+		t.Fatalf("Expected a conflict on the snippet, got %+v", result)
+	}
+	kept, err := repoB.Snippets().GetByID(ctx, snippet.ID)
+	if err != nil {
+		t.Fatalf("Failed to get snippet: %v", err)
+	}
+	if kept.Content != "Hi from B" {
+		t.Errorf("Expected local snippet to be kept on conflict, got %q", kept.Content)
+	}
+
+	// Deleting on A deletes the branch on the remote and then on B
+	if err := repoA.Prompts().Delete(ctx, prompt.ID); err != nil {
+		t.Fatalf("Failed to delete prompt: %v", err)
+	}
+	if result := runSync(t, checkerA, ""); len(result.Pushed) != 1 || result.Pushed[0] != git.PromptBranch(prompt.ID) {
+		t.Errorf("Expected the deletion to be pushed, got %+v", result)
+	}
+	if result := runSync(t, checkerB, ""); len(result.Deleted) != 1 || result.Deleted[0] != git.PromptBranch(prompt.ID) {
+		t.Errorf("Expected the deletion to be pulled, got %+v", result)
+	}
+	if _, err := repoB.Prompts().GetByID(ctx, prompt.ID); err == nil {
+		t.Error("Expected prompt deleted on the remote to be deleted locally")
+	}
+
+	assertClean(t, checkerA)
+	assertClean(t, checkerB)
+}
+
+func TestSyncUnknownRemote(t *testing.T) {
+	_, _, withoutRemotes := setupChecker(t)
+	if _, err := withoutRemotes.Sync(context.Background(), ""); !errors.Is(err, git.ErrUnknownRemote) {
+		t.Errorf("Expected ErrUnknownRemote without remotes, got %v", err)
+	}
+
+	_, _, checker := setupChecker(t, config.Remote{Name: "origin", URL: t.TempDir()})
+	if _, err := checker.Sync(context.Background(), "upstream"); !errors.Is(err, git.ErrUnknownRemote) {
+		t.Errorf("Expected ErrUnknownRemote for unconfigured name, got %v", err)
+	}
+}
+
+// runSync runs Sync and fails the test on error
+func runSync(t *testing.T, checker *Checker, remote string) *SyncResult {
+	t.Helper()
+
+	result, err := checker.Sync(context.Background(), remote)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	return result
+}
+
+// editSnippet changes the content of a snippet
+func editSnippet(t *testing.T, repo repository.Repository, id, content string) {
+	t.Helper()
+
+	snippet, err := repo.Snippets().GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get snippet: %v", err)
+	}
+	snippet.Content = content
+	if err := repo.Snippets().Update(context.Background(), snippet); err != nil {
+		t.Fatalf("Failed to update snippet: %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dikkadev/proompt/server/internal/db"
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/dikkadev/proompt/server/internal/template"
+)
+
+// timestampFormat is how both database drivers store time.Time values
+const timestampFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// tagCondition builds a WHERE condition matching rows by their tags in a tag table
+// such as prompt_tags. The condition applies to the id column of the listed table.
+func tagCondition(tagTable, idColumn string, tags []string, mode TagMode) (string, []interface{}) {
+	// Deduplicate so "all" can compare against the number of distinct tags
+	seen := make(map[string]struct{}, len(tags))
+	args := make([]interface{}, 0, len(tags)+1)
+	for _, tag := range tags {
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		args = append(args, tag)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	subquery := fmt.Sprintf("SELECT %s FROM %s WHERE %s IS NOT NULL AND tag_name IN (%s)",
+		idColumn, tagTable, idColumn, placeholders)
+
+	switch mode {
+	case TagModeAll:
+		subquery += fmt.Sprintf(" GROUP BY %s HAVING COUNT(DISTINCT tag_name) = ?", idColumn)
+		args = append(args, len(seen))
+		return "id IN (" + subquery + ")", args
+	case TagModeNone:
+		return "id NOT IN (" + subquery + ")", args
+	default:
+		return "id IN (" + subquery + ")", args
+	}
+}
+
+// timeRangeConditions builds WHERE conditions keeping rows whose timestamp column lies
+// strictly between after and before. Either bound may be nil. Comparing julianday values
+// rather than strings keeps rows with different zone offsets in order.
+func timeRangeConditions(column string, after, before *time.Time) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if after != nil {
+		conditions = append(conditions, fmt.Sprintf("julianday(%s) > julianday(?)", column))
+		args = append(args, after.Format(timestampFormat))
+	}
+
+	if before != nil {
+		conditions = append(conditions, fmt.Sprintf("julianday(%s) < julianday(?)", column))
+		args = append(args, before.Format(timestampFormat))
+	}
+
+	return conditions, args
+}
+
+// hasVariables reports whether content contains {{variable}} placeholders
+func hasVariables(content string) bool {
+	return len(template.ExtractVariables(content)) > 0
+}
+
+// IndexVariables fills in has_variables for the prompts and snippets written before the
+// column existed. Writes keep the column up to date, so it only needs to run once after
+// migrating, before the repository serves has_variables filters.
+func IndexVariables(ctx context.Context, database *db.DB) error {
+	for _, table := range []string{"prompts", "snippets"} {
+		var rows []struct {
+			ID      string `db:"id"`
+			Content string `db:"content"`
+		}
+		if err := database.SelectContext(ctx, &rows, "SELECT id, content FROM "+table+" WHERE has_variables IS NULL"); err != nil {
+			return fmt.Errorf("failed to find unindexed %s: %w", table, err)
+		}
+
+		for _, row := range rows {
+			if _, err := database.ExecContext(ctx, "UPDATE "+table+" SET has_variables = ? WHERE id = ?", hasVariables(row.Content), row.ID); err != nil {
+				return fmt.Errorf("failed to index variables of %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded or was made
+// for a different sort field
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor marks the last item of a page by its sort value and id
+type cursor struct {
+	SortBy SortField `json:"s"`
+	Value  string    `json:"v"`
+	ID     string    `json:"id"`
+}
+
+// PromptCursor returns a cursor continuing a listing sorted by sortBy after prompt
+func PromptCursor(prompt *models.Prompt, sortBy SortField) string {
+	return encodeCursor(sortBy, sortValue(sortBy, prompt.Title, prompt.CreatedAt, prompt.UpdatedAt), prompt.ID)
+}
+
+// SnippetCursor returns a cursor continuing a listing sorted by sortBy after snippet
+func SnippetCursor(snippet *models.Snippet, sortBy SortField) string {
+	return encodeCursor(sortBy, sortValue(sortBy, snippet.Title, snippet.CreatedAt, snippet.UpdatedAt), snippet.ID)
+}
+
+func sortValue(sortBy SortField, title string, createdAt, updatedAt time.Time) string {
+	switch sortBy {
+	case SortByTitle:
+		return title
+	case SortByCreatedAt:
+		return createdAt.Format(timestampFormat)
+	default:
+		return updatedAt.Format(timestampFormat)
+	}
+}
+
+func encodeCursor(sortBy SortField, value, id string) string {
+	sortBy, _ = resolveSort(sortBy, "")
+	data, _ := json.Marshal(cursor{SortBy: sortBy, Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string, sortBy SortField) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return c, ErrInvalidCursor
+	}
+	if c.SortBy != sortBy {
+		return c, fmt.Errorf("%w: made for sort %q", ErrInvalidCursor, c.SortBy)
+	}
+	return c, nil
+}
+
+// resolveSort fills in the default sort: most recently updated first, and titles
+// ascending when no order is given
+func resolveSort(field SortField, order SortOrder) (SortField, SortOrder) {
+	if field == "" {
+		field = SortByUpdatedAt
+	}
+	if order == "" {
+		order = SortDesc
+		if field == SortByTitle {
+			order = SortAsc
+		}
+	}
+	return field, order
+}
+
+// sortExpression returns the SQL expression a field is ordered by, and the expression
+// a bound value must be wrapped in to compare with it. Timestamps are compared as
+// julianday values so different zone offsets order correctly.
+func sortExpression(field SortField) (string, string) {
+	switch field {
+	case SortByTitle:
+		return "title", "?"
+	case SortByCreatedAt:
+		return "julianday(created_at)", "julianday(?)"
+	default:
+		return "julianday(updated_at)", "julianday(?)"
+	}
+}
+
+// orderClause builds the ORDER BY clause for a sort. The id breaks ties so that
+// cursors always continue at the same position.
+func orderClause(field SortField, order SortOrder) string {
+	expression, _ := sortExpression(field)
+	direction := "ASC"
+	if order == SortDesc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", expression, direction, direction)
+}
+
+// cursorCondition builds a WHERE condition keeping the rows that follow the cursor
+func cursorCondition(encoded string, field SortField, order SortOrder) (string, []interface{}, error) {
+	c, err := decodeCursor(encoded, field)
+	if err != nil {
+		return "", nil, err
+	}
+
+	expression, placeholder := sortExpression(field)
+	operator := ">"
+	if order == SortDesc {
+		operator = "<"
+	}
+	return fmt.Sprintf("(%s, id) %s (%s, ?)", expression, operator, placeholder), []interface{}{c.Value, c.ID}, nil
+}
+
+// limitClause builds the LIMIT and OFFSET clauses. SQLite only accepts OFFSET after
+// a LIMIT, where -1 means no limit.
+func limitClause(limit, offset *int) (string, []interface{}) {
+	if limit == nil && offset == nil {
+		return "", nil
+	}
+
+	clause := " LIMIT ?"
+	args := []interface{}{-1}
+	if limit != nil {
+		args[0] = *limit
+	}
+	if offset != nil {
+		clause += " OFFSET ?"
+		args = append(args, *offset)
+	}
+	return clause, args
+}
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/dikkadev/proompt/server/internal/git"
 	"github.com/dikkadev/proompt/server/internal/models"
 )
 
@@ -11,24 +14,45 @@ type Repository interface {
 	Prompts() PromptRepository
 	Snippets() SnippetRepository
 	Notes() NoteRepository
+	Search() SearchRepository
 
 	// WithTx executes a function within a database transaction
 	// If the function returns an error, the transaction is rolled back
+	// Git writes are applied only when the transaction commits
 	WithTx(ctx context.Context, fn func(Repository) error) error
 
 	// Close closes the repository and releases resources
 	Close() error
 }
 
+// ErrVersionConflict is returned by conditional updates when the item was changed since
+// the caller read it
+var ErrVersionConflict = errors.New("version conflict")
+
 // PromptRepository handles CRUD operations for prompts
 type PromptRepository interface {
 	Create(ctx context.Context, prompt *models.Prompt) error
 	GetByID(ctx context.Context, id string) (*models.Prompt, error)
 	Update(ctx context.Context, prompt *models.Prompt) error
+	// UpdateIfUnchanged updates a prompt only if its stored UpdatedAt still equals
+	// expected, and returns ErrVersionConflict otherwise
+	UpdateIfUnchanged(ctx context.Context, prompt *models.Prompt, expected time.Time) error
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, filters PromptFilters) ([]*models.Prompt, error)
+	// Count returns how many prompts match the filters, ignoring pagination
+	Count(ctx context.Context, filters PromptFilters) (int, error)
 	Search(ctx context.Context, query string) ([]*models.Prompt, error)
 
+	// Restore rolls a prompt back to the version stored in the given git commit
+	Restore(ctx context.Context, id string, commitHash string) (*models.Prompt, error)
+	// Import writes prompts as stored in git into the database without new commits
+	Import(ctx context.Context, snapshots []*git.PromptSnapshot) error
+	// SetGitRef records the commit a prompt's branch points at, for branches written outside the repository
+	SetGitRef(ctx context.Context, id string, commitHash string) error
+	// PromoteDraft merges a draft into its prompt and deletes the draft. A draft whose content
+	// conflicts with changes made to the prompt since it was forked returns ErrVersionConflict.
+	PromoteDraft(ctx context.Context, id, name, message string) (*models.Prompt, error)
+
 	// Link management
 	CreateLink(ctx context.Context, link *models.PromptLink) error
 	DeleteLink(ctx context.Context, fromPromptID, toPromptID string) error
@@ -47,10 +71,22 @@ type SnippetRepository interface {
 	Create(ctx context.Context, snippet *models.Snippet) error
 	GetByID(ctx context.Context, id string) (*models.Snippet, error)
 	Update(ctx context.Context, snippet *models.Snippet) error
+	// UpdateIfUnchanged updates a snippet only if its stored UpdatedAt still equals
+	// expected, and returns ErrVersionConflict otherwise
+	UpdateIfUnchanged(ctx context.Context, snippet *models.Snippet, expected time.Time) error
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context, filters SnippetFilters) ([]*models.Snippet, error)
+	// Count returns how many snippets match the filters, ignoring pagination
+	Count(ctx context.Context, filters SnippetFilters) (int, error)
 	Search(ctx context.Context, query string) ([]*models.Snippet, error)
 
+	// Restore rolls a snippet back to the version stored in the given git commit
+	Restore(ctx context.Context, id string, commitHash string) (*models.Snippet, error)
+	// Import writes snippets as stored in git into the database without new commits
+	Import(ctx context.Context, snippets []*models.Snippet) error
+	// SetGitRef records the commit a snippet's branch points at, for branches written outside the repository
+	SetGitRef(ctx context.Context, id string, commitHash string) error
+
 	// Tag management
 	AddTag(ctx context.Context, snippetID, tagName string) error
 	RemoveTag(ctx context.Context, snippetID, tagName string) error
@@ -68,28 +104,106 @@ type NoteRepository interface {
 	Search(ctx context.Context, query string) ([]*models.Note, error)
 }
 
-// PromptFilters defines filtering options for prompt queries
+// SearchRepository handles full-text search across prompts, snippets and notes
+type SearchRepository interface {
+	Search(ctx context.Context, query string, filters SearchFilters) ([]*models.SearchResult, error)
+}
+
+// TagMode controls how the Tags of a filter are matched
+type TagMode string
+
+const (
+	// TagModeAny matches items with at least one of the tags (default)
+	TagModeAny TagMode = "any"
+	// TagModeAll matches items with every one of the tags
+	TagModeAll TagMode = "all"
+	// TagModeNone matches items with none of the tags
+	TagModeNone TagMode = "none"
+)
+
+func (m TagMode) Valid() bool {
+	switch m {
+	case TagModeAny, TagModeAll, TagModeNone:
+		return true
+	}
+	return false
+}
+
+// SortField is a field list results can be ordered by
+type SortField string
+
+const (
+	SortByTitle     SortField = "title"
+	SortByCreatedAt SortField = "created_at"
+	// SortByUpdatedAt is the default sort field
+	SortByUpdatedAt SortField = "updated_at"
+)
+
+func (f SortField) Valid() bool {
+	switch f {
+	case SortByTitle, SortByCreatedAt, SortByUpdatedAt:
+		return true
+	}
+	return false
+}
+
+// SortOrder is the direction results are ordered in
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+func (o SortOrder) Valid() bool {
+	switch o {
+	case SortAsc, SortDesc:
+		return true
+	}
+	return false
+}
+
+// PromptFilters defines filtering options for prompt queries.
+// HasVariables matches prompts whose content does or does not contain {{variable}} placeholders.
+// Without SortOrder, titles sort ascending and timestamps descending. Cursor continues a
+// listing after the prompt it was made from with PromptCursor, using the same sort.
 type PromptFilters struct {
 	Type          *string
 	UseCase       *string
 	Tags          []string
+	TagMode       TagMode
 	HasVariables  *bool
-	CreatedAfter  *string
-	CreatedBefore *string
-	UpdatedAfter  *string
-	UpdatedBefore *string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	SortBy        SortField
+	SortOrder     SortOrder
+	Cursor        *string
 	Limit         *int
 	Offset        *int
 }
 
-// SnippetFilters defines filtering options for snippet queries
+// SnippetFilters defines filtering options for snippet queries.
+// HasVariables matches snippets whose content does or does not contain {{variable}} placeholders.
+// Sorting and cursors work as for PromptFilters; cursors are made with SnippetCursor.
 type SnippetFilters struct {
 	Tags          []string
+	TagMode       TagMode
 	HasVariables  *bool
-	CreatedAfter  *string
-	CreatedBefore *string
-	UpdatedAfter  *string
-	UpdatedBefore *string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	SortBy        SortField
+	SortOrder     SortOrder
+	Cursor        *string
 	Limit         *int
 	Offset        *int
 }
+
+// SearchFilters defines filtering options for full-text search
+type SearchFilters struct {
+	Types []models.SearchResultType
+	Limit *int
+}
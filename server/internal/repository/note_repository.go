@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/dikkadev/proompt/server/internal/git"
 	"github.com/dikkadev/proompt/server/internal/models"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -14,28 +15,47 @@ import (
 
 // noteRepository implements NoteRepository interface
 type noteRepository struct {
-	db     txExecutor
-	logger *slog.Logger
+	db         txExecutor
+	gitService git.GitService
+	logger     *slog.Logger
+
+	// conn is set outside WithTx; each write then runs in its own transaction
+	conn *sqlx.DB
 }
 
 // newNoteRepository creates a new note repository
-func newNoteRepository(db *sqlx.DB, logger *slog.Logger) NoteRepository {
+func newNoteRepository(db *sqlx.DB, gitService git.GitService, logger *slog.Logger) NoteRepository {
 	return &noteRepository{
-		db:     db,
-		logger: logger,
+		db:         db,
+		gitService: gitService,
+		logger:     logger,
+		conn:       db,
 	}
 }
 
 // newNoteRepositoryWithTx creates a new note repository with transaction
-func newNoteRepositoryWithTx(tx *sqlx.Tx, logger *slog.Logger) NoteRepository {
+func newNoteRepositoryWithTx(tx *sqlx.Tx, gitService git.GitService, logger *slog.Logger) NoteRepository {
 	return &noteRepository{
-		db:     tx,
-		logger: logger,
+		db:         tx,
+		gitService: gitService,
+		logger:     logger,
 	}
 }
 
+// inTx runs fn against a copy of the repository bound to a new transaction, so a
+// write and the git commit recording it succeed or fail together
+func (r *noteRepository) inTx(ctx context.Context, fn func(*noteRepository) error) error {
+	return runInTx(ctx, r.conn, r.gitService, r.logger, func(tx *sqlx.Tx, gitService git.GitService) error {
+		return fn(&noteRepository{db: tx, gitService: gitService, logger: r.logger})
+	})
+}
+
 // Create creates a new note
 func (r *noteRepository) Create(ctx context.Context, note *models.Note) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *noteRepository) error { return tx.Create(ctx, note) })
+	}
+
 	if note.ID == "" {
 		note.ID = uuid.New().String()
 	}
@@ -59,6 +79,12 @@ func (r *noteRepository) Create(ctx context.Context, note *models.Note) error {
 		return fmt.Errorf("failed to create note: %w", err)
 	}
 
+	// Notes are versioned on their prompt's branch
+	if err := recordNotesAndLinks(ctx, r.db, r.gitService, note.PromptID, "Add note: "+note.Title); err != nil {
+		r.logger.Error("Failed to record note in git branch", "error", err, "id", note.ID, "prompt_id", note.PromptID)
+		return err
+	}
+
 	r.logger.Info("Note created successfully", "id", note.ID, "title", note.Title)
 	return nil
 }
@@ -89,6 +115,10 @@ func (r *noteRepository) GetByID(ctx context.Context, id string) (*models.Note,
 
 // Update updates an existing note
 func (r *noteRepository) Update(ctx context.Context, note *models.Note) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *noteRepository) error { return tx.Update(ctx, note) })
+	}
+
 	note.UpdatedAt = time.Now()
 
 	r.logger.Debug("Updating note", "id", note.ID, "title", note.Title)
@@ -117,14 +147,35 @@ func (r *noteRepository) Update(ctx context.Context, note *models.Note) error {
 		return fmt.Errorf("note not found: %s", note.ID)
 	}
 
+	// The caller may not have set the prompt, so read it back
+	var promptID string
+	if err := r.db.GetContext(ctx, &promptID, `SELECT prompt_id FROM notes WHERE id = ?`, note.ID); err != nil {
+		r.logger.Error("Failed to get prompt of note", "error", err, "id", note.ID)
+		return fmt.Errorf("failed to get prompt of note: %w", err)
+	}
+
+	if err := recordNotesAndLinks(ctx, r.db, r.gitService, promptID, "Update note: "+note.Title); err != nil {
+		r.logger.Error("Failed to record note in git branch", "error", err, "id", note.ID, "prompt_id", promptID)
+		return err
+	}
+
 	r.logger.Info("Note updated successfully", "id", note.ID, "title", note.Title)
 	return nil
 }
 
 // Delete deletes a note
 func (r *noteRepository) Delete(ctx context.Context, id string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *noteRepository) error { return tx.Delete(ctx, id) })
+	}
+
 	r.logger.Debug("Deleting note", "id", id)
 
+	note, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	query := `DELETE FROM notes WHERE id = ?`
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
@@ -143,6 +194,12 @@ func (r *noteRepository) Delete(ctx context.Context, id string) error {
 		return fmt.Errorf("note not found: %s", id)
 	}
 
+	// The note stays recoverable from earlier commits of its prompt's branch
+	if err := recordNotesAndLinks(ctx, r.db, r.gitService, note.PromptID, "Delete note: "+note.Title); err != nil {
+		r.logger.Error("Failed to record note deletion in git branch", "error", err, "id", id, "prompt_id", note.PromptID)
+		return err
+	}
+
 	r.logger.Info("Note deleted successfully", "id", id)
 	return nil
 }
@@ -172,15 +229,20 @@ func (r *noteRepository) ListByPromptID(ctx context.Context, promptID string) ([
 func (r *noteRepository) Search(ctx context.Context, query string) ([]*models.Note, error) {
 	r.logger.Debug("Searching notes", "query", query)
 
+	match := ftsQuery(query)
+	if match == "" {
+		return []*models.Note{}, nil
+	}
+
 	searchQuery := `
 		SELECT n.id, n.prompt_id, n.title, n.body, n.created_at, n.updated_at
-		FROM notes n
-		JOIN notes_fts fts ON n.id = fts.rowid
+		FROM notes_fts
+		JOIN notes n ON n.id = notes_fts.id
 		WHERE notes_fts MATCH ?
-		ORDER BY rank`
+		ORDER BY ` + notesRank
 
 	var notes []*models.Note
-	err := r.db.SelectContext(ctx, &notes, searchQuery, query)
+	err := r.db.SelectContext(ctx, &notes, searchQuery, match)
 	if err != nil {
 		r.logger.Error("Failed to search notes", "error", err, "query", query)
 		return nil, fmt.Errorf("failed to search notes: %w", err)
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/models"
+)
+
+// recordNotesAndLinks commits a prompt's current notes and outgoing links to its git branch,
+// with message as the commit subject
+func recordNotesAndLinks(ctx context.Context, db txExecutor, gitService git.GitService, promptID, message string) error {
+	var notes []*models.Note
+	err := db.SelectContext(ctx, &notes, `
+		SELECT id, prompt_id, title, body, created_at, updated_at
+		FROM notes
+		WHERE prompt_id = ?`, promptID)
+	if err != nil {
+		return fmt.Errorf("failed to list notes: %w", err)
+	}
+
+	var links []*models.PromptLink
+	err = db.SelectContext(ctx, &links, `
+		SELECT from_prompt_id, to_prompt_id, link_type, created_at
+		FROM prompt_links
+		WHERE from_prompt_id = ?`, promptID)
+	if err != nil {
+		return fmt.Errorf("failed to list prompt links: %w", err)
+	}
+
+	if err := gitService.UpdatePromptNotesAndLinks(ctx, promptID, notes, links, message); err != nil {
+		return fmt.Errorf("failed to update git branch: %w", err)
+	}
+
+	return nil
+}
@@ -19,6 +19,9 @@ type promptRepository struct {
 	db         txExecutor
 	gitService git.GitService
 	logger     *slog.Logger
+
+	// conn is set outside WithTx; each write then runs in its own transaction
+	conn *sqlx.DB
 }
 
 // newPromptRepository creates a new prompt repository
@@ -27,6 +30,7 @@ func newPromptRepository(db *sqlx.DB, gitService git.GitService, logger *slog.Lo
 		db:         db,
 		gitService: gitService,
 		logger:     logger,
+		conn:       db,
 	}
 }
 
@@ -39,8 +43,30 @@ func newPromptRepositoryWithTx(tx *sqlx.Tx, gitService git.GitService, logger *s
 	}
 }
 
+// inTx runs fn against a copy of the repository bound to a new transaction, so a
+// write and the git commit recording it succeed or fail together
+func (r *promptRepository) inTx(ctx context.Context, fn func(*promptRepository) error) error {
+	return runInTx(ctx, r.conn, r.gitService, r.logger, func(tx *sqlx.Tx, gitService git.GitService) error {
+		return fn(&promptRepository{db: tx, gitService: gitService, logger: r.logger})
+	})
+}
+
+// promptRow is a prompt as written to its table, along with the columns derived from it
+type promptRow struct {
+	*models.Prompt
+	HasVariables bool `db:"has_variables"`
+}
+
+func newPromptRow(prompt *models.Prompt) promptRow {
+	return promptRow{Prompt: prompt, HasVariables: hasVariables(prompt.Content)}
+}
+
 // Create creates a new prompt
 func (r *promptRepository) Create(ctx context.Context, prompt *models.Prompt) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.Create(ctx, prompt) })
+	}
+
 	if prompt.ID == "" {
 		prompt.ID = uuid.New().String()
 	}
@@ -54,18 +80,22 @@ func (r *promptRepository) Create(ctx context.Context, prompt *models.Prompt) er
 	query := `
 		INSERT INTO prompts (
 			id, title, content, type, use_case, model_compatibility_tags, 
-			temperature_suggestion, other_parameters, created_at, updated_at
+			temperature_suggestion, other_parameters, created_at, updated_at, has_variables
 		) VALUES (
 			:id, :title, :content, :type, :use_case, :model_compatibility_tags,
-			:temperature_suggestion, :other_parameters, :created_at, :updated_at
+			:temperature_suggestion, :other_parameters, :created_at, :updated_at, :has_variables
 		)`
 
-	_, err := r.db.NamedExecContext(ctx, query, prompt)
+	_, err := r.db.NamedExecContext(ctx, query, newPromptRow(prompt))
 	if err != nil {
 		r.logger.Error("Failed to create prompt in database", "error", err, "id", prompt.ID)
 		return fmt.Errorf("failed to create prompt: %w", err)
 	}
 
+	if err := r.insertTags(ctx, prompt.ID, prompt.Tags); err != nil {
+		return err
+	}
+
 	// Create git branch for versioning
 	if err := r.gitService.CreatePromptBranch(ctx, prompt, ""); err != nil {
 		r.logger.Error("Failed to create git branch for prompt", "error", err, "id", prompt.ID)
@@ -97,16 +127,281 @@ func (r *promptRepository) GetByID(ctx context.Context, id string) (*models.Prom
 		return nil, fmt.Errorf("failed to get prompt: %w", err)
 	}
 
+	tags, err := r.GetTags(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	prompt.Tags = tags
+
 	r.logger.Debug("Prompt retrieved successfully", "id", id, "title", prompt.Title)
 	return &prompt, nil
 }
 
 // Update updates an existing prompt
 func (r *promptRepository) Update(ctx context.Context, prompt *models.Prompt) error {
-	prompt.UpdatedAt = time.Now()
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.Update(ctx, prompt) })
+	}
 
 	r.logger.Debug("Updating prompt", "id", prompt.ID, "title", prompt.Title)
 
+	if err := r.updateRow(ctx, prompt); err != nil {
+		return err
+	}
+
+	// The snapshot records the tags stored for the prompt, not whatever the caller passed
+	tags, err := r.GetTags(ctx, prompt.ID)
+	if err != nil {
+		return err
+	}
+	prompt.Tags = tags
+
+	// Update git branch
+	if err := r.gitService.UpdatePromptBranch(ctx, prompt, ""); err != nil {
+		r.logger.Error("Failed to update git branch for prompt", "error", err, "id", prompt.ID)
+		return fmt.Errorf("failed to update git branch: %w", err)
+	}
+
+	r.logger.Info("Prompt updated successfully", "id", prompt.ID, "title", prompt.Title)
+	return nil
+}
+
+// UpdateIfUnchanged updates a prompt like Update, but only if its stored updated_at still
+// equals expected. The check runs in the same transaction as the write.
+func (r *promptRepository) UpdateIfUnchanged(ctx context.Context, prompt *models.Prompt, expected time.Time) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.UpdateIfUnchanged(ctx, prompt, expected) })
+	}
+
+	var current time.Time
+	err := r.db.GetContext(ctx, &current, `SELECT updated_at FROM prompts WHERE id = ?`, prompt.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			r.logger.Debug("Prompt not found for update", "id", prompt.ID)
+			return fmt.Errorf("prompt not found: %s", prompt.ID)
+		}
+		r.logger.Error("Failed to get prompt version", "error", err, "id", prompt.ID)
+		return fmt.Errorf("failed to get prompt version: %w", err)
+	}
+
+	if !current.Equal(expected) {
+		r.logger.Debug("Prompt changed since it was read", "id", prompt.ID, "expected", expected, "current", current)
+		return fmt.Errorf("%w: prompt %s was updated at %s", ErrVersionConflict, prompt.ID, current.Format(time.RFC3339Nano))
+	}
+
+	return r.Update(ctx, prompt)
+}
+
+// Restore rolls a prompt back to the version stored in the given git commit
+func (r *promptRepository) Restore(ctx context.Context, id string, commitHash string) (*models.Prompt, error) {
+	if r.conn != nil {
+		var restored *models.Prompt
+		err := r.inTx(ctx, func(tx *promptRepository) (err error) {
+			restored, err = tx.Restore(ctx, id, commitHash)
+			return err
+		})
+		return restored, err
+	}
+
+	r.logger.Debug("Restoring prompt", "id", id, "commit", commitHash)
+
+	prompt, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := r.gitService.GetPromptSnapshot(ctx, id, commitHash)
+	if err != nil {
+		r.logger.Error("Failed to load prompt version", "error", err, "id", id, "commit", commitHash)
+		return nil, fmt.Errorf("failed to load prompt version: %w", err)
+	}
+	version := snapshot.Prompt
+
+	// Only versioned fields are restored; fields not stored in git are kept as they are
+	prompt.Title = version.Title
+	prompt.Content = version.Content
+	prompt.Type = version.Type
+	prompt.UseCase = version.UseCase
+	prompt.ModelCompatibilityTags = version.ModelCompatibilityTags
+	prompt.OtherParameters = version.OtherParameters
+
+	if err := r.updateRow(ctx, prompt); err != nil {
+		return nil, err
+	}
+
+	if err := r.replaceTags(ctx, id, version.Tags); err != nil {
+		return nil, err
+	}
+	prompt.Tags = version.Tags
+
+	// Versions committed before notes and links were stored in git leave them as they are
+	if snapshot.Notes != nil {
+		if err := r.replaceNotes(ctx, id, snapshot.Notes); err != nil {
+			return nil, err
+		}
+	}
+	if snapshot.Links != nil {
+		if err := r.replaceLinks(ctx, id, snapshot.Links); err != nil {
+			return nil, err
+		}
+	}
+
+	// Record the restore as a new commit instead of rewriting history
+	if err := r.gitService.RestorePromptBranch(ctx, prompt, commitHash, ""); err != nil {
+		r.logger.Error("Failed to record restore in git branch", "error", err, "id", id)
+		return nil, fmt.Errorf("failed to update git branch: %w", err)
+	}
+
+	r.logger.Info("Prompt restored successfully", "id", id, "commit", commitHash)
+	return prompt, nil
+}
+
+// PromoteDraft merges a draft into its prompt, records the result as a merge commit of the
+// draft's branch into the prompt's and deletes the draft. Fields not stored in git are kept.
+func (r *promptRepository) PromoteDraft(ctx context.Context, id, name, message string) (*models.Prompt, error) {
+	if r.conn != nil {
+		var promoted *models.Prompt
+		err := r.inTx(ctx, func(tx *promptRepository) (err error) {
+			promoted, err = tx.PromoteDraft(ctx, id, name, message)
+			return err
+		})
+		return promoted, err
+	}
+
+	r.logger.Debug("Promoting prompt draft", "id", id, "draft", name)
+
+	current, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	draft, err := r.gitService.GetPromptDraft(ctx, id, name)
+	if err != nil {
+		r.logger.Error("Failed to load prompt draft", "error", err, "id", id, "draft", name)
+		return nil, fmt.Errorf("failed to load prompt draft: %w", err)
+	}
+	base, err := r.gitService.GetPromptVersion(ctx, id, draft.Base)
+	if err != nil {
+		r.logger.Error("Failed to load draft base", "error", err, "id", id, "commit", draft.Base)
+		return nil, fmt.Errorf("failed to load draft base: %w", err)
+	}
+
+	prompt, content := git.MergePrompt(base, current, draft.Prompt)
+	if !content.Clean() {
+		r.logger.Debug("Draft conflicts with prompt", "id", id, "draft", name, "conflicts", content.Conflicts)
+		return nil, fmt.Errorf("%w: draft %s conflicts with prompt %s in %d places", ErrVersionConflict, name, id, content.Conflicts)
+	}
+
+	if err := r.updateRow(ctx, prompt); err != nil {
+		return nil, err
+	}
+	if err := r.replaceTags(ctx, id, prompt.Tags); err != nil {
+		return nil, err
+	}
+
+	if err := r.gitService.PromotePromptDraft(ctx, prompt, draft, message); err != nil {
+		r.logger.Error("Failed to record draft promotion in git branch", "error", err, "id", id)
+		return nil, fmt.Errorf("failed to update git branch: %w", err)
+	}
+	if err := r.gitService.DeletePromptDraft(ctx, id, name); err != nil {
+		r.logger.Error("Failed to delete promoted draft", "error", err, "id", id, "draft", name)
+		return nil, fmt.Errorf("failed to delete draft: %w", err)
+	}
+
+	r.logger.Info("Prompt draft promoted successfully", "id", id, "draft", name)
+	return prompt, nil
+}
+
+// Import writes prompts as stored in git, inserting or replacing their rows, tags, notes
+// and outgoing links without recording new commits. Links are written after all rows, so
+// they may point at prompts later in the slice. Fields git does not store, like the
+// temperature suggestion, are kept for existing rows; git_ref is set to the commit each
+// snapshot was read from.
+func (r *promptRepository) Import(ctx context.Context, snapshots []*git.PromptSnapshot) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.Import(ctx, snapshots) })
+	}
+
+	r.logger.Debug("Importing prompts", "count", len(snapshots))
+
+	query := `
+		INSERT INTO prompts (
+			id, title, content, type, use_case, model_compatibility_tags,
+			temperature_suggestion, other_parameters, created_at, updated_at, git_ref, has_variables
+		) VALUES (
+			:id, :title, :content, :type, :use_case, :model_compatibility_tags,
+			:temperature_suggestion, :other_parameters, :created_at, :updated_at, :git_ref, :has_variables
+		)
+		ON CONFLICT(id) DO UPDATE SET
+			title = excluded.title,
+			content = excluded.content,
+			has_variables = excluded.has_variables,
+			type = excluded.type,
+			use_case = excluded.use_case,
+			model_compatibility_tags = excluded.model_compatibility_tags,
+			other_parameters = excluded.other_parameters,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			git_ref = excluded.git_ref`
+
+	for _, snapshot := range snapshots {
+		prompt := snapshot.Prompt
+		if _, err := r.db.NamedExecContext(ctx, query, newPromptRow(prompt)); err != nil {
+			r.logger.Error("Failed to import prompt", "error", err, "id", prompt.ID)
+			return fmt.Errorf("failed to import prompt: %w", err)
+		}
+		if err := r.replaceTags(ctx, prompt.ID, prompt.Tags); err != nil {
+			return err
+		}
+		if snapshot.Notes != nil {
+			if err := r.replaceNotes(ctx, prompt.ID, snapshot.Notes); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.Links != nil {
+			if err := r.replaceLinks(ctx, snapshot.Prompt.ID, snapshot.Links); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.logger.Info("Prompts imported successfully", "count", len(snapshots))
+	return nil
+}
+
+// SetGitRef sets the git_ref of a prompt without touching its branch. Writes through the
+// repository keep git_ref up to date themselves; this is for branches changed directly.
+func (r *promptRepository) SetGitRef(ctx context.Context, id string, commitHash string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.SetGitRef(ctx, id, commitHash) })
+	}
+
+	result, err := r.db.ExecContext(ctx, `UPDATE prompts SET git_ref = ? WHERE id = ?`, commitHash, id)
+	if err != nil {
+		r.logger.Error("Failed to set git ref", "error", err, "id", id)
+		return fmt.Errorf("failed to set git ref: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", "error", err, "id", id)
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("prompt not found: %s", id)
+	}
+
+	r.logger.Debug("Git ref set", "id", id, "commit", commitHash)
+	return nil
+}
+
+// updateRow writes the prompt fields to the database without touching git
+func (r *promptRepository) updateRow(ctx context.Context, prompt *models.Prompt) error {
+	prompt.UpdatedAt = time.Now()
+
 	query := `
 		UPDATE prompts SET
 			title = :title,
@@ -116,10 +411,11 @@ func (r *promptRepository) Update(ctx context.Context, prompt *models.Prompt) er
 			model_compatibility_tags = :model_compatibility_tags,
 			temperature_suggestion = :temperature_suggestion,
 			other_parameters = :other_parameters,
-			updated_at = :updated_at
+			updated_at = :updated_at,
+			has_variables = :has_variables
 		WHERE id = :id`
 
-	result, err := r.db.NamedExecContext(ctx, query, prompt)
+	result, err := r.db.NamedExecContext(ctx, query, newPromptRow(prompt))
 	if err != nil {
 		r.logger.Error("Failed to update prompt in database", "error", err, "id", prompt.ID)
 		return fmt.Errorf("failed to update prompt: %w", err)
@@ -136,18 +432,15 @@ func (r *promptRepository) Update(ctx context.Context, prompt *models.Prompt) er
 		return fmt.Errorf("prompt not found: %s", prompt.ID)
 	}
 
-	// Update git branch
-	if err := r.gitService.UpdatePromptBranch(ctx, prompt, ""); err != nil {
-		r.logger.Error("Failed to update git branch for prompt", "error", err, "id", prompt.ID)
-		return fmt.Errorf("failed to update git branch: %w", err)
-	}
-
-	r.logger.Info("Prompt updated successfully", "id", prompt.ID, "title", prompt.Title)
 	return nil
 }
 
 // Delete deletes a prompt
 func (r *promptRepository) Delete(ctx context.Context, id string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.Delete(ctx, id) })
+	}
+
 	r.logger.Debug("Deleting prompt", "id", id)
 
 	query := `DELETE FROM prompts WHERE id = ?`
@@ -184,6 +477,10 @@ func (r *promptRepository) List(ctx context.Context, filters PromptFilters) ([]*
 		"type", filters.Type,
 		"use_case", filters.UseCase,
 		"tags", filters.Tags,
+		"tag_mode", filters.TagMode,
+		"has_variables", filters.HasVariables,
+		"sort_by", filters.SortBy,
+		"sort_order", filters.SortOrder,
 		"limit", filters.Limit,
 		"offset", filters.Offset)
 
@@ -192,6 +489,62 @@ func (r *promptRepository) List(ctx context.Context, filters PromptFilters) ([]*
 		       temperature_suggestion, other_parameters, created_at, updated_at, git_ref
 		FROM prompts`
 
+	conditions, args := promptConditions(filters)
+
+	sortBy, sortOrder := resolveSort(filters.SortBy, filters.SortOrder)
+	if filters.Cursor != nil {
+		condition, cursorArgs, err := cursorCondition(*filters.Cursor, sortBy, sortOrder)
+		if err != nil {
+			r.logger.Debug("Invalid prompt cursor", "cursor", *filters.Cursor, "error", err)
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, cursorArgs...)
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += orderClause(sortBy, sortOrder)
+
+	clause, limitArgs := limitClause(filters.Limit, filters.Offset)
+	query += clause
+	args = append(args, limitArgs...)
+
+	var prompts []*models.Prompt
+	err := r.db.SelectContext(ctx, &prompts, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to list prompts", "error", err)
+		return nil, fmt.Errorf("failed to list prompts: %w", err)
+	}
+
+	r.logger.Debug("Prompts listed successfully", "count", len(prompts))
+	return prompts, nil
+}
+
+// Count counts the prompts matching the filters, ignoring pagination
+func (r *promptRepository) Count(ctx context.Context, filters PromptFilters) (int, error) {
+	r.logger.Debug("Counting prompts", "filters", filters)
+
+	conditions, args := promptConditions(filters)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var count int
+	if err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM prompts"+where, args...); err != nil {
+		r.logger.Error("Failed to count prompts", "error", err)
+		return 0, fmt.Errorf("failed to count prompts: %w", err)
+	}
+
+	r.logger.Debug("Prompts counted successfully", "count", count)
+	return count, nil
+}
+
+// promptConditions builds the WHERE conditions for the SQL filters of a listing
+func promptConditions(filters PromptFilters) ([]string, []interface{}) {
 	var conditions []string
 	var args []interface{}
 
@@ -206,66 +559,46 @@ func (r *promptRepository) List(ctx context.Context, filters PromptFilters) ([]*
 	}
 
 	if len(filters.Tags) > 0 {
-		placeholders := make([]string, len(filters.Tags))
-		for i, tag := range filters.Tags {
-			placeholders[i] = "?"
-			args = append(args, "%"+tag+"%")
-		}
-		conditions = append(conditions, fmt.Sprintf("tags LIKE %s", strings.Join(placeholders, " OR tags LIKE ")))
-	}
-
-	if filters.CreatedAfter != nil {
-		conditions = append(conditions, "created_at > ?")
-		args = append(args, *filters.CreatedAfter)
-	}
-
-	if filters.CreatedBefore != nil {
-		conditions = append(conditions, "created_at < ?")
-		args = append(args, *filters.CreatedBefore)
+		condition, tagArgs := tagCondition("prompt_tags", "prompt_id", filters.Tags, filters.TagMode)
+		conditions = append(conditions, condition)
+		args = append(args, tagArgs...)
 	}
 
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
+	if filters.HasVariables != nil {
+		conditions = append(conditions, "has_variables = ?")
+		args = append(args, *filters.HasVariables)
 	}
 
-	query += " ORDER BY updated_at DESC"
-
-	if filters.Limit != nil {
-		query += " LIMIT ?"
-		args = append(args, *filters.Limit)
-	}
+	createdConditions, createdArgs := timeRangeConditions("created_at", filters.CreatedAfter, filters.CreatedBefore)
+	conditions = append(conditions, createdConditions...)
+	args = append(args, createdArgs...)
 
-	if filters.Offset != nil {
-		query += " OFFSET ?"
-		args = append(args, *filters.Offset)
-	}
+	updatedConditions, updatedArgs := timeRangeConditions("updated_at", filters.UpdatedAfter, filters.UpdatedBefore)
+	conditions = append(conditions, updatedConditions...)
+	args = append(args, updatedArgs...)
 
-	var prompts []*models.Prompt
-	err := r.db.SelectContext(ctx, &prompts, query, args...)
-	if err != nil {
-		r.logger.Error("Failed to list prompts", "error", err)
-		return nil, fmt.Errorf("failed to list prompts: %w", err)
-	}
-
-	r.logger.Debug("Prompts listed successfully", "count", len(prompts))
-	return prompts, nil
+	return conditions, args
 }
 
 // Search searches prompts using full-text search
 func (r *promptRepository) Search(ctx context.Context, query string) ([]*models.Prompt, error) {
 	r.logger.Debug("Searching prompts", "query", query)
 
-	// For now, use simple LIKE search since we removed FTS
+	match := ftsQuery(query)
+	if match == "" {
+		return []*models.Prompt{}, nil
+	}
+
 	searchQuery := `
-		SELECT id, title, content, type, use_case, model_compatibility_tags,
-		       temperature_suggestion, other_parameters, created_at, updated_at, git_ref
-		FROM prompts
-		WHERE title LIKE ? OR content LIKE ?
-		ORDER BY updated_at DESC`
+		SELECT p.id, p.title, p.content, p.type, p.use_case, p.model_compatibility_tags,
+		       p.temperature_suggestion, p.other_parameters, p.created_at, p.updated_at, p.git_ref
+		FROM prompts_fts
+		JOIN prompts p ON p.id = prompts_fts.id
+		WHERE prompts_fts MATCH ?
+		ORDER BY ` + promptsRank
 
-	searchTerm := "%" + query + "%"
 	var prompts []*models.Prompt
-	err := r.db.SelectContext(ctx, &prompts, searchQuery, searchTerm, searchTerm)
+	err := r.db.SelectContext(ctx, &prompts, searchQuery, match)
 	if err != nil {
 		r.logger.Error("Failed to search prompts", "error", err, "query", query)
 		return nil, fmt.Errorf("failed to search prompts: %w", err)
@@ -277,6 +610,10 @@ func (r *promptRepository) Search(ctx context.Context, query string) ([]*models.
 
 // CreateLink creates a bidirectional link between two prompts
 func (r *promptRepository) CreateLink(ctx context.Context, link *models.PromptLink) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.CreateLink(ctx, link) })
+	}
+
 	r.logger.Debug("Creating prompt link", "from", link.FromPromptID, "to", link.ToPromptID, "type", link.LinkType)
 
 	// Set default link type if not provided
@@ -296,12 +633,22 @@ func (r *promptRepository) CreateLink(ctx context.Context, link *models.PromptLi
 		return fmt.Errorf("failed to create prompt link: %w", err)
 	}
 
+	message := fmt.Sprintf("Link: %s (%s)", link.ToPromptID, link.LinkType)
+	if err := recordNotesAndLinks(ctx, r.db, r.gitService, link.FromPromptID, message); err != nil {
+		r.logger.Error("Failed to record prompt link in git branch", "error", err, "from", link.FromPromptID, "to", link.ToPromptID)
+		return err
+	}
+
 	r.logger.Info("Prompt link created successfully", "from", link.FromPromptID, "to", link.ToPromptID, "type", link.LinkType)
 	return nil
 }
 
 // DeleteLink deletes a link between two prompts
 func (r *promptRepository) DeleteLink(ctx context.Context, fromPromptID, toPromptID string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.DeleteLink(ctx, fromPromptID, toPromptID) })
+	}
+
 	r.logger.Debug("Deleting prompt link", "from", fromPromptID, "to", toPromptID)
 
 	query := `DELETE FROM prompt_links WHERE from_prompt_id = ? AND to_prompt_id = ?`
@@ -322,6 +669,11 @@ func (r *promptRepository) DeleteLink(ctx context.Context, fromPromptID, toPromp
 		return fmt.Errorf("prompt link not found")
 	}
 
+	if err := recordNotesAndLinks(ctx, r.db, r.gitService, fromPromptID, "Unlink: "+toPromptID); err != nil {
+		r.logger.Error("Failed to record prompt link removal in git branch", "error", err, "from", fromPromptID, "to", toPromptID)
+		return err
+	}
+
 	r.logger.Info("Prompt link deleted successfully", "from", fromPromptID, "to", toPromptID)
 	return nil
 }
@@ -370,21 +722,45 @@ func (r *promptRepository) GetLinksTo(ctx context.Context, promptID string) ([]*
 
 // AddTag adds a tag to a prompt
 func (r *promptRepository) AddTag(ctx context.Context, promptID, tagName string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.AddTag(ctx, promptID, tagName) })
+	}
+
 	r.logger.Debug("Adding tag to prompt", "id", promptID, "tag", tagName)
 
 	query := `INSERT OR IGNORE INTO prompt_tags (prompt_id, tag_name) VALUES (?, ?)`
-	_, err := r.db.ExecContext(ctx, query, promptID, tagName)
+	result, err := r.db.ExecContext(ctx, query, promptID, tagName)
 	if err != nil {
 		r.logger.Error("Failed to add tag to prompt", "error", err, "id", promptID, "tag", tagName)
 		return fmt.Errorf("failed to add tag to prompt: %w", err)
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", "error", err, "id", promptID, "tag", tagName)
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	// Adding a tag the prompt already has changes nothing, so there is nothing to commit
+	if rowsAffected == 0 {
+		r.logger.Debug("Prompt already has tag", "id", promptID, "tag", tagName)
+		return nil
+	}
+
+	if err := r.commitTags(ctx, promptID, []string{tagName}, nil); err != nil {
+		return err
+	}
+
 	r.logger.Info("Tag added to prompt successfully", "id", promptID, "tag", tagName)
 	return nil
 }
 
 // RemoveTag removes a tag from a prompt
 func (r *promptRepository) RemoveTag(ctx context.Context, promptID, tagName string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *promptRepository) error { return tx.RemoveTag(ctx, promptID, tagName) })
+	}
+
 	r.logger.Debug("Removing tag from prompt", "id", promptID, "tag", tagName)
 
 	query := `DELETE FROM prompt_tags WHERE prompt_id = ? AND tag_name = ?`
@@ -405,6 +781,10 @@ func (r *promptRepository) RemoveTag(ctx context.Context, promptID, tagName stri
 		return fmt.Errorf("tag not found on prompt")
 	}
 
+	if err := r.commitTags(ctx, promptID, nil, []string{tagName}); err != nil {
+		return err
+	}
+
 	r.logger.Info("Tag removed from prompt successfully", "id", promptID, "tag", tagName)
 	return nil
 }
@@ -440,3 +820,86 @@ func (r *promptRepository) ListAllTags(ctx context.Context) ([]string, error) {
 	r.logger.Debug("All prompt tags listed successfully", "count", len(tags))
 	return tags, nil
 }
+
+// commitTags records the prompt's current tags in its git branch
+func (r *promptRepository) commitTags(ctx context.Context, promptID string, added, removed []string) error {
+	prompt, err := r.GetByID(ctx, promptID)
+	if err != nil {
+		return err
+	}
+
+	if err := r.gitService.UpdatePromptTags(ctx, prompt, added, removed); err != nil {
+		r.logger.Error("Failed to record tags in git branch", "error", err, "id", promptID)
+		return fmt.Errorf("failed to update git branch: %w", err)
+	}
+
+	return nil
+}
+
+// replaceTags sets the prompt's tags in the database without touching git
+func (r *promptRepository) replaceTags(ctx context.Context, promptID string, tags []string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM prompt_tags WHERE prompt_id = ?`, promptID); err != nil {
+		r.logger.Error("Failed to clear prompt tags", "error", err, "id", promptID)
+		return fmt.Errorf("failed to clear prompt tags: %w", err)
+	}
+
+	return r.insertTags(ctx, promptID, tags)
+}
+
+// insertTags adds tags to the prompt in the database without touching git
+func (r *promptRepository) insertTags(ctx context.Context, promptID string, tags []string) error {
+	for _, tag := range tags {
+		if _, err := r.db.ExecContext(ctx, `INSERT OR IGNORE INTO prompt_tags (prompt_id, tag_name) VALUES (?, ?)`, promptID, tag); err != nil {
+			r.logger.Error("Failed to add tag to prompt", "error", err, "id", promptID, "tag", tag)
+			return fmt.Errorf("failed to add tag to prompt: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// replaceNotes sets the prompt's notes in the database without touching git
+func (r *promptRepository) replaceNotes(ctx context.Context, promptID string, notes []*models.Note) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM notes WHERE prompt_id = ?`, promptID); err != nil {
+		r.logger.Error("Failed to clear prompt notes", "error", err, "id", promptID)
+		return fmt.Errorf("failed to clear prompt notes: %w", err)
+	}
+
+	query := `
+		INSERT INTO notes (
+			id, prompt_id, title, body, created_at, updated_at
+		) VALUES (
+			:id, :prompt_id, :title, :body, :created_at, :updated_at
+		)`
+	for _, note := range notes {
+		if _, err := r.db.NamedExecContext(ctx, query, note); err != nil {
+			r.logger.Error("Failed to restore note", "error", err, "id", promptID, "note_id", note.ID)
+			return fmt.Errorf("failed to restore note: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// replaceLinks sets the prompt's outgoing links in the database without touching git.
+// Links to prompts that no longer exist are skipped.
+func (r *promptRepository) replaceLinks(ctx context.Context, promptID string, links []*models.PromptLink) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM prompt_links WHERE from_prompt_id = ?`, promptID); err != nil {
+		r.logger.Error("Failed to clear prompt links", "error", err, "id", promptID)
+		return fmt.Errorf("failed to clear prompt links: %w", err)
+	}
+
+	query := `
+		INSERT INTO prompt_links (from_prompt_id, to_prompt_id, link_type, created_at)
+		SELECT ?, ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM prompts WHERE id = ?)`
+	for _, link := range links {
+		_, err := r.db.ExecContext(ctx, query, promptID, link.ToPromptID, link.LinkType, link.CreatedAt, link.ToPromptID)
+		if err != nil {
+			r.logger.Error("Failed to restore prompt link", "error", err, "from", promptID, "to", link.ToPromptID)
+			return fmt.Errorf("failed to restore prompt link: %w", err)
+		}
+	}
+
+	return nil
+}
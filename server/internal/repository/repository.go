@@ -3,7 +3,6 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"log/slog"
 
 	"github.com/dikkadev/proompt/server/internal/db"
@@ -21,6 +20,7 @@ type repository struct {
 	prompts  PromptRepository
 	snippets SnippetRepository
 	notes    NoteRepository
+	search   SearchRepository
 }
 
 // New creates a new repository instance
@@ -35,7 +35,8 @@ func New(database *db.DB, gitService git.GitService) Repository {
 
 	repo.prompts = newPromptRepository(database.DB, gitService, logger.WithGroup("prompts"))
 	repo.snippets = newSnippetRepository(database.DB, gitService, logger.WithGroup("snippets"))
-	repo.notes = newNoteRepository(database.DB, logger.WithGroup("notes"))
+	repo.notes = newNoteRepository(database.DB, gitService, logger.WithGroup("notes"))
+	repo.search = newSearchRepository(database.DB, logger.WithGroup("search"))
 
 	return repo
 }
@@ -55,50 +56,29 @@ func (r *repository) Notes() NoteRepository {
 	return r.notes
 }
 
-// WithTx executes a function within a database transaction
-func (r *repository) WithTx(ctx context.Context, fn func(Repository) error) error {
-	r.logger.Debug("Starting database transaction")
-
-	tx, err := r.db.BeginTxx(ctx, nil)
-	if err != nil {
-		r.logger.Error("Failed to begin transaction", "error", err)
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-
-	txRepo := &repository{
-		db:         r.db,
-		gitService: r.gitService,
-		logger:     r.logger,
-	}
-
-	txRepo.prompts = newPromptRepositoryWithTx(tx, r.gitService, r.logger.WithGroup("prompts"))
-	txRepo.snippets = newSnippetRepositoryWithTx(tx, r.gitService, r.logger.WithGroup("snippets"))
-	txRepo.notes = newNoteRepositoryWithTx(tx, r.logger.WithGroup("notes"))
-
-	defer func() {
-		if p := recover(); p != nil {
-			r.logger.Error("Transaction panic, rolling back", "panic", p)
-			tx.Rollback()
-			panic(p)
-		}
-	}()
+// Search returns the search repository
+func (r *repository) Search() SearchRepository {
+	return r.search
+}
 
-	if err := fn(txRepo); err != nil {
-		r.logger.Debug("Transaction function failed, rolling back", "error", err)
-		if rbErr := tx.Rollback(); rbErr != nil {
-			r.logger.Error("Failed to rollback transaction", "error", rbErr, "original_error", err)
-			return fmt.Errorf("transaction failed: %w (rollback error: %v)", err, rbErr)
+// WithTx executes a function within a database transaction. Git writes made by the
+// transaction's repositories are held back until fn succeeds and are undone if the
+// transaction cannot be committed.
+func (r *repository) WithTx(ctx context.Context, fn func(Repository) error) error {
+	return runInTx(ctx, r.db.DB, r.gitService, r.logger, func(tx *sqlx.Tx, gitService git.GitService) error {
+		txRepo := &repository{
+			db:         r.db,
+			gitService: r.gitService,
+			logger:     r.logger,
 		}
-		return err
-	}
 
-	if err := tx.Commit(); err != nil {
-		r.logger.Error("Failed to commit transaction", "error", err)
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+		txRepo.prompts = newPromptRepositoryWithTx(tx, gitService, r.logger.WithGroup("prompts"))
+		txRepo.snippets = newSnippetRepositoryWithTx(tx, gitService, r.logger.WithGroup("snippets"))
+		txRepo.notes = newNoteRepositoryWithTx(tx, gitService, r.logger.WithGroup("notes"))
+		txRepo.search = newSearchRepositoryWithTx(tx, r.logger.WithGroup("search"))
 
-	r.logger.Debug("Transaction committed successfully")
-	return nil
+		return fn(txRepo)
+	})
 }
 
 // Close closes the repository and releases resources
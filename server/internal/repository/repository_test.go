@@ -2,20 +2,25 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dikkadev/proompt/server/internal/config"
 	"github.com/dikkadev/proompt/server/internal/db"
+	"github.com/dikkadev/proompt/server/internal/db/hrana/hranatest"
 	"github.com/dikkadev/proompt/server/internal/git"
 	"github.com/dikkadev/proompt/server/internal/models"
 )
 
+// testBackend selects the database used by setupTestRepo: "local" or "turso"
+var testBackend = "local"
+
 func setupTestRepo(t *testing.T) (Repository, func()) {
-	// Create in-memory database
-	database, err := db.NewLocal(":memory:")
-	if err != nil {
-		t.Fatalf("Failed to create test database: %v", err)
-	}
+	database := newTestDatabase(t)
 
 	// Run migrations
 	if err := database.RunMigrations("../db/migrations"); err != nil {
@@ -46,6 +51,31 @@ func setupTestRepo(t *testing.T) (Repository, func()) {
 	return repo, cleanup
 }
 
+// newTestDatabase creates an empty database for the selected test backend
+func newTestDatabase(t *testing.T) *db.DB {
+	if testBackend == "turso" {
+		// Run against an in-process libSQL server
+		server, err := hranatest.NewServer(filepath.Join(t.TempDir(), "turso.db"), "test-token")
+		if err != nil {
+			t.Fatalf("Failed to start libSQL server: %v", err)
+		}
+		t.Cleanup(server.Close)
+
+		database, err := db.NewTurso(server.URL, "test-token")
+		if err != nil {
+			t.Fatalf("Failed to create test database: %v", err)
+		}
+		return database
+	}
+
+	// Create in-memory database
+	database, err := db.NewLocal(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	return database
+}
+
 func TestPromptCRUD(t *testing.T) {
 	repo, cleanup := setupTestRepo(t)
 	defer cleanup()
@@ -118,6 +148,69 @@ func TestPromptCRUD(t *testing.T) {
 	}
 }
 
+func TestPromptRestore(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	prompt := &models.Prompt{
+		Title:   "Original Title",
+		Content: "Original content",
+		Type:    models.PromptTypeSystem,
+	}
+
+	if err := repo.Prompts().Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+
+	history, err := repo.Prompts().(*promptRepository).gitService.GetPromptHistory(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt history: %v", err)
+	}
+	originalHash := history[0].Hash
+
+	prompt.Title = "Broken Title"
+	prompt.Content = "Broken content"
+	if err := repo.Prompts().Update(ctx, prompt); err != nil {
+		t.Fatalf("Failed to update prompt: %v", err)
+	}
+
+	restored, err := repo.Prompts().Restore(ctx, prompt.ID, originalHash)
+	if err != nil {
+		t.Fatalf("Failed to restore prompt: %v", err)
+	}
+
+	if restored.Title != "Original Title" || restored.Content != "Original content" {
+		t.Errorf("Expected original title and content, got %s / %s", restored.Title, restored.Content)
+	}
+
+	// Verify the database row was restored
+	retrieved, err := repo.Prompts().GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+
+	if retrieved.Content != "Original content" {
+		t.Errorf("Expected restored content in database, got %s", retrieved.Content)
+	}
+
+	// Verify the restore was recorded as a new commit
+	history, err = repo.Prompts().(*promptRepository).gitService.GetPromptHistory(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt history: %v", err)
+	}
+
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 commits after restore, got %d", len(history))
+	}
+
+	expectedMessage := "Restore: Original Title to " + originalHash[:7]
+	if history[0].Message != expectedMessage {
+		t.Errorf("Expected commit message %q, got %q", expectedMessage, history[0].Message)
+	}
+}
+
 func TestSnippetCRUD(t *testing.T) {
 	repo, cleanup := setupTestRepo(t)
 	defer cleanup()
@@ -480,3 +573,1139 @@ func TestSnippetTags(t *testing.T) {
 		t.Fatalf("Expected 1 snippet tag after removal, got %d", len(tags))
 	}
 }
+
+func TestSearch(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	titleMatch := &models.Prompt{
+		Title:   "Summarize meeting notes",
+		Content: "Write a short overview of the discussion.",
+		Type:    models.PromptTypeSystem,
+	}
+	bodyMatch := &models.Prompt{
+		Title:   "Email writer",
+		Content: "Draft a reply and summarize the thread in one line.",
+		Type:    models.PromptTypeUser,
+	}
+	unrelated := &models.Prompt{
+		Title:   "Code reviewer",
+		Content: "Review the following diff.",
+		Type:    models.PromptTypeUser,
+	}
+	for _, p := range []*models.Prompt{titleMatch, bodyMatch, unrelated} {
+		if err := repo.Prompts().Create(ctx, p); err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+	}
+
+	snippet := &models.Snippet{
+		Title:   "Summary footer",
+		Content: "Always end with a summary.",
+	}
+	if err := repo.Snippets().Create(ctx, snippet); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+
+	body := "Users asked for shorter summaries"
+	note := &models.Note{PromptID: titleMatch.ID, Title: "Feedback", Body: &body}
+	if err := repo.Notes().Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// Prefix matching and stemming find all forms of "summar..."
+	results, err := repo.Search().Search(ctx, "summar", SearchFilters{})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+
+	var noteResult *models.SearchResult
+	for _, res := range results {
+		if res.Type == models.SearchResultNote {
+			noteResult = res
+		}
+	}
+	if noteResult == nil || noteResult.PromptID == nil || *noteResult.PromptID != titleMatch.ID {
+		t.Errorf("Expected note result linked to its prompt, got %+v", noteResult)
+	}
+	if noteResult != nil && !strings.Contains(noteResult.Snippet, "<mark>summaries</mark>") {
+		t.Errorf("Expected highlighted snippet, got %q", noteResult.Snippet)
+	}
+
+	// Markup in stored text is escaped around the highlight
+	markup := &models.Snippet{
+		Title:   "Markup",
+		Content: `Render <img src=x onerror="alert(1)"> as a widget`,
+	}
+	if err := repo.Snippets().Create(ctx, markup); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+	results, err = repo.Search().Search(ctx, "widget", SearchFilters{})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	expectedSnippet := "Render &lt;img src=x onerror=&#34;alert(1)&#34;&gt; as a <mark>widget</mark>"
+	if len(results) != 1 || results[0].Snippet != expectedSnippet {
+		t.Errorf("Expected escaped snippet %q, got %+v", expectedSnippet, results)
+	}
+
+	// Title matches rank above body matches
+	results, err = repo.Search().Search(ctx, "summar", SearchFilters{Types: []models.SearchResultType{models.SearchResultPrompt}})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != titleMatch.ID {
+		t.Errorf("Expected title match to rank first among 2 prompts, got %+v", results)
+	}
+
+	// Type filter
+	results, err = repo.Search().Search(ctx, "summar", SearchFilters{Types: []models.SearchResultType{models.SearchResultSnippet}})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != snippet.ID {
+		t.Errorf("Expected only the snippet, got %d results", len(results))
+	}
+
+	// FTS syntax in user input is matched literally instead of failing
+	if _, err := repo.Search().Search(ctx, `"summar* OR (`, SearchFilters{}); err != nil {
+		t.Errorf("Expected special characters to be escaped, got %v", err)
+	}
+
+	// Updates and deletes keep the index in sync
+	unrelated.Content = "Summarize the diff before reviewing it."
+	if err := repo.Prompts().Update(ctx, unrelated); err != nil {
+		t.Fatalf("Failed to update prompt: %v", err)
+	}
+	if err := repo.Prompts().Delete(ctx, bodyMatch.ID); err != nil {
+		t.Fatalf("Failed to delete prompt: %v", err)
+	}
+
+	prompts, err := repo.Prompts().Search(ctx, "summarize")
+	if err != nil {
+		t.Fatalf("Failed to search prompts: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("Expected 2 prompts after update and delete, got %d", len(prompts))
+	}
+	for _, p := range prompts {
+		if p.ID == bodyMatch.ID {
+			t.Error("Deleted prompt still returned by search")
+		}
+	}
+
+	// Index rows share the rowid of the row they index, so triggers update them by rowid
+	database := repo.(*repository).db
+	var indexed, linked int
+	if err := database.GetContext(ctx, &indexed, "SELECT COUNT(*) FROM prompts_fts"); err != nil {
+		t.Fatalf("Failed to count index rows: %v", err)
+	}
+	if err := database.GetContext(ctx, &linked, "SELECT COUNT(*) FROM prompts_fts f JOIN prompts p ON p.rowid = f.rowid AND p.id = f.id"); err != nil {
+		t.Fatalf("Failed to count linked index rows: %v", err)
+	}
+	if indexed != 2 || linked != indexed {
+		t.Errorf("Expected 2 index rows linked by rowid, got %d of %d", linked, indexed)
+	}
+}
+
+func TestPromptTagFilters(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// Tags per prompt title
+	tagged := map[string][]string{
+		"Go reviewer":     {"code", "go"},
+		"Python reviewer": {"code", "python"},
+		"Poem writer":     {"creative"},
+		"Untagged":        nil,
+	}
+
+	ids := make(map[string]string)
+	for title, tags := range tagged {
+		prompt := &models.Prompt{Title: title, Content: "content", Type: models.PromptTypeUser}
+		if err := repo.Prompts().Create(ctx, prompt); err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+		ids[prompt.ID] = title
+		for _, tag := range tags {
+			if err := repo.Prompts().AddTag(ctx, prompt.ID, tag); err != nil {
+				t.Fatalf("Failed to add tag: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		tags     []string
+		mode     TagMode
+		expected []string
+	}{
+		{"default mode is any", []string{"go", "creative"}, "", []string{"Go reviewer", "Poem writer"}},
+		{"any", []string{"code"}, TagModeAny, []string{"Go reviewer", "Python reviewer"}},
+		{"all", []string{"code", "go"}, TagModeAll, []string{"Go reviewer"}},
+		{"all with duplicates", []string{"code", "code"}, TagModeAll, []string{"Go reviewer", "Python reviewer"}},
+		{"all without match", []string{"go", "python"}, TagModeAll, nil},
+		{"none", []string{"code"}, TagModeNone, []string{"Poem writer", "Untagged"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompts, err := repo.Prompts().List(ctx, PromptFilters{Tags: tt.tags, TagMode: tt.mode})
+			if err != nil {
+				t.Fatalf("Failed to list prompts: %v", err)
+			}
+
+			got := make(map[string]bool)
+			for _, p := range prompts {
+				got[ids[p.ID]] = true
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, got)
+			}
+			for _, title := range tt.expected {
+				if !got[title] {
+					t.Errorf("Expected %q in results, got %v", title, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSnippetTagFilters(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	tagged := &models.Snippet{Title: "Tagged", Content: "content"}
+	untagged := &models.Snippet{Title: "Untagged", Content: "content"}
+	for _, s := range []*models.Snippet{tagged, untagged} {
+		if err := repo.Snippets().Create(ctx, s); err != nil {
+			t.Fatalf("Failed to create snippet: %v", err)
+		}
+	}
+	for _, tag := range []string{"intro", "formal"} {
+		if err := repo.Snippets().AddTag(ctx, tagged.ID, tag); err != nil {
+			t.Fatalf("Failed to add tag: %v", err)
+		}
+	}
+
+	snippets, err := repo.Snippets().List(ctx, SnippetFilters{Tags: []string{"intro", "formal"}, TagMode: TagModeAll})
+	if err != nil {
+		t.Fatalf("Failed to list snippets: %v", err)
+	}
+	if len(snippets) != 1 || snippets[0].ID != tagged.ID {
+		t.Errorf("Expected only the tagged snippet, got %d snippets", len(snippets))
+	}
+
+	snippets, err = repo.Snippets().List(ctx, SnippetFilters{Tags: []string{"intro"}, TagMode: TagModeNone})
+	if err != nil {
+		t.Fatalf("Failed to list snippets: %v", err)
+	}
+	if len(snippets) != 1 || snippets[0].ID != untagged.ID {
+		t.Errorf("Expected only the untagged snippet, got %d snippets", len(snippets))
+	}
+}
+
+func TestPromptVariableFilters(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	contents := map[string]string{
+		"Greeting":  "Hello {{name}}",
+		"Summary":   "Summarize {{text:the input}} in {{words}} words",
+		"Plain":     "No placeholders here",
+		"Malformed": "Braces {{ } but no variable",
+	}
+
+	ids := make(map[string]string)
+	for title, content := range contents {
+		prompt := &models.Prompt{Title: title, Content: content, Type: models.PromptTypeUser}
+		if err := repo.Prompts().Create(ctx, prompt); err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+		ids[prompt.ID] = title
+	}
+
+	withVariables := true
+	withoutVariables := false
+
+	prompts, err := repo.Prompts().List(ctx, PromptFilters{HasVariables: &withVariables})
+	if err != nil {
+		t.Fatalf("Failed to list prompts: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("Expected 2 prompts with variables, got %d", len(prompts))
+	}
+	for _, p := range prompts {
+		if title := ids[p.ID]; title != "Greeting" && title != "Summary" {
+			t.Errorf("Unexpected prompt with variables: %s", title)
+		}
+	}
+
+	prompts, err = repo.Prompts().List(ctx, PromptFilters{HasVariables: &withoutVariables})
+	if err != nil {
+		t.Fatalf("Failed to list prompts: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Fatalf("Expected 2 prompts without variables, got %d", len(prompts))
+	}
+
+	// Pagination applies to the filtered list
+	limit, offset := 1, 1
+	prompts, err = repo.Prompts().List(ctx, PromptFilters{HasVariables: &withVariables, Limit: &limit, Offset: &offset})
+	if err != nil {
+		t.Fatalf("Failed to list prompts: %v", err)
+	}
+	if len(prompts) != 1 {
+		t.Fatalf("Expected 1 prompt, got %d", len(prompts))
+	}
+	if title := ids[prompts[0].ID]; title != "Greeting" && title != "Summary" {
+		t.Errorf("Unexpected prompt with variables: %s", title)
+	}
+
+	// Updates keep the stored flag in step with the content
+	var plain *models.Prompt
+	for id, title := range ids {
+		if title == "Plain" {
+			plain, err = repo.Prompts().GetByID(ctx, id)
+			if err != nil {
+				t.Fatalf("Failed to get prompt: %v", err)
+			}
+		}
+	}
+	plain.Content = "Now with {{placeholder}}"
+	if err := repo.Prompts().Update(ctx, plain); err != nil {
+		t.Fatalf("Failed to update prompt: %v", err)
+	}
+
+	// Rows written before the flag existed are indexed once at startup
+	database := repo.(*repository).db
+	if _, err := database.ExecContext(ctx, "UPDATE prompts SET has_variables = NULL"); err != nil {
+		t.Fatalf("Failed to clear variable index: %v", err)
+	}
+	if err := IndexVariables(ctx, database); err != nil {
+		t.Fatalf("Failed to index variables: %v", err)
+	}
+	count, err := repo.Prompts().Count(ctx, PromptFilters{HasVariables: &withVariables})
+	if err != nil {
+		t.Fatalf("Failed to count prompts: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 prompts with variables, got %d", count)
+	}
+	var unindexed int
+	if err := database.GetContext(ctx, &unindexed, "SELECT COUNT(*) FROM prompts WHERE has_variables IS NULL"); err != nil {
+		t.Fatalf("Failed to count unindexed prompts: %v", err)
+	}
+	if unindexed != 0 {
+		t.Errorf("Expected every prompt to be indexed, %d are not", unindexed)
+	}
+}
+
+func TestSnippetTimeFilters(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	first := &models.Snippet{Title: "First", Content: "first"}
+	if err := repo.Snippets().Create(ctx, first); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	betweenCreates := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	second := &models.Snippet{Title: "Second", Content: "second"}
+	if err := repo.Snippets().Create(ctx, second); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	beforeUpdate := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	first.Content = "first, updated"
+	if err := repo.Snippets().Update(ctx, first); err != nil {
+		t.Fatalf("Failed to update snippet: %v", err)
+	}
+
+	// The same instant in another zone must select the same snippets
+	elsewhere := betweenCreates.In(time.FixedZone("UTC+5", 5*60*60))
+
+	tests := []struct {
+		name     string
+		filters  SnippetFilters
+		expected string
+	}{
+		{"created after", SnippetFilters{CreatedAfter: &betweenCreates}, "Second"},
+		{"created before", SnippetFilters{CreatedBefore: &betweenCreates}, "First"},
+		{"created after in other zone", SnippetFilters{CreatedAfter: &elsewhere}, "Second"},
+		{"updated after", SnippetFilters{UpdatedAfter: &beforeUpdate}, "First"},
+		{"updated before", SnippetFilters{UpdatedBefore: &beforeUpdate}, "Second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := repo.Snippets().List(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("Failed to list snippets: %v", err)
+			}
+			if len(snippets) != 1 {
+				t.Fatalf("Expected 1 snippet, got %d", len(snippets))
+			}
+			if snippets[0].Title != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, snippets[0].Title)
+			}
+		})
+	}
+}
+
+func TestPromptPagination(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	for _, title := range []string{"Delta", "alpha", "Charlie", "Bravo", "Echo"} {
+		prompt := &models.Prompt{Title: title, Content: "content", Type: models.PromptTypeUser}
+		if err := repo.Prompts().Create(ctx, prompt); err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+		// Timestamps are compared with millisecond precision
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	count, err := repo.Prompts().Count(ctx, PromptFilters{})
+	if err != nil {
+		t.Fatalf("Failed to count prompts: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("Expected 5 prompts, got %d", count)
+	}
+
+	titles := func(prompts []*models.Prompt) string {
+		var names []string
+		for _, p := range prompts {
+			names = append(names, p.Title)
+		}
+		return strings.Join(names, ",")
+	}
+
+	prompts, err := repo.Prompts().List(ctx, PromptFilters{SortBy: SortByTitle})
+	if err != nil {
+		t.Fatalf("Failed to list prompts: %v", err)
+	}
+	if got := titles(prompts); got != "Bravo,Charlie,Delta,Echo,alpha" {
+		t.Errorf("Unexpected ascending title order: %s", got)
+	}
+
+	prompts, err = repo.Prompts().List(ctx, PromptFilters{SortBy: SortByCreatedAt, SortOrder: SortAsc})
+	if err != nil {
+		t.Fatalf("Failed to list prompts: %v", err)
+	}
+	if got := titles(prompts); got != "Delta,alpha,Charlie,Bravo,Echo" {
+		t.Errorf("Unexpected creation order: %s", got)
+	}
+
+	// An offset without a limit skips items
+	offset := 3
+	prompts, err = repo.Prompts().List(ctx, PromptFilters{SortBy: SortByTitle, Offset: &offset})
+	if err != nil {
+		t.Fatalf("Failed to list prompts: %v", err)
+	}
+	if got := titles(prompts); got != "Echo,alpha" {
+		t.Errorf("Unexpected prompts after offset: %s", got)
+	}
+
+	// Walk the listing by cursor while a prompt is added before the current position
+	limit := 2
+	filters := PromptFilters{SortBy: SortByTitle, SortOrder: SortDesc, Limit: &limit}
+	var walked []*models.Prompt
+	for page := 0; page < 5; page++ {
+		prompts, err := repo.Prompts().List(ctx, filters)
+		if err != nil {
+			t.Fatalf("Failed to list prompts: %v", err)
+		}
+		walked = append(walked, prompts...)
+		if len(prompts) < limit {
+			break
+		}
+
+		if page == 0 {
+			added := &models.Prompt{Title: "Zulu", Content: "content", Type: models.PromptTypeUser}
+			if err := repo.Prompts().Create(ctx, added); err != nil {
+				t.Fatalf("Failed to create prompt: %v", err)
+			}
+		}
+
+		cursor := PromptCursor(prompts[len(prompts)-1], SortByTitle)
+		filters.Cursor = &cursor
+	}
+	if got := titles(walked); got != "alpha,Echo,Delta,Charlie,Bravo" {
+		t.Errorf("Unexpected prompts walking by cursor: %s", got)
+	}
+
+	invalid := "not a cursor"
+	if _, err := repo.Prompts().List(ctx, PromptFilters{Cursor: &invalid}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+
+	// Cursors only continue listings with the sort they were made for
+	other := PromptCursor(walked[0], SortByTitle)
+	if _, err := repo.Prompts().List(ctx, PromptFilters{SortBy: SortByUpdatedAt, Cursor: &other}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a different sort, got %v", err)
+	}
+}
+
+func TestSnippetCount(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	for i, content := range []string{"Hello {{name}}", "plain", "also plain"} {
+		snippet := &models.Snippet{Title: fmt.Sprintf("Snippet %d", i), Content: content}
+		if err := repo.Snippets().Create(ctx, snippet); err != nil {
+			t.Fatalf("Failed to create snippet: %v", err)
+		}
+		if i > 0 {
+			if err := repo.Snippets().AddTag(ctx, snippet.ID, "plain"); err != nil {
+				t.Fatalf("Failed to add tag: %v", err)
+			}
+		}
+	}
+
+	withoutVariables := false
+	tests := []struct {
+		name     string
+		filters  SnippetFilters
+		expected int
+	}{
+		{"all", SnippetFilters{}, 3},
+		{"tagged", SnippetFilters{Tags: []string{"plain"}}, 2},
+		{"without variables", SnippetFilters{HasVariables: &withoutVariables}, 2},
+		{"pagination is ignored", SnippetFilters{Limit: &[]int{1}[0]}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := repo.Snippets().Count(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("Failed to count snippets: %v", err)
+			}
+			if count != tt.expected {
+				t.Errorf("Expected %d snippets, got %d", tt.expected, count)
+			}
+		})
+	}
+}
+
+func TestPromptTagHistory(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	prompts := repo.Prompts()
+	gitService := prompts.(*promptRepository).gitService
+
+	prompt := &models.Prompt{Title: "Tagged", Content: "Hello {{name}}", Type: models.PromptTypeUser}
+	if err := prompts.Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+
+	for _, tag := range []string{"b", "a", "a"} {
+		if err := prompts.AddTag(ctx, prompt.ID, tag); err != nil {
+			t.Fatalf("Failed to add tag: %v", err)
+		}
+	}
+	if err := prompts.RemoveTag(ctx, prompt.ID, "b"); err != nil {
+		t.Fatalf("Failed to remove tag: %v", err)
+	}
+
+	// Adding a tag twice only commits once
+	history, err := gitService.GetPromptHistory(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != 4 {
+		t.Fatalf("Expected 4 commits, got %d", len(history))
+	}
+	if history[0].Message != "Tags: Tagged (-b)" {
+		t.Errorf("Unexpected commit message: %q", history[0].Message)
+	}
+
+	bothTags := history[1].Hash
+	version, err := gitService.GetPromptVersion(ctx, prompt.ID, bothTags)
+	if err != nil {
+		t.Fatalf("Failed to get version: %v", err)
+	}
+	if strings.Join(version.Tags, ",") != "a,b" {
+		t.Errorf("Expected tags a,b at %s, got %v", bothTags, version.Tags)
+	}
+
+	// Restoring brings back the tags of that version
+	restored, err := prompts.Restore(ctx, prompt.ID, bothTags)
+	if err != nil {
+		t.Fatalf("Failed to restore prompt: %v", err)
+	}
+	if strings.Join(restored.Tags, ",") != "a,b" {
+		t.Errorf("Expected restored tags a,b, got %v", restored.Tags)
+	}
+
+	tags, err := prompts.GetTags(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	if strings.Join(tags, ",") != "a,b" {
+		t.Errorf("Expected stored tags a,b, got %v", tags)
+	}
+}
+
+func TestPromptNotesAndLinksHistory(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	prompts := repo.Prompts()
+	gitService := prompts.(*promptRepository).gitService
+
+	prompt := &models.Prompt{Title: "Source", Content: "Hello", Type: models.PromptTypeUser}
+	target := &models.Prompt{Title: "Target", Content: "World", Type: models.PromptTypeUser}
+	for _, p := range []*models.Prompt{prompt, target} {
+		if err := prompts.Create(ctx, p); err != nil {
+			t.Fatalf("Failed to create prompt: %v", err)
+		}
+	}
+
+	body := "Worth keeping"
+	note := &models.Note{PromptID: prompt.ID, Title: "Feedback", Body: &body}
+	if err := repo.Notes().Create(ctx, note); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	link := &models.PromptLink{FromPromptID: prompt.ID, ToPromptID: target.ID, LinkType: "followup"}
+	if err := prompts.CreateLink(ctx, link); err != nil {
+		t.Fatalf("Failed to create link: %v", err)
+	}
+	if err := repo.Notes().Delete(ctx, note.ID); err != nil {
+		t.Fatalf("Failed to delete note: %v", err)
+	}
+
+	history, err := gitService.GetPromptHistory(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(history) != 4 {
+		t.Fatalf("Expected 4 commits, got %d", len(history))
+	}
+	if history[0].Message != "Delete note: Feedback" {
+		t.Errorf("Unexpected commit message: %q", history[0].Message)
+	}
+
+	linked := history[1].Hash
+	snapshot, err := gitService.GetPromptSnapshot(ctx, prompt.ID, linked)
+	if err != nil {
+		t.Fatalf("Failed to get snapshot: %v", err)
+	}
+	if len(snapshot.Notes) != 1 || snapshot.Notes[0].ID != note.ID || *snapshot.Notes[0].Body != body {
+		t.Errorf("Expected note %s in snapshot, got %+v", note.ID, snapshot.Notes)
+	}
+	if len(snapshot.Links) != 1 || snapshot.Links[0].ToPromptID != target.ID {
+		t.Errorf("Expected link to %s in snapshot, got %+v", target.ID, snapshot.Links)
+	}
+
+	// Restoring brings back the deleted note
+	if _, err := prompts.Restore(ctx, prompt.ID, linked); err != nil {
+		t.Fatalf("Failed to restore prompt: %v", err)
+	}
+	notes, err := repo.Notes().ListByPromptID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to list notes: %v", err)
+	}
+	if len(notes) != 1 || notes[0].ID != note.ID {
+		t.Errorf("Expected restored note %s, got %+v", note.ID, notes)
+	}
+
+	// Restoring the initial version drops the link again
+	if _, err := prompts.Restore(ctx, prompt.ID, history[3].Hash); err != nil {
+		t.Fatalf("Failed to restore prompt: %v", err)
+	}
+	links, err := prompts.GetLinksFrom(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get links: %v", err)
+	}
+	if len(links) != 0 {
+		t.Errorf("Expected no links after restoring initial version, got %d", len(links))
+	}
+}
+
+// errGitUnavailable is returned by failingGitService writes
+var errGitUnavailable = errors.New("git unavailable")
+
+// failingGitService wraps a git service and fails the selected writes
+type failingGitService struct {
+	git.GitService
+	failPromptUpdates  bool
+	failSnippetCreates bool
+	// beforeSnippetCreate, when set, runs before every snippet create
+	beforeSnippetCreate func(ctx context.Context)
+}
+
+func (f *failingGitService) UpdatePromptBranch(ctx context.Context, prompt *models.Prompt, userNote string) error {
+	if f.failPromptUpdates {
+		return errGitUnavailable
+	}
+	return f.GitService.UpdatePromptBranch(ctx, prompt, userNote)
+}
+
+func (f *failingGitService) CreateSnippetBranch(ctx context.Context, snippet *models.Snippet, userNote string) error {
+	if f.beforeSnippetCreate != nil {
+		f.beforeSnippetCreate(ctx)
+	}
+	if f.failSnippetCreates {
+		return errGitUnavailable
+	}
+	return f.GitService.CreateSnippetBranch(ctx, snippet, userNote)
+}
+
+// setupFailingGitRepo creates a repository on its own git repo whose writes can be made to fail
+func setupFailingGitRepo(t *testing.T) (Repository, *failingGitService, func()) {
+	database := newTestDatabase(t)
+	if err := database.RunMigrations("../db/migrations"); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	cfg := &config.Config{
+		Storage: config.Storage{
+			ReposDir: t.TempDir(),
+		},
+	}
+	gitService, err := git.NewGitService(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create git service: %v", err)
+	}
+
+	failing := &failingGitService{GitService: gitService}
+	repo := New(database, failing)
+	return repo, failing, func() { repo.Close() }
+}
+
+func TestGitFailureRollsBackWrite(t *testing.T) {
+	repo, failing, cleanup := setupFailingGitRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	prompt := &models.Prompt{Title: "Original", Content: "Hello", Type: models.PromptTypeUser}
+	if err := repo.Prompts().Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+
+	failing.failPromptUpdates = true
+	prompt.Title = "Changed"
+	if err := repo.Prompts().Update(ctx, prompt); !errors.Is(err, errGitUnavailable) {
+		t.Fatalf("Expected git error from update, got %v", err)
+	}
+
+	stored, err := repo.Prompts().GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	if stored.Title != "Original" {
+		t.Errorf("Expected title to stay %q, got %q", "Original", stored.Title)
+	}
+
+	failing.failSnippetCreates = true
+	snippet := &models.Snippet{Title: "Lost", Content: "Never stored"}
+	if err := repo.Snippets().Create(ctx, snippet); !errors.Is(err, errGitUnavailable) {
+		t.Fatalf("Expected git error from create, got %v", err)
+	}
+	if _, err := repo.Snippets().GetByID(ctx, snippet.ID); err == nil {
+		t.Error("Expected snippet row to be rolled back")
+	}
+}
+
+func TestTransactionResetsBranches(t *testing.T) {
+	repo, failing, cleanup := setupFailingGitRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	prompt := &models.Prompt{Title: "Original", Content: "Hello", Type: models.PromptTypeUser}
+	if err := repo.Prompts().Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+	head, err := failing.BranchHead(ctx, git.PromptBranch(prompt.ID))
+	if err != nil || head == "" {
+		t.Fatalf("Expected prompt branch, got %q (%v)", head, err)
+	}
+
+	// Nothing reaches git when the transaction function fails
+	var created string
+	err = repo.WithTx(ctx, func(txRepo Repository) error {
+		other := &models.Prompt{Title: "Discarded", Content: "Bye", Type: models.PromptTypeUser}
+		if err := txRepo.Prompts().Create(ctx, other); err != nil {
+			return err
+		}
+		created = other.ID
+		return context.Canceled
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected transaction to fail, got %v", err)
+	}
+	if branch, _ := failing.BranchHead(ctx, git.PromptBranch(created)); branch != "" {
+		t.Errorf("Expected no branch for rolled back prompt, got %s", branch)
+	}
+
+	// A git write that fails at commit undoes the writes applied before it
+	failing.failSnippetCreates = true
+	err = repo.WithTx(ctx, func(txRepo Repository) error {
+		prompt.Title = "Changed"
+		if err := txRepo.Prompts().Update(ctx, prompt); err != nil {
+			return err
+		}
+		return txRepo.Snippets().Create(ctx, &models.Snippet{Title: "Lost", Content: "Never stored"})
+	})
+	if !errors.Is(err, errGitUnavailable) {
+		t.Fatalf("Expected git error from commit, got %v", err)
+	}
+
+	after, err := failing.BranchHead(ctx, git.PromptBranch(prompt.ID))
+	if err != nil {
+		t.Fatalf("Failed to read branch head: %v", err)
+	}
+	if after != head {
+		t.Errorf("Expected prompt branch reset to %s, got %s", head, after)
+	}
+
+	stored, err := repo.Prompts().GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	if stored.Title != "Original" {
+		t.Errorf("Expected title to stay %q, got %q", "Original", stored.Title)
+	}
+
+	// A failed delete brings back the prompt's drafts and releases along with its branch
+	draft, err := failing.CreatePromptDraft(ctx, prompt.ID, "experiment")
+	if err != nil {
+		t.Fatalf("Failed to create draft: %v", err)
+	}
+	release, err := failing.CreatePromptRelease(ctx, prompt.ID, "v1", "", false)
+	if err != nil {
+		t.Fatalf("Failed to create release: %v", err)
+	}
+	err = repo.WithTx(ctx, func(txRepo Repository) error {
+		if err := txRepo.Prompts().Delete(ctx, prompt.ID); err != nil {
+			return err
+		}
+		return txRepo.Snippets().Create(ctx, &models.Snippet{Title: "Lost", Content: "Never stored"})
+	})
+	if !errors.Is(err, errGitUnavailable) {
+		t.Fatalf("Expected git error from commit, got %v", err)
+	}
+
+	if after, _ := failing.BranchHead(ctx, git.PromptBranch(prompt.ID)); after != head {
+		t.Errorf("Expected prompt branch restored to %s, got %q", head, after)
+	}
+	drafts, err := failing.ListPromptDrafts(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to list drafts: %v", err)
+	}
+	if len(drafts) != 1 || drafts[0].Name != draft.Name || drafts[0].Head != draft.Head {
+		t.Errorf("Expected draft %s to be restored, got %+v", draft.Name, drafts)
+	}
+	releases, err := failing.ListPromptReleases(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to list releases: %v", err)
+	}
+	if len(releases) != 1 || releases[0].Label != release.Label || releases[0].Commit != release.Commit {
+		t.Errorf("Expected release %s to be restored, got %+v", release.Label, releases)
+	}
+}
+
+func TestTransactionKeepsMovedBranches(t *testing.T) {
+	repo, failing, cleanup := setupFailingGitRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	prompt := &models.Prompt{Title: "Original", Content: "Hello", Type: models.PromptTypeUser}
+	if err := repo.Prompts().Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+
+	// Another writer commits to the prompt branch after the transaction wrote it
+	var moved string
+	failing.failSnippetCreates = true
+	failing.beforeSnippetCreate = func(ctx context.Context) {
+		other := *prompt
+		other.Title = "Concurrent"
+		if err := failing.GitService.UpdatePromptBranch(ctx, &other, ""); err != nil {
+			t.Fatalf("Failed to update prompt branch: %v", err)
+		}
+		moved, _ = failing.GitService.BranchHead(ctx, git.PromptBranch(prompt.ID))
+	}
+
+	err := repo.WithTx(ctx, func(txRepo Repository) error {
+		prompt.Title = "Changed"
+		if err := txRepo.Prompts().Update(ctx, prompt); err != nil {
+			return err
+		}
+		return txRepo.Snippets().Create(ctx, &models.Snippet{Title: "Lost", Content: "Never stored"})
+	})
+	if !errors.Is(err, errGitUnavailable) {
+		t.Fatalf("Expected git error from commit, got %v", err)
+	}
+	if !errors.Is(err, git.ErrBranchMoved) {
+		t.Errorf("Expected the moved branch to be reported, got %v", err)
+	}
+
+	// The other writer's commit is kept rather than reset away
+	head, err := failing.BranchHead(ctx, git.PromptBranch(prompt.ID))
+	if err != nil {
+		t.Fatalf("Failed to read branch head: %v", err)
+	}
+	if moved == "" || head != moved {
+		t.Errorf("Expected prompt branch to stay at %s, got %s", moved, head)
+	}
+}
+
+func TestConditionalUpdate(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	prompt := &models.Prompt{Title: "Original", Content: "Hello", Type: models.PromptTypeUser}
+	if err := repo.Prompts().Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+
+	// Two readers of the same version; only the first write may succeed
+	first, err := repo.Prompts().GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	second, err := repo.Prompts().GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+
+	first.Title = "First"
+	if err := repo.Prompts().UpdateIfUnchanged(ctx, first, second.UpdatedAt); err != nil {
+		t.Fatalf("Expected update of unchanged prompt to succeed: %v", err)
+	}
+
+	expected := second.UpdatedAt
+	second.Title = "Second"
+	err = repo.Prompts().UpdateIfUnchanged(ctx, second, expected)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("Expected version conflict, got %v", err)
+	}
+
+	stored, err := repo.Prompts().GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	if stored.Title != "First" {
+		t.Errorf("Expected title %q to be kept, got %q", "First", stored.Title)
+	}
+	if !stored.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Errorf("Expected stored version %s, got %s", first.UpdatedAt, stored.UpdatedAt)
+	}
+
+	snippet := &models.Snippet{Title: "Snippet", Content: "Hi"}
+	if err := repo.Snippets().Create(ctx, snippet); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+	stale := snippet.UpdatedAt.Add(-time.Second)
+	snippet.Content = "Changed"
+	if err := repo.Snippets().UpdateIfUnchanged(ctx, snippet, stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected version conflict for snippet, got %v", err)
+	}
+	if err := repo.Snippets().UpdateIfUnchanged(ctx, &models.Snippet{ID: "missing", Title: "x", Content: "x"}, stale); err == nil || errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected not found error for missing snippet, got %v", err)
+	}
+}
+
+func TestGitRef(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	prompts := repo.Prompts()
+	gitService := prompts.(*promptRepository).gitService
+
+	// assertHead checks that gitRef and the stored git_ref are the head of branch
+	assertHead := func(what string, gitRef *string, stored *string, branch string) {
+		t.Helper()
+		head, err := gitService.BranchHead(ctx, branch)
+		if err != nil {
+			t.Fatalf("Failed to get branch head: %v", err)
+		}
+		if gitRef == nil || *gitRef != head {
+			t.Errorf("Expected %s to set git ref %s, got %v", what, head, gitRef)
+		}
+		if stored == nil || *stored != head {
+			t.Errorf("Expected %s to store git ref %s, got %v", what, head, stored)
+		}
+	}
+	getPrompt := func(id string) *models.Prompt {
+		t.Helper()
+		prompt, err := prompts.GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to get prompt: %v", err)
+		}
+		return prompt
+	}
+
+	prompt := &models.Prompt{Title: "Versioned", Content: "Hello", Type: models.PromptTypeUser}
+	if err := prompts.Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+	assertHead("create", prompt.GitRef, getPrompt(prompt.ID).GitRef, git.PromptBranch(prompt.ID))
+	created := *prompt.GitRef
+
+	prompt.Content = "Hello again"
+	if err := prompts.Update(ctx, prompt); err != nil {
+		t.Fatalf("Failed to update prompt: %v", err)
+	}
+	assertHead("update", prompt.GitRef, getPrompt(prompt.ID).GitRef, git.PromptBranch(prompt.ID))
+	if *prompt.GitRef == created {
+		t.Error("Expected update to move the git ref")
+	}
+
+	// Notes are committed on the prompt's branch as well
+	if err := repo.Notes().Create(ctx, &models.Note{PromptID: prompt.ID, Title: "Note"}); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	stored := getPrompt(prompt.ID)
+	assertHead("note", stored.GitRef, stored.GitRef, git.PromptBranch(prompt.ID))
+
+	restored, err := prompts.Restore(ctx, prompt.ID, created)
+	if err != nil {
+		t.Fatalf("Failed to restore prompt: %v", err)
+	}
+	assertHead("restore", restored.GitRef, getPrompt(prompt.ID).GitRef, git.PromptBranch(prompt.ID))
+
+	// Versions read from git carry the commit they were read from
+	version, err := gitService.GetPromptVersion(ctx, prompt.ID, created[:7])
+	if err != nil {
+		t.Fatalf("Failed to get prompt version: %v", err)
+	}
+	if version.GitRef == nil || *version.GitRef != created {
+		t.Errorf("Expected version to carry full hash %s, got %v", created, version.GitRef)
+	}
+
+	snippet := &models.Snippet{Title: "Snippet", Content: "Hi"}
+	if err := repo.Snippets().Create(ctx, snippet); err != nil {
+		t.Fatalf("Failed to create snippet: %v", err)
+	}
+	storedSnippet, err := repo.Snippets().GetByID(ctx, snippet.ID)
+	if err != nil {
+		t.Fatalf("Failed to get snippet: %v", err)
+	}
+	assertHead("snippet create", snippet.GitRef, storedSnippet.GitRef, git.SnippetBranch(snippet.ID))
+}
+
+func TestPromoteDraft(t *testing.T) {
+	repo, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	prompts := repo.Prompts()
+	gitService := prompts.(*promptRepository).gitService
+
+	temperature := 0.7
+	prompt := &models.Prompt{
+		Title:                 "Greeting",
+		Content:               "Hello\n\nHow are you?\n",
+		Type:                  models.PromptTypeUser,
+		TemperatureSuggestion: &temperature,
+	}
+	if err := prompts.Create(ctx, prompt); err != nil {
+		t.Fatalf("Failed to create prompt: %v", err)
+	}
+
+	draft, err := gitService.CreatePromptDraft(ctx, prompt.ID, "casual")
+	if err != nil {
+		t.Fatalf("Failed to create draft: %v", err)
+	}
+	draft.Prompt.Content = "Hey\n\nHow are you?\n"
+	draft.Prompt.Tags = []string{"casual"}
+	if err := gitService.UpdatePromptDraft(ctx, draft.Prompt, "casual", ""); err != nil {
+		t.Fatalf("Failed to update draft: %v", err)
+	}
+
+	// The prompt moves on while the draft is being worked on
+	prompt.Content = "Hello\n\nHow are you today?\n"
+	if err := prompts.Update(ctx, prompt); err != nil {
+		t.Fatalf("Failed to update prompt: %v", err)
+	}
+
+	promoted, err := prompts.PromoteDraft(ctx, prompt.ID, "casual", "Sounds friendlier")
+	if err != nil {
+		t.Fatalf("Failed to promote draft: %v", err)
+	}
+	if promoted.Content != "Hey\n\nHow are you today?\n" {
+		t.Errorf("Expected both changes to be merged, got %q", promoted.Content)
+	}
+
+	stored, err := prompts.GetByID(ctx, prompt.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prompt: %v", err)
+	}
+	if stored.Content != promoted.Content || len(stored.Tags) != 1 || stored.Tags[0] != "casual" {
+		t.Errorf("Expected the merge to be stored, got %q with tags %v", stored.Content, stored.Tags)
+	}
+	if stored.TemperatureSuggestion == nil || *stored.TemperatureSuggestion != temperature {
+		t.Error("Expected fields not stored in git to be kept")
+	}
+	head, err := gitService.BranchHead(ctx, git.PromptBranch(prompt.ID))
+	if err != nil {
+		t.Fatalf("Failed to get branch head: %v", err)
+	}
+	if stored.GitRef == nil || *stored.GitRef != head {
+		t.Errorf("Expected git ref %s after promotion, got %v", head, stored.GitRef)
+	}
+	if _, err := gitService.GetPromptDraft(ctx, prompt.ID, "casual"); !errors.Is(err, git.ErrDraftNotFound) {
+		t.Errorf("Expected the promoted draft to be deleted, got %v", err)
+	}
+
+	// A draft whose content conflicts with the prompt is not promoted
+	draft, err = gitService.CreatePromptDraft(ctx, prompt.ID, "formal")
+	if err != nil {
+		t.Fatalf("Failed to create draft: %v", err)
+	}
+	draft.Prompt.Content = "Good day\n\nHow are you today?\n"
+	if err := gitService.UpdatePromptDraft(ctx, draft.Prompt, "formal", ""); err != nil {
+		t.Fatalf("Failed to update draft: %v", err)
+	}
+	stored.Content = "Hi\n\nHow are you today?\n"
+	if err := prompts.Update(ctx, stored); err != nil {
+		t.Fatalf("Failed to update prompt: %v", err)
+	}
+
+	if _, err := prompts.PromoteDraft(ctx, prompt.ID, "formal", ""); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+	if _, err := gitService.GetPromptDraft(ctx, prompt.ID, "formal"); err != nil {
+		t.Errorf("Expected the conflicting draft to be kept: %v", err)
+	}
+	if _, err := prompts.PromoteDraft(ctx, prompt.ID, "missing", ""); !errors.Is(err, git.ErrDraftNotFound) {
+		t.Errorf("Expected ErrDraftNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// defaultSearchLimit is used when SearchFilters.Limit is not set
+	defaultSearchLimit = 20
+
+	// Markers that FTS5 places around matched terms in result snippets. They are control
+	// characters, so they cannot be confused with the HTML that highlight turns them into.
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+
+	// Highlight tags placed around matched terms once the snippet is HTML-escaped
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+
+	// snippetTokens is the approximate number of tokens in a result snippet
+	snippetTokens = 16
+)
+
+// BM25 column weights. Matches in titles rank above matches in body text;
+// the unindexed id columns get no weight.
+const (
+	promptsRank  = "bm25(prompts_fts, 0, 10.0, 1.0, 2.0)"
+	snippetsRank = "bm25(snippets_fts, 0, 10.0, 1.0, 2.0)"
+	notesRank    = "bm25(notes_fts, 0, 0, 10.0, 1.0)"
+)
+
+// searchRepository implements SearchRepository interface
+type searchRepository struct {
+	db     txExecutor
+	logger *slog.Logger
+}
+
+// newSearchRepository creates a new search repository
+func newSearchRepository(db *sqlx.DB, logger *slog.Logger) SearchRepository {
+	return &searchRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// newSearchRepositoryWithTx creates a new search repository with transaction
+func newSearchRepositoryWithTx(tx *sqlx.Tx, logger *slog.Logger) SearchRepository {
+	return &searchRepository{
+		db:     tx,
+		logger: logger,
+	}
+}
+
+// Search runs a ranked full-text search across prompts, snippets and notes
+func (r *searchRepository) Search(ctx context.Context, query string, filters SearchFilters) ([]*models.SearchResult, error) {
+	r.logger.Debug("Searching", "query", query, "types", filters.Types)
+
+	match := ftsQuery(query)
+	if match == "" {
+		return []*models.SearchResult{}, nil
+	}
+
+	wanted := func(t models.SearchResultType) bool {
+		if len(filters.Types) == 0 {
+			return true
+		}
+		for _, ft := range filters.Types {
+			if ft == t {
+				return true
+			}
+		}
+		return false
+	}
+
+	var selects []string
+	var args []interface{}
+
+	if wanted(models.SearchResultPrompt) {
+		selects = append(selects, fmt.Sprintf(`
+		SELECT 'prompt' AS type, id, NULL AS prompt_id, title,
+		       snippet(prompts_fts, -1, ?, ?, '…', %d) AS snippet,
+		       -%s AS score
+		FROM prompts_fts
+		WHERE prompts_fts MATCH ?`, snippetTokens, promptsRank))
+		args = append(args, matchStart, matchEnd, match)
+	}
+	if wanted(models.SearchResultSnippet) {
+		selects = append(selects, fmt.Sprintf(`
+		SELECT 'snippet' AS type, id, NULL AS prompt_id, title,
+		       snippet(snippets_fts, -1, ?, ?, '…', %d) AS snippet,
+		       -%s AS score
+		FROM snippets_fts
+		WHERE snippets_fts MATCH ?`, snippetTokens, snippetsRank))
+		args = append(args, matchStart, matchEnd, match)
+	}
+	if wanted(models.SearchResultNote) {
+		selects = append(selects, fmt.Sprintf(`
+		SELECT 'note' AS type, id, prompt_id, title,
+		       snippet(notes_fts, -1, ?, ?, '…', %d) AS snippet,
+		       -%s AS score
+		FROM notes_fts
+		WHERE notes_fts MATCH ?`, snippetTokens, notesRank))
+		args = append(args, matchStart, matchEnd, match)
+	}
+
+	if len(selects) == 0 {
+		return []*models.SearchResult{}, nil
+	}
+
+	limit := defaultSearchLimit
+	if filters.Limit != nil {
+		limit = *filters.Limit
+	}
+
+	searchQuery := strings.Join(selects, "\n\t\tUNION ALL") + "\n\t\tORDER BY score DESC\n\t\tLIMIT ?"
+	args = append(args, limit)
+
+	results := []*models.SearchResult{}
+	err := r.db.SelectContext(ctx, &results, searchQuery, args...)
+	if err != nil {
+		r.logger.Error("Failed to search", "error", err, "query", query)
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	for _, result := range results {
+		result.Snippet = highlight(result.Snippet)
+	}
+
+	r.logger.Debug("Search completed", "query", query, "count", len(results))
+	return results, nil
+}
+
+// highlight turns a snippet with match markers into HTML. The stored text is escaped,
+// since it may contain markup of its own, and the matches are wrapped in <mark> tags.
+func highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, matchStart, highlightStart)
+	return strings.ReplaceAll(snippet, matchEnd, highlightEnd)
+}
+
+// ftsQuery turns free-form user input into a safe FTS5 query. Every word is quoted,
+// so FTS5 operators and punctuation are matched literally, and becomes a prefix
+// match; all words must match. Returns "" when the input has no words.
+func ftsQuery(input string) string {
+	words := strings.Fields(input)
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		// Words made only of punctuation produce no tokens and would be a syntax error
+		if !strings.ContainsFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		word = strings.ReplaceAll(word, `"`, `""`)
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -19,6 +19,9 @@ type snippetRepository struct {
 	db         txExecutor
 	gitService git.GitService
 	logger     *slog.Logger
+
+	// conn is set outside WithTx; each write then runs in its own transaction
+	conn *sqlx.DB
 }
 
 // newSnippetRepository creates a new snippet repository
@@ -27,6 +30,7 @@ func newSnippetRepository(db *sqlx.DB, gitService git.GitService, logger *slog.L
 		db:         db,
 		gitService: gitService,
 		logger:     logger,
+		conn:       db,
 	}
 }
 
@@ -39,8 +43,30 @@ func newSnippetRepositoryWithTx(tx *sqlx.Tx, gitService git.GitService, logger *
 	}
 }
 
+// inTx runs fn against a copy of the repository bound to a new transaction, so a
+// write and the git commit recording it succeed or fail together
+func (r *snippetRepository) inTx(ctx context.Context, fn func(*snippetRepository) error) error {
+	return runInTx(ctx, r.conn, r.gitService, r.logger, func(tx *sqlx.Tx, gitService git.GitService) error {
+		return fn(&snippetRepository{db: tx, gitService: gitService, logger: r.logger})
+	})
+}
+
+// snippetRow is a snippet as written to its table, along with the columns derived from it
+type snippetRow struct {
+	*models.Snippet
+	HasVariables bool `db:"has_variables"`
+}
+
+func newSnippetRow(snippet *models.Snippet) snippetRow {
+	return snippetRow{Snippet: snippet, HasVariables: hasVariables(snippet.Content)}
+}
+
 // Create creates a new snippet
 func (r *snippetRepository) Create(ctx context.Context, snippet *models.Snippet) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *snippetRepository) error { return tx.Create(ctx, snippet) })
+	}
+
 	if snippet.ID == "" {
 		snippet.ID = uuid.New().String()
 	}
@@ -53,17 +79,21 @@ func (r *snippetRepository) Create(ctx context.Context, snippet *models.Snippet)
 
 	query := `
 		INSERT INTO snippets (
-			id, title, content, description, created_at, updated_at
+			id, title, content, description, created_at, updated_at, has_variables
 		) VALUES (
-			:id, :title, :content, :description, :created_at, :updated_at
+			:id, :title, :content, :description, :created_at, :updated_at, :has_variables
 		)`
 
-	_, err := r.db.NamedExecContext(ctx, query, snippet)
+	_, err := r.db.NamedExecContext(ctx, query, newSnippetRow(snippet))
 	if err != nil {
 		r.logger.Error("Failed to create snippet in database", "error", err, "id", snippet.ID)
 		return fmt.Errorf("failed to create snippet: %w", err)
 	}
 
+	if err := r.insertTags(ctx, snippet.ID, snippet.Tags); err != nil {
+		return err
+	}
+
 	// Create git branch for versioning
 	if err := r.gitService.CreateSnippetBranch(ctx, snippet, ""); err != nil {
 		r.logger.Error("Failed to create git branch for snippet", "error", err, "id", snippet.ID)
@@ -94,25 +124,196 @@ func (r *snippetRepository) GetByID(ctx context.Context, id string) (*models.Sni
 		return nil, fmt.Errorf("failed to get snippet: %w", err)
 	}
 
+	tags, err := r.GetTags(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	snippet.Tags = tags
+
 	r.logger.Debug("Snippet retrieved successfully", "id", id, "title", snippet.Title)
 	return &snippet, nil
 }
 
 // Update updates an existing snippet
 func (r *snippetRepository) Update(ctx context.Context, snippet *models.Snippet) error {
-	snippet.UpdatedAt = time.Now()
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *snippetRepository) error { return tx.Update(ctx, snippet) })
+	}
 
 	r.logger.Debug("Updating snippet", "id", snippet.ID, "title", snippet.Title)
 
+	if err := r.updateRow(ctx, snippet); err != nil {
+		return err
+	}
+
+	// The snapshot records the tags stored for the snippet, not whatever the caller passed
+	tags, err := r.GetTags(ctx, snippet.ID)
+	if err != nil {
+		return err
+	}
+	snippet.Tags = tags
+
+	// Update git branch
+	if err := r.gitService.UpdateSnippetBranch(ctx, snippet, ""); err != nil {
+		r.logger.Error("Failed to update git branch for snippet", "error", err, "id", snippet.ID)
+		return fmt.Errorf("failed to update git branch: %w", err)
+	}
+
+	r.logger.Info("Snippet updated successfully", "id", snippet.ID, "title", snippet.Title)
+	return nil
+}
+
+// UpdateIfUnchanged updates a snippet like Update, but only if its stored updated_at still
+// equals expected. The check runs in the same transaction as the write.
+func (r *snippetRepository) UpdateIfUnchanged(ctx context.Context, snippet *models.Snippet, expected time.Time) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *snippetRepository) error { return tx.UpdateIfUnchanged(ctx, snippet, expected) })
+	}
+
+	var current time.Time
+	err := r.db.GetContext(ctx, &current, `SELECT updated_at FROM snippets WHERE id = ?`, snippet.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			r.logger.Debug("Snippet not found for update", "id", snippet.ID)
+			return fmt.Errorf("snippet not found: %s", snippet.ID)
+		}
+		r.logger.Error("Failed to get snippet version", "error", err, "id", snippet.ID)
+		return fmt.Errorf("failed to get snippet version: %w", err)
+	}
+
+	if !current.Equal(expected) {
+		r.logger.Debug("Snippet changed since it was read", "id", snippet.ID, "expected", expected, "current", current)
+		return fmt.Errorf("%w: snippet %s was updated at %s", ErrVersionConflict, snippet.ID, current.Format(time.RFC3339Nano))
+	}
+
+	return r.Update(ctx, snippet)
+}
+
+// Restore rolls a snippet back to the version stored in the given git commit
+func (r *snippetRepository) Restore(ctx context.Context, id string, commitHash string) (*models.Snippet, error) {
+	if r.conn != nil {
+		var restored *models.Snippet
+		err := r.inTx(ctx, func(tx *snippetRepository) (err error) {
+			restored, err = tx.Restore(ctx, id, commitHash)
+			return err
+		})
+		return restored, err
+	}
+
+	r.logger.Debug("Restoring snippet", "id", id, "commit", commitHash)
+
+	snippet, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := r.gitService.GetSnippetVersion(ctx, id, commitHash)
+	if err != nil {
+		r.logger.Error("Failed to load snippet version", "error", err, "id", id, "commit", commitHash)
+		return nil, fmt.Errorf("failed to load snippet version: %w", err)
+	}
+
+	// Only versioned fields are restored; the description is not stored in git
+	snippet.Title = version.Title
+	snippet.Content = version.Content
+
+	if err := r.updateRow(ctx, snippet); err != nil {
+		return nil, err
+	}
+
+	if err := r.replaceTags(ctx, id, version.Tags); err != nil {
+		return nil, err
+	}
+	snippet.Tags = version.Tags
+
+	// Record the restore as a new commit instead of rewriting history
+	if err := r.gitService.RestoreSnippetBranch(ctx, snippet, commitHash, ""); err != nil {
+		r.logger.Error("Failed to record restore in git branch", "error", err, "id", id)
+		return nil, fmt.Errorf("failed to update git branch: %w", err)
+	}
+
+	r.logger.Info("Snippet restored successfully", "id", id, "commit", commitHash)
+	return snippet, nil
+}
+
+// Import writes snippets as stored in git, inserting or replacing their rows and tags
+// without recording new commits. Fields git does not store, like the description, are
+// kept for existing rows; git_ref is set to the commit each snippet was read from.
+func (r *snippetRepository) Import(ctx context.Context, snippets []*models.Snippet) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *snippetRepository) error { return tx.Import(ctx, snippets) })
+	}
+
+	r.logger.Debug("Importing snippets", "count", len(snippets))
+
+	query := `
+		INSERT INTO snippets (
+			id, title, content, description, created_at, updated_at, git_ref, has_variables
+		) VALUES (
+			:id, :title, :content, :description, :created_at, :updated_at, :git_ref, :has_variables
+		)
+		ON CONFLICT(id) DO UPDATE SET
+			title = excluded.title,
+			content = excluded.content,
+			has_variables = excluded.has_variables,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			git_ref = excluded.git_ref`
+
+	for _, snippet := range snippets {
+		if _, err := r.db.NamedExecContext(ctx, query, newSnippetRow(snippet)); err != nil {
+			r.logger.Error("Failed to import snippet", "error", err, "id", snippet.ID)
+			return fmt.Errorf("failed to import snippet: %w", err)
+		}
+		if err := r.replaceTags(ctx, snippet.ID, snippet.Tags); err != nil {
+			return err
+		}
+	}
+
+	r.logger.Info("Snippets imported successfully", "count", len(snippets))
+	return nil
+}
+
+// SetGitRef sets the git_ref of a snippet without touching its branch. Writes through the
+// repository keep git_ref up to date themselves; this is for branches changed directly.
+func (r *snippetRepository) SetGitRef(ctx context.Context, id string, commitHash string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *snippetRepository) error { return tx.SetGitRef(ctx, id, commitHash) })
+	}
+
+	result, err := r.db.ExecContext(ctx, `UPDATE snippets SET git_ref = ? WHERE id = ?`, commitHash, id)
+	if err != nil {
+		r.logger.Error("Failed to set git ref", "error", err, "id", id)
+		return fmt.Errorf("failed to set git ref: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", "error", err, "id", id)
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("snippet not found: %s", id)
+	}
+
+	r.logger.Debug("Git ref set", "id", id, "commit", commitHash)
+	return nil
+}
+
+// updateRow writes the snippet fields to the database without touching git
+func (r *snippetRepository) updateRow(ctx context.Context, snippet *models.Snippet) error {
+	snippet.UpdatedAt = time.Now()
+
 	query := `
 		UPDATE snippets SET
 			title = :title,
 			content = :content,
 			description = :description,
-			updated_at = :updated_at
+			updated_at = :updated_at,
+			has_variables = :has_variables
 		WHERE id = :id`
 
-	result, err := r.db.NamedExecContext(ctx, query, snippet)
+	result, err := r.db.NamedExecContext(ctx, query, newSnippetRow(snippet))
 	if err != nil {
 		r.logger.Error("Failed to update snippet in database", "error", err, "id", snippet.ID)
 		return fmt.Errorf("failed to update snippet: %w", err)
@@ -129,18 +330,15 @@ func (r *snippetRepository) Update(ctx context.Context, snippet *models.Snippet)
 		return fmt.Errorf("snippet not found: %s", snippet.ID)
 	}
 
-	// Update git branch
-	if err := r.gitService.UpdateSnippetBranch(ctx, snippet, ""); err != nil {
-		r.logger.Error("Failed to update git branch for snippet", "error", err, "id", snippet.ID)
-		return fmt.Errorf("failed to update git branch: %w", err)
-	}
-
-	r.logger.Info("Snippet updated successfully", "id", snippet.ID, "title", snippet.Title)
 	return nil
 }
 
 // Delete deletes a snippet
 func (r *snippetRepository) Delete(ctx context.Context, id string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *snippetRepository) error { return tx.Delete(ctx, id) })
+	}
+
 	r.logger.Debug("Deleting snippet", "id", id)
 
 	query := `DELETE FROM snippets WHERE id = ?`
@@ -173,49 +371,41 @@ func (r *snippetRepository) Delete(ctx context.Context, id string) error {
 
 // List retrieves snippets with filtering
 func (r *snippetRepository) List(ctx context.Context, filters SnippetFilters) ([]*models.Snippet, error) {
-	r.logger.Debug("Listing snippets with filters")
+	r.logger.Debug("Listing snippets with filters",
+		"tags", filters.Tags,
+		"tag_mode", filters.TagMode,
+		"has_variables", filters.HasVariables,
+		"sort_by", filters.SortBy,
+		"sort_order", filters.SortOrder,
+		"limit", filters.Limit,
+		"offset", filters.Offset)
 
 	query := `
 		SELECT id, title, content, description, created_at, updated_at, git_ref
 		FROM snippets`
 
-	var conditions []string
-	var args []interface{}
+	conditions, args := snippetConditions(filters)
 
-	if len(filters.Tags) > 0 {
-		placeholders := make([]string, len(filters.Tags))
-		for i, tag := range filters.Tags {
-			placeholders[i] = "?"
-			args = append(args, "%"+tag+"%")
+	sortBy, sortOrder := resolveSort(filters.SortBy, filters.SortOrder)
+	if filters.Cursor != nil {
+		condition, cursorArgs, err := cursorCondition(*filters.Cursor, sortBy, sortOrder)
+		if err != nil {
+			r.logger.Debug("Invalid snippet cursor", "cursor", *filters.Cursor, "error", err)
+			return nil, err
 		}
-		conditions = append(conditions, fmt.Sprintf("tags LIKE %s", strings.Join(placeholders, " OR tags LIKE ")))
-	}
-
-	if filters.CreatedAfter != nil {
-		conditions = append(conditions, "created_at > ?")
-		args = append(args, *filters.CreatedAfter)
-	}
-
-	if filters.CreatedBefore != nil {
-		conditions = append(conditions, "created_at < ?")
-		args = append(args, *filters.CreatedBefore)
+		conditions = append(conditions, condition)
+		args = append(args, cursorArgs...)
 	}
 
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	query += " ORDER BY updated_at DESC"
-
-	if filters.Limit != nil {
-		query += " LIMIT ?"
-		args = append(args, *filters.Limit)
-	}
+	query += orderClause(sortBy, sortOrder)
 
-	if filters.Offset != nil {
-		query += " OFFSET ?"
-		args = append(args, *filters.Offset)
-	}
+	clause, limitArgs := limitClause(filters.Limit, filters.Offset)
+	query += clause
+	args = append(args, limitArgs...)
 
 	var snippets []*models.Snippet
 	err := r.db.SelectContext(ctx, &snippets, query, args...)
@@ -228,19 +418,71 @@ func (r *snippetRepository) List(ctx context.Context, filters SnippetFilters) ([
 	return snippets, nil
 }
 
+// Count counts the snippets matching the filters, ignoring pagination
+func (r *snippetRepository) Count(ctx context.Context, filters SnippetFilters) (int, error) {
+	r.logger.Debug("Counting snippets", "filters", filters)
+
+	conditions, args := snippetConditions(filters)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var count int
+	if err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM snippets"+where, args...); err != nil {
+		r.logger.Error("Failed to count snippets", "error", err)
+		return 0, fmt.Errorf("failed to count snippets: %w", err)
+	}
+
+	r.logger.Debug("Snippets counted successfully", "count", count)
+	return count, nil
+}
+
+// snippetConditions builds the WHERE conditions for the SQL filters of a listing
+func snippetConditions(filters SnippetFilters) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if len(filters.Tags) > 0 {
+		condition, tagArgs := tagCondition("snippet_tags", "snippet_id", filters.Tags, filters.TagMode)
+		conditions = append(conditions, condition)
+		args = append(args, tagArgs...)
+	}
+
+	if filters.HasVariables != nil {
+		conditions = append(conditions, "has_variables = ?")
+		args = append(args, *filters.HasVariables)
+	}
+
+	createdConditions, createdArgs := timeRangeConditions("created_at", filters.CreatedAfter, filters.CreatedBefore)
+	conditions = append(conditions, createdConditions...)
+	args = append(args, createdArgs...)
+
+	updatedConditions, updatedArgs := timeRangeConditions("updated_at", filters.UpdatedAfter, filters.UpdatedBefore)
+	conditions = append(conditions, updatedConditions...)
+	args = append(args, updatedArgs...)
+
+	return conditions, args
+}
+
 // Search searches snippets using full-text search
 func (r *snippetRepository) Search(ctx context.Context, query string) ([]*models.Snippet, error) {
 	r.logger.Debug("Searching snippets", "query", query)
 
+	match := ftsQuery(query)
+	if match == "" {
+		return []*models.Snippet{}, nil
+	}
+
 	searchQuery := `
 		SELECT s.id, s.title, s.content, s.description, s.created_at, s.updated_at, s.git_ref
-		FROM snippets s
-		JOIN snippets_fts fts ON s.id = fts.rowid
+		FROM snippets_fts
+		JOIN snippets s ON s.id = snippets_fts.id
 		WHERE snippets_fts MATCH ?
-		ORDER BY rank`
+		ORDER BY ` + snippetsRank
 
 	var snippets []*models.Snippet
-	err := r.db.SelectContext(ctx, &snippets, searchQuery, query)
+	err := r.db.SelectContext(ctx, &snippets, searchQuery, match)
 	if err != nil {
 		r.logger.Error("Failed to search snippets", "error", err, "query", query)
 		return nil, fmt.Errorf("failed to search snippets: %w", err)
@@ -252,21 +494,45 @@ func (r *snippetRepository) Search(ctx context.Context, query string) ([]*models
 
 // AddTag adds a tag to a snippet
 func (r *snippetRepository) AddTag(ctx context.Context, snippetID, tagName string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *snippetRepository) error { return tx.AddTag(ctx, snippetID, tagName) })
+	}
+
 	r.logger.Debug("Adding tag to snippet", "id", snippetID, "tag", tagName)
 
 	query := `INSERT OR IGNORE INTO snippet_tags (snippet_id, tag_name) VALUES (?, ?)`
-	_, err := r.db.ExecContext(ctx, query, snippetID, tagName)
+	result, err := r.db.ExecContext(ctx, query, snippetID, tagName)
 	if err != nil {
 		r.logger.Error("Failed to add tag to snippet", "error", err, "id", snippetID, "tag", tagName)
 		return fmt.Errorf("failed to add tag to snippet: %w", err)
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.Error("Failed to get rows affected", "error", err, "id", snippetID, "tag", tagName)
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	// Adding a tag the snippet already has changes nothing, so there is nothing to commit
+	if rowsAffected == 0 {
+		r.logger.Debug("Snippet already has tag", "id", snippetID, "tag", tagName)
+		return nil
+	}
+
+	if err := r.commitTags(ctx, snippetID, []string{tagName}, nil); err != nil {
+		return err
+	}
+
 	r.logger.Info("Tag added to snippet successfully", "id", snippetID, "tag", tagName)
 	return nil
 }
 
 // RemoveTag removes a tag from a snippet
 func (r *snippetRepository) RemoveTag(ctx context.Context, snippetID, tagName string) error {
+	if r.conn != nil {
+		return r.inTx(ctx, func(tx *snippetRepository) error { return tx.RemoveTag(ctx, snippetID, tagName) })
+	}
+
 	r.logger.Debug("Removing tag from snippet", "id", snippetID, "tag", tagName)
 
 	query := `DELETE FROM snippet_tags WHERE snippet_id = ? AND tag_name = ?`
@@ -287,6 +553,10 @@ func (r *snippetRepository) RemoveTag(ctx context.Context, snippetID, tagName st
 		return fmt.Errorf("tag not found on snippet")
 	}
 
+	if err := r.commitTags(ctx, snippetID, nil, []string{tagName}); err != nil {
+		return err
+	}
+
 	r.logger.Info("Tag removed from snippet successfully", "id", snippetID, "tag", tagName)
 	return nil
 }
@@ -322,3 +592,40 @@ func (r *snippetRepository) ListAllTags(ctx context.Context) ([]string, error) {
 	r.logger.Debug("All snippet tags listed successfully", "count", len(tags))
 	return tags, nil
 }
+
+// commitTags records the snippet's current tags in its git branch
+func (r *snippetRepository) commitTags(ctx context.Context, snippetID string, added, removed []string) error {
+	snippet, err := r.GetByID(ctx, snippetID)
+	if err != nil {
+		return err
+	}
+
+	if err := r.gitService.UpdateSnippetTags(ctx, snippet, added, removed); err != nil {
+		r.logger.Error("Failed to record tags in git branch", "error", err, "id", snippetID)
+		return fmt.Errorf("failed to update git branch: %w", err)
+	}
+
+	return nil
+}
+
+// replaceTags sets the snippet's tags in the database without touching git
+func (r *snippetRepository) replaceTags(ctx context.Context, snippetID string, tags []string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM snippet_tags WHERE snippet_id = ?`, snippetID); err != nil {
+		r.logger.Error("Failed to clear snippet tags", "error", err, "id", snippetID)
+		return fmt.Errorf("failed to clear snippet tags: %w", err)
+	}
+
+	return r.insertTags(ctx, snippetID, tags)
+}
+
+// insertTags adds tags to the snippet in the database without touching git
+func (r *snippetRepository) insertTags(ctx context.Context, snippetID string, tags []string) error {
+	for _, tag := range tags {
+		if _, err := r.db.ExecContext(ctx, `INSERT OR IGNORE INTO snippet_tags (snippet_id, tag_name) VALUES (?, ?)`, snippetID, tag); err != nil {
+			r.logger.Error("Failed to add tag to snippet", "error", err, "id", snippetID, "tag", tag)
+			return fmt.Errorf("failed to add tag to snippet: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package repository
+
+import "testing"
+
+// TestTursoBackend runs the repository suite against the libSQL backend
+func TestTursoBackend(t *testing.T) {
+	testBackend = "turso"
+	defer func() { testBackend = "local" }()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T)
+	}{
+		{"PromptCRUD", TestPromptCRUD},
+		{"PromptRestore", TestPromptRestore},
+		{"SnippetCRUD", TestSnippetCRUD},
+		{"Transactions", TestTransactions},
+		{"PromptLinks", TestPromptLinks},
+		{"PromptTags", TestPromptTags},
+		{"SnippetTags", TestSnippetTags},
+		{"Search", TestSearch},
+		{"PromptTagFilters", TestPromptTagFilters},
+		{"SnippetTagFilters", TestSnippetTagFilters},
+		{"PromptVariableFilters", TestPromptVariableFilters},
+		{"SnippetTimeFilters", TestSnippetTimeFilters},
+		{"PromptPagination", TestPromptPagination},
+		{"SnippetCount", TestSnippetCount},
+		{"PromptTagHistory", TestPromptTagHistory},
+		{"PromptNotesAndLinksHistory", TestPromptNotesAndLinksHistory},
+		{"GitFailureRollsBackWrite", TestGitFailureRollsBackWrite},
+		{"TransactionResetsBranches", TestTransactionResetsBranches},
+		{"ConditionalUpdate", TestConditionalUpdate},
+		{"GitRef", TestGitRef},
+		{"PromoteDraft", TestPromoteDraft},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, tt.fn)
+	}
+}
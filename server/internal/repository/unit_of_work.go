@@ -0,0 +1,336 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/dikkadev/proompt/server/internal/git"
+	"github.com/dikkadev/proompt/server/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// unitOfWork is a git.GitService that holds back branch writes until the database
+// transaction they belong to is about to commit. Reads go straight to the wrapped service.
+// The new head of every written branch is stored in the git_ref column of its row.
+type unitOfWork struct {
+	git.GitService
+	logger  *slog.Logger
+	pending []pendingWrite
+}
+
+// pendingWrite is a queued git write and the branch it changes. gitRef, when set, is the
+// GitRef field of the caller's prompt or snippet, pointed at the new head after the commit.
+// snapshot, when set, records the other refs the write changes right before it is applied
+// and returns how to put them back.
+type pendingWrite struct {
+	branch   string
+	gitRef   **string
+	apply    func(ctx context.Context) error
+	snapshot func(ctx context.Context) (undo func(ctx context.Context) error, err error)
+}
+
+// newUnitOfWork creates an empty unit of work on top of gitService
+func newUnitOfWork(gitService git.GitService, logger *slog.Logger) *unitOfWork {
+	return &unitOfWork{
+		GitService: gitService,
+		logger:     logger,
+	}
+}
+
+// runInTx runs fn in a database transaction. Git writes made through the git service
+// passed to fn are applied only once fn succeeds, right before the transaction commits;
+// if they or the commit fail, the branches they touched are reset to where they were.
+func runInTx(ctx context.Context, database *sqlx.DB, gitService git.GitService, logger *slog.Logger, fn func(tx *sqlx.Tx, gitService git.GitService) error) error {
+	logger.Debug("Starting database transaction")
+
+	tx, err := database.BeginTxx(ctx, nil)
+	if err != nil {
+		logger.Error("Failed to begin transaction", "error", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	work := newUnitOfWork(gitService, logger)
+
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Error("Transaction panic, rolling back", "panic", p)
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx, work); err != nil {
+		logger.Debug("Transaction function failed, rolling back", "error", err)
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logger.Error("Failed to rollback transaction", "error", rbErr, "original_error", err)
+			return fmt.Errorf("transaction failed: %w (rollback error: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := work.commit(ctx, tx); err != nil {
+		return err
+	}
+
+	logger.Debug("Transaction committed successfully")
+	return nil
+}
+
+// commit applies the queued git writes in order, records the new branch heads as git_ref
+// and then commits tx. The branch heads are recorded before each branch is first written,
+// so a failure anywhere undoes them, and after every write, so the undo only moves
+// branches that nobody else has written to since.
+func (u *unitOfWork) commit(ctx context.Context, tx *sqlx.Tx) error {
+	heads := make(map[string]string)
+	produced := make(map[string]string)
+	var touched []string
+	var undos []func(ctx context.Context) error
+
+	fail := func(err error) error {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			u.logger.Error("Failed to rollback transaction", "error", rbErr, "original_error", err)
+		}
+		if resetErr := u.reset(ctx, touched, heads, produced, undos); resetErr != nil {
+			return fmt.Errorf("%w (git rollback error: %w)", err, resetErr)
+		}
+		return err
+	}
+
+	for _, write := range u.pending {
+		if _, seen := heads[write.branch]; !seen {
+			head, err := u.GitService.BranchHead(ctx, write.branch)
+			if err != nil {
+				u.logger.Error("Failed to read branch head", "error", err, "branch", write.branch)
+				return fail(fmt.Errorf("failed to read branch head: %w", err))
+			}
+			heads[write.branch] = head
+			produced[write.branch] = head
+			touched = append(touched, write.branch)
+		}
+
+		if write.snapshot != nil {
+			undo, err := write.snapshot(ctx)
+			if err != nil {
+				u.logger.Error("Failed to snapshot refs", "error", err, "branch", write.branch)
+				return fail(err)
+			}
+			undos = append(undos, undo)
+		}
+
+		if err := write.apply(ctx); err != nil {
+			u.logger.Error("Failed to apply git write, rolling back", "error", err, "branch", write.branch)
+			return fail(err)
+		}
+
+		head, err := u.GitService.BranchHead(ctx, write.branch)
+		if err != nil {
+			u.logger.Error("Failed to read branch head", "error", err, "branch", write.branch)
+			return fail(fmt.Errorf("failed to read branch head: %w", err))
+		}
+		produced[write.branch] = head
+	}
+
+	newHeads := make(map[string]string, len(touched))
+	for _, branch := range touched {
+		head := produced[branch]
+		// Deleted branches have no head, and their rows are gone as well
+		if head == "" {
+			continue
+		}
+		if err := recordGitRef(ctx, tx, branch, head); err != nil {
+			u.logger.Error("Failed to record git ref", "error", err, "branch", branch)
+			return fail(err)
+		}
+		newHeads[branch] = head
+	}
+
+	if err := tx.Commit(); err != nil {
+		u.logger.Error("Failed to commit transaction", "error", err)
+		return fail(fmt.Errorf("failed to commit transaction: %w", err))
+	}
+
+	for _, write := range u.pending {
+		if head, ok := newHeads[write.branch]; ok && write.gitRef != nil {
+			*write.gitRef = &head
+		}
+	}
+	return nil
+}
+
+// recordGitRef stores the head of a prompt or snippet branch in the git_ref column of its
+// row. Other branches, like those of drafts, have no row to record it in.
+func recordGitRef(ctx context.Context, tx *sqlx.Tx, branch, head string) error {
+	var table, id string
+	if promptID, ok := strings.CutPrefix(branch, git.PromptBranch("")); ok {
+		table, id = "prompts", promptID
+	} else if snippetID, ok := strings.CutPrefix(branch, git.SnippetBranch("")); ok {
+		table, id = "snippets", snippetID
+	} else {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET git_ref = ? WHERE id = ?`, head, id); err != nil {
+		return fmt.Errorf("failed to record git ref: %w", err)
+	}
+	return nil
+}
+
+// reset moves the touched branches back from the heads the writes produced to their
+// recorded heads, then puts back the other refs the writes changed, newest first. A branch
+// written by someone else in the meantime is left alone and reported as
+// git.ErrBranchMoved. It keeps going after a failure so as many refs as possible end up
+// matching the database.
+func (u *unitOfWork) reset(ctx context.Context, branches []string, heads, produced map[string]string, undos []func(ctx context.Context) error) error {
+	var firstErr error
+	for _, branch := range branches {
+		if err := u.GitService.ResetBranch(ctx, branch, produced[branch], heads[branch]); err != nil {
+			u.logger.Error("Failed to reset branch; git and database are out of sync", "error", err, "branch", branch, "commit", heads[branch])
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to reset branch %s: %w", branch, err)
+			}
+		}
+	}
+	for i := len(undos) - 1; i >= 0; i-- {
+		if err := undos[i](ctx); err != nil {
+			u.logger.Error("Failed to restore refs; git and database are out of sync", "error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// queue records a git write to apply when the unit of work commits
+func (u *unitOfWork) queue(branch string, gitRef **string, apply func(ctx context.Context) error) error {
+	u.pending = append(u.pending, pendingWrite{branch: branch, gitRef: gitRef, apply: apply})
+	return nil
+}
+
+// Queued writes copy prompts and snippets, so later changes by the caller do not leak into the commit
+
+func (u *unitOfWork) CreatePromptBranch(ctx context.Context, prompt *models.Prompt, userNote string) error {
+	p := *prompt
+	return u.queue(git.PromptBranch(p.ID), &prompt.GitRef, func(ctx context.Context) error {
+		return u.GitService.CreatePromptBranch(ctx, &p, userNote)
+	})
+}
+
+func (u *unitOfWork) UpdatePromptBranch(ctx context.Context, prompt *models.Prompt, userNote string) error {
+	p := *prompt
+	return u.queue(git.PromptBranch(p.ID), &prompt.GitRef, func(ctx context.Context) error {
+		return u.GitService.UpdatePromptBranch(ctx, &p, userNote)
+	})
+}
+
+// DeletePromptBranch also deletes the prompt's drafts and releases, so they are recorded
+// before the delete and recreated if the transaction fails
+func (u *unitOfWork) DeletePromptBranch(ctx context.Context, promptID string) error {
+	u.pending = append(u.pending, pendingWrite{
+		branch: git.PromptBranch(promptID),
+		apply: func(ctx context.Context) error {
+			return u.GitService.DeletePromptBranch(ctx, promptID)
+		},
+		snapshot: func(ctx context.Context) (func(ctx context.Context) error, error) {
+			return u.snapshotDraftsAndReleases(ctx, promptID)
+		},
+	})
+	return nil
+}
+
+// snapshotDraftsAndReleases records the draft branches and release tags of a prompt and
+// returns a function that recreates them. It runs after the prompt's branch is reset.
+func (u *unitOfWork) snapshotDraftsAndReleases(ctx context.Context, promptID string) (func(ctx context.Context) error, error) {
+	drafts, err := u.GitService.ListPromptDrafts(ctx, promptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drafts: %w", err)
+	}
+	releases, err := u.GitService.ListPromptReleases(ctx, promptID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	return func(ctx context.Context) error {
+		var firstErr error
+		for _, draft := range drafts {
+			if err := u.GitService.ResetBranch(ctx, git.DraftBranch(promptID, draft.Name), "", draft.Head); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to restore draft %s: %w", draft.Name, err)
+			}
+		}
+		for _, release := range releases {
+			if _, err := u.GitService.CreatePromptRelease(ctx, promptID, release.Label, release.Commit, true); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to restore release %s: %w", release.Label, err)
+			}
+		}
+		return firstErr
+	}, nil
+}
+
+func (u *unitOfWork) RestorePromptBranch(ctx context.Context, prompt *models.Prompt, commitHash string, userNote string) error {
+	p := *prompt
+	return u.queue(git.PromptBranch(p.ID), &prompt.GitRef, func(ctx context.Context) error {
+		return u.GitService.RestorePromptBranch(ctx, &p, commitHash, userNote)
+	})
+}
+
+func (u *unitOfWork) UpdatePromptTags(ctx context.Context, prompt *models.Prompt, added, removed []string) error {
+	p := *prompt
+	return u.queue(git.PromptBranch(p.ID), &prompt.GitRef, func(ctx context.Context) error {
+		return u.GitService.UpdatePromptTags(ctx, &p, added, removed)
+	})
+}
+
+func (u *unitOfWork) UpdatePromptNotesAndLinks(ctx context.Context, promptID string, notes []*models.Note, links []*models.PromptLink, message string) error {
+	return u.queue(git.PromptBranch(promptID), nil, func(ctx context.Context) error {
+		return u.GitService.UpdatePromptNotesAndLinks(ctx, promptID, notes, links, message)
+	})
+}
+
+func (u *unitOfWork) PromotePromptDraft(ctx context.Context, prompt *models.Prompt, draft *git.PromptDraft, userNote string) error {
+	p := *prompt
+	return u.queue(git.PromptBranch(p.ID), &prompt.GitRef, func(ctx context.Context) error {
+		return u.GitService.PromotePromptDraft(ctx, &p, draft, userNote)
+	})
+}
+
+func (u *unitOfWork) DeletePromptDraft(ctx context.Context, promptID, name string) error {
+	return u.queue(git.DraftBranch(promptID, name), nil, func(ctx context.Context) error {
+		return u.GitService.DeletePromptDraft(ctx, promptID, name)
+	})
+}
+
+func (u *unitOfWork) CreateSnippetBranch(ctx context.Context, snippet *models.Snippet, userNote string) error {
+	s := *snippet
+	return u.queue(git.SnippetBranch(s.ID), &snippet.GitRef, func(ctx context.Context) error {
+		return u.GitService.CreateSnippetBranch(ctx, &s, userNote)
+	})
+}
+
+func (u *unitOfWork) UpdateSnippetBranch(ctx context.Context, snippet *models.Snippet, userNote string) error {
+	s := *snippet
+	return u.queue(git.SnippetBranch(s.ID), &snippet.GitRef, func(ctx context.Context) error {
+		return u.GitService.UpdateSnippetBranch(ctx, &s, userNote)
+	})
+}
+
+func (u *unitOfWork) DeleteSnippetBranch(ctx context.Context, snippetID string) error {
+	return u.queue(git.SnippetBranch(snippetID), nil, func(ctx context.Context) error {
+		return u.GitService.DeleteSnippetBranch(ctx, snippetID)
+	})
+}
+
+func (u *unitOfWork) RestoreSnippetBranch(ctx context.Context, snippet *models.Snippet, commitHash string, userNote string) error {
+	s := *snippet
+	return u.queue(git.SnippetBranch(s.ID), &snippet.GitRef, func(ctx context.Context) error {
+		return u.GitService.RestoreSnippetBranch(ctx, &s, commitHash, userNote)
+	})
+}
+
+func (u *unitOfWork) UpdateSnippetTags(ctx context.Context, snippet *models.Snippet, added, removed []string) error {
+	s := *snippet
+	return u.queue(git.SnippetBranch(s.ID), &snippet.GitRef, func(ctx context.Context) error {
+		return u.GitService.UpdateSnippetTags(ctx, &s, added, removed)
+	})
+}
@@ -0,0 +1,139 @@
+package template
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic codes
+const (
+	CodeUndefinedVariable = "undefined-variable"
+	CodeInvalidValue      = "invalid-value"
+	CodeInvalidType       = "invalid-type"
+	CodeUnknownFilter     = "unknown-filter"
+	CodeFilterFailed      = "filter-failed"
+	CodeUnknownBlock      = "unknown-block"
+	CodeInvalidBlock      = "invalid-block"
+	CodeUnclosedBlock     = "unclosed-block"
+	CodeUnexpectedClose   = "unexpected-close"
+	CodeDuplicateElse     = "duplicate-else"
+	CodeUnknownSnippet    = "unknown-snippet"
+	CodeCircularSnippet   = "circular-snippet"
+	CodeUnknownArgument   = "unknown-argument"
+)
+
+// Position is a location in a template. Line and Column start at 1, and Column counts
+// characters rather than bytes.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Span is the part of a template from Start up to End
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// Include is a step in the chain of snippets that leads to a problem. Span is where the
+// next snippet is referenced in this snippet's content, or where the problem is for the
+// last step.
+type Include struct {
+	Snippet string
+	Span    Span
+}
+
+// Diagnostic is a problem found in a template. Span is where it is in the content that
+// was given; for a problem inside a snippet that is the reference to the snippet, and
+// Includes leads from there to the problem.
+type Diagnostic struct {
+	Severity string
+	Code     string
+	Message  string
+	Span     Span
+	Includes []Include
+}
+
+// spanOf returns the span of content[start:end]
+func spanOf(content string, start, end int) Span {
+	return Span{Start: positionOf(content, start), End: positionOf(content, end)}
+}
+
+// positionOf returns the position of a byte offset in content
+func positionOf(content string, offset int) Position {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	lineStart := strings.LastIndexByte(content[:offset], '\n') + 1
+	return Position{
+		Offset: offset,
+		Line:   1 + strings.Count(content[:offset], "\n"),
+		Column: 1 + utf8.RuneCountInString(content[lineStart:offset]),
+	}
+}
+
+// reporter collects the diagnostics of one pass over content, along with their messages
+// as warnings. Each message is warned about once and each diagnostic reported once, so
+// a loop does not repeat them.
+type reporter struct {
+	content     string
+	diagnostics []Diagnostic
+	warnings    []string
+	seen        map[string]bool
+}
+
+// report records a problem with content[start:end]
+func (r *reporter) report(severity, code string, start, end int, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if r.seen == nil {
+		r.seen = make(map[string]bool)
+	}
+
+	if !r.seen[message] {
+		r.seen[message] = true
+		r.warnings = append(r.warnings, message)
+	}
+
+	key := fmt.Sprintf("%d:%d:%s", start, end, message)
+	if r.seen[key] {
+		return
+	}
+	r.seen[key] = true
+	r.diagnostics = append(r.diagnostics, Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  message,
+		Span:     spanOf(r.content, start, end),
+	})
+}
+
+// include adds diagnostics found by another pass over the same content
+func (r *reporter) include(diagnostics []Diagnostic) {
+	if r.seen == nil {
+		r.seen = make(map[string]bool)
+	}
+	for _, d := range diagnostics {
+		if !r.seen[d.Message] {
+			r.seen[d.Message] = true
+			r.warnings = append(r.warnings, d.Message)
+		}
+		r.diagnostics = append(r.diagnostics, d)
+	}
+}
+
+// sortDiagnostics orders diagnostics by where they are, keeping the order of those at the
+// same place
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Span.Start.Offset < diagnostics[j].Span.Start.Offset
+	})
+}
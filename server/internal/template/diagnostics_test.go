@@ -0,0 +1,144 @@
+package template
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dikkadev/proompt/server/internal/models"
+)
+
+// span builds the span from line:column to line:column at the given offsets
+func span(startOffset, startLine, startColumn, endOffset, endLine, endColumn int) Span {
+	return Span{
+		Start: Position{Offset: startOffset, Line: startLine, Column: startColumn},
+		End:   Position{Offset: endOffset, Line: endLine, Column: endColumn},
+	}
+}
+
+func TestResolve_Diagnostics(t *testing.T) {
+	content := "Héllo {{name}}\n  {{count:int()}} {{#bogus}}\n{{#if ready}}"
+	result := NewVariableResolver(map[string]string{"count": "many"}).Resolve(content)
+
+	expected := []Diagnostic{
+		{
+			Severity: SeverityWarning,
+			Code:     CodeUndefinedVariable,
+			Message:  "Variable 'name' is not defined and has no default value",
+			Span:     span(7, 1, 7, 15, 1, 15),
+		},
+		{
+			Severity: SeverityError,
+			Code:     CodeInvalidValue,
+			Message:  "Value of variable 'count' is invalid: 'many' is not a whole number",
+			Span:     span(18, 2, 3, 33, 2, 18),
+		},
+		{
+			Severity: SeverityError,
+			Code:     CodeUnknownBlock,
+			Message:  "Unknown block '{{#bogus}}'",
+			Span:     span(34, 2, 19, 44, 2, 29),
+		},
+		{
+			Severity: SeverityError,
+			Code:     CodeUnclosedBlock,
+			Message:  "Block '{{#if ready}}' is never closed",
+			Span:     span(45, 3, 1, 58, 3, 14),
+		},
+	}
+	if !reflect.DeepEqual(result.Diagnostics, expected) {
+		t.Errorf("Expected diagnostics %+v, got %+v", expected, result.Diagnostics)
+	}
+	if len(result.Warnings) != len(expected) {
+		t.Errorf("Expected a warning per diagnostic, got %v", result.Warnings)
+	}
+}
+
+func TestSnippetResolver_Diagnostics(t *testing.T) {
+	snippets := []*models.Snippet{
+		{Title: "outer", Content: "Intro\n  @middle"},
+		{Title: "middle", Content: "Line\n@{inner}"},
+		{Title: "inner", Content: "Say {{word}} and @missing"},
+		{Title: "ping", Content: "@pong"},
+		{Title: "pong", Content: "@{ping}"},
+		{Title: "greet", Content: "Hi {{name}}, {{mood}}"},
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected []Diagnostic
+	}{
+		{
+			name:    "nested snippets",
+			content: "Start\n@outer",
+			expected: []Diagnostic{
+				{
+					Severity: SeverityWarning,
+					Code:     CodeUnknownSnippet,
+					Message:  "Snippet 'missing' not found",
+					Span:     span(6, 2, 1, 12, 2, 7),
+					Includes: []Include{
+						{Snippet: "outer", Span: span(8, 2, 3, 15, 2, 10)},
+						{Snippet: "middle", Span: span(5, 2, 1, 13, 2, 9)},
+						{Snippet: "inner", Span: span(17, 1, 18, 25, 1, 26)},
+					},
+				},
+				{
+					Severity: SeverityWarning,
+					Code:     CodeUndefinedVariable,
+					Message:  "Variable 'word' is not defined and has no default value",
+					Span:     span(6, 2, 1, 12, 2, 7),
+					Includes: []Include{
+						{Snippet: "outer", Span: span(8, 2, 3, 15, 2, 10)},
+						{Snippet: "middle", Span: span(5, 2, 1, 13, 2, 9)},
+						{Snippet: "inner", Span: span(4, 1, 5, 12, 1, 13)},
+					},
+				},
+			},
+		},
+		{
+			name:    "circular reference",
+			content: "@ping",
+			expected: []Diagnostic{
+				{
+					Severity: SeverityError,
+					Code:     CodeCircularSnippet,
+					Message:  "Circular reference detected for snippet 'ping'",
+					Span:     span(0, 1, 1, 5, 1, 6),
+					Includes: []Include{
+						{Snippet: "ping", Span: span(0, 1, 1, 5, 1, 6)},
+						{Snippet: "pong", Span: span(0, 1, 1, 7, 1, 8)},
+					},
+				},
+			},
+		},
+		{
+			name:    "snippet with arguments",
+			content: "Go: @{greet name=Bob tone=dry}",
+			expected: []Diagnostic{
+				{
+					Severity: SeverityWarning,
+					Code:     CodeUnknownArgument,
+					Message:  "Snippet 'greet' has no variable 'tone'",
+					Span:     span(4, 1, 5, 30, 1, 31),
+				},
+				{
+					Severity: SeverityWarning,
+					Code:     CodeUndefinedVariable,
+					Message:  "Variable 'mood' is not defined and has no default value",
+					Span:     span(4, 1, 5, 30, 1, 31),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewSnippetResolver(snippets, map[string]string{})
+			diagnostics := resolver.Diagnose(tt.content)
+			if !reflect.DeepEqual(diagnostics, tt.expected) {
+				t.Errorf("Expected diagnostics %+v, got %+v", tt.expected, diagnostics)
+			}
+		})
+	}
+}
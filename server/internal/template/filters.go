@@ -0,0 +1,257 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Filter transforms the value of a variable at render time, as in {{name | upper}}. args
+// are the arguments written after the filter's name.
+type Filter func(value string, args []string) (string, error)
+
+// FilterCall is a filter applied to a variable, with its arguments
+type FilterCall struct {
+	Name string
+	Args []string
+}
+
+// FilterRegistry holds the filters available to templates by name
+type FilterRegistry struct {
+	mu      sync.RWMutex
+	filters map[string]Filter
+}
+
+// NewFilterRegistry creates a registry with the built-in filters
+func NewFilterRegistry() *FilterRegistry {
+	r := &FilterRegistry{filters: make(map[string]Filter)}
+	r.Register("upper", noArgs(strings.ToUpper))
+	r.Register("lower", noArgs(strings.ToLower))
+	r.Register("trim", noArgs(strings.TrimSpace))
+	r.Register("indent", indentFilter)
+	r.Register("truncate", truncateFilter)
+	r.Register("json", jsonFilter)
+	r.Register("join", joinFilter)
+	return r
+}
+
+// DefaultFilters is the registry used by resolvers unless they are given another one.
+// Filters registered here are available to every template.
+var DefaultFilters = NewFilterRegistry()
+
+// Register adds a filter, replacing any filter with the same name
+func (r *FilterRegistry) Register(name string, filter Filter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.filters[name] = filter
+}
+
+// Get returns the filter with the given name
+func (r *FilterRegistry) Get(name string) (Filter, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	filter, exists := r.filters[name]
+	return filter, exists
+}
+
+// Names returns the names of the registered filters, sorted
+func (r *FilterRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.filters))
+	for name := range r.filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// noArgs adapts a string function to a filter that takes no arguments
+func noArgs(fn func(string) string) Filter {
+	return func(value string, args []string) (string, error) {
+		if len(args) > 0 {
+			return "", fmt.Errorf("takes no arguments")
+		}
+		return fn(value), nil
+	}
+}
+
+// countArg parses the single non-negative count argument of a filter
+func countArg(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("takes one number")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("'%s' is not a non-negative whole number", args[0])
+	}
+	return n, nil
+}
+
+// indentFilter indents every non-empty line by the given number of spaces
+func indentFilter(value string, args []string) (string, error) {
+	n, err := countArg(args)
+	if err != nil {
+		return "", err
+	}
+
+	prefix := strings.Repeat(" ", n)
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// truncateFilter shortens the value to at most the given number of characters, ending
+// with an ellipsis when something was cut
+func truncateFilter(value string, args []string) (string, error) {
+	n, err := countArg(args)
+	if err != nil {
+		return "", err
+	}
+
+	runes := []rune(value)
+	if len(runes) <= n {
+		return value, nil
+	}
+	if n == 0 {
+		return "", nil
+	}
+	return string(runes[:n-1]) + "…", nil
+}
+
+// jsonFilter quotes the value as a JSON string
+func jsonFilter(value string, args []string) (string, error) {
+	if len(args) > 0 {
+		return "", fmt.Errorf("takes no arguments")
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// joinFilter joins the items of a list with the given separator, ", " by default
+func joinFilter(value string, args []string) (string, error) {
+	separator := ", "
+	switch len(args) {
+	case 0:
+	case 1:
+		separator = args[0]
+	default:
+		return "", fmt.Errorf("takes one separator")
+	}
+	return strings.Join(splitList(value), separator), nil
+}
+
+// filterNameRegex matches the name of a filter
+var filterNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// parseFilters splits the inside of a tag into the variable part and its filters. When a
+// part after a '|' is not a filter call, the tag has no filters and inner is returned as
+// is, so a '|' in a plain default value keeps working. A default value can also look like
+// a filter call, as in {{format:json|yaml}}, so after a default only filters known to
+// registry are split off.
+func parseFilters(inner string, registry *FilterRegistry) (string, []FilterCall) {
+	parts := splitPipes(inner)
+	if len(parts) == 1 {
+		return inner, nil
+	}
+
+	hasDefault := strings.Contains(parts[0], ":")
+	filters := make([]FilterCall, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		args, ok := splitArgs(part)
+		if !ok || len(args) == 0 || !filterNameRegex.MatchString(args[0]) {
+			return inner, nil
+		}
+		if _, exists := registry.Get(args[0]); hasDefault && !exists {
+			return inner, nil
+		}
+		filters = append(filters, FilterCall{Name: args[0], Args: args[1:]})
+	}
+	return parts[0], filters
+}
+
+// splitPipes splits s at the '|' characters that are not inside parentheses or quotes.
+// Quotes only count in filter arguments, after the first '|', so an apostrophe in a
+// default value does not hide the filters after it.
+func splitPipes(s string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && len(parts) > 0:
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == '|' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitArgs splits a filter call into words. Words may be quoted with double quotes, which
+// allow Go escapes, or single quotes, which are taken literally.
+func splitArgs(s string) ([]string, bool) {
+	var args []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case isBlank(c):
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(s) && s[end] != c {
+				if c == '"' && s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, false
+			}
+			arg := s[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(s[i : end+1])
+				if err != nil {
+					return nil, false
+				}
+				arg = unquoted
+			}
+			args = append(args, arg)
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !isBlank(s[end]) {
+				end++
+			}
+			args = append(args, s[i:end])
+			i = end
+		}
+	}
+	return args, true
+}
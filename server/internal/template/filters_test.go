@@ -0,0 +1,172 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariableResolver_ResolveFilters(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          string
+		variables        map[string]string
+		expectedContent  string
+		expectedWarnings int
+	}{
+		{
+			name:            "upper",
+			content:         "{{name | upper}}",
+			variables:       map[string]string{"name": "Alice"},
+			expectedContent: "ALICE",
+		},
+		{
+			name:            "chained filters",
+			content:         "{{name|trim|lower}}",
+			variables:       map[string]string{"name": "  Alice "},
+			expectedContent: "alice",
+		},
+		{
+			name:            "filters apply to defaults",
+			content:         "{{name:World | upper}}!",
+			variables:       map[string]string{},
+			expectedContent: "WORLD!",
+		},
+		{
+			name:            "filters apply to typed defaults",
+			content:         "{{tone:enum(formal|casual)=casual | upper}}",
+			variables:       map[string]string{},
+			expectedContent: "CASUAL",
+		},
+		{
+			name:            "indent",
+			content:         "code:\n{{code | indent 4}}",
+			variables:       map[string]string{"code": "if x {\n\n  y()\n}"},
+			expectedContent: "code:\n    if x {\n\n      y()\n    }",
+		},
+		{
+			name:            "truncate",
+			content:         "{{doc | truncate 5}}|{{short | truncate 5}}",
+			variables:       map[string]string{"doc": "Hello, world", "short": "Hi"},
+			expectedContent: "Hell…|Hi",
+		},
+		{
+			name:            "json",
+			content:         `{"text": {{text | json}}}`,
+			variables:       map[string]string{"text": "Say \"hi\" <b>\n"},
+			expectedContent: `{"text": "Say \"hi\" <b>\n"}`,
+		},
+		{
+			name:            "join with quoted separator",
+			content:         `{{list | join " | "}} / {{list | join}} / {{list | join '\n'}}`,
+			variables:       map[string]string{"list": `["a", "b"]`},
+			expectedContent: `a | b / a, b / a\nb`,
+		},
+		{
+			name:            "filter on loop item",
+			content:         "{{#each items}}{{this | upper}} {{/each}}",
+			variables:       map[string]string{"items": "a\nb"},
+			expectedContent: "A B ",
+		},
+		{
+			name:             "unknown filter is skipped",
+			content:          "{{name | shout | upper}}",
+			variables:        map[string]string{"name": "Alice"},
+			expectedContent:  "ALICE",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "invalid filter arguments are skipped",
+			content:          "{{name | truncate many}}",
+			variables:        map[string]string{"name": "Alice"},
+			expectedContent:  "Alice",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "missing variable keeps its filters",
+			content:          "{{name | upper}}",
+			variables:        map[string]string{},
+			expectedContent:  "{{name | upper}}",
+			expectedWarnings: 1,
+		},
+		{
+			name:            "pipe in a plain default",
+			content:         "{{sep: | }}",
+			variables:       map[string]string{},
+			expectedContent: " | ",
+		},
+		{
+			name:            "apostrophe in a default",
+			content:         "{{q:Don't | upper}}",
+			variables:       map[string]string{},
+			expectedContent: "DON'T",
+		},
+		{
+			name:            "apostrophe in a value's default",
+			content:         `{{q:it's | join "'"}}`,
+			variables:       map[string]string{"q": "a\nb"},
+			expectedContent: "a'b",
+		},
+		{
+			name:            "default that looks like a filter call",
+			content:         "{{format:json|yaml}}",
+			variables:       map[string]string{},
+			expectedContent: "json|yaml",
+		},
+		{
+			name:            "registered filter after a default",
+			content:         "{{format:json|upper}}",
+			variables:       map[string]string{},
+			expectedContent: "JSON",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewVariableResolver(tt.variables)
+			result := resolver.Resolve(tt.content)
+
+			if result.Content != tt.expectedContent {
+				t.Errorf("Resolve() content = %q, want %q", result.Content, tt.expectedContent)
+			}
+
+			if len(result.Warnings) != tt.expectedWarnings {
+				t.Errorf("Resolve() warnings = %v, want %v warnings", result.Warnings, tt.expectedWarnings)
+			}
+		})
+	}
+}
+
+func TestFilterRegistry(t *testing.T) {
+	registry := NewFilterRegistry()
+	registry.Register("wrap", func(value string, args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("takes an opening and a closing string")
+		}
+		return args[0] + value + args[1], nil
+	})
+
+	resolver := NewVariableResolver(map[string]string{"tag": "b"}).WithFilters(registry)
+	result := resolver.Resolve(`{{tag | wrap "<" ">" | upper}}`)
+	if result.Content != "<B>" || len(result.Warnings) != 0 {
+		t.Errorf("Resolve() = %q, %v, want \"<B>\" without warnings", result.Content, result.Warnings)
+	}
+
+	// Filters after a default are recognized by the resolver's registry
+	result = NewVariableResolver(nil).WithFilters(registry).Resolve(`{{tag:b|wrap "<" ">"}}`)
+	if result.Content != "<b>" || len(result.Warnings) != 0 {
+		t.Errorf("Resolve() = %q, %v, want \"<b>\" without warnings", result.Content, result.Warnings)
+	}
+
+	// The default registry does not know the custom filter
+	result = NewVariableResolver(map[string]string{"tag": "b"}).Resolve(`{{tag | wrap "<" ">"}}`)
+	if result.Content != "b" || len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "wrap") {
+		t.Errorf("Resolve() = %q, %v, want the value unchanged with a warning", result.Content, result.Warnings)
+	}
+
+	expected := []string{"indent", "join", "json", "lower", "trim", "truncate", "upper", "wrap"}
+	if names := registry.Names(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Names() = %v, want %v", names, expected)
+	}
+}
@@ -0,0 +1,367 @@
+package template
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Block kinds
+const (
+	BlockIf   = "if"
+	BlockEach = "each"
+)
+
+// loopItem is the name that refers to the current item inside an {{#each}} block
+const loopItem = "this"
+
+// Node is an element of a parsed template
+type Node interface {
+	Position() int
+}
+
+// TextNode is literal text that is copied to the output as is
+type TextNode struct {
+	Pos  int
+	Text string
+}
+
+// RawNode is text written with escapes or in a {{raw}}…{{/raw}} block. Its Text is copied
+// to the output, while Source is kept when a render leaves the template unresolved.
+type RawNode struct {
+	Pos    int
+	Text   string
+	Source string
+}
+
+// VariableNode is a {{name}}, {{name:default}} or {{name:type=default}} placeholder
+type VariableNode struct {
+	Pos          int
+	Raw          string // The placeholder as written, kept when the variable cannot be resolved
+	Name         string
+	DefaultValue string
+	HasDefault   bool
+	Type         *Type // The declared type, if any
+	Filters      []FilterCall
+}
+
+// BlockNode is an {{#if name}}…{{else}}…{{/if}} or {{#each name}}…{{else}}…{{/each}} block.
+// An if block renders Body when the variable is truthy, an each block renders Body once per
+// item of the list in the variable. Both render Else otherwise.
+type BlockNode struct {
+	Pos      int
+	Kind     string
+	Variable string
+	Body     []Node
+	Else     []Node
+	HasElse  bool
+
+	// The source of the block's tags, including any whitespace removed with them
+	Open    string
+	ElseTag string
+	Close   string
+}
+
+// openTagEnd returns the end of the block's opening tag
+func (n *BlockNode) openTagEnd() int {
+	return n.Pos + len(strings.TrimSpace(n.Open))
+}
+
+func (n *TextNode) Position() int     { return n.Pos }
+func (n *RawNode) Position() int      { return n.Pos }
+func (n *VariableNode) Position() int { return n.Pos }
+func (n *BlockNode) Position() int    { return n.Pos }
+
+// Tree is a parsed template. Malformed tags are kept as text and reported in Warnings
+// and Diagnostics.
+type Tree struct {
+	Nodes       []Node
+	Warnings    []string
+	Diagnostics []Diagnostic
+}
+
+// Parse parses content into a tree. Tags that are not valid placeholders or block tags are
+// left as text, so any content parses. Filters after a default value are recognized by the
+// names in DefaultFilters.
+func Parse(content string) *Tree {
+	return parseWith(content, DefaultFilters)
+}
+
+// parseWith parses content, recognizing the filters registered in filters
+func parseWith(content string, filters *FilterRegistry) *Tree {
+	p := &parser{content: content, filters: filters, reporter: reporter{content: content}}
+	p.parse()
+	return &Tree{
+		Nodes:       p.nodes,
+		Warnings:    p.warnings,
+		Diagnostics: p.diagnostics,
+	}
+}
+
+// openBlock is a block whose closing tag has not been reached yet
+type openBlock struct {
+	node     *BlockNode
+	inElse   bool
+	children *[]Node
+}
+
+type parser struct {
+	reporter
+	content string
+	filters *FilterRegistry
+	nodes   []Node
+	stack   []*openBlock
+}
+
+func (p *parser) parse() {
+	text := 0 // Start of the text not yet added to the tree
+	for i := 0; ; {
+		start := strings.Index(p.content[i:], "{{")
+		if at := strings.Index(p.content[i:], "@@"); at >= 0 && (start < 0 || at < start) {
+			// @@ is a literal @
+			at += i
+			p.addText(text, at)
+			p.add(&RawNode{Pos: at, Text: "@", Source: "@@"})
+			text, i = at+2, at+2
+			continue
+		}
+		if start < 0 {
+			break
+		}
+		start += i
+
+		// \{{ is a literal {{
+		if start > text && p.content[start-1] == '\\' {
+			p.addText(text, start-1)
+			p.add(&RawNode{Pos: start - 1, Text: "{{", Source: p.content[start-1 : start+2]})
+			text, i = start+2, start+2
+			continue
+		}
+
+		// Like the placeholders this replaces, a tag may not contain '}'
+		end := strings.IndexByte(p.content[start+2:], '}')
+		if end < 0 {
+			i = start + 2
+			continue
+		}
+		end += start + 2
+		if !strings.HasPrefix(p.content[end:], "}}") {
+			i = start + 1
+			continue
+		}
+		end += 2
+
+		tag := p.content[start:end]
+		inner := strings.TrimSpace(p.content[start+2 : end-2])
+
+		if inner == "raw" {
+			if rawEnd, ok := p.rawBlock(start, end, text); ok {
+				text, i = rawEnd, rawEnd
+				continue
+			}
+		}
+
+		if p.isBlockTag(inner) {
+			spanStart, spanEnd := p.standalone(start, end)
+			p.addText(text, spanStart)
+			p.blockTag(start, tag, inner, p.content[spanStart:spanEnd])
+			text, i = spanEnd, spanEnd
+			continue
+		}
+
+		if variable, ok := p.variable(start, tag); ok {
+			p.addText(text, start)
+			p.add(variable)
+			text = end
+		}
+		i = end
+	}
+	p.addText(text, len(p.content))
+
+	// Blocks that are never closed fall back to text, keeping what they contain
+	for len(p.stack) > 0 {
+		block := p.stack[len(p.stack)-1]
+		p.report(SeverityError, CodeUnclosedBlock, block.node.Pos, block.node.openTagEnd(), "Block '%s' is never closed", strings.TrimSpace(block.node.Open))
+		p.unwind(block)
+	}
+}
+
+// rawCloseRegex matches the tag that ends a {{raw}} block
+var rawCloseRegex = regexp.MustCompile(`\{\{\s*/raw\s*\}\}`)
+
+// rawBlock adds the {{raw}} block whose opening tag is content[start:end] as literal text
+// and returns where the block ends. Without a closing tag, {{raw}} is a variable as before.
+func (p *parser) rawBlock(start, end, text int) (int, bool) {
+	loc := rawCloseRegex.FindStringIndex(p.content[end:])
+	if loc == nil {
+		return 0, false
+	}
+
+	openStart, openEnd := p.standalone(start, end)
+	closeStart, closeEnd := p.standalone(end+loc[0], end+loc[1])
+	p.addText(text, openStart)
+	p.add(&RawNode{
+		Pos:    start,
+		Text:   p.content[openEnd:closeStart],
+		Source: p.content[openStart:closeEnd],
+	})
+	return closeEnd, true
+}
+
+// isBlockTag reports whether the trimmed inside of a tag opens, divides or closes a block.
+// {{else}} outside of a block stays a variable named else.
+func (p *parser) isBlockTag(inner string) bool {
+	if strings.HasPrefix(inner, "#") || strings.HasPrefix(inner, "/") {
+		return true
+	}
+	return inner == "else" && len(p.stack) > 0
+}
+
+// blockTag handles a block tag whose source, including any whitespace removed with it, is span
+func (p *parser) blockTag(pos int, tag, inner, span string) {
+	switch {
+	case strings.HasPrefix(inner, "#"):
+		fields := strings.Fields(inner[1:])
+		if len(fields) == 0 || (fields[0] != BlockIf && fields[0] != BlockEach) {
+			p.report(SeverityError, CodeUnknownBlock, pos, pos+len(tag), "Unknown block '%s'", tag)
+			p.add(&TextNode{Pos: pos, Text: span})
+			return
+		}
+		if len(fields) != 2 {
+			p.report(SeverityError, CodeInvalidBlock, pos, pos+len(tag), "Block '%s' needs exactly one variable", tag)
+			p.add(&TextNode{Pos: pos, Text: span})
+			return
+		}
+		block := &openBlock{
+			node: &BlockNode{Pos: pos, Kind: fields[0], Variable: fields[1], Open: span},
+		}
+		block.children = &block.node.Body
+		p.stack = append(p.stack, block)
+
+	case inner == "else":
+		block := p.stack[len(p.stack)-1]
+		if block.inElse {
+			p.report(SeverityError, CodeDuplicateElse, pos, pos+len(tag), "Block '{{#%s %s}}' has more than one {{else}}", block.node.Kind, block.node.Variable)
+			p.add(&TextNode{Pos: pos, Text: span})
+			return
+		}
+		block.inElse = true
+		block.node.ElseTag = span
+		block.node.HasElse = true
+		block.children = &block.node.Else
+
+	default:
+		kind := strings.TrimSpace(inner[1:])
+		if len(p.stack) == 0 || p.stack[len(p.stack)-1].node.Kind != kind {
+			p.report(SeverityError, CodeUnexpectedClose, pos, pos+len(tag), "Unexpected '%s' with no matching open block", tag)
+			p.add(&TextNode{Pos: pos, Text: span})
+			return
+		}
+		block := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		block.node.Close = span
+		p.add(block.node)
+	}
+}
+
+// unwind pops a block that is never closed and adds its tags back as text with its children
+func (p *parser) unwind(block *openBlock) {
+	p.stack = p.stack[:len(p.stack)-1]
+	p.add(&TextNode{Pos: block.node.Pos, Text: block.node.Open})
+	for _, node := range block.node.Body {
+		p.add(node)
+	}
+	if block.node.HasElse {
+		p.add(&TextNode{Pos: block.node.Pos, Text: block.node.ElseTag})
+		for _, node := range block.node.Else {
+			p.add(node)
+		}
+	}
+}
+
+// standalone widens the span of a block tag that is alone on its line to the whole line, so
+// the tag does not leave an empty line behind
+func (p *parser) standalone(start, end int) (int, int) {
+	lineStart := start
+	for lineStart > 0 && isBlank(p.content[lineStart-1]) {
+		lineStart--
+	}
+	if lineStart > 0 && p.content[lineStart-1] != '\n' {
+		return start, end
+	}
+
+	lineEnd := end
+	for lineEnd < len(p.content) && isBlank(p.content[lineEnd]) {
+		lineEnd++
+	}
+	switch {
+	case lineEnd == len(p.content):
+		return lineStart, lineEnd
+	case strings.HasPrefix(p.content[lineEnd:], "\n"):
+		return lineStart, lineEnd + 1
+	case strings.HasPrefix(p.content[lineEnd:], "\r\n"):
+		return lineStart, lineEnd + 2
+	}
+	return start, end
+}
+
+// addText adds the content between from and to as text
+func (p *parser) addText(from, to int) {
+	if from < to {
+		p.add(&TextNode{Pos: from, Text: p.content[from:to]})
+	}
+}
+
+// add appends a node to the innermost open block, or to the tree
+func (p *parser) add(node Node) {
+	if len(p.stack) == 0 {
+		p.nodes = append(p.nodes, node)
+		return
+	}
+	children := p.stack[len(p.stack)-1].children
+	*children = append(*children, node)
+}
+
+// variable parses a {{name}}, {{name:default}}, {{name:type()}} or {{name:type=default}}
+// tag, optionally followed by filters as in {{name | upper}}. The name is trimmed, a plain
+// default value is kept as written up to the first filter.
+func (p *parser) variable(pos int, tag string) (*VariableNode, bool) {
+	inner, filters := parseFilters(tag[2:len(tag)-2], p.filters)
+	if len(filters) > 0 {
+		inner = strings.TrimRight(inner, " \t")
+	}
+
+	name, spec, hasSpec := strings.Cut(inner, ":")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, false
+	}
+
+	node := &VariableNode{
+		Pos:          pos,
+		Raw:          tag,
+		Name:         name,
+		DefaultValue: spec,
+		HasDefault:   hasSpec && spec != "",
+		Filters:      filters,
+	}
+	// A bare type name is a plain default, as it was before types existed: {{format:string}}
+	// defaults to "string". Declaring a type takes arguments or a default value, as in
+	// {{items:list()}} or {{count:int=3}}.
+	if !hasSpec || !strings.ContainsAny(spec, "(=") {
+		return node, true
+	}
+
+	t, defaultValue, hasDefault, ok, err := parseType(spec)
+	switch {
+	case err != nil:
+		p.report(SeverityError, CodeInvalidType, pos, pos+len(tag), "Invalid type for variable '%s': %v", name, err)
+		node.DefaultValue, node.HasDefault = "", false
+	case ok:
+		node.Type, node.DefaultValue, node.HasDefault = t, defaultValue, hasDefault
+	}
+	return node, true
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
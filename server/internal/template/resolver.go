@@ -1,8 +1,8 @@
 package template
 
 import (
-	"fmt"
-	"regexp"
+	"encoding/json"
+	"strconv"
 	"strings"
 )
 
@@ -11,17 +11,38 @@ type Variable struct {
 	Name         string
 	DefaultValue string
 	HasDefault   bool
+	Condition    bool  // Only tested by {{#if}} blocks, so it may be left out
+	List         bool  // Listed by an {{#each}} block
+	Type         *Type // The declared type, nil for a variable that takes any text
+	Argument     bool  // Only used in snippets whose call sites pass it as an argument
 }
 
-// ResolveResult contains the resolved content and any warnings
+// TypeName returns the name of the declared type of a variable, or the type implied by
+// how it is used: a list for loops, a bool for conditions and a string otherwise
+func (v Variable) TypeName() string {
+	switch {
+	case v.Type != nil:
+		return v.Type.Name
+	case v.List:
+		return TypeList
+	case v.Condition:
+		return TypeBool
+	}
+	return TypeString
+}
+
+// ResolveResult contains the resolved content and any warnings, with their positions in
+// Diagnostics
 type ResolveResult struct {
-	Content  string
-	Warnings []string
+	Content     string
+	Warnings    []string
+	Diagnostics []Diagnostic
 }
 
 // VariableResolver handles template variable resolution
 type VariableResolver struct {
 	variables map[string]string
+	filters   *FilterRegistry
 }
 
 // NewVariableResolver creates a new variable resolver with the given variables
@@ -31,71 +52,265 @@ func NewVariableResolver(variables map[string]string) *VariableResolver {
 	}
 	return &VariableResolver{
 		variables: variables,
+		filters:   DefaultFilters,
 	}
 }
 
-// variableRegex matches {{variable_name}} or {{variable_name:default_value}}
-var variableRegex = regexp.MustCompile(`\{\{([^}:]+)(?::([^}]*))?\}\}`)
+// WithFilters makes the resolver use the filters of registry instead of DefaultFilters
+func (r *VariableResolver) WithFilters(registry *FilterRegistry) *VariableResolver {
+	r.filters = registry
+	return r
+}
 
-// ExtractVariables extracts all variables from the given content
+// ExtractVariables extracts all variables from the given content, in order of first use.
+// Variables tested by {{#if}} blocks and listed by {{#each}} blocks are included, but not
+// the {{this}} of a loop.
 func ExtractVariables(content string) []Variable {
-	matches := variableRegex.FindAllStringSubmatch(content, -1)
-	variables := make([]Variable, 0, len(matches))
+	variables := make([]Variable, 0)
+	index := make(map[string]int)
 
-	seen := make(map[string]bool)
-	for _, match := range matches {
-		name := strings.TrimSpace(match[1])
-		if seen[name] {
-			continue
+	walkVariables(Parse(content).Nodes, false, func(variable Variable) {
+		i, seen := index[variable.Name]
+		if !seen {
+			index[variable.Name] = len(variables)
+			variables = append(variables, variable)
+			return
 		}
-		seen[name] = true
+		variables[i] = mergeVariable(variables[i], variable)
+	})
 
-		variable := Variable{
-			Name: name,
-		}
+	return variables
+}
 
-		if len(match) > 2 && match[2] != "" {
-			variable.DefaultValue = match[2]
-			variable.HasDefault = true
+// walkVariables calls visit for every use of a variable in nodes
+func walkVariables(nodes []Node, inLoop bool, visit func(Variable)) {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *VariableNode:
+			if inLoop && n.Name == loopItem {
+				continue
+			}
+			visit(Variable{
+				Name:         n.Name,
+				DefaultValue: n.DefaultValue,
+				HasDefault:   n.HasDefault,
+				Type:         n.Type,
+			})
+		case *BlockNode:
+			if !inLoop || n.Variable != loopItem {
+				visit(Variable{
+					Name:      n.Variable,
+					Condition: n.Kind == BlockIf,
+					List:      n.Kind == BlockEach,
+				})
+			}
+			walkVariables(n.Body, inLoop || n.Kind == BlockEach, visit)
+			walkVariables(n.Else, inLoop, visit)
 		}
-
-		variables = append(variables, variable)
 	}
+}
 
-	return variables
+// mergeVariable combines two uses of the same variable. The first declared type and default
+// value win, and the variable is only a condition if every use is one.
+func mergeVariable(existing, variable Variable) Variable {
+	if existing.Type == nil {
+		existing.Type = variable.Type
+	}
+	if !existing.HasDefault && variable.HasDefault {
+		existing.DefaultValue = variable.DefaultValue
+		existing.HasDefault = true
+	}
+	existing.Condition = existing.Condition && variable.Condition
+	existing.List = existing.List || variable.List
+	return existing
 }
 
-// Resolve replaces all variables in the content with their values
+// Resolve replaces all variables in the content with their values and renders its blocks
 func (r *VariableResolver) Resolve(content string) ResolveResult {
-	var warnings []string
+	tree := parseWith(content, r.filters)
+	state := &renderState{reporter: reporter{content: content}}
+	state.include(tree.Diagnostics)
 
-	resolved := variableRegex.ReplaceAllStringFunc(content, func(match string) string {
-		submatch := variableRegex.FindStringSubmatch(match)
-		if len(submatch) < 2 {
-			return match
-		}
+	var sb strings.Builder
+	r.render(&sb, tree.Nodes, nil, state)
+	sortDiagnostics(state.diagnostics)
+
+	return ResolveResult{
+		Content:     sb.String(),
+		Warnings:    state.warnings,
+		Diagnostics: state.diagnostics,
+	}
+}
 
-		name := strings.TrimSpace(submatch[1])
+// renderState collects the problems found by a render. A partial render only replaces the
+// variables it has values for and keeps everything else as written, to be resolved later.
+type renderState struct {
+	reporter
+	partial bool
+}
 
-		// Check if we have a value for this variable
-		if value, exists := r.variables[name]; exists {
-			return value
-		}
+// render writes nodes to sb. item is the current item of the innermost loop, if any.
+func (r *VariableResolver) render(sb *strings.Builder, nodes []Node, item *string, state *renderState) {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *TextNode:
+			sb.WriteString(n.Text)
+
+		case *RawNode:
+			if state.partial {
+				sb.WriteString(n.Source)
+			} else {
+				sb.WriteString(n.Text)
+			}
+
+		case *VariableNode:
+			// Check if we have a value for this variable
+			if value, exists := r.lookup(n.Name, item); exists {
+				if n.Type != nil {
+					if err := n.Type.Validate(value); err != nil {
+						state.report(SeverityError, CodeInvalidValue, n.Pos, n.Pos+len(n.Raw), "Value of variable '%s' is invalid: %v", n.Name, err)
+					}
+				}
+				sb.WriteString(r.applyFilters(n, value, state))
+				continue
+			}
+
+			if state.partial {
+				sb.WriteString(n.Raw)
+				continue
+			}
+
+			// Check if there's a default value
+			if n.HasDefault {
+				sb.WriteString(r.applyFilters(n, n.DefaultValue, state))
+				continue
+			}
+
+			// No value and no default - add warning and keep original
+			state.report(SeverityWarning, CodeUndefinedVariable, n.Pos, n.Pos+len(n.Raw), "Variable '%s' is not defined and has no default value", n.Name)
+			sb.WriteString(n.Raw)
+
+		case *BlockNode:
+			value, exists := r.lookup(n.Variable, item)
+			if !exists && state.partial {
+				// Keep the block, resolving what it contains. Inside a loop that is kept,
+				// {{this}} is that loop's item.
+				inner := item
+				if n.Kind == BlockEach {
+					inner = nil
+				}
+				sb.WriteString(n.Open)
+				r.render(sb, n.Body, inner, state)
+				sb.WriteString(n.ElseTag)
+				r.render(sb, n.Else, item, state)
+				sb.WriteString(n.Close)
+				continue
+			}
 
-		// Check if there's a default value
-		if len(submatch) > 2 && submatch[2] != "" {
-			return submatch[2]
+			switch n.Kind {
+			case BlockIf:
+				// A missing condition is false
+				if exists && truthy(value) {
+					r.render(sb, n.Body, item, state)
+				} else {
+					r.render(sb, n.Else, item, state)
+				}
+			case BlockEach:
+				if !exists {
+					state.report(SeverityWarning, CodeUndefinedVariable, n.Pos, n.openTagEnd(), "Variable '%s' is not defined and has no default value", n.Variable)
+				}
+				items := splitList(value)
+				if len(items) == 0 {
+					r.render(sb, n.Else, item, state)
+				}
+				for i := range items {
+					r.render(sb, n.Body, &items[i], state)
+				}
+			}
 		}
+	}
+}
 
-		// No value and no default - add warning and keep original
-		warnings = append(warnings, fmt.Sprintf("Variable '%s' is not defined and has no default value", name))
-		return match
-	})
+// bind partially renders content, replacing only the variables the resolver has values
+// for. Blocks on those variables are rendered, all other tags are kept as written.
+func (r *VariableResolver) bind(content string) ResolveResult {
+	state := &renderState{reporter: reporter{content: content}, partial: true}
+
+	var sb strings.Builder
+	r.render(&sb, parseWith(content, r.filters).Nodes, nil, state)
 
 	return ResolveResult{
-		Content:  resolved,
-		Warnings: warnings,
+		Content:     sb.String(),
+		Warnings:    state.warnings,
+		Diagnostics: state.diagnostics,
+	}
+}
+
+// applyFilters runs value through the filters of a placeholder in order. A filter that is
+// unknown or fails is skipped with a warning.
+func (r *VariableResolver) applyFilters(n *VariableNode, value string, state *renderState) string {
+	for _, call := range n.Filters {
+		filter, exists := r.filters.Get(call.Name)
+		if !exists {
+			state.report(SeverityWarning, CodeUnknownFilter, n.Pos, n.Pos+len(n.Raw), "Unknown filter '%s' on variable '%s'", call.Name, n.Name)
+			continue
+		}
+		filtered, err := filter(value, call.Args)
+		if err != nil {
+			state.report(SeverityWarning, CodeFilterFailed, n.Pos, n.Pos+len(n.Raw), "Filter '%s' on variable '%s' failed: %v", call.Name, n.Name, err)
+			continue
+		}
+		value = filtered
+	}
+	return value
+}
+
+// lookup returns the value of a variable, where {{this}} is the current loop item
+func (r *VariableResolver) lookup(name string, item *string) (string, bool) {
+	if item != nil && name == loopItem {
+		return *item, true
+	}
+	value, exists := r.variables[name]
+	return value, exists
+}
+
+// truthy reports whether a value makes an {{#if}} block render its body. Values that parse
+// as a false bool, empty values and empty lists are false.
+func truthy(value string) bool {
+	trimmed := strings.TrimSpace(value)
+	if b, err := strconv.ParseBool(trimmed); err == nil {
+		return b
+	}
+	return trimmed != "" && trimmed != "[]"
+}
+
+// splitList splits the value of a list variable into its items. A JSON array gives its
+// elements, anything else gives one item per non-blank line.
+func splitList(value string) []string {
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, "[") {
+		var elements []json.RawMessage
+		if err := json.Unmarshal([]byte(trimmed), &elements); err == nil {
+			items := make([]string, 0, len(elements))
+			for _, element := range elements {
+				var text string
+				if err := json.Unmarshal(element, &text); err != nil {
+					text = string(element)
+				}
+				items = append(items, text)
+			}
+			return items
+		}
 	}
+
+	var items []string
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			items = append(items, line)
+		}
+	}
+	return items
 }
 
 // GetMissingVariables returns variables that are required but not provided
@@ -104,7 +319,7 @@ func (r *VariableResolver) GetMissingVariables(content string) []string {
 	var missing []string
 
 	for _, variable := range variables {
-		if _, exists := r.variables[variable.Name]; !exists && !variable.HasDefault {
+		if r.variableStatus(variable) == "missing" {
 			missing = append(missing, variable.Name)
 		}
 	}
@@ -118,14 +333,75 @@ func (r *VariableResolver) GetVariableStatus(content string) map[string]string {
 	status := make(map[string]string)
 
 	for _, variable := range variables {
-		if _, exists := r.variables[variable.Name]; exists {
-			status[variable.Name] = "provided"
-		} else if variable.HasDefault {
-			status[variable.Name] = "default"
-		} else {
-			status[variable.Name] = "missing"
-		}
+		status[variable.Name] = r.variableStatus(variable)
 	}
 
 	return status
 }
+
+// Validate checks the provided values of the typed variables in content
+func (r *VariableResolver) Validate(content string) []VariableError {
+	return validateVariables(ExtractVariables(content), r.variables)
+}
+
+// variableStatus returns whether a variable is provided, falls back to a default, is
+// missing, or is supplied by snippet arguments. A condition that is left out defaults to
+// false.
+func (r *VariableResolver) variableStatus(variable Variable) string {
+	if variable.Argument {
+		return "argument"
+	}
+	if _, exists := r.variables[variable.Name]; exists {
+		return "provided"
+	}
+	if variable.HasDefault || variable.Condition {
+		return "default"
+	}
+	return "missing"
+}
+
+// Block describes an {{#if}} or {{#each}} block of a template
+type Block struct {
+	Kind     string
+	Variable string
+	HasElse  bool
+	Active   bool // Whether the body renders: the condition is true, or the list has items
+	Items    int  // The number of items an each block repeats its body for
+	Blocks   []Block
+}
+
+// GetBlocks returns the blocks of content, nested as they are in the template, and how
+// they render with the resolver's variables. Blocks inside a loop are evaluated against
+// the variables rather than per item, so a block on {{this}} is never active.
+func (r *VariableResolver) GetBlocks(content string) []Block {
+	return r.blocks(parseWith(content, r.filters).Nodes)
+}
+
+func (r *VariableResolver) blocks(nodes []Node) []Block {
+	blocks := make([]Block, 0)
+	for _, node := range nodes {
+		n, ok := node.(*BlockNode)
+		if !ok {
+			continue
+		}
+
+		block := Block{
+			Kind:     n.Kind,
+			Variable: n.Variable,
+			HasElse:  n.HasElse,
+		}
+		if value, exists := r.variables[n.Variable]; exists {
+			switch n.Kind {
+			case BlockIf:
+				block.Active = truthy(value)
+			case BlockEach:
+				block.Items = len(splitList(value))
+				block.Active = block.Items > 0
+			}
+		}
+		block.Blocks = append(r.blocks(n.Body), r.blocks(n.Else)...)
+
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
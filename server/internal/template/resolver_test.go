@@ -52,6 +52,13 @@ func TestExtractVariables(t *testing.T) {
 				{Name: "name", DefaultValue: " default value ", HasDefault: true},
 			},
 		},
+		{
+			name:    "escaped and raw placeholders",
+			content: "\\{{a}} {{raw}}{{b}}{{/raw}} {{c}}",
+			expected: []Variable{
+				{Name: "c", DefaultValue: "", HasDefault: false},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -203,3 +210,168 @@ func TestVariableResolver_GetVariableStatus(t *testing.T) {
 		t.Errorf("GetVariableStatus() = %v, want %v", status, expected)
 	}
 }
+
+func TestVariableResolver_ResolveBlocks(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          string
+		variables        map[string]string
+		expectedContent  string
+		expectedWarnings int
+	}{
+		{
+			name:             "if with true condition",
+			content:          "{{#if formal}}Dear {{name}}{{else}}Hi {{name}}{{/if}},",
+			variables:        map[string]string{"formal": "yes", "name": "Alice"},
+			expectedContent:  "Dear Alice,",
+			expectedWarnings: 0,
+		},
+		{
+			name:             "if with false condition",
+			content:          "{{#if formal}}Dear {{name}}{{else}}Hi {{name}}{{/if}},",
+			variables:        map[string]string{"formal": "false", "name": "Alice"},
+			expectedContent:  "Hi Alice,",
+			expectedWarnings: 0,
+		},
+		{
+			name:             "if with missing condition",
+			content:          "Hello{{#if name}} {{name}}{{/if}}!",
+			variables:        map[string]string{},
+			expectedContent:  "Hello!",
+			expectedWarnings: 0,
+		},
+		{
+			name:             "block tags on their own lines",
+			content:          "Start\n{{#if extra}}\nExtra line\n{{else}}\nNo extra\n{{/if}}\nEnd",
+			variables:        map[string]string{"extra": "1"},
+			expectedContent:  "Start\nExtra line\nEnd",
+			expectedWarnings: 0,
+		},
+		{
+			name:             "each over lines",
+			content:          "Rules:\n{{#each rules}}\n- {{this}}\n{{/each}}\nDone",
+			variables:        map[string]string{"rules": "Be brief\n\nBe kind\n"},
+			expectedContent:  "Rules:\n- Be brief\n- Be kind\nDone",
+			expectedWarnings: 0,
+		},
+		{
+			name:             "each over JSON array",
+			content:          "{{#each items}}[{{this}}]{{/each}}",
+			variables:        map[string]string{"items": `["a", "b c", 3]`},
+			expectedContent:  "[a][b c][3]",
+			expectedWarnings: 0,
+		},
+		{
+			name:             "each over empty list renders else",
+			content:          "{{#each items}}{{this}}{{else}}none{{/each}}",
+			variables:        map[string]string{"items": "[]"},
+			expectedContent:  "none",
+			expectedWarnings: 0,
+		},
+		{
+			name:             "each with missing list",
+			content:          "{{#each items}}{{this}}{{/each}}",
+			variables:        map[string]string{},
+			expectedContent:  "",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "nested blocks",
+			content:          "{{#each people}}{{#if this}}{{greeting:Hi}} {{this}}. {{/if}}{{/each}}",
+			variables:        map[string]string{"people": `["Ann", "", "Bob"]`},
+			expectedContent:  "Hi Ann. Hi Bob. ",
+			expectedWarnings: 0,
+		},
+		{
+			name:             "missing variable in loop warns once",
+			content:          "{{#each items}}{{this}}{{sep}}{{/each}}",
+			variables:        map[string]string{"items": "a\nb"},
+			expectedContent:  "a{{sep}}b{{sep}}",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "else outside a block is a variable",
+			content:          "{{else}}",
+			variables:        map[string]string{"else": "value"},
+			expectedContent:  "value",
+			expectedWarnings: 0,
+		},
+		{
+			name:             "unclosed block is kept as text",
+			content:          "{{#if flag}}shown",
+			variables:        map[string]string{},
+			expectedContent:  "{{#if flag}}shown",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "unexpected closing tag is kept as text",
+			content:          "{{#if flag}}a{{/each}}b{{/if}}",
+			variables:        map[string]string{"flag": "true"},
+			expectedContent:  "a{{/each}}b",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "unknown block is kept as text",
+			content:          "{{#with user}}",
+			variables:        map[string]string{},
+			expectedContent:  "{{#with user}}",
+			expectedWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewVariableResolver(tt.variables)
+			result := resolver.Resolve(tt.content)
+
+			if result.Content != tt.expectedContent {
+				t.Errorf("Resolve() content = %q, want %q", result.Content, tt.expectedContent)
+			}
+
+			if len(result.Warnings) != tt.expectedWarnings {
+				t.Errorf("Resolve() warnings = %v, want %v warnings", result.Warnings, tt.expectedWarnings)
+			}
+		})
+	}
+}
+
+func TestExtractVariables_Blocks(t *testing.T) {
+	content := "{{#if formal}}Dear {{name:Sir}}{{/if}}{{#each items}}{{this}} {{sep:,}}{{/each}}{{#if items}}!{{/if}}"
+	expected := []Variable{
+		{Name: "formal", Condition: true},
+		{Name: "name", DefaultValue: "Sir", HasDefault: true},
+		{Name: "items", List: true},
+		{Name: "sep", DefaultValue: ",", HasDefault: true},
+	}
+
+	result := ExtractVariables(content)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ExtractVariables() = %+v, want %+v", result, expected)
+	}
+
+	status := NewVariableResolver(nil).GetVariableStatus(content)
+	if status["formal"] != "default" || status["items"] != "missing" {
+		t.Errorf("GetVariableStatus() = %v, want formal to default and items to be missing", status)
+	}
+}
+
+func TestVariableResolver_GetBlocks(t *testing.T) {
+	resolver := NewVariableResolver(map[string]string{
+		"formal": "true",
+		"items":  "a\nb\nc",
+	})
+
+	content := "{{#if formal}}Dear{{else}}Hi{{/if}}\n{{#each items}}{{#if this}}{{this}}{{/if}}{{/each}}\n{{#if extra}}!{{/if}}"
+	expected := []Block{
+		{Kind: BlockIf, Variable: "formal", HasElse: true, Active: true, Blocks: []Block{}},
+		{Kind: BlockEach, Variable: "items", Active: true, Items: 3, Blocks: []Block{
+			{Kind: BlockIf, Variable: "this", Blocks: []Block{}},
+		}},
+		{Kind: BlockIf, Variable: "extra", Blocks: []Block{}},
+	}
+
+	result := resolver.GetBlocks(content)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("GetBlocks() = %+v, want %+v", result, expected)
+	}
+}
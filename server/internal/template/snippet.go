@@ -3,6 +3,8 @@ package template
 import (
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/dikkadev/proompt/server/internal/models"
@@ -31,84 +33,287 @@ func NewSnippetResolver(snippets []*models.Snippet, variables map[string]string)
 	}
 }
 
-// snippetRegex matches @snippet_name or @{snippet name with spaces}
-var snippetRegex = regexp.MustCompile(`@(?:\{([^}]+)\}|([a-zA-Z_][a-zA-Z0-9_]*))`)
+// snippetRegex matches @snippet_name or @{snippet name with spaces}, which may pass
+// arguments as in @{greeting name="Alice" tone=formal}. It also matches what must not be
+// taken for a reference, so it can be skipped: {{raw}}…{{/raw}} blocks, escaped \{{ and
+// @@, and an @ right after a letter or digit as in an email address.
+var snippetRegex = regexp.MustCompile(`\{\{\s*raw\s*\}\}[\s\S]*?\{\{\s*/raw\s*\}\}|\\\{\{|@@|[\p{L}\p{N}_]@+|@(?:\{([^}]+)\}|([a-zA-Z_][a-zA-Z0-9_]*))`)
+
+// snippetArgStartRegex finds the first name=value argument of a snippet reference
+var snippetArgStartRegex = regexp.MustCompile(`\s[a-zA-Z_][a-zA-Z0-9_]*=`)
+
+// snippetArgRegex matches one name=value argument of a snippet reference. A value is
+// double quoted with Go escapes, single quoted and taken literally, or a bare word.
+var snippetArgRegex = regexp.MustCompile(`^\s+([a-zA-Z_][a-zA-Z0-9_]*)=("(?:[^"\\]|\\.)*"|'[^']*'|[^\s"']+)`)
 
 // SnippetInsertResult contains the result of snippet insertion
 type SnippetInsertResult struct {
-	Content   string
-	Warnings  []string
-	Variables []Variable
+	Content     string
+	Warnings    []string
+	Diagnostics []Diagnostic
+	Variables   []Variable
+	Arguments   []Variable // Variables of snippets that were supplied by call-site arguments
+}
+
+// frame is a snippet being inserted, referenced at start:end in its parent's content
+type frame struct {
+	snippet    string
+	start, end int
+}
+
+// segment is a part of inserted content that was copied from one place. It starts at
+// start in the inserted content and was copied from source in the content of the innermost
+// snippet in frames, or of the content itself when there are no frames. A segment that
+// binding arguments generated has no source, and maps to its snippet's reference.
+type segment struct {
+	start  int
+	source int
+	frames []frame
+}
+
+// insertion is the state of inserting snippets into content
+type insertion struct {
+	content     string
+	processed   map[string]bool
+	warnings    []string
+	diagnostics []Diagnostic
+	variables   []Variable
+	arguments   []Variable
 }
 
 // InsertSnippets replaces snippet references with their content and resolves variables
 func (sr *SnippetResolver) InsertSnippets(content string) SnippetInsertResult {
-	var warnings []string
-	var allVariables []Variable
+	result, _ := sr.insertSnippets(content)
+	return result
+}
 
+// insertSnippets inserts snippets into content, returning where each part of the result
+// came from along with it
+func (sr *SnippetResolver) insertSnippets(content string) (SnippetInsertResult, []segment) {
 	// Track processed snippets to prevent infinite recursion
-	processed := make(map[string]bool)
+	ins := &insertion{
+		content:   content,
+		processed: make(map[string]bool),
+	}
 
-	result := sr.insertSnippetsRecursive(content, processed, &warnings, &allVariables)
+	result, segments := sr.insertSnippetsRecursive(ins, content, nil)
 
 	return SnippetInsertResult{
-		Content:   result,
-		Warnings:  warnings,
-		Variables: allVariables,
-	}
+		Content:     result,
+		Warnings:    ins.warnings,
+		Diagnostics: ins.diagnostics,
+		Variables:   ins.variables,
+		Arguments:   ins.arguments,
+	}, segments
 }
 
-func (sr *SnippetResolver) insertSnippetsRecursive(content string, processed map[string]bool, warnings *[]string, allVariables *[]Variable) string {
-	return snippetRegex.ReplaceAllStringFunc(content, func(match string) string {
-		submatch := snippetRegex.FindStringSubmatch(match)
-		if len(submatch) < 3 {
-			return match
+// insertSnippetsRecursive inserts the snippets referenced in source, which is the content
+// of the innermost snippet in frames or the content itself
+func (sr *SnippetResolver) insertSnippetsRecursive(ins *insertion, source string, frames []frame) (string, []segment) {
+	var sb strings.Builder
+	var segments []segment
+	copied := 0 // Start of the source not yet copied
+	copyTo := func(end int) {
+		if copied < end {
+			segments = append(segments, segment{start: sb.Len(), source: copied, frames: frames})
+			sb.WriteString(source[copied:end])
 		}
+	}
+
+	for _, loc := range snippetRegex.FindAllStringSubmatchIndex(source, -1) {
+		start, end := loc[0], loc[1]
 
-		// Extract snippet name (either from {name} or direct name)
+		// Extract snippet name (either from {name} or direct name) and arguments. Escapes
+		// match neither and are kept for Resolve.
 		var snippetName string
-		if submatch[1] != "" {
-			snippetName = strings.TrimSpace(submatch[1])
-		} else if submatch[2] != "" {
-			snippetName = submatch[2]
+		var args map[string]string
+		if loc[2] >= 0 {
+			snippetName, args = parseSnippetReference(source[loc[2]:loc[3]])
+		} else if loc[4] >= 0 {
+			snippetName = source[loc[4]:loc[5]]
 		} else {
-			return match
+			continue
 		}
 
 		// Check for recursion
-		if processed[snippetName] {
-			*warnings = append(*warnings, fmt.Sprintf("Circular reference detected for snippet '%s'", snippetName))
-			return match
+		if ins.processed[snippetName] {
+			sr.report(ins, frames, start, end, SeverityError, CodeCircularSnippet, "Circular reference detected for snippet '%s'", snippetName)
+			continue
 		}
 
 		// Find the snippet
 		snippet, exists := sr.snippets[snippetName]
 		if !exists {
-			*warnings = append(*warnings, fmt.Sprintf("Snippet '%s' not found", snippetName))
-			return match
+			sr.report(ins, frames, start, end, SeverityWarning, CodeUnknownSnippet, "Snippet '%s' not found", snippetName)
+			continue
 		}
 
+		copyTo(start)
+		copied = end
+
 		// Mark as processed
-		processed[snippetName] = true
+		ins.processed[snippetName] = true
 
 		// Extract variables from snippet content
 		snippetVars := ExtractVariables(snippet.Content)
-		*allVariables = append(*allVariables, snippetVars...)
+		ins.variables = append(ins.variables, snippetVars...)
 
 		// Recursively process the snippet content (in case it contains other snippets)
-		processedContent := sr.insertSnippetsRecursive(snippet.Content, processed, warnings, allVariables)
+		inner := append(frames[:len(frames):len(frames)], frame{snippet: snippetName, start: start, end: end})
+		processedContent, innerSegments := sr.insertSnippetsRecursive(ins, snippet.Content, inner)
 
 		// Unmark to allow reuse in different contexts
-		delete(processed, snippetName)
+		delete(ins.processed, snippetName)
+
+		if len(args) > 0 {
+			processedContent = sr.bindArguments(ins, inner, processedContent, innerSegments, args)
+			innerSegments = []segment{{source: -1, frames: inner}}
+		}
+
+		for _, seg := range innerSegments {
+			seg.start += sb.Len()
+			segments = append(segments, seg)
+		}
+		sb.WriteString(processedContent)
+	}
+	copyTo(len(source))
+
+	return sb.String(), segments
+}
+
+// bindArguments replaces the variables of an inserted snippet that are passed as arguments
+// at its call site. Nested snippets already inserted see the arguments too, unless they
+// were given their own.
+func (sr *SnippetResolver) bindArguments(ins *insertion, frames []frame, content string, segments []segment, args map[string]string) string {
+	snippet := frames[len(frames)-1]
+
+	used := make(map[string]bool)
+	for _, variable := range ExtractVariables(content) {
+		if _, exists := args[variable.Name]; exists {
+			used[variable.Name] = true
+			ins.arguments = append(ins.arguments, variable)
+		}
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !used[name] {
+			sr.report(ins, frames[:len(frames)-1], snippet.start, snippet.end, SeverityWarning, CodeUnknownArgument, "Snippet '%s' has no variable '%s'", snippet.snippet, name)
+		}
+	}
+
+	result := NewVariableResolver(args).bind(content)
+	for _, d := range result.Diagnostics {
+		d.Message = fmt.Sprintf("Snippet '%s': %s", snippet.snippet, d.Message)
+		ins.warnings = append(ins.warnings, d.Message)
+		ins.diagnostics = append(ins.diagnostics, sr.mapDiagnostic(ins.content, segments, len(content), d))
+	}
+	return result.Content
+}
 
-		return processedContent
-	})
+// report records a problem at start:end in the content of the innermost snippet in frames,
+// or in the content itself
+func (sr *SnippetResolver) report(ins *insertion, frames []frame, start, end int, severity, code, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	ins.warnings = append(ins.warnings, message)
+	ins.diagnostics = append(ins.diagnostics, sr.locate(ins.content, frames, start, end, Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  message,
+	}))
+}
+
+// locate places d at start:end in the content of the innermost snippet in frames. The
+// span of d is set in content, and its includes lead from there to the problem.
+func (sr *SnippetResolver) locate(content string, frames []frame, start, end int, d Diagnostic) Diagnostic {
+	if len(frames) == 0 {
+		d.Span = spanOf(content, start, end)
+		d.Includes = nil
+		return d
+	}
+
+	d.Span = spanOf(content, frames[0].start, frames[0].end)
+	d.Includes = make([]Include, 0, len(frames))
+	for i, f := range frames {
+		includeStart, includeEnd := start, end
+		if i+1 < len(frames) {
+			includeStart, includeEnd = frames[i+1].start, frames[i+1].end
+		}
+		d.Includes = append(d.Includes, Include{
+			Snippet: f.snippet,
+			Span:    spanOf(sr.snippets[f.snippet].Content, includeStart, includeEnd),
+		})
+	}
+	return d
+}
+
+// mapDiagnostic moves a diagnostic found in inserted content of the given length to where
+// its text came from, using the segments of the inserted content
+func (sr *SnippetResolver) mapDiagnostic(content string, segments []segment, length int, d Diagnostic) Diagnostic {
+	offset := d.Span.Start.Offset
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].start > offset }) - 1
+	if i < 0 {
+		return sr.locate(content, nil, offset, d.Span.End.Offset, d)
+	}
+
+	seg := segments[i]
+	if seg.source < 0 {
+		// Generated by binding arguments, so the best place is the snippet's reference
+		f := seg.frames[len(seg.frames)-1]
+		return sr.locate(content, seg.frames[:len(seg.frames)-1], f.start, f.end, d)
+	}
+
+	segmentEnd := length
+	if i+1 < len(segments) {
+		segmentEnd = segments[i+1].start
+	}
+	start := seg.source + offset - seg.start
+	end := start + min(d.Span.End.Offset, segmentEnd) - offset
+	return sr.locate(content, seg.frames, start, end, d)
+}
+
+// parseSnippetReference splits the text inside @{...} into the snippet's name and its
+// name=value arguments. Text that does not end in valid arguments is all name.
+func parseSnippetReference(ref string) (string, map[string]string) {
+	ref = strings.TrimSpace(ref)
+	loc := snippetArgStartRegex.FindStringIndex(ref)
+	if loc == nil {
+		return ref, nil
+	}
+
+	name, rest := strings.TrimSpace(ref[:loc[0]]), ref[loc[0]:]
+	args := make(map[string]string)
+	for rest != "" {
+		match := snippetArgRegex.FindStringSubmatch(rest)
+		if match == nil {
+			return ref, nil
+		}
+
+		value := match[2]
+		switch value[0] {
+		case '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return ref, nil
+			}
+			value = unquoted
+		case '\'':
+			value = value[1 : len(value)-1]
+		}
+		args[match[1]] = value
+		rest = rest[len(match[0]):]
+	}
+	return name, args
 }
 
 // ResolveWithSnippets performs both snippet insertion and variable resolution
 func (sr *SnippetResolver) ResolveWithSnippets(content string) ResolveResult {
 	// First, insert snippets
-	snippetResult := sr.InsertSnippets(content)
+	snippetResult, segments := sr.insertSnippets(content)
 
 	// Then resolve variables
 	resolver := NewVariableResolver(sr.variables)
@@ -117,53 +322,72 @@ func (sr *SnippetResolver) ResolveWithSnippets(content string) ResolveResult {
 	// Combine warnings
 	allWarnings := append(snippetResult.Warnings, variableResult.Warnings...)
 
+	// Place the problems found while resolving where their text came from
+	diagnostics := snippetResult.Diagnostics
+	for _, d := range variableResult.Diagnostics {
+		diagnostics = append(diagnostics, sr.mapDiagnostic(content, segments, len(snippetResult.Content), d))
+	}
+	sortDiagnostics(diagnostics)
+
 	return ResolveResult{
-		Content:  variableResult.Content,
-		Warnings: allWarnings,
+		Content:     variableResult.Content,
+		Warnings:    allWarnings,
+		Diagnostics: diagnostics,
 	}
 }
 
-// GetAllVariables returns all variables from content and any referenced snippets
+// Diagnose returns the problems found when resolving content, placed in content and the
+// snippets it includes
+func (sr *SnippetResolver) Diagnose(content string) []Diagnostic {
+	return sr.ResolveWithSnippets(content).Diagnostics
+}
+
+// GetAllVariables returns all variables from content and any referenced snippets. The
+// variables are extracted after inserting the snippets, so a snippet used inside a loop
+// can refer to the loop's {{this}}.
+// Variables that every call site of their snippets supplies as an argument are marked as
+// arguments.
 func (sr *SnippetResolver) GetAllVariables(content string) []Variable {
 	snippetResult := sr.InsertSnippets(content)
-	contentVars := ExtractVariables(content)
-
-	// Combine and deduplicate variables
-	varMap := make(map[string]Variable)
+	variables := ExtractVariables(snippetResult.Content)
 
-	for _, v := range contentVars {
-		varMap[v.Name] = v
+	seen := make(map[string]bool, len(variables))
+	for _, variable := range variables {
+		seen[variable.Name] = true
 	}
-
-	for _, v := range snippetResult.Variables {
-		if existing, exists := varMap[v.Name]; !exists || (!existing.HasDefault && v.HasDefault) {
-			varMap[v.Name] = v
+	for _, variable := range snippetResult.Arguments {
+		if seen[variable.Name] {
+			continue
 		}
+		seen[variable.Name] = true
+		variable.Argument = true
+		variables = append(variables, variable)
 	}
 
-	// Convert back to slice
-	var result []Variable
-	for _, v := range varMap {
-		result = append(result, v)
-	}
-
-	return result
+	return variables
 }
 
 // GetVariableStatusWithSnippets returns variable status considering snippet variables
 func (sr *SnippetResolver) GetVariableStatusWithSnippets(content string) map[string]string {
 	allVars := sr.GetAllVariables(content)
+	resolver := NewVariableResolver(sr.variables)
 	status := make(map[string]string)
 
 	for _, variable := range allVars {
-		if _, exists := sr.variables[variable.Name]; exists {
-			status[variable.Name] = "provided"
-		} else if variable.HasDefault {
-			status[variable.Name] = "default"
-		} else {
-			status[variable.Name] = "missing"
-		}
+		status[variable.Name] = resolver.variableStatus(variable)
 	}
 
 	return status
 }
+
+// ValidateWithSnippets checks the provided values of the typed variables in content and
+// its snippets
+func (sr *SnippetResolver) ValidateWithSnippets(content string) []VariableError {
+	return validateVariables(sr.GetAllVariables(content), sr.variables)
+}
+
+// GetBlocksWithSnippets returns the blocks of content after inserting its snippets
+func (sr *SnippetResolver) GetBlocksWithSnippets(content string) []Block {
+	snippetResult := sr.InsertSnippets(content)
+	return NewVariableResolver(sr.variables).GetBlocks(snippetResult.Content)
+}
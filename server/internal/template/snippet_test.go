@@ -159,6 +159,10 @@ func TestSnippetResolver_GetAllVariables(t *testing.T) {
 			Title:   "signature",
 			Content: "Best regards,\n{{author}}",
 		},
+		{
+			Title:   "item",
+			Content: "- {{this}} ({{unit:pcs}})",
+		},
 	}
 
 	resolver := NewSnippetResolver(snippets, map[string]string{})
@@ -188,6 +192,11 @@ func TestSnippetResolver_GetAllVariables(t *testing.T) {
 			content:  "{{intro}} @greeting @signature",
 			expected: []string{"intro", "name", "author"},
 		},
+		{
+			name:     "snippet inside a loop",
+			content:  "{{#each items}}@item\n{{/each}}",
+			expected: []string{"items", "unit"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -254,3 +263,228 @@ func TestSnippetResolver_GetVariableStatusWithSnippets(t *testing.T) {
 		t.Errorf("GetVariableStatusWithSnippets() = %v, want %v", status, expected)
 	}
 }
+
+func TestSnippetResolver_Arguments(t *testing.T) {
+	snippets := []*models.Snippet{
+		{
+			Title:   "greeting",
+			Content: "{{#if formal}}Dear{{else}}Hi{{/if}} {{name | upper}}, from {{author}}",
+		},
+		{
+			Title:   "item",
+			Content: "- {{label}}: {{count:int()}}",
+		},
+		{
+			Title:   "card",
+			Content: "@{item label=Total}",
+		},
+		{
+			Title:   "my greeting",
+			Content: "Hello",
+		},
+	}
+
+	tests := []struct {
+		name             string
+		content          string
+		variables        map[string]string
+		expectedContent  string
+		expectedWarnings int
+	}{
+		{
+			name:            "arguments are scoped to each inclusion",
+			content:         "@{greeting name=\"Alice\" formal=true}\n@{greeting name='Bob'}",
+			variables:       map[string]string{"author": "Eve", "name": "Zed"},
+			expectedContent: "Dear ALICE, from Eve\nHi BOB, from Eve",
+		},
+		{
+			name:            "global variables fill the rest",
+			content:         "@{greeting formal=yes}",
+			variables:       map[string]string{"author": "Eve", "name": "Zed"},
+			expectedContent: "Dear ZED, from Eve",
+		},
+		{
+			name:            "arguments reach nested snippets",
+			content:         "@{card count=3}",
+			variables:       map[string]string{},
+			expectedContent: "- Total: 3",
+		},
+		{
+			name:             "inner arguments win",
+			content:          "@{card label=Sum count=3}",
+			variables:        map[string]string{},
+			expectedContent:  "- Total: 3",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "unknown argument",
+			content:          "@{item label=Apples count=2 colour=red}",
+			variables:        map[string]string{},
+			expectedContent:  "- Apples: 2",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "invalid argument value",
+			content:          "@{item label=Apples count=many}",
+			variables:        map[string]string{},
+			expectedContent:  "- Apples: many",
+			expectedWarnings: 1,
+		},
+		{
+			name:            "name with spaces and no arguments",
+			content:         "@{my greeting}",
+			variables:       map[string]string{},
+			expectedContent: "Hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewSnippetResolver(snippets, tt.variables)
+			result := resolver.ResolveWithSnippets(tt.content)
+
+			if result.Content != tt.expectedContent {
+				t.Errorf("ResolveWithSnippets() content = %q, want %q", result.Content, tt.expectedContent)
+			}
+
+			if len(result.Warnings) != tt.expectedWarnings {
+				t.Errorf("ResolveWithSnippets() warnings = %v, want %v warnings", result.Warnings, tt.expectedWarnings)
+			}
+		})
+	}
+}
+
+func TestSnippetResolver_GetAllVariablesWithArguments(t *testing.T) {
+	snippets := []*models.Snippet{
+		{
+			Title:   "greeting",
+			Content: "Hi {{name}} from {{author}}",
+		},
+		{
+			Title:   "item",
+			Content: "- {{label}}: {{count:int()}}",
+		},
+	}
+
+	resolver := NewSnippetResolver(snippets, map[string]string{})
+	content := "@{greeting name=Alice} @{item label=A count=1} @{item count=2}"
+
+	status := resolver.GetVariableStatusWithSnippets(content)
+	expected := map[string]string{
+		"author": "missing",
+		"name":   "argument",
+		"label":  "missing",
+		"count":  "argument",
+	}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("GetVariableStatusWithSnippets() = %v, want %v", status, expected)
+	}
+
+	for _, v := range resolver.GetAllVariables(content) {
+		if v.Name == "count" && (v.Type == nil || v.Type.Name != TypeInt) {
+			t.Errorf("Expected the argument count to keep its type, got %+v", v)
+		}
+	}
+}
+
+func TestSnippetResolver_Escapes(t *testing.T) {
+	snippets := []*models.Snippet{
+		{
+			Title:   "example",
+			Content: "EXAMPLE",
+		},
+		{
+			Title:   "handlebars",
+			Content: "Write \\{{{{name}}}} for {{subject}}, and @@mention people",
+		},
+		{
+			Title:   "jinja",
+			Content: "{{raw}}{% for x in {{items}} %}@example{% endfor %}{{/raw}} in {{lang}}",
+		},
+	}
+
+	tests := []struct {
+		name             string
+		content          string
+		variables        map[string]string
+		expectedInserted string
+		expectedContent  string
+		expectedWarnings int
+	}{
+		{
+			name:             "email address",
+			content:          "Mail user@example.com or @example",
+			expectedInserted: "Mail user@example.com or EXAMPLE",
+			expectedContent:  "Mail user@example.com or EXAMPLE",
+		},
+		{
+			name:             "escaped at",
+			content:          "Follow @@example on @@@example",
+			expectedInserted: "Follow @@example on @@EXAMPLE",
+			expectedContent:  "Follow @example on @EXAMPLE",
+		},
+		{
+			name:             "escaped braces",
+			content:          "Use \\{{name}} for {{name}}",
+			variables:        map[string]string{"name": "Alice"},
+			expectedInserted: "Use \\{{name}} for {{name}}",
+			expectedContent:  "Use {{name}} for Alice",
+		},
+		{
+			name:             "raw code sample",
+			content:          "Example:\n{{raw}}\nfunction hi() { return `{{name}}` + user@example; }\n@example {{#if x}}\n{{/raw}}\nDone {{name}}",
+			variables:        map[string]string{"name": "Alice"},
+			expectedInserted: "Example:\n{{raw}}\nfunction hi() { return `{{name}}` + user@example; }\n@example {{#if x}}\n{{/raw}}\nDone {{name}}",
+			expectedContent:  "Example:\nfunction hi() { return `{{name}}` + user@example; }\n@example {{#if x}}\nDone Alice",
+		},
+		{
+			name:             "nested braces",
+			content:          "{{raw}}{{{{name}}}}{{/raw}} \\{{\\{{name}}}}",
+			variables:        map[string]string{"name": "Alice"},
+			expectedInserted: "{{raw}}{{{{name}}}}{{/raw}} \\{{\\{{name}}}}",
+			expectedContent:  "{{{{name}}}} {{{{name}}}}",
+		},
+		{
+			name:             "escapes in snippets",
+			content:          "@handlebars",
+			variables:        map[string]string{"subject": "greetings"},
+			expectedInserted: "Write \\{{{{name}}}} for {{subject}}, and @@mention people",
+			expectedContent:  "Write {{{{name}}}} for greetings, and @mention people",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "escapes in snippets with arguments",
+			content:          "@{jinja items=rows lang=Jinja}",
+			expectedInserted: "{{raw}}{% for x in {{items}} %}@example{% endfor %}{{/raw}} in Jinja",
+			expectedContent:  "{% for x in {{items}} %}@example{% endfor %} in Jinja",
+			expectedWarnings: 1,
+		},
+		{
+			name:             "unclosed raw is a variable",
+			content:          "{{raw}} @example",
+			variables:        map[string]string{"raw": "R"},
+			expectedInserted: "{{raw}} EXAMPLE",
+			expectedContent:  "R EXAMPLE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewSnippetResolver(snippets, tt.variables)
+
+			inserted := resolver.InsertSnippets(tt.content)
+			if inserted.Content != tt.expectedInserted {
+				t.Errorf("InsertSnippets() content = %q, want %q", inserted.Content, tt.expectedInserted)
+			}
+
+			result := resolver.ResolveWithSnippets(tt.content)
+			if result.Content != tt.expectedContent {
+				t.Errorf("ResolveWithSnippets() content = %q, want %q", result.Content, tt.expectedContent)
+			}
+
+			if len(result.Warnings) != tt.expectedWarnings {
+				t.Errorf("ResolveWithSnippets() warnings = %v, want %v warnings", result.Warnings, tt.expectedWarnings)
+			}
+		})
+	}
+}
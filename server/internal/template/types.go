@@ -0,0 +1,197 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Variable types
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeNumber = "number"
+	TypeBool   = "bool"
+	TypeEnum   = "enum"
+	TypeList   = "list"
+)
+
+// Type constrains the values of a variable, as declared in {{count:int=3}},
+// {{count:int(1..10)}}, {{tone:enum(formal|casual)}} or {{items:list()}}
+type Type struct {
+	Name    string
+	Options []string // The allowed values of an enum
+	Min     *float64 // The bounds of an int or number, if given
+	Max     *float64
+}
+
+// typeRegex matches the part of a placeholder after the colon that declares a type, with
+// optional arguments and default value. Anything else after the colon is a plain default,
+// and so is a type name with neither, which the parser leaves alone.
+var typeRegex = regexp.MustCompile(`^\s*(string|int|number|bool|enum|list)\s*(?:\(([^)]*)\))?\s*(?:=(.*))?$`)
+
+// parseType parses the type declaration in spec. ok is false when spec is a plain default
+// value, and err is set when spec declares a type with invalid arguments or default value.
+func parseType(spec string) (t *Type, defaultValue string, hasDefault bool, ok bool, err error) {
+	match := typeRegex.FindStringSubmatch(spec)
+	if match == nil {
+		return nil, "", false, false, nil
+	}
+
+	t = &Type{Name: match[1]}
+	args := strings.TrimSpace(match[2])
+	switch t.Name {
+	case TypeEnum:
+		for _, option := range strings.Split(args, "|") {
+			if option = strings.TrimSpace(option); option != "" {
+				t.Options = append(t.Options, option)
+			}
+		}
+		if len(t.Options) == 0 {
+			return nil, "", false, true, fmt.Errorf("enum needs at least one option, as in enum(a|b)")
+		}
+	case TypeInt, TypeNumber:
+		if args != "" {
+			if t.Min, t.Max, err = parseRange(args); err != nil {
+				return nil, "", false, true, err
+			}
+		}
+	default:
+		if args != "" {
+			return nil, "", false, true, fmt.Errorf("%s takes no arguments", t.Name)
+		}
+	}
+
+	if strings.Contains(match[0], "=") {
+		defaultValue, hasDefault = strings.TrimSpace(match[3]), true
+		if err := t.Validate(defaultValue); err != nil {
+			return nil, "", false, true, fmt.Errorf("default value: %w", err)
+		}
+	}
+	return t, defaultValue, hasDefault, true, nil
+}
+
+// parseRange parses the bounds of a number, written as min..max, min.. or ..max
+func parseRange(args string) (*float64, *float64, error) {
+	low, high, found := strings.Cut(args, "..")
+	if !found {
+		return nil, nil, fmt.Errorf("invalid range '%s', use min..max", args)
+	}
+
+	bound := func(s string) (*float64, error) {
+		if s = strings.TrimSpace(s); s == "" {
+			return nil, nil
+		}
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bound '%s'", s)
+		}
+		return &value, nil
+	}
+
+	min, err := bound(low)
+	if err != nil {
+		return nil, nil, err
+	}
+	max, err := bound(high)
+	if err != nil {
+		return nil, nil, err
+	}
+	if min != nil && max != nil && *min > *max {
+		return nil, nil, fmt.Errorf("invalid range '%s', min is greater than max", args)
+	}
+	return min, max, nil
+}
+
+// Validate checks that value is a valid value of the type
+func (t *Type) Validate(value string) error {
+	trimmed := strings.TrimSpace(value)
+	switch t.Name {
+	case TypeInt, TypeNumber:
+		var number float64
+		if t.Name == TypeInt {
+			n, err := strconv.ParseInt(trimmed, 10, 64)
+			if err != nil {
+				return fmt.Errorf("'%s' is not a whole number", value)
+			}
+			number = float64(n)
+		} else {
+			n, err := strconv.ParseFloat(trimmed, 64)
+			if err != nil {
+				return fmt.Errorf("'%s' is not a number", value)
+			}
+			number = n
+		}
+		if t.Min != nil && number < *t.Min {
+			return fmt.Errorf("%s is less than %s", trimmed, formatBound(*t.Min))
+		}
+		if t.Max != nil && number > *t.Max {
+			return fmt.Errorf("%s is greater than %s", trimmed, formatBound(*t.Max))
+		}
+	case TypeBool:
+		if _, err := strconv.ParseBool(trimmed); err != nil {
+			return fmt.Errorf("'%s' is not true or false", value)
+		}
+	case TypeEnum:
+		for _, option := range t.Options {
+			if value == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("'%s' is not one of %s", value, strings.Join(t.Options, ", "))
+	case TypeList:
+		if strings.HasPrefix(trimmed, "[") && !json.Valid([]byte(trimmed)) {
+			return fmt.Errorf("list is not a valid JSON array")
+		}
+	}
+	return nil
+}
+
+// String returns the type as it is declared, e.g. "enum(formal|casual)"
+func (t *Type) String() string {
+	switch {
+	case t.Name == TypeEnum:
+		return t.Name + "(" + strings.Join(t.Options, "|") + ")"
+	case t.Min != nil || t.Max != nil:
+		var low, high string
+		if t.Min != nil {
+			low = formatBound(*t.Min)
+		}
+		if t.Max != nil {
+			high = formatBound(*t.Max)
+		}
+		return t.Name + "(" + low + ".." + high + ")"
+	}
+	return t.Name
+}
+
+func formatBound(bound float64) string {
+	return strconv.FormatFloat(bound, 'f', -1, 64)
+}
+
+// VariableError reports a value that is not valid for the type of its variable
+type VariableError struct {
+	Variable string
+	Message  string
+}
+
+func (e *VariableError) Error() string {
+	return fmt.Sprintf("variable '%s': %s", e.Variable, e.Message)
+}
+
+// validateVariables checks the provided values of variables against their types
+func validateVariables(variables []Variable, values map[string]string) []VariableError {
+	var errors []VariableError
+	for _, variable := range variables {
+		value, exists := values[variable.Name]
+		if !exists || variable.Type == nil || variable.Argument {
+			continue
+		}
+		if err := variable.Type.Validate(value); err != nil {
+			errors = append(errors, VariableError{Variable: variable.Name, Message: err.Error()})
+		}
+	}
+	return errors
+}
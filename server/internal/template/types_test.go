@@ -0,0 +1,153 @@
+package template
+
+import (
+	"reflect"
+	"testing"
+)
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func TestExtractVariables_Types(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []Variable
+	}{
+		{
+			name:    "int with default",
+			content: "{{count:int=3}}",
+			expected: []Variable{
+				{Name: "count", DefaultValue: "3", HasDefault: true, Type: &Type{Name: TypeInt}},
+			},
+		},
+		{
+			name:    "int with range",
+			content: "{{ count : int(1..10) }}",
+			expected: []Variable{
+				{Name: "count", Type: &Type{Name: TypeInt, Min: float(1), Max: float(10)}},
+			},
+		},
+		{
+			name:    "enum",
+			content: "{{tone:enum(formal|casual)=casual}}",
+			expected: []Variable{
+				{Name: "tone", DefaultValue: "casual", HasDefault: true, Type: &Type{Name: TypeEnum, Options: []string{"formal", "casual"}}},
+			},
+		},
+		{
+			name:    "list used in a loop",
+			content: "{{items:list()}}{{#each items}}{{this}}{{/each}}",
+			expected: []Variable{
+				{Name: "items", List: true, Type: &Type{Name: TypeList}},
+			},
+		},
+		{
+			name:    "plain default that is not a type",
+			content: "{{greeting:integer}} {{mood:bool or not}}",
+			expected: []Variable{
+				{Name: "greeting", DefaultValue: "integer", HasDefault: true},
+				{Name: "mood", DefaultValue: "bool or not", HasDefault: true},
+			},
+		},
+		{
+			name:    "bare type name is a plain default",
+			content: "{{format:string}} {{mode:list}} {{flag:bool}} {{kind:enum}} {{count: int }}",
+			expected: []Variable{
+				{Name: "format", DefaultValue: "string", HasDefault: true},
+				{Name: "mode", DefaultValue: "list", HasDefault: true},
+				{Name: "flag", DefaultValue: "bool", HasDefault: true},
+				{Name: "kind", DefaultValue: "enum", HasDefault: true},
+				{Name: "count", DefaultValue: " int ", HasDefault: true},
+			},
+		},
+		{
+			name:    "first declared type wins",
+			content: "{{count}} {{count:int()}} {{count:number=2}}",
+			expected: []Variable{
+				{Name: "count", DefaultValue: "2", HasDefault: true, Type: &Type{Name: TypeInt}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ExtractVariables(tt.content)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("ExtractVariables() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParse_InvalidTypes(t *testing.T) {
+	for _, content := range []string{
+		"{{tone:enum()}}",
+		"{{count:int(10..1)}}",
+		"{{count:int(a..b)}}",
+		"{{count:int=three}}",
+		"{{flag:bool(x)}}",
+	} {
+		tree := Parse(content)
+		if len(tree.Warnings) != 1 {
+			t.Errorf("Parse(%q) warnings = %v, want 1 warning", content, tree.Warnings)
+		}
+		if v := ExtractVariables(content); len(v) != 1 || v[0].Type != nil || v[0].HasDefault {
+			t.Errorf("ExtractVariables(%q) = %+v, want an untyped variable without default", content, v)
+		}
+	}
+}
+
+func TestType_Validate(t *testing.T) {
+	tests := []struct {
+		declaration string
+		value       string
+		valid       bool
+	}{
+		{"int", "42", true},
+		{"int", " -7 ", true},
+		{"int", "4.2", false},
+		{"int", "many", false},
+		{"int(1..10)", "10", true},
+		{"int(1..10)", "11", false},
+		{"int(1..)", "0", false},
+		{"number", "4.2", true},
+		{"number(..1.5)", "1.6", false},
+		{"bool", "true", true},
+		{"bool", "0", true},
+		{"bool", "maybe", false},
+		{"enum(formal|casual)", "casual", true},
+		{"enum(formal|casual)", "Casual", false},
+		{"list", "a\nb", true},
+		{"list", `["a", "b"]`, true},
+		{"list", `["a", "b"`, false},
+		{"string", "anything", true},
+	}
+
+	for _, tt := range tests {
+		typ, _, _, ok, err := parseType(tt.declaration)
+		if !ok || err != nil {
+			t.Fatalf("parseType(%q) = %v, %v", tt.declaration, ok, err)
+		}
+		if got := typ.String(); got != tt.declaration {
+			t.Errorf("String() = %q, want %q", got, tt.declaration)
+		}
+		if err := typ.Validate(tt.value); (err == nil) != tt.valid {
+			t.Errorf("%s.Validate(%q) = %v, want valid %v", tt.declaration, tt.value, err, tt.valid)
+		}
+	}
+}
+
+func TestSnippetResolver_ValidateWithSnippets(t *testing.T) {
+	resolver := NewSnippetResolver(nil, map[string]string{
+		"count": "many",
+		"tone":  "formal",
+		"name":  "Alice",
+	})
+
+	errors := resolver.ValidateWithSnippets("{{name}} {{count:int=3}} {{tone:enum(formal|casual)}} {{size:int()}}")
+	if len(errors) != 1 || errors[0].Variable != "count" {
+		t.Errorf("ValidateWithSnippets() = %v, want one error for count", errors)
+	}
+}